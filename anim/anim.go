@@ -0,0 +1,106 @@
+// Package anim assembles frames, such as the FormatFrame output of AnimateDiff or the images of
+// a seed travel, into an animated GIF, APNG or WebP file.
+package anim
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Formats of Assemble.
+const (
+	FormatGIF  = sdcli.FormatGIF
+	FormatAPNG = "apng"
+	FormatWEBP = sdcli.FormatWEBP
+)
+
+// DefaultDelay is the frame delay when Options sets none.
+const DefaultDelay = 100 * time.Millisecond
+
+// Options of an animation, the zero value loops forever at DefaultDelay.
+type Options struct {
+	// Delay is the display time of every frame.
+	Delay time.Duration
+	// Delays overrides Delay per frame, frames past its end use Delay.
+	Delays []time.Duration
+	// Loops is how many times the animation plays, 0 means forever.
+	Loops int
+}
+
+func (o *Options) delay(i int) time.Duration {
+	if i < len(o.Delays) && o.Delays[i] > 0 {
+		return o.Delays[i]
+	}
+	if o.Delay > 0 {
+		return o.Delay
+	}
+	return DefaultDelay
+}
+
+// Assemble writes the encoded frames, e.g. the RawImages of a response, as an animation of
+// format. GIF and APNG decode and re-encode the frames, while WebP requires WebP frames and
+// muxes them as they are since there is no WebP encoder.
+func Assemble(w io.Writer, format string, frames [][]byte, opt Options) error {
+	if format == FormatWEBP {
+		return WebP(w, frames, opt)
+	}
+
+	imgs := make([]image.Image, len(frames))
+	for i, data := range frames {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to decode frame %d: %w", i, err)
+		}
+		imgs[i] = img
+	}
+
+	switch format {
+	case FormatGIF:
+		return GIF(w, imgs, opt)
+	case FormatAPNG:
+		return APNG(w, imgs, opt)
+	}
+	return fmt.Errorf("unsupported animation format %q", format)
+}
+
+// GIF writes the frames as an animated GIF, quantized to the Plan 9 palette with dithering.
+func GIF(w io.Writer, frames []image.Image, opt Options) error {
+	if len(frames) == 0 {
+		return errors.New("no frames")
+	}
+
+	g := &gif.GIF{LoopCount: gifLoopCount(opt.Loops)}
+	for i, img := range frames {
+		b := img.Bounds()
+		p := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette.Plan9)
+		draw.FloydSteinberg.Draw(p, p.Bounds(), img, b.Min)
+		g.Image = append(g.Image, p)
+		// GIF delays are in hundredths of a second.
+		g.Delay = append(g.Delay, int(opt.delay(i)/(10*time.Millisecond)))
+	}
+
+	if err := gif.EncodeAll(w, g); err != nil {
+		return fmt.Errorf("failed to encode gif: %w", err)
+	}
+	return nil
+}
+
+// gifLoopCount converts the number of plays to the GIF repeat count, where -1 plays once.
+func gifLoopCount(loops int) int {
+	if loops <= 0 {
+		return 0
+	}
+	if loops == 1 {
+		return -1
+	}
+	return loops - 1
+}
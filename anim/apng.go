@@ -0,0 +1,127 @@
+package anim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"time"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// APNG writes the frames as an animated PNG, the frames must have the size of the first one.
+func APNG(w io.Writer, frames []image.Image, opt Options) error {
+	if len(frames) == 0 {
+		return errors.New("no frames")
+	}
+	size := frames[0].Bounds().Size()
+
+	buf := &bytes.Buffer{}
+	buf.Write(pngSignature)
+	seq := uint32(0)
+	for i, img := range frames {
+		b := img.Bounds()
+		if b.Size() != size {
+			return fmt.Errorf("frame %d size %v does not match %v", i, b.Size(), size)
+		}
+		// Encode every frame as NRGBA so they share the IHDR of the first one.
+		nrgba := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+		draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+		encoded := &bytes.Buffer{}
+		if err := png.Encode(encoded, nrgba); err != nil {
+			return fmt.Errorf("failed to encode frame %d: %w", i, err)
+		}
+		chunks, err := pngChunks(encoded.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to encode frame %d: %w", i, err)
+		}
+
+		if i == 0 {
+			for _, c := range chunks {
+				if c.typ == "IHDR" {
+					writePNGChunk(buf, "IHDR", c.data)
+				}
+			}
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl, uint32(len(frames)))
+			binary.BigEndian.PutUint32(actl[4:], uint32(opt.Loops))
+			writePNGChunk(buf, "acTL", actl)
+		}
+
+		writePNGChunk(buf, "fcTL", frameControl(seq, size, opt.delay(i)))
+		seq++
+		for _, c := range chunks {
+			if c.typ != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writePNGChunk(buf, "IDAT", c.data)
+				continue
+			}
+			fdat := make([]byte, 4, 4+len(c.data))
+			binary.BigEndian.PutUint32(fdat, seq)
+			writePNGChunk(buf, "fdAT", append(fdat, c.data...))
+			seq++
+		}
+	}
+	writePNGChunk(buf, "IEND", nil)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// frameControl is the fcTL chunk of a full canvas frame, with the delay in milliseconds.
+func frameControl(seq uint32, size image.Point, delay time.Duration) []byte {
+	ms := delay.Milliseconds()
+	if ms > 0xffff {
+		ms = 0xffff
+	}
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl, seq)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(size.X))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(size.Y))
+	// x and y offsets are 0.
+	binary.BigEndian.PutUint16(fctl[20:], uint16(ms))
+	binary.BigEndian.PutUint16(fctl[22:], 1000)
+	// Dispose op none and blend op source.
+	return fctl
+}
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+func pngChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("invalid png: bad signature")
+	}
+
+	var chunks []pngChunk
+	p := len(pngSignature)
+	for p+12 <= len(data) {
+		n := int(binary.BigEndian.Uint32(data[p:]))
+		if p+12+n > len(data) {
+			return nil, errors.New("invalid png: truncated chunk")
+		}
+		chunks = append(chunks, pngChunk{typ: string(data[p+4 : p+8]), data: data[p+8 : p+8+n]})
+		p += 12 + n
+	}
+	return chunks, nil
+}
+
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	_ = binary.Write(w, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	w.WriteString(typ)
+	w.Write(data)
+	_ = binary.Write(w, binary.BigEndian, crc.Sum32())
+}
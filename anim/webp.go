@@ -0,0 +1,168 @@
+package anim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WebP VP8X flags.
+const (
+	webpFlagAnimation = 0x02
+	webpFlagAlpha     = 0x10
+)
+
+// WebP muxes WebP encoded frames into an animated WebP without re-encoding them, the canvas is
+// the size of the largest frame and frames are drawn at its top left corner.
+func WebP(w io.Writer, frames [][]byte, opt Options) error {
+	if len(frames) == 0 {
+		return errors.New("no frames")
+	}
+
+	var (
+		anmf          [][]byte
+		width, height int
+		alpha         bool
+	)
+	for i, data := range frames {
+		chunks, err := webpChunks(data)
+		if err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+		fw, fh, err := webpSize(chunks)
+		if err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+		if fw > width {
+			width = fw
+		}
+		if fh > height {
+			height = fh
+		}
+
+		body := &bytes.Buffer{}
+		header := make([]byte, 16)
+		// The x and y offsets are 0.
+		putUint24(header[6:], uint32(fw-1))
+		putUint24(header[9:], uint32(fh-1))
+		putUint24(header[12:], uint32(opt.delay(i).Milliseconds()))
+		// Do not blend with the previous frame.
+		header[15] = 0x02
+		body.Write(header)
+		for _, c := range chunks {
+			switch c.typ {
+			case "ALPH":
+				alpha = true
+				writeWebPChunk(body, c.typ, c.data)
+			case "VP8 ", "VP8L":
+				// VP8L carries its own alpha, the flag is a hint for decoders.
+				alpha = alpha || c.typ == "VP8L"
+				writeWebPChunk(body, c.typ, c.data)
+			}
+		}
+		anmf = append(anmf, body.Bytes())
+	}
+
+	body := &bytes.Buffer{}
+	body.WriteString("WEBP")
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpFlagAnimation
+	if alpha {
+		vp8x[0] |= webpFlagAlpha
+	}
+	putUint24(vp8x[4:], uint32(width-1))
+	putUint24(vp8x[7:], uint32(height-1))
+	writeWebPChunk(body, "VP8X", vp8x)
+
+	loops := opt.Loops
+	if loops > 0xffff {
+		loops = 0
+	}
+	anim := make([]byte, 6)
+	// The background color is transparent black.
+	binary.LittleEndian.PutUint16(anim[4:], uint16(loops))
+	writeWebPChunk(body, "ANIM", anim)
+	for _, f := range anmf {
+		writeWebPChunk(body, "ANMF", f)
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, body.Len()+8))
+	out.WriteString("RIFF")
+	_ = binary.Write(out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+type webpChunk struct {
+	typ  string
+	data []byte
+}
+
+func webpChunks(data []byte) ([]webpChunk, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("invalid webp: bad header")
+	}
+
+	var chunks []webpChunk
+	p := 12
+	for p+8 <= len(data) {
+		n := int(binary.LittleEndian.Uint32(data[p+4:]))
+		if p+8+n > len(data) {
+			return nil, errors.New("invalid webp: truncated chunk")
+		}
+		chunks = append(chunks, webpChunk{typ: string(data[p : p+4]), data: data[p+8 : p+8+n]})
+		p += 8 + n + n%2
+	}
+	return chunks, nil
+}
+
+// webpSize reads the frame size from the VP8X chunk or the VP8 or VP8L bitstream.
+func webpSize(chunks []webpChunk) (int, int, error) {
+	for _, c := range chunks {
+		switch c.typ {
+		case "VP8X":
+			if len(c.data) < 10 {
+				return 0, 0, errors.New("invalid webp: short VP8X chunk")
+			}
+			if c.data[0]&webpFlagAnimation != 0 {
+				return 0, 0, errors.New("webp frame is already animated")
+			}
+			return int(uint24(c.data[4:])) + 1, int(uint24(c.data[7:])) + 1, nil
+		case "VP8 ":
+			if len(c.data) < 10 {
+				return 0, 0, errors.New("invalid webp: short VP8 chunk")
+			}
+			w := int(binary.LittleEndian.Uint16(c.data[6:]) & 0x3fff)
+			h := int(binary.LittleEndian.Uint16(c.data[8:]) & 0x3fff)
+			return w, h, nil
+		case "VP8L":
+			if len(c.data) < 5 || c.data[0] != 0x2f {
+				return 0, 0, errors.New("invalid webp: bad VP8L chunk")
+			}
+			bits := binary.LittleEndian.Uint32(c.data[1:])
+			return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+		}
+	}
+	return 0, 0, errors.New("invalid webp: missing image data")
+}
+
+func writeWebPChunk(w *bytes.Buffer, typ string, data []byte) {
+	w.WriteString(typ)
+	_ = binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+	if len(data)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
@@ -0,0 +1,32 @@
+package sdcli
+
+import "context"
+
+// API is the set of operations offered by Client, depend on it instead of *Client
+// to swap in mocks, fakes or other implementations.
+type API interface {
+	Txt2Img(ctx context.Context, opt Txt2ImageOption, opts ...RequestOption) (*Txt2ImageResponse, error)
+	Img2Img(ctx context.Context, opt Img2ImgOption, opts ...RequestOption) (*Img2ImgResponse, error)
+	ExtraSingleImg(ctx context.Context, opt ExtraSingleImgOption, opts ...RequestOption) (*ExtraSingleImgResponse, error)
+	GetProgress(ctx context.Context, skipCurrentImg bool) (*ProgressResponse, error)
+	Interrupt(ctx context.Context) error
+	Skip(ctx context.Context) error
+	GetOptions(ctx context.Context) (*OptionsResponse, error)
+	SetOptions(ctx context.Context, opts map[string]any) error
+	GetModels(ctx context.Context) ([]*ModelsResponse, error)
+	RefreshCheckpoints(ctx context.Context) error
+	GetSamplers(ctx context.Context) ([]*SamplersResponse, error)
+	GetSchedulers(ctx context.Context) ([]*SchedulersResponse, error)
+	GetUpscalers(ctx context.Context) ([]*UpscalersResponse, error)
+	GetVAEs(ctx context.Context) ([]*VAEsResponse, error)
+	GetSdModules(ctx context.Context) ([]*SdModulesResponse, error)
+	RefreshVAEs(ctx context.Context) error
+	GetScripts(ctx context.Context) (*ScriptsResponse, error)
+	GetLoras(ctx context.Context) ([]*LorasResponse, error)
+	GetHypernetworks(ctx context.Context) ([]*HypernetworksResponse, error)
+	GetEmbeddings(ctx context.Context) (*EmbeddingsResponse, error)
+	GetMemory(ctx context.Context) (*MemoryResponse, error)
+	Capabilities(ctx context.Context) (*Capabilities, error)
+}
+
+var _ API = (*Client)(nil)
@@ -0,0 +1,190 @@
+// Package batch runs many generations described by a YAML or JSON job file.
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/shallowclouds/go-sd-webui-cli/prompt"
+)
+
+const (
+	ModeTxt2Img = "txt2img"
+	ModeImg2Img = "img2img"
+)
+
+// DefaultOutput is the filename template used when neither the file nor the job sets one.
+const DefaultOutput = "{{.Name}}-{{.Image}}.png"
+
+// File describes a set of generations, Defaults are API parameters shared by all jobs
+// and overridden key by key by each job's Params.
+type File struct {
+	// Concurrency is the number of jobs run at the same time, 1 if unset.
+	Concurrency int `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
+	// Output is a text/template for output filenames, see OutputData for available fields.
+	Output   string         `json:"output,omitempty" yaml:"output,omitempty"`
+	Defaults map[string]any `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	Jobs     []*Job         `json:"jobs" yaml:"jobs"`
+
+	// BaseDir resolves relative image paths, set by Load to the directory of the file.
+	BaseDir string `json:"-" yaml:"-"`
+}
+
+// Job is a single generation.
+type Job struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Mode is either txt2img (default) or img2img.
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// InitImages and Mask are image file paths for img2img.
+	InitImages []string `json:"init_images,omitempty" yaml:"init_images,omitempty"`
+	Mask       string   `json:"mask,omitempty" yaml:"mask,omitempty"`
+	// Output overrides the file level output template.
+	Output string `json:"output,omitempty" yaml:"output,omitempty"`
+	// Params are API parameters such as prompt and steps, using the WebUI JSON names.
+	Params map[string]any `json:"params,omitempty" yaml:"params,omitempty"`
+	// Vars are substituted into the prompt and negative prompt templates, list values expand
+	// the job into one job per combination, see prompt.Combinations.
+	Vars map[string]any `json:"vars,omitempty" yaml:"vars,omitempty"`
+
+	vars map[string]any
+}
+
+// Load reads a job file, files ending with .yaml or .yml are parsed as YAML and anything else as JSON.
+func Load(name string) (*File, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job file: %w", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	f, err := Parse(data, ext == ".yaml" || ext == ".yml")
+	if err != nil {
+		return nil, err
+	}
+	f.BaseDir = filepath.Dir(name)
+
+	return f, nil
+}
+
+// Parse parses a job file from YAML or JSON data and validates it.
+func Parse(data []byte, isYAML bool) (*File, error) {
+	f := &File{}
+	if isYAML {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		// An empty document decodes to io.EOF, leave it to Validate.
+		if err := dec.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse job file: %w", err)
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(f); err != nil {
+			return nil, fmt.Errorf("failed to parse job file: %w", err)
+		}
+	}
+
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if err := f.Expand(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Validate checks the file for obvious mistakes and fills in job names.
+func (f *File) Validate() error {
+	if len(f.Jobs) == 0 {
+		return fmt.Errorf("job file has no jobs")
+	}
+
+	for i, job := range f.Jobs {
+		if job == nil {
+			return fmt.Errorf("job %d is empty", i)
+		}
+		if len(job.Name) == 0 {
+			job.Name = fmt.Sprintf("job-%03d", i)
+		}
+		switch job.Mode {
+		case "", ModeTxt2Img:
+		case ModeImg2Img:
+			if len(job.InitImages) == 0 {
+				return fmt.Errorf("job %s: img2img requires init_images", job.Name)
+			}
+		default:
+			return fmt.Errorf("job %s: unknown mode %q", job.Name, job.Mode)
+		}
+	}
+
+	return nil
+}
+
+// templatedParams are the params rendered as prompt templates when a job has vars.
+var templatedParams = []string{"prompt", "negative_prompt"}
+
+// Expand replaces every job having Vars with one job per combination of the vars,
+// with the prompt templates rendered. Expanded jobs are named <name>-<index>.
+func (f *File) Expand() error {
+	jobs := make([]*Job, 0, len(f.Jobs))
+	for _, job := range f.Jobs {
+		if len(job.Vars) == 0 {
+			jobs = append(jobs, job)
+			continue
+		}
+
+		params := f.params(job)
+		combos := prompt.Combinations(job.Vars)
+		for i, vars := range combos {
+			expanded := *job
+			expanded.Name = fmt.Sprintf("%s-%03d", job.Name, i)
+			expanded.Vars = nil
+			expanded.vars = vars
+			expanded.Params = make(map[string]any, len(job.Params)+len(templatedParams))
+			for k, v := range job.Params {
+				expanded.Params[k] = v
+			}
+
+			for _, key := range templatedParams {
+				text, ok := params[key].(string)
+				if !ok {
+					continue
+				}
+				tmpl, err := prompt.Parse(text)
+				if err != nil {
+					return fmt.Errorf("job %s: %w", job.Name, err)
+				}
+				if expanded.Params[key], err = tmpl.Render(vars); err != nil {
+					return fmt.Errorf("job %s: %w", job.Name, err)
+				}
+			}
+
+			jobs = append(jobs, &expanded)
+		}
+	}
+	f.Jobs = jobs
+
+	return nil
+}
+
+// params merges the file defaults with the job params, job params win.
+func (f *File) params(job *Job) map[string]any {
+	res := make(map[string]any, len(f.Defaults)+len(job.Params))
+	for k, v := range f.Defaults {
+		res[k] = v
+	}
+	for k, v := range job.Params {
+		res[k] = v
+	}
+
+	return res
+}
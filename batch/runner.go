@@ -0,0 +1,263 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+	"github.com/shallowclouds/go-sd-webui-cli/sink"
+)
+
+// Runner runs the jobs of a File against an API.
+type Runner struct {
+	Client sdcli.API
+	// Concurrency overrides the file concurrency when positive.
+	Concurrency int
+	// OutDir is prepended to the rendered output filenames.
+	OutDir string
+	// Sink stores the outputs under their rendered filenames, a sink.Dir of OutDir if nil.
+	Sink sink.Sink
+}
+
+// OutputData is passed to the output filename template.
+type OutputData struct {
+	Name string
+	Mode string
+	// Job is the index of the job in the file.
+	Job int
+	// Image is the index of the image in the job result.
+	Image int
+	// Seed is the seed of the image if the server reported it.
+	Seed int64
+	// Vars is the combination of template vars the job was expanded from.
+	Vars map[string]any
+	Time time.Time
+}
+
+// Report is the machine-readable outcome of a run.
+type Report struct {
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	Results   []*Result `json:"results"`
+}
+
+// Result is the outcome of a single job.
+type Result struct {
+	Name     string        `json:"name"`
+	Mode     string        `json:"mode"`
+	Files    []string      `json:"files,omitempty"`
+	Seeds    []int64       `json:"seeds,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Run runs all jobs and returns a report in job order, a failed job does not stop the others.
+// Jobs not started before ctx is done are reported as failed with the context error.
+func (r *Runner) Run(ctx context.Context, f *File) *Report {
+	concurrency := f.Concurrency
+	if r.Concurrency > 0 {
+		concurrency = r.Concurrency
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	report := &Report{
+		Started: time.Now(),
+		Results: make([]*Result, len(f.Jobs)),
+	}
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	for i, job := range f.Jobs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			report.Results[i] = &Result{Name: job.Name, Mode: modeOf(job), Error: ctx.Err().Error()}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, job *Job) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			report.Results[i] = r.runJob(ctx, f, i, job)
+		}(i, job)
+	}
+	wg.Wait()
+
+	for _, res := range report.Results {
+		if len(res.Error) == 0 {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+	}
+	report.Finished = time.Now()
+
+	return report
+}
+
+func (r *Runner) runJob(ctx context.Context, f *File, index int, job *Job) *Result {
+	started := time.Now()
+	res := &Result{Name: job.Name, Mode: modeOf(job)}
+
+	images, info, err := r.generate(ctx, f, job)
+	res.Duration = time.Since(started)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	gen, _ := sdcli.ParseInfo(info)
+	if gen != nil {
+		res.Seeds = gen.AllSeeds
+	}
+	tmpl, err := parseOutput(f, job)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	for i, data := range images {
+		out := OutputData{Name: job.Name, Mode: res.Mode, Job: index, Image: i, Vars: job.vars, Time: started}
+		if i < len(res.Seeds) {
+			out.Seed = res.Seeds[i]
+		}
+
+		meta := sink.Metadata{sink.MetaContentType: sink.ContentType(sdcli.DetectFormat(data))}
+		if gen != nil && len(gen.Infotext(i)) != 0 {
+			if withParams, err := sdcli.SetParameters(data, gen.Infotext(i)); err == nil {
+				data = withParams
+			}
+			meta[sink.MetaParameters] = gen.Infotext(i)
+		}
+		if i < len(res.Seeds) {
+			meta[sink.MetaSeed] = strconv.FormatInt(res.Seeds[i], 10)
+		}
+
+		name, err := r.write(ctx, tmpl, out, data, meta)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		res.Files = append(res.Files, name)
+	}
+
+	return res
+}
+
+func (r *Runner) generate(ctx context.Context, f *File, job *Job) ([][]byte, string, error) {
+	params, err := json.Marshal(f.params(job))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode params: %w", err)
+	}
+
+	if modeOf(job) == ModeTxt2Img {
+		var opt sdcli.Txt2ImageOption
+		if err := json.Unmarshal(params, &opt); err != nil {
+			return nil, "", fmt.Errorf("invalid params: %w", err)
+		}
+		res, err := r.Client.Txt2Img(ctx, opt)
+		if err != nil {
+			return nil, "", err
+		}
+		return res.RawImages, res.Info, nil
+	}
+
+	var opt sdcli.Img2ImgOption
+	if err := json.Unmarshal(params, &opt); err != nil {
+		return nil, "", fmt.Errorf("invalid params: %w", err)
+	}
+	opt.InitImages = opt.InitImages[:0]
+	for _, name := range job.InitImages {
+		img, err := readImage(f.BaseDir, name)
+		if err != nil {
+			return nil, "", err
+		}
+		opt.InitImages = append(opt.InitImages, img)
+	}
+	if len(job.Mask) != 0 {
+		if opt.Mask, err = readImage(f.BaseDir, job.Mask); err != nil {
+			return nil, "", err
+		}
+	}
+	res, err := r.Client.Img2Img(ctx, opt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return res.RawImages, res.Info, nil
+}
+
+// write stores an output and returns its file path, or its name for sinks other than sink.Dir.
+func (r *Runner) write(ctx context.Context, tmpl *template.Template, data OutputData, img []byte, meta sink.Metadata) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("failed to render output filename: %w", err)
+	}
+	name := filepath.ToSlash(buf.String())
+
+	s := r.Sink
+	if s == nil {
+		s = sink.NewDir(r.OutDir)
+	}
+	if err := s.Put(ctx, name, bytes.NewReader(img), meta); err != nil {
+		return "", err
+	}
+	if d, ok := s.(*sink.Dir); ok {
+		return d.Path(name)
+	}
+
+	return name, nil
+}
+
+func parseOutput(f *File, job *Job) (*template.Template, error) {
+	output := job.Output
+	if len(output) == 0 {
+		output = f.Output
+	}
+	if len(output) == 0 {
+		output = DefaultOutput
+	}
+
+	tmpl, err := template.New("output").Parse(output)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+func readImage(baseDir, name string) (string, error) {
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(baseDir, name)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+
+	return sdcli.ImgBytes2Base64(data), nil
+}
+
+func modeOf(job *Job) string {
+	if len(job.Mode) == 0 {
+		return ModeTxt2Img
+	}
+	return job.Mode
+}
@@ -0,0 +1,394 @@
+package sdcli
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Defaults set by the option builders, matching the WebUI defaults.
+const (
+	DefaultSteps             = 20
+	DefaultCfgScale          = 7
+	DefaultSize              = 512
+	DefaultSampler           = SamplerEulerA
+	DefaultDenoisingStrength = 0.75
+)
+
+// builderErrors collects the errors of the builder calls, reported by Build.
+type builderErrors struct {
+	errs []error
+}
+
+func (b *builderErrors) fail(format string, args ...any) {
+	b.errs = append(b.errs, fmt.Errorf(format, args...))
+}
+
+func (b *builderErrors) build(validate func() error) error {
+	errs := b.errs
+	if err := validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Txt2ImgBuilder builds a Txt2ImageOption with defaults set, catching settings that exclude
+// each other. Errors are reported by Build.
+type Txt2ImgBuilder struct {
+	builderErrors
+	opt       Txt2ImageOption
+	hrScale   bool
+	hrResize  bool
+	hasScript bool
+}
+
+// NewTxt2Img returns a builder with the default size, steps, cfg scale and sampler and a
+// random seed.
+func NewTxt2Img() *Txt2ImgBuilder {
+	return &Txt2ImgBuilder{opt: Txt2ImageOption{
+		Steps:       DefaultSteps,
+		CfgScale:    DefaultCfgScale,
+		Width:       DefaultSize,
+		Height:      DefaultSize,
+		SamplerName: DefaultSampler,
+		Seed:        SeedRandom,
+		BatchSize:   1,
+		NIter:       1,
+	}}
+}
+
+func (b *Txt2ImgBuilder) Prompt(prompt string) *Txt2ImgBuilder {
+	b.opt.Prompt = prompt
+	return b
+}
+
+func (b *Txt2ImgBuilder) NegativePrompt(prompt string) *Txt2ImgBuilder {
+	b.opt.NegativePrompt = prompt
+	return b
+}
+
+func (b *Txt2ImgBuilder) Styles(styles ...string) *Txt2ImgBuilder {
+	b.opt.Styles = append(b.opt.Styles, styles...)
+	return b
+}
+
+func (b *Txt2ImgBuilder) Size(width, height int) *Txt2ImgBuilder {
+	b.opt.Width, b.opt.Height = width, height
+	return b
+}
+
+func (b *Txt2ImgBuilder) Steps(steps int) *Txt2ImgBuilder {
+	b.opt.Steps = steps
+	return b
+}
+
+func (b *Txt2ImgBuilder) CfgScale(cfg float32) *Txt2ImgBuilder {
+	b.opt.CfgScale = cfg
+	return b
+}
+
+func (b *Txt2ImgBuilder) Sampler(name string) *Txt2ImgBuilder {
+	b.opt.SamplerName = name
+	return b
+}
+
+func (b *Txt2ImgBuilder) Seed(seed int) *Txt2ImgBuilder {
+	b.opt.Seed = seed
+	return b
+}
+
+// Variation sets the subseed blended into the seed with strength.
+func (b *Txt2ImgBuilder) Variation(subseed int, strength float32) *Txt2ImgBuilder {
+	b.opt.Subseed, b.opt.SubseedStrength = subseed, strength
+	return b
+}
+
+// Batch sets the images generated per batch and the number of batches.
+func (b *Txt2ImgBuilder) Batch(size, count int) *Txt2ImgBuilder {
+	b.opt.BatchSize, b.opt.NIter = size, count
+	return b
+}
+
+func (b *Txt2ImgBuilder) RestoreFaces() *Txt2ImgBuilder {
+	b.opt.RestoreFaces = true
+	return b
+}
+
+func (b *Txt2ImgBuilder) Tiling() *Txt2ImgBuilder {
+	b.opt.Tiling = true
+	return b
+}
+
+// HiresFix enables the high resolution pass upscaling by scale, it excludes HiresResize.
+func (b *Txt2ImgBuilder) HiresFix(upscaler string, scale, denoising float32) *Txt2ImgBuilder {
+	if b.hrResize {
+		b.fail("HiresFix: the hires pass is already set with HiresResize")
+	}
+	b.hrScale = true
+	b.opt.EnableHR = true
+	b.opt.HrUpscaler = upscaler
+	b.opt.HRScale = scale
+	b.opt.DenoisingStrength = denoising
+	return b
+}
+
+// HiresResize enables the high resolution pass upscaling to width x height, it excludes HiresFix.
+func (b *Txt2ImgBuilder) HiresResize(upscaler string, width, height int, denoising float32) *Txt2ImgBuilder {
+	if b.hrScale {
+		b.fail("HiresResize: the hires pass is already set with HiresFix")
+	}
+	b.hrResize = true
+	b.opt.EnableHR = true
+	b.opt.HrUpscaler = upscaler
+	b.opt.HrResizeX, b.opt.HrResizeY = width, height
+	b.opt.DenoisingStrength = denoising
+	return b
+}
+
+// HiresSteps sets the steps of the high resolution pass, 0 uses the same as the first pass.
+func (b *Txt2ImgBuilder) HiresSteps(steps int) *Txt2ImgBuilder {
+	b.opt.HrSecondPassSteps = steps
+	return b
+}
+
+// Script sets the selectable script, only one can run per request.
+func (b *Txt2ImgBuilder) Script(s Script) *Txt2ImgBuilder {
+	if b.hasScript {
+		b.fail("Script: %q replaces script %q, only one script runs per request", s.ScriptName(), b.opt.ScriptName)
+	}
+	b.hasScript = true
+	if err := b.opt.SetScript(s); err != nil {
+		b.fail("Script: %w", err)
+	}
+	return b
+}
+
+// AlwaysonScript adds the arguments of an alwayson script such as ControlNet.
+func (b *Txt2ImgBuilder) AlwaysonScript(s Script) *Txt2ImgBuilder {
+	if err := b.opt.SetAlwaysonScript(s); err != nil {
+		b.fail("AlwaysonScript: %w", err)
+	}
+	return b
+}
+
+// OverrideSettings overrides server options for this request only, merged with the options
+// set by other calls such as TokenMerging.
+func (b *Txt2ImgBuilder) OverrideSettings(settings Overrides) *Txt2ImgBuilder {
+	for name, value := range settings {
+		b.override(name, value)
+	}
+	b.opt.OverrideSettingsRestoreAfterwards = true
+	return b
+}
+
+// TokenMerging overrides the ratio of tokens merged for this request, see Overrides.SetTokenMerging.
+func (b *Txt2ImgBuilder) TokenMerging(ratio float32) *Txt2ImgBuilder {
+	if ratio < 0 || ratio > MaxTokenMergingRatio {
+		b.fail("TokenMerging: ratio %g is out of [0, %g]", ratio, MaxTokenMergingRatio)
+	}
+	return b.override(OptionTokenMergingRatio, ratio)
+}
+
+// SMinUncond skips the negative prompt for the steps whose sigma is under v, speeding up the
+// last steps, 0 disables it.
+func (b *Txt2ImgBuilder) SMinUncond(v float32) *Txt2ImgBuilder {
+	b.opt.SMinUncond = v
+	return b
+}
+
+// override sets one server option for this request only, along with the others set.
+func (b *Txt2ImgBuilder) override(name string, value any) *Txt2ImgBuilder {
+	if b.opt.OverrideSettings == nil {
+		b.opt.OverrideSettings = Overrides{}
+	}
+	b.opt.OverrideSettings.Set(name, value)
+	b.opt.OverrideSettingsRestoreAfterwards = true
+	return b
+}
+
+// Build returns the options, or the errors of the builder calls and of Validate.
+func (b *Txt2ImgBuilder) Build() (Txt2ImageOption, error) {
+	if err := b.build(b.opt.Validate); err != nil {
+		return Txt2ImageOption{}, err
+	}
+	return b.opt, nil
+}
+
+// Img2ImgBuilder builds an Img2ImgOption with defaults set, catching settings that exclude
+// each other. Errors are reported by Build.
+type Img2ImgBuilder struct {
+	builderErrors
+	opt       Img2ImgOption
+	hasScript bool
+}
+
+// NewImg2Img returns a builder for the init images with the default size, steps, cfg
+// scale, sampler and denoising strength and a random seed.
+func NewImg2Img(initImages ...[]byte) *Img2ImgBuilder {
+	b := &Img2ImgBuilder{opt: Img2ImgOption{
+		DenoisingStrength: DefaultDenoisingStrength,
+		Steps:             DefaultSteps,
+		CfgScale:          DefaultCfgScale,
+		Width:             DefaultSize,
+		Height:            DefaultSize,
+		SamplerName:       DefaultSampler,
+		Seed:              SeedRandom,
+		BatchSize:         1,
+		NIter:             1,
+	}}
+	for _, img := range initImages {
+		b.opt.InitImages = append(b.opt.InitImages, ImgBytes2Base64(img))
+	}
+	return b
+}
+
+func (b *Img2ImgBuilder) Prompt(prompt string) *Img2ImgBuilder {
+	b.opt.Prompt = prompt
+	return b
+}
+
+func (b *Img2ImgBuilder) NegativePrompt(prompt string) *Img2ImgBuilder {
+	b.opt.NegativePrompt = prompt
+	return b
+}
+
+func (b *Img2ImgBuilder) Styles(styles ...string) *Img2ImgBuilder {
+	b.opt.Styles = append(b.opt.Styles, styles...)
+	return b
+}
+
+func (b *Img2ImgBuilder) Size(width, height int) *Img2ImgBuilder {
+	b.opt.Width, b.opt.Height = width, height
+	return b
+}
+
+func (b *Img2ImgBuilder) Steps(steps int) *Img2ImgBuilder {
+	b.opt.Steps = steps
+	return b
+}
+
+func (b *Img2ImgBuilder) CfgScale(cfg float32) *Img2ImgBuilder {
+	b.opt.CfgScale = cfg
+	return b
+}
+
+func (b *Img2ImgBuilder) Sampler(name string) *Img2ImgBuilder {
+	b.opt.SamplerName = name
+	return b
+}
+
+func (b *Img2ImgBuilder) Seed(seed int) *Img2ImgBuilder {
+	b.opt.Seed = seed
+	return b
+}
+
+// Variation sets the subseed blended into the seed with strength.
+func (b *Img2ImgBuilder) Variation(subseed int, strength float32) *Img2ImgBuilder {
+	b.opt.Subseed, b.opt.SubseedStrength = subseed, strength
+	return b
+}
+
+// Batch sets the images generated per batch and the number of batches.
+func (b *Img2ImgBuilder) Batch(size, count int) *Img2ImgBuilder {
+	b.opt.BatchSize, b.opt.NIter = size, count
+	return b
+}
+
+func (b *Img2ImgBuilder) Denoising(strength float32) *Img2ImgBuilder {
+	b.opt.DenoisingStrength = strength
+	return b
+}
+
+// ResizeMode sets how init images are fitted to the size.
+func (b *Img2ImgBuilder) ResizeMode(mode ResizeMode) *Img2ImgBuilder {
+	b.opt.ResizeMode = mode
+	return b
+}
+
+func (b *Img2ImgBuilder) RestoreFaces() *Img2ImgBuilder {
+	b.opt.RestoreFaces = true
+	return b
+}
+
+// Inpaint sets the mask of the area to repaint with the given blur, it requires an init image.
+func (b *Img2ImgBuilder) Inpaint(mask []byte, blur int) *Img2ImgBuilder {
+	if len(b.opt.InitImages) == 0 {
+		b.fail("Inpaint: a mask requires an init image")
+	}
+	b.opt.Mask = ImgBytes2Base64(mask)
+	b.opt.MaskBlur = blur
+	return b
+}
+
+// InpaintOnlyMasked inpaints the masked area at full resolution with padding pixels of
+// context, it requires Inpaint.
+func (b *Img2ImgBuilder) InpaintOnlyMasked(padding int) *Img2ImgBuilder {
+	if len(b.opt.Mask) == 0 {
+		b.fail("InpaintOnlyMasked: requires Inpaint first")
+	}
+	b.opt.InpaintFullRes = true
+	b.opt.InpaintFullResPadding = padding
+	return b
+}
+
+// Script sets the selectable script, only one can run per request.
+func (b *Img2ImgBuilder) Script(s Script) *Img2ImgBuilder {
+	if b.hasScript {
+		b.fail("Script: %q replaces script %q, only one script runs per request", s.ScriptName(), b.opt.ScriptName)
+	}
+	b.hasScript = true
+	if err := b.opt.SetScript(s); err != nil {
+		b.fail("Script: %w", err)
+	}
+	return b
+}
+
+// AlwaysonScript adds the arguments of an alwayson script such as ControlNet.
+func (b *Img2ImgBuilder) AlwaysonScript(s Script) *Img2ImgBuilder {
+	if err := b.opt.SetAlwaysonScript(s); err != nil {
+		b.fail("AlwaysonScript: %w", err)
+	}
+	return b
+}
+
+// OverrideSettings overrides server options for this request only, merged with the options
+// set by other calls such as TokenMerging.
+func (b *Img2ImgBuilder) OverrideSettings(settings Overrides) *Img2ImgBuilder {
+	for name, value := range settings {
+		b.override(name, value)
+	}
+	b.opt.OverrideSettingsRestoreAfterwards = true
+	return b
+}
+
+// TokenMerging overrides the ratio of tokens merged for this request, see Overrides.SetTokenMerging.
+func (b *Img2ImgBuilder) TokenMerging(ratio float32) *Img2ImgBuilder {
+	if ratio < 0 || ratio > MaxTokenMergingRatio {
+		b.fail("TokenMerging: ratio %g is out of [0, %g]", ratio, MaxTokenMergingRatio)
+	}
+	return b.override(OptionTokenMergingRatioImg2Img, ratio)
+}
+
+// SMinUncond skips the negative prompt for the steps whose sigma is under v, speeding up the
+// last steps, 0 disables it.
+func (b *Img2ImgBuilder) SMinUncond(v float32) *Img2ImgBuilder {
+	b.opt.SMinUncond = v
+	return b
+}
+
+// override sets one server option for this request only, along with the others set.
+func (b *Img2ImgBuilder) override(name string, value any) *Img2ImgBuilder {
+	if b.opt.OverrideSettings == nil {
+		b.opt.OverrideSettings = Overrides{}
+	}
+	b.opt.OverrideSettings.Set(name, value)
+	b.opt.OverrideSettingsRestoreAfterwards = true
+	return b
+}
+
+// Build returns the options, or the errors of the builder calls and of Validate.
+func (b *Img2ImgBuilder) Build() (Img2ImgOption, error) {
+	if err := b.build(b.opt.Validate); err != nil {
+		return Img2ImgOption{}, err
+	}
+	return b.opt, nil
+}
@@ -0,0 +1,116 @@
+package sdcli
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultCachedPaths are the API paths cached by WithCache when none are given, lists that
+// UIs tend to poll while they rarely change.
+var DefaultCachedPaths = []string{"/sd-models", "/samplers", "/options"}
+
+// WithCache caches the responses of GET requests to paths (relative to /sdapi/v1, DefaultCachedPaths
+// if none) for ttl. Any other request to a cached path, such as setting the options, invalidates it,
+// and so do RefreshCheckpoints and RefreshVAEs for the lists they refresh.
+func WithCache(ttl time.Duration, paths ...string) Option {
+	return func(c *Client) {
+		if len(paths) == 0 {
+			paths = DefaultCachedPaths
+		}
+		c.cache.ttl = ttl
+		c.cache.paths = map[string]bool{}
+		for _, p := range paths {
+			c.cache.paths["/sdapi/v1"+p] = true
+		}
+	}
+}
+
+type cacheEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	paths   map[string]bool
+	entries map[string]cacheEntry
+}
+
+func (rc *responseCache) enabled(path string) bool {
+	return rc.ttl > 0 && rc.paths[path]
+}
+
+func (rc *responseCache) get(path string) ([]byte, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	e, ok := rc.entries[path]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	// Raw results hand the data to the caller.
+	return bytes.Clone(e.data), true
+}
+
+func (rc *responseCache) put(path string, data []byte) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.entries == nil {
+		rc.entries = map[string]cacheEntry{}
+	}
+	rc.entries[path] = cacheEntry{data: bytes.Clone(data), expires: time.Now().Add(rc.ttl)}
+}
+
+func (rc *responseCache) invalidate(paths ...string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if len(paths) == 0 {
+		rc.entries = nil
+		return
+	}
+	for _, p := range paths {
+		delete(rc.entries, p)
+	}
+}
+
+// InvalidateCache drops the cached responses of paths (relative to /sdapi/v1), or all of them.
+func (c *Client) InvalidateCache(paths ...string) {
+	full := make([]string, len(paths))
+	for i, p := range paths {
+		full[i] = "/sdapi/v1" + p
+	}
+	c.cache.invalidate(full...)
+}
+
+// SetOptions updates the server options, only the given keys are changed.
+func (c *Client) SetOptions(ctx context.Context, opts map[string]any) error {
+	return c.doReq(ctx, "/options", http.MethodPost, opts, http.StatusOK, nil)
+}
+
+// RefreshCheckpoints rescans the checkpoint directory of the server.
+func (c *Client) RefreshCheckpoints(ctx context.Context) error {
+	if err := c.doReq(ctx, "/refresh-checkpoints", http.MethodPost, nil, http.StatusOK, nil); err != nil {
+		return err
+	}
+
+	c.InvalidateCache("/sd-models")
+	c.InvalidateCapabilities()
+	return nil
+}
+
+// RefreshVAEs rescans the VAE directory of the server.
+func (c *Client) RefreshVAEs(ctx context.Context) error {
+	if err := c.doReq(ctx, "/refresh-vae", http.MethodPost, nil, http.StatusOK, nil); err != nil {
+		return err
+	}
+
+	c.InvalidateCache("/sd-vae")
+	c.InvalidateCapabilities()
+	return nil
+}
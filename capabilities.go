@@ -0,0 +1,208 @@
+package sdcli
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCapabilitiesTTL is how long Capabilities results are reused before refetching.
+const DefaultCapabilitiesTTL = 5 * time.Minute
+
+// WithCapabilitiesTTL sets how long the result of Capabilities is cached, a non-positive ttl disables caching.
+func WithCapabilitiesTTL(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.capsTTL = ttl
+	}
+}
+
+// Capabilities is a snapshot of the models, samplers, upscalers, VAEs and scripts the server offers.
+type Capabilities struct {
+	Models   []*ModelsResponse
+	Samplers []*SamplersResponse
+	// Schedulers is nil when the server predates the schedulers endpoint.
+	Schedulers []*SchedulersResponse
+	Upscalers  []*UpscalersResponse
+	VAEs       []*VAEsResponse
+	Scripts    *ScriptsResponse
+
+	FetchedAt time.Time
+}
+
+// HasModel reports whether a checkpoint matches the title, model name or hash, see FindModel.
+func (c *Capabilities) HasModel(name string) bool {
+	return FindModel(c.Models, name) != nil
+}
+
+// HasSampler reports whether a sampler matches the name or one of its aliases.
+func (c *Capabilities) HasSampler(name string) bool {
+	for _, s := range c.Samplers {
+		if s.Name == name {
+			return true
+		}
+		for _, alias := range s.Aliases {
+			if alias == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// HasScheduler reports whether a scheduler matches the name, label or one of its aliases.
+func (c *Capabilities) HasScheduler(name string) bool {
+	for _, s := range c.Schedulers {
+		if s.Name == name || s.Label == name {
+			return true
+		}
+		for _, alias := range s.Aliases {
+			if alias == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// HasUpscaler reports whether an upscaler with the name is available.
+func (c *Capabilities) HasUpscaler(name string) bool {
+	for _, u := range c.Upscalers {
+		if strings.EqualFold(u.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasVAE reports whether a VAE with the model name or filename is available, see FindVAE.
+func (c *Capabilities) HasVAE(name string) bool {
+	return FindVAE(c.VAEs, name) != nil
+}
+
+// HasScript reports whether a script is available for txt2img (or img2img if img2img is true),
+// names are compared case-insensitively as webui does.
+func (c *Capabilities) HasScript(name string, img2img bool) bool {
+	if c.Scripts == nil {
+		return false
+	}
+	scripts := c.Scripts.Txt2Img
+	if img2img {
+		scripts = c.Scripts.Img2Img
+	}
+	for _, s := range scripts {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type capsCache struct {
+	mu      sync.Mutex
+	value   *Capabilities
+	expires time.Time
+	// call is the fetch in flight, joined by the callers meanwhile instead of holding mu
+	// across it.
+	call *capsCall
+	// gen counts the invalidations, a fetch started before one is not cached.
+	gen uint64
+}
+
+type capsCall struct {
+	done  chan struct{}
+	value *Capabilities
+	err   error
+}
+
+// Capabilities returns the server capabilities, fetching them only when the cached copy is missing or expired.
+// Concurrent callers share a single fetch. The returned value is shared between callers and must not be modified.
+func (c *Client) Capabilities(ctx context.Context) (*Capabilities, error) {
+	for {
+		c.caps.mu.Lock()
+		if c.caps.value != nil && time.Now().Before(c.caps.expires) {
+			value := c.caps.value
+			c.caps.mu.Unlock()
+			return value, nil
+		}
+		if call := c.caps.call; call != nil {
+			c.caps.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, wrapError(ctx.Err(), nil, "failed to get capabilities")
+			}
+			// The fetch was canceled by the context of the caller that started it, not ours.
+			if call.err != nil && ctx.Err() == nil && (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) {
+				continue
+			}
+			return call.value, call.err
+		}
+
+		call := &capsCall{done: make(chan struct{})}
+		c.caps.call = call
+		gen := c.caps.gen
+		c.caps.mu.Unlock()
+
+		call.value, call.err = c.fetchCapabilities(ctx)
+
+		c.caps.mu.Lock()
+		if c.caps.call == call {
+			c.caps.call = nil
+		}
+		if call.err == nil && c.capsTTL > 0 && c.caps.gen == gen {
+			c.caps.value = call.value
+			c.caps.expires = call.value.FetchedAt.Add(c.capsTTL)
+		}
+		c.caps.mu.Unlock()
+		close(call.done)
+
+		return call.value, call.err
+	}
+}
+
+// InvalidateCapabilities drops the cached capabilities so the next call to Capabilities refetches them, a
+// fetch in flight is not cached.
+func (c *Client) InvalidateCapabilities() {
+	c.caps.mu.Lock()
+	defer c.caps.mu.Unlock()
+
+	c.caps.value = nil
+	c.caps.expires = time.Time{}
+	c.caps.call = nil
+	c.caps.gen++
+}
+
+func (c *Client) fetchCapabilities(ctx context.Context) (*Capabilities, error) {
+	var (
+		caps = &Capabilities{}
+		err  error
+	)
+
+	if caps.Models, err = c.GetModels(ctx); err != nil {
+		return nil, err
+	}
+	if caps.Samplers, err = c.GetSamplers(ctx); err != nil {
+		return nil, err
+	}
+	if caps.Schedulers, err = c.GetSchedulers(ctx); err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	if caps.Upscalers, err = c.GetUpscalers(ctx); err != nil {
+		return nil, err
+	}
+	if caps.VAEs, err = c.GetVAEs(ctx); err != nil {
+		return nil, err
+	}
+	if caps.Scripts, err = c.GetScripts(ctx); err != nil {
+		return nil, err
+	}
+	caps.FetchedAt = time.Now()
+
+	return caps, nil
+}
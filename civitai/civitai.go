@@ -0,0 +1,153 @@
+// Package civitai exports the metadata of generated images in the JSON format of Civitai, the
+// generation parameters with the resources used and their hashes, so the images are linked to
+// their models when published.
+package civitai
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+	"github.com/shallowclouds/go-sd-webui-cli/prompt"
+)
+
+// Types of resources.
+const (
+	TypeModel    = "model"
+	TypeLora     = "lora"
+	TypeHypernet = "hypernet"
+	TypeEmbed    = "embed"
+	TypeVAE      = "vae"
+)
+
+// Resource is a model, network or embedding used by a generation.
+type Resource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Weight is the weight of the network tag in the prompt, nil for the other resources.
+	Weight *float64 `json:"weight,omitempty"`
+	// Hash is the short hash Civitai matches the resource with, empty when unknown.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Metadata is the metadata of an image, with the keys Civitai reads.
+type Metadata struct {
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negativePrompt,omitempty"`
+	Steps          int    `json:"steps,omitempty"`
+	// Sampler includes the schedule type like Civitai shows it, such as "DPM++ 2M Karras".
+	Sampler   string  `json:"sampler,omitempty"`
+	CfgScale  float32 `json:"cfgScale,omitempty"`
+	Seed      int64   `json:"seed,omitempty"`
+	Size      string  `json:"Size,omitempty"`
+	Model     string  `json:"Model,omitempty"`
+	ModelHash string  `json:"Model hash,omitempty"`
+	ClipSkip  int     `json:"clipSkip,omitempty"`
+
+	DenoisingStrength float32 `json:"Denoising strength,omitempty"`
+	HiresUpscale      float32 `json:"Hires upscale,omitempty"`
+	HiresUpscaler     string  `json:"Hires upscaler,omitempty"`
+	HiresSteps        int     `json:"Hires steps,omitempty"`
+
+	Resources []*Resource `json:"resources"`
+	// Hashes are the hashes by resource, "model", "vae", "lora:name" or "embed:name".
+	Hashes map[string]string `json:"hashes,omitempty"`
+}
+
+// FromInfotext returns the metadata of an image from its parsed infotext. The LoRAs and
+// hypernetworks are the network tags of the prompt, with their hashes when the infotext has them.
+func FromInfotext(t *sdcli.Infotext) *Metadata {
+	m := &Metadata{
+		Prompt:            t.Prompt,
+		NegativePrompt:    t.NegativePrompt,
+		Steps:             t.Steps,
+		Sampler:           t.Sampler,
+		CfgScale:          t.CfgScale,
+		Seed:              t.Seed,
+		Model:             t.Model,
+		ModelHash:         t.ModelHash,
+		ClipSkip:          t.ClipSkip,
+		DenoisingStrength: t.DenoisingStrength,
+		HiresUpscale:      t.HiresUpscale,
+		HiresUpscaler:     t.HiresUpscaler,
+		HiresSteps:        t.HiresSteps,
+		Resources:         []*Resource{},
+		Hashes:            map[string]string{},
+	}
+	if len(t.ScheduleType) != 0 && t.ScheduleType != "Automatic" && !strings.HasSuffix(m.Sampler, " "+t.ScheduleType) {
+		m.Sampler += " " + t.ScheduleType
+	}
+	if t.Width != 0 && t.Height != 0 {
+		m.Size = fmt.Sprintf("%dx%d", t.Width, t.Height)
+	}
+	for key, hash := range t.Hashes {
+		m.Hashes[key] = hash
+	}
+
+	if len(m.ModelHash) == 0 {
+		m.ModelHash = m.Hashes["model"]
+	}
+	if len(m.Model) != 0 || len(m.ModelHash) != 0 {
+		m.add(&Resource{Name: m.Model, Type: TypeModel, Hash: m.ModelHash}, "model")
+	}
+	if hash := t.VAEHash; len(t.VAE) != 0 || len(hash) != 0 {
+		if len(hash) == 0 {
+			hash = m.Hashes["vae"]
+		}
+		m.add(&Resource{Name: t.VAE, Type: TypeVAE, Hash: hash}, "vae")
+	}
+	for _, tag := range prompt.ParseNetworkTags(t.Prompt) {
+		r := &Resource{Name: tag.Name, Weight: &tag.Weight}
+		switch tag.Kind {
+		case prompt.KindLora:
+			r.Type, r.Hash = TypeLora, t.LoraHashes[tag.Name]
+		case prompt.KindHypernet:
+			r.Type = TypeHypernet
+		default:
+			continue
+		}
+		if len(r.Hash) == 0 {
+			r.Hash = m.Hashes[r.Type+":"+r.Name]
+		}
+		m.add(r, r.Type+":"+r.Name)
+	}
+	names := make([]string, 0, len(t.TIHashes))
+	for name := range t.TIHashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m.add(&Resource{Name: name, Type: TypeEmbed, Hash: t.TIHashes[name]}, "embed:"+name)
+	}
+
+	if len(m.Hashes) == 0 {
+		m.Hashes = nil
+	}
+	return m
+}
+
+// add appends a resource, recording its hash under key.
+func (m *Metadata) add(r *Resource, key string) {
+	m.Resources = append(m.Resources, r)
+	if len(r.Hash) != 0 {
+		m.Hashes[key] = r.Hash
+	}
+}
+
+// FromInfo returns the metadata of image i of a generation response from its Info.
+func FromInfo(info string, i int) (*Metadata, error) {
+	gen, err := sdcli.ParseInfo(info)
+	if err != nil {
+		return nil, err
+	}
+	text := gen.Infotext(i)
+	if len(text) == 0 {
+		return nil, fmt.Errorf("no infotext for image %d", i)
+	}
+	t, err := sdcli.ParseInfotext(text)
+	if err != nil {
+		return nil, err
+	}
+	return FromInfotext(t), nil
+}
@@ -5,28 +5,82 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Client struct {
 	cli                *http.Client
 	baseURL            string
+	credMu             sync.RWMutex
 	username, password string
+	credentialProvider CredentialProvider
+
+	capsTTL time.Duration
+	caps    capsCache
+	cache   responseCache
+
+	maxResponseBytes int64
+	requestIDHeader  string
+
+	retries      int
+	retryBackoff time.Duration
+
+	preflight        bool
+	skipDecode       bool
+	interruptTimeout time.Duration
+	oomPolicy        *OOMPolicy
+	processors       []ImageProcessor
+	recovery         *Recovery
+	debug            *debugDumper
+	payloadLimit     *PayloadLimit
+	inputLimit       *InputLimit
+}
+
+// Option configures optional behaviors of the Client.
+type Option func(c *Client)
+
+// WithSkipDecode keeps the images of responses as raw bytes without parsing them into image.Image,
+// e.g. for services storing or forwarding outputs. ParsedImages and ParsedCurrentImage stay empty.
+func WithSkipDecode() Option {
+	return func(c *Client) {
+		c.skipDecode = true
+	}
+}
+
+// WithMaxResponseBytes limits the size of response bodies, larger responses fail instead of being
+// read into memory. A non-positive n (the default) means no limit. Generation responses carry
+// base64 images, leave room for batch size * image size * 4/3.
+func WithMaxResponseBytes(n int64) Option {
+	return func(c *Client) {
+		c.maxResponseBytes = n
+	}
 }
 
 // NewClient creates the API client, leave username and password empty if not set.
-func NewClient(baseURL, username, password string, httpCli *http.Client) (*Client, error) {
+func NewClient(baseURL, username, password string, httpCli *http.Client, opts ...Option) (*Client, error) {
 	if len(baseURL) == 0 {
 		baseURL = "http://127.0.0.1:7860"
 	}
 	cli := &Client{
-		cli:     httpCli,
-		baseURL: baseURL,
+		cli:      httpCli,
+		baseURL:  baseURL,
+		username: username,
+		password: password,
+		capsTTL:  DefaultCapabilitiesTTL,
+
+		requestIDHeader: DefaultRequestIDHeader,
+	}
+
+	for _, opt := range opts {
+		opt(cli)
 	}
 
 	return cli, nil
@@ -36,12 +90,17 @@ type Error struct {
 	Err      error
 	Msg      string
 	Response *http.Response
+	// RequestID is the ID sent in the request ID header, to find the request in proxy and server logs.
+	RequestID string
 }
 
 func (e *Error) Error() string {
 	if e == nil {
 		return ""
 	}
+	if len(e.RequestID) != 0 {
+		return fmt.Sprintf("%s (request %s): %+v", e.Msg, e.RequestID, e.Err)
+	}
 	return fmt.Sprintf("%s: %+v", e.Msg, e.Err)
 }
 
@@ -57,44 +116,183 @@ func wrapError(err error, resp *http.Response, format string, args ...any) *Erro
 	}
 }
 
+// Doer sends JSON requests to arbitrary paths of the server, it is implemented by Client
+// and used by the extension subpackages.
+type Doer interface {
+	Do(ctx context.Context, method, path string, body, result any) error
+}
+
+// Do sends a JSON request to path under the base URL, such as an extension route, with the
+// client's authentication and expects a 200 response decoded into result. A *Form body is sent
+// as multipart/form-data, and a *[]byte result receives the raw body, see DoRaw.
+func (c *Client) Do(ctx context.Context, method, path string, body, result any) error {
+	return c.do(ctx, path, method, body, http.StatusOK, result)
+}
+
+// DoRaw is Do returning the raw response body, e.g. for routes answering images or text.
+func (c *Client) DoRaw(ctx context.Context, method, path string, body any) ([]byte, error) {
+	var data []byte
+	if err := c.Do(ctx, method, path, body, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 func (c *Client) doReq(ctx context.Context, path, method string, body any, expectedStatus int, result any) error {
-	var (
-		b   io.Reader
-		err error
-	)
-	if body != nil {
+	return c.do(ctx, "/sdapi/v1"+path, method, body, expectedStatus, result)
+}
+
+func (c *Client) do(ctx context.Context, path, method string, body any, expectedStatus int, result any) error {
+	var id string
+	if len(c.requestIDHeader) != 0 {
+		if id = RequestIDFromContext(ctx); len(id) == 0 {
+			id = newRequestID()
+		}
+	}
+
+	err := c.send(ctx, id, path, method, body, expectedStatus, result)
+	var e *Error
+	if errors.As(err, &e) {
+		e.RequestID = id
+	}
+
+	return err
+}
+
+func (c *Client) send(ctx context.Context, requestID, path, method string, body any, expectedStatus int, result any) error {
+	var payload []byte
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case *Form:
+		var err error
+		if payload, contentType, err = b.encode(); err != nil {
+			return wrapError(err, nil, "failed to encode form")
+		}
+	default:
 		buf := bytes.NewBuffer(nil)
-		if err = json.NewEncoder(buf).Encode(body); err != nil {
+		if err := json.NewEncoder(buf).Encode(body); err != nil {
 			return wrapError(err, nil, "failed to encode body")
 		}
-		b = buf
+		payload = buf.Bytes()
+	}
+
+	if dry, err := c.dryRun(ctx, requestID, path, method, contentType, payload); dry {
+		return err
+	}
+	if err := c.checkPayload(ctx, path, payload); err != nil {
+		return err
+	}
+
+	cached := c.cache.enabled(path)
+	if cached && method == http.MethodGet {
+		if data, ok := c.cache.get(path); ok {
+			if c.debug != nil {
+				if req, err := c.newRequest(ctx, requestID, path, method, contentType, payload); err == nil {
+					c.debug.dump(req, payload, nil, data, 0, nil)
+				}
+			}
+			return decodeResult(data, nil, result)
+		}
+	}
+
+	resp, data, err := c.roundTripRetry(ctx, requestID, path, method, contentType, payload)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.credentialProvider != nil {
+		username, password, err := c.credentialProvider(ctx)
+		if err != nil {
+			return wrapError(err, resp, "failed to refresh credentials")
+		}
+		c.setCredentials(username, password)
+
+		if resp, data, err = c.roundTripRetry(ctx, requestID, path, method, contentType, payload); err != nil {
+			return err
+		}
+	}
+
+	if resp.StatusCode != expectedStatus {
+		return wrapError(nil, resp, "got bad status %d, body: %s", resp.StatusCode, string(data))
+	}
+
+	if cached {
+		if method == http.MethodGet {
+			c.cache.put(path, data)
+		} else {
+			c.cache.invalidate(path)
+		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/sdapi/v1%s", c.baseURL, path), b)
+	return decodeResult(data, resp, result)
+}
+
+// newRequest builds a request with the headers and credentials of the client.
+func (c *Client) newRequest(ctx context.Context, requestID, path, method, contentType string, payload []byte) (*http.Request, error) {
+	var b io.Reader
+	if payload != nil {
+		b = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, b)
 	if err != nil {
-		return wrapError(err, nil, "failed to initialize request")
+		return nil, wrapError(err, nil, "failed to initialize request")
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
+	if len(requestID) != 0 {
+		req.Header.Set(c.requestIDHeader, requestID)
+	}
+	for key, values := range headerFromContext(ctx) {
+		req.Header[key] = values
+	}
 	// If any.
-	if len(c.username) != 0 && len(c.password) != 0 {
-		req.SetBasicAuth(c.username, c.password)
+	if username, password := c.credentials(); len(username) != 0 && len(password) != 0 {
+		req.SetBasicAuth(username, password)
 	}
 
+	return req, nil
+}
+
+// roundTrip sends a request and reads the whole response body.
+func (c *Client) roundTrip(ctx context.Context, requestID, path, method, contentType string, payload []byte) (*http.Response, []byte, error) {
+	req, err := c.newRequest(ctx, requestID, path, method, contentType, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start := time.Now()
 	resp, err := c.cli.Do(req)
 	if err != nil {
-		return wrapError(err, nil, "failed to do request")
+		c.debug.dump(req, payload, nil, nil, time.Since(start), err)
+		return nil, nil, wrapError(err, nil, "failed to do request")
 	}
 
 	defer resp.Body.Close()
 
-	data, err := io.ReadAll(resp.Body)
+	var r io.Reader = resp.Body
+	if c.maxResponseBytes > 0 {
+		r = io.LimitReader(resp.Body, c.maxResponseBytes+1)
+	}
+	data, err := io.ReadAll(r)
+	c.debug.dump(req, payload, resp, data, time.Since(start), err)
 	if err != nil {
-		return wrapError(err, resp, "failed to read response body")
+		return nil, nil, wrapError(err, resp, "failed to read response body")
+	}
+	if c.maxResponseBytes > 0 && int64(len(data)) > c.maxResponseBytes {
+		return nil, nil, wrapError(nil, resp, "response body of %s %s exceeds the limit of %d bytes", method, path, c.maxResponseBytes)
 	}
 
-	if resp.StatusCode != expectedStatus {
-		return wrapError(nil, resp, "got bad status %d, body: %s", resp.StatusCode, string(data))
+	return resp, data, nil
+}
+
+func decodeResult(data []byte, resp *http.Response, result any) error {
+	if result == nil {
+		return nil
+	}
+	if raw, ok := result.(*[]byte); ok {
+		*raw = data
+		return nil
 	}
 
 	if err := json.Unmarshal(data, result); err != nil {
@@ -118,173 +316,120 @@ func Img2Base64(img image.Image) string {
 	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
 }
 
+// decodeBase64 decodes base64 data with or without a data URI prefix.
+func decodeBase64(raw string) ([]byte, error) {
+	if i := strings.IndexByte(raw, ','); i >= 0 {
+		raw = raw[i+1:]
+	}
+	return base64.StdEncoding.DecodeString(raw)
+}
+
 func ImgBytes2Base64(data []byte) string {
 	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)
 }
 
-type Txt2ImageOption struct {
-	Prompt                            string           `json:"prompt,omitempty"`
-	NegativePrompt                    string           `json:"negative_prompt,omitempty"`
-	Steps                             int              `json:"steps,omitempty"`
-	CfgScale                          float32          `json:"cfg_scale,omitempty"`
-	Width                             int              `json:"width,omitempty"`
-	Height                            int              `json:"height,omitempty"`
-	SamplerIndex                      string           `json:"sampler_index,omitempty"`
-	OverrideSettings                  *OptionsResponse `json:"override_settings,omitempty"`
-	EnableHR                          bool             `json:"enable_hr,omitempty"`
-	DenoisingStrength                 float32          `json:"denoising_strenght,omitempty"`
-	FirstPhaseWidth                   int              `json:"firstphase_width,omitempty"`
-	FirstPhaseHeight                  int              `json:"firstphase_height,omitempty"`
-	HRScale                           float32          `json:"hr_scale,omitempty"`
-	HrUpscaler                        string           `json:"hr_upscaler,omitempty"`
-	HrSecondPassSteps                 int              `json:"hr_second_pass_steps,omitempty"`
-	HrResizeX                         int              `json:"hr_resize_x,omitempty"`
-	HrResizeY                         int              `json:"hr_resize_y,omitempty"`
-	Styles                            []string         `json:"styles,omitempty"`
-	Seed                              int              `json:"seed,omitempty"`
-	Subseed                           int              `json:"subseed,omitempty"`
-	SubseedStrength                   float32          `json:"subseed_strength,omitempty"`
-	SeedResizeFromH                   int              `json:"seed_resize_from_h,omitempty"`
-	SeedResizeFromW                   int              `json:"seed_resize_from_w,omitempty"`
-	SamplerName                       string           `json:"sampler_name,omitempty"`
-	BatchSize                         int              `json:"batch_size,omitempty"`
-	NIter                             int              `json:"n_iter,omitempty"`
-	RestoreFaces                      bool             `json:"restore_faces,omitempty"`
-	Tiling                            bool             `json:"tiling,omitempty"`
-	Eta                               float32          `json:"eta,omitempty"`
-	SChurn                            float32          `json:"s_churn,omitempty"`
-	STmax                             float32          `json:"s_tmax,omitempty"`
-	STmin                             float32          `json:"s_tmin,omitempty"`
-	SNoise                            float32          `json:"s_noise,omitempty"`
-	OverrideSettingsRestoreAfterwards bool             `json:"override_settings_restore_afterwards,omitempty"`
-	ScriptArgs                        []interface{}    `json:"script_args,omitempty"`
-	ScriptName                        string           `json:"script_name,omitempty"`
+// Base642Img decodes a base64 image returned by the server, with or without a data URI prefix.
+func Base642Img(raw string) (image.Image, []byte, error) {
+	data, err := decodeBase64(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, data, err
+	}
+
+	return img, data, nil
 }
 
+// SeedRandom lets the server pick a random seed, see the seed package to fix it client side.
+const SeedRandom = -1
+
 type Txt2ImageResponse struct {
 	Images     []string         `json:"images"`
 	Parameters *Txt2ImageOption `json:"parameters"`
 	Info       string           `json:"info"`
 
+	// RawImages are the still images of Images, ParsedImages[i] is RawImages[i] decoded.
 	ParsedImages []image.Image `json:"-"`
 	RawImages    [][]byte      `json:"-"`
+	// Videos are the animated outputs (GIF, MP4, WebM) of extensions such as AnimateDiff.
+	Videos []*Video `json:"-"`
+	// Degraded are the parameters changed by WithOOMRecovery to get the images.
+	Degraded []Degradation `json:"-"`
+	// Outputs are the still images kept by the processors of WithImageProcessors.
+	Outputs []*Output `json:"-"`
 }
 
-func (c *Client) Txt2Img(ctx context.Context, opt Txt2ImageOption) (*Txt2ImageResponse, error) {
-	res := new(Txt2ImageResponse)
-	if err := c.doReq(ctx, "/txt2img", http.MethodPost, &opt, http.StatusOK, res); err != nil {
+func (c *Client) Txt2Img(ctx context.Context, opt Txt2ImageOption, opts ...RequestOption) (*Txt2ImageResponse, error) {
+	ctx, cancel := requestContext(ctx, opts)
+	defer cancel()
+
+	if err := c.preflightTxt2Img(ctx, &opt); err != nil {
 		return nil, err
 	}
 
-	imgs := make([]image.Image, 0, len(res.Images))
-	raws := make([][]byte, 0, len(res.Images))
-
-	for _, raw := range res.Images {
-		raw = strings.SplitN(raw, ",", 1)[0]
-		data, err := base64.StdEncoding.DecodeString(raw)
-		if err != nil {
-			// Should not happen.
-			continue
-		}
-
-		raws = append(raws, data)
-
-		img, err := png.Decode(bytes.NewReader(data))
-		if err != nil {
-			// Should not happen.
-			continue
-		}
-
-		imgs = append(imgs, img)
+	res := new(Txt2ImageResponse)
+	f := degradable{enableHR: &opt.EnableHR, batchSize: &opt.BatchSize, nIter: &opt.NIter, width: &opt.Width, height: &opt.Height}
+	degraded, err := c.generateOOM(ctx, "/txt2img", &opt, f, res)
+	if err != nil {
+		return nil, err
 	}
+	res.Degraded = degraded
 
-	res.ParsedImages = imgs
-	res.RawImages = raws
+	res.ParsedImages, res.RawImages, res.Videos = decodeImages(res.Images, !c.skipDecode)
+	if res.Outputs, err = c.postProcess(ctx, res.Info, &res.RawImages, &res.ParsedImages); err != nil {
+		return nil, err
+	}
 
 	return res, nil
 }
 
-type Img2ImgOption struct {
-	InitImages                        []string         `json:"init_images,omitempty"`
-	ResizeMode                        int              `json:"resize_mode,omitempty"`
-	DenoisingStrength                 float32          `json:"denoising_strength,omitempty"`
-	ImageCfgScale                     float32          `json:"image_cfg_scale,omitempty"`
-	Mask                              string           `json:"mask,omitempty"`
-	MaskBlur                          int              `json:"mask_blur,omitempty"`
-	InpaintingFill                    int              `json:"inpainting_fill,omitempty"`
-	InpaintFullRes                    bool             `json:"inpaint_full_res,omitempty"`
-	InpaintFullResPadding             int              `json:"inpaint_full_res_padding,omitempty"`
-	InpaintingMaskInvert              int              `json:"inpainting_mask_invert,omitempty"`
-	InitialNoiseMultiplier            int              `json:"initial_noise_multiplier,omitempty"`
-	Prompt                            string           `json:"prompt,omitempty"`
-	Styles                            []string         `json:"styles,omitempty"`
-	Seed                              int              `json:"seed,omitempty"`
-	Subseed                           int              `json:"subseed,omitempty"`
-	SubseedStrength                   float32          `json:"subseed_strength,omitempty"`
-	SeedResizeFromH                   int              `json:"seed_resize_from_h,omitempty"`
-	SeedResizeFromW                   int              `json:"seed_resize_from_w,omitempty"`
-	SamplerName                       string           `json:"sampler_name,omitempty"`
-	BatchSize                         int              `json:"batch_size,omitempty"`
-	NIter                             int              `json:"n_iter,omitempty"`
-	Steps                             int              `json:"steps,omitempty"`
-	CfgScale                          float32          `json:"cfg_scale,omitempty"`
-	Width                             int              `json:"width,omitempty"`
-	Height                            int              `json:"height,omitempty"`
-	RestoreFaces                      bool             `json:"restore_faces,omitempty"`
-	Tiling                            bool             `json:"tiling,omitempty"`
-	NegativePrompt                    string           `json:"negative_prompt,omitempty"`
-	Eta                               float32          `json:"eta,omitempty"`
-	SChurn                            float32          `json:"s_churn,omitempty"`
-	STmax                             float32          `json:"s_tmax,omitempty"`
-	STmin                             float32          `json:"s_tmin,omitempty"`
-	SNoise                            int              `json:"s_noise,omitempty"`
-	OverrideSettings                  *OptionsResponse `json:"override_settings,omitempty"`
-	OverrideSettingsRestoreAfterwards bool             `json:"override_settings_restore_afterwards,omitempty"`
-	ScriptArgs                        []interface{}    `json:"script_args,omitempty"`
-	SamplerIndex                      string           `json:"sampler_index,omitempty"`
-	IncludeInitImages                 bool             `json:"include_init_images,omitempty"`
-	ScriptName                        string           `json:"script_name,omitempty"`
-}
-
 type Img2ImgResponse struct {
 	Images     []string         `json:"images"`
 	Parameters *Txt2ImageOption `json:"parameters"`
 	Info       string           `json:"info"`
 
+	// RawImages are the still images of Images, ParsedImages[i] is RawImages[i] decoded.
 	ParsedImages []image.Image `json:"-"`
 	RawImages    [][]byte      `json:"-"`
+	// Videos are the animated outputs (GIF, MP4, WebM) of extensions such as AnimateDiff.
+	Videos []*Video `json:"-"`
+	// Degraded are the parameters changed by WithOOMRecovery to get the images.
+	Degraded []Degradation `json:"-"`
+	// Outputs are the still images kept by the processors of WithImageProcessors.
+	Outputs []*Output `json:"-"`
 }
 
-func (c *Client) Img2Img(ctx context.Context, opt Img2ImgOption) (*Img2ImgResponse, error) {
-	res := new(Img2ImgResponse)
-	if err := c.doReq(ctx, "/img2img", http.MethodPost, &opt, http.StatusOK, res); err != nil {
-		return nil, err
-	}
-
-	imgs := make([]image.Image, 0, len(res.Images))
-	raws := make([][]byte, 0, len(res.Images))
-
-	for _, raw := range res.Images {
-		raw = strings.SplitN(raw, ",", 1)[0]
-		data, err := base64.StdEncoding.DecodeString(raw)
-		if err != nil {
-			// Should not happen.
-			continue
-		}
+func (c *Client) Img2Img(ctx context.Context, opt Img2ImgOption, opts ...RequestOption) (*Img2ImgResponse, error) {
+	ctx, cancel := requestContext(ctx, opts)
+	defer cancel()
 
-		raws = append(raws, data)
+	return c.img2img(ctx, &opt, &opt)
+}
 
-		img, err := png.Decode(bytes.NewReader(data))
-		if err != nil {
-			// Should not happen.
-			continue
-		}
+// img2img runs an img2img generation of opt, sending body which encodes opt.
+func (c *Client) img2img(ctx context.Context, opt *Img2ImgOption, body any) (*Img2ImgResponse, error) {
+	if err := c.preflightImg2Img(ctx, opt); err != nil {
+		return nil, err
+	}
+	if err := c.downscaleInputs(opt); err != nil {
+		return nil, err
+	}
 
-		imgs = append(imgs, img)
+	res := new(Img2ImgResponse)
+	f := degradable{batchSize: &opt.BatchSize, nIter: &opt.NIter, width: &opt.Width, height: &opt.Height}
+	degraded, err := c.generateOOM(ctx, "/img2img", body, f, res)
+	if err != nil {
+		return nil, err
 	}
+	res.Degraded = degraded
 
-	res.ParsedImages = imgs
-	res.RawImages = raws
+	res.ParsedImages, res.RawImages, res.Videos = decodeImages(res.Images, !c.skipDecode)
+	if res.Outputs, err = c.postProcess(ctx, res.Info, &res.RawImages, &res.ParsedImages); err != nil {
+		return nil, err
+	}
 
 	return res, nil
 }
@@ -302,37 +447,6 @@ const (
 	UpscalerSwinIR4x           = "SwinIR 4x"
 )
 
-type ExtraSingleImgOption struct {
-	// Sets the resize mode: 0 to upscale by upscaling_resize amount, 1 to upscale up to upscaling_resize_h x upscaling_resize_w.
-	ResizeMode int `json:"resize_mode,omitempty"`
-	// Should the backend return the generated image?
-	ShowExtrasResults bool `json:"show_extras_results,omitempty"`
-	// Sets the visibility of GFPGAN, values should be between 0 and 1.
-	GfpganVisibility int `json:"gfpgan_visibility,omitempty"`
-	// Sets the visibility of CodeFormer, values should be between 0 and 1.
-	CodeformerVisibility int `json:"codeformer_visibility,omitempty"`
-	// Sets the weight of CodeFormer, values should be between 0 and 1.
-	CodeformerWeight int `json:"codeformer_weight,omitempty"`
-	// By how much to upscale the image, only used when resize_mode=0.
-	UpscalingResize int `json:"upscaling_resize,omitempty"`
-	// Target width for the upscaler to hit. Only used when resize_mode=1.
-	UpscalingResizeW int `json:"upscaling_resize_w,omitempty"`
-	// Target height for the upscaler to hit. Only used when resize_mode=1.
-	UpscalingResizeH int `json:"upscaling_resize_h,omitempty"`
-	// Should the upscaler crop the image to fit in the chosen size?
-	UpscalingCrop bool `json:"upscaling_crop,omitempty"`
-	// The name of the main upscaler to use, it has to be one of this list: None , Lanczos , Nearest , ESRGAN_4x , R-ESRGAN 4x+ , R-ESRGAN 4x+ Anime6B , LDSR , ScuNET GAN , ScuNET PSNR , SwinIR 4x
-	Upscaler1 string `json:"upscaler_1,omitempty"`
-	// The name of the secondary upscaler to use, it has to be one of this list: None , Lanczos , Nearest , ESRGAN_4x , R-ESRGAN 4x+ , R-ESRGAN 4x+ Anime6B , LDSR , ScuNET GAN , ScuNET PSNR , SwinIR 4x
-	Upscaler2 string `json:"upscaler_2,omitempty"`
-	// Sets the visibility of secondary upscaler, values should be between 0 and 1.
-	ExtrasUpscaler2Visibility int `json:"extras_upscaler_2_visibility,omitempty"`
-	// Should the upscaler run before restoring faces?
-	UpscaleFirst bool `json:"upscale_first,omitempty"`
-	// Image to work on, must be a Base64 string containing the image's data.
-	Image string `json:"image,omitempty"`
-}
-
 type ExtraSingleImgResponse struct {
 	HTMLInfo string `json:"html_info"`
 	Image    string `json:"image"`
@@ -341,29 +455,40 @@ type ExtraSingleImgResponse struct {
 	RawImage    []byte      `json:"-"`
 }
 
-func (c *Client) ExtraSingleImg(ctx context.Context, opt ExtraSingleImgOption) (*ExtraSingleImgResponse, error) {
+func (c *Client) ExtraSingleImg(ctx context.Context, opt ExtraSingleImgOption, opts ...RequestOption) (*ExtraSingleImgResponse, error) {
+	ctx, cancel := requestContext(ctx, opts)
+	defer cancel()
+
+	if err := c.preflightExtras(ctx, &opt); err != nil {
+		return nil, err
+	}
+
 	res := new(ExtraSingleImgResponse)
-	if err := c.doReq(ctx, "/extra-single-image", http.MethodPost, &opt, http.StatusOK, res); err != nil {
+	if err := c.generate(ctx, "/extra-single-image", &opt, res); err != nil {
 		return nil, err
 	}
 
-	raw := strings.SplitN(res.Image, ",", 1)[0]
-	data, err := base64.StdEncoding.DecodeString(raw)
-	if err != nil {
-		// Should not happen.
-	} else {
-		res.RawImage = data
-		img, err := png.Decode(bytes.NewReader(data))
-		if err != nil {
-			// Should not happen.
-		} else {
-			res.ParsedImage = img
-		}
+	// The image may come with a data URI prefix and in the format of the samples settings.
+	imgs, raws, _ := decodeImages([]string{res.Image}, !c.skipDecode)
+	if len(raws) != 0 {
+		res.RawImage = raws[0]
+	}
+	if len(imgs) != 0 {
+		res.ParsedImage = imgs[0]
 	}
 
 	return res, nil
 }
 
+// ExtraSingleImgFrom runs ExtraSingleImg on src, an image.Image, the []byte of an encoded image
+// or an io.Reader of one, see EncodeImage. The Image of opt is replaced.
+func (c *Client) ExtraSingleImgFrom(ctx context.Context, src any, opt ExtraSingleImgOption, opts ...RequestOption) (*ExtraSingleImgResponse, error) {
+	if err := opt.SetImage(src); err != nil {
+		return nil, err
+	}
+	return c.ExtraSingleImg(ctx, opt, opts...)
+}
+
 type ProgressResponse struct {
 	Progress    float32 `json:"progress"`
 	ETARelative float32 `json:"eta_relative"`
@@ -379,6 +504,14 @@ type ProgressResponse struct {
 	} `json:"state"`
 	CurrentImage string `json:"current_image"`
 	TextInfo     string `json:"textinfo"`
+
+	// RawCurrentImage is the decoded live preview, if requested and available, and
+	// ParsedCurrentImage the parsed one unless WithSkipDecode is set.
+	RawCurrentImage    []byte      `json:"-"`
+	ParsedCurrentImage image.Image `json:"-"`
+
+	// FetchedAt is when the progress was received, the reference of ETA based helpers.
+	FetchedAt time.Time `json:"-"`
 }
 
 func (c *Client) GetProgress(ctx context.Context, skipCurrentImg bool) (*ProgressResponse, error) {
@@ -386,155 +519,17 @@ func (c *Client) GetProgress(ctx context.Context, skipCurrentImg bool) (*Progres
 	if err := c.doReq(ctx, fmt.Sprintf("/progress?skip_current_image=%v", skipCurrentImg), http.MethodGet, nil, http.StatusOK, res); err != nil {
 		return nil, err
 	}
+	res.FetchedAt = time.Now()
 
-	return res, nil
-}
+	if len(res.CurrentImage) != 0 {
+		if c.skipDecode {
+			res.RawCurrentImage, _ = decodeBase64(res.CurrentImage)
+		} else {
+			res.ParsedCurrentImage, res.RawCurrentImage, _ = Base642Img(res.CurrentImage)
+		}
+	}
 
-type OptionsResponse struct {
-	SdModelCheckpoint string `json:"sd_model_checkpoint,omitempty"`
-
-	SamplesSave                        bool          `json:"samples_save,omitempty"`
-	SamplesFormat                      string        `json:"samples_format,omitempty"`
-	SamplesFilenamePattern             string        `json:"samples_filename_pattern,omitempty"`
-	SaveImagesAddNumber                bool          `json:"save_images_add_number,omitempty"`
-	GridSave                           bool          `json:"grid_save,omitempty"`
-	GridFormat                         string        `json:"grid_format,omitempty"`
-	GridExtendedFilename               bool          `json:"grid_extended_filename,omitempty"`
-	GridOnlyIfMultiple                 bool          `json:"grid_only_if_multiple,omitempty"`
-	GridPreventEmptySpots              bool          `json:"grid_prevent_empty_spots,omitempty"`
-	NRows                              float32       `json:"n_rows,omitempty"`
-	EnablePnginfo                      bool          `json:"enable_pnginfo,omitempty"`
-	SaveTxt                            bool          `json:"save_txt,omitempty"`
-	SaveImagesBeforeFaceRestoration    bool          `json:"save_images_before_face_restoration,omitempty"`
-	SaveImagesBeforeHighresFix         bool          `json:"save_images_before_highres_fix,omitempty"`
-	SaveImagesBeforeColorCorrection    bool          `json:"save_images_before_color_correction,omitempty"`
-	JpegQuality                        float32       `json:"jpeg_quality,omitempty"`
-	ExportFor4Chan                     bool          `json:"export_for_4chan,omitempty"`
-	ImgDownscaleThreshold              float32       `json:"img_downscale_threshold,omitempty"`
-	TargetSideLength                   float32       `json:"target_side_length,omitempty"`
-	UseOriginalNameBatch               bool          `json:"use_original_name_batch,omitempty"`
-	UseUpscalerNameAsSuffix            bool          `json:"use_upscaler_name_as_suffix,omitempty"`
-	SaveSelectedOnly                   bool          `json:"save_selected_only,omitempty"`
-	DoNotAddWatermark                  bool          `json:"do_not_add_watermark,omitempty"`
-	TempDir                            string        `json:"temp_dir,omitempty"`
-	CleanTempDirAtStart                bool          `json:"clean_temp_dir_at_start,omitempty"`
-	OutdirSamples                      string        `json:"outdir_samples,omitempty"`
-	OutdirTxt2ImgSamples               string        `json:"outdir_txt2img_samples,omitempty"`
-	OutdirImg2ImgSamples               string        `json:"outdir_img2img_samples,omitempty"`
-	OutdirExtrasSamples                string        `json:"outdir_extras_samples,omitempty"`
-	OutdirGrids                        string        `json:"outdir_grids,omitempty"`
-	OutdirTxt2ImgGrids                 string        `json:"outdir_txt2img_grids,omitempty"`
-	OutdirImg2ImgGrids                 string        `json:"outdir_img2img_grids,omitempty"`
-	OutdirSave                         string        `json:"outdir_save,omitempty"`
-	SaveToDirs                         bool          `json:"save_to_dirs,omitempty"`
-	GridSaveToDirs                     bool          `json:"grid_save_to_dirs,omitempty"`
-	UseSaveToDirsForUI                 bool          `json:"use_save_to_dirs_for_ui,omitempty"`
-	DirectoriesFilenamePattern         string        `json:"directories_filename_pattern,omitempty"`
-	DirectoriesMaxPromptWords          float32       `json:"directories_max_prompt_words,omitempty"`
-	ESRGANTile                         float32       `json:"ESRGAN_tile,omitempty"`
-	ESRGANTileOverlap                  float32       `json:"ESRGAN_tile_overlap,omitempty"`
-	RealesrganEnabledModels            []string      `json:"realesrgan_enabled_models,omitempty"`
-	UpscalerForImg2Img                 string        `json:"upscaler_for_img2img,omitempty"`
-	LdsrSteps                          float32       `json:"ldsr_steps,omitempty"`
-	LdsrCached                         bool          `json:"ldsr_cached,omitempty"`
-	SWINTile                           float32       `json:"SWIN_tile,omitempty"`
-	SWINTileOverlap                    float32       `json:"SWIN_tile_overlap,omitempty"`
-	FaceRestorationModel               string        `json:"face_restoration_model,omitempty"`
-	CodeFormerWeight                   float32       `json:"code_former_weight,omitempty"`
-	FaceRestorationUnload              bool          `json:"face_restoration_unload,omitempty"`
-	ShowWarnings                       bool          `json:"show_warnings,omitempty"`
-	MemmonPollRate                     float32       `json:"memmon_poll_rate,omitempty"`
-	SamplesLogStdout                   bool          `json:"samples_log_stdout,omitempty"`
-	MultipleTqdm                       bool          `json:"multiple_tqdm,omitempty"`
-	PrintHypernetExtra                 bool          `json:"print_hypernet_extra,omitempty"`
-	UnloadModelsWhenTraining           bool          `json:"unload_models_when_training,omitempty"`
-	PinMemory                          bool          `json:"pin_memory,omitempty"`
-	SaveOptimizerState                 bool          `json:"save_optimizer_state,omitempty"`
-	SaveTrainingSettingsToTxt          bool          `json:"save_training_settings_to_txt,omitempty"`
-	DatasetFilenameWordRegex           string        `json:"dataset_filename_word_regex,omitempty"`
-	DatasetFilenameJoinString          string        `json:"dataset_filename_join_string,omitempty"`
-	TrainingImageRepeatsPerEpoch       float32       `json:"training_image_repeats_per_epoch,omitempty"`
-	TrainingWriteCsvEvery              float32       `json:"training_write_csv_every,omitempty"`
-	TrainingXattentionOptimizations    bool          `json:"training_xattention_optimizations,omitempty"`
-	TrainingEnableTensorboard          bool          `json:"training_enable_tensorboard,omitempty"`
-	TrainingTensorboardSaveImages      bool          `json:"training_tensorboard_save_images,omitempty"`
-	TrainingTensorboardFlushEvery      float32       `json:"training_tensorboard_flush_every,omitempty"`
-	SdCheckpointCache                  float32       `json:"sd_checkpoint_cache,omitempty"`
-	SdVaeCheckpointCache               float32       `json:"sd_vae_checkpoint_cache,omitempty"`
-	SdVae                              string        `json:"sd_vae,omitempty"`
-	SdVaeAsDefault                     bool          `json:"sd_vae_as_default,omitempty"`
-	InpaintingMaskWeight               float32       `json:"inpainting_mask_weight,omitempty"`
-	InitialNoiseMultiplier             float32       `json:"initial_noise_multiplier,omitempty"`
-	Img2ImgColorCorrection             bool          `json:"img2img_color_correction,omitempty"`
-	Img2ImgFixSteps                    bool          `json:"img2img_fix_steps,omitempty"`
-	Img2ImgBackgroundColor             string        `json:"img2img_background_color,omitempty"`
-	EnableQuantization                 bool          `json:"enable_quantization,omitempty"`
-	EnableEmphasis                     bool          `json:"enable_emphasis,omitempty"`
-	EnableBatchSeeds                   bool          `json:"enable_batch_seeds,omitempty"`
-	CommaPaddingBacktrack              float32       `json:"comma_padding_backtrack,omitempty"`
-	CLIPStopAtLastLayers               float32       `json:"CLIP_stop_at_last_layers,omitempty"`
-	UpcastAttn                         bool          `json:"upcast_attn,omitempty"`
-	UseOldEmphasisImplementation       bool          `json:"use_old_emphasis_implementation,omitempty"`
-	UseOldKarrasSchedulerSigmas        bool          `json:"use_old_karras_scheduler_sigmas,omitempty"`
-	NoDpmppSdeBatchDeterminism         bool          `json:"no_dpmpp_sde_batch_determinism,omitempty"`
-	UseOldHiresFixWidthHeight          bool          `json:"use_old_hires_fix_width_height,omitempty"`
-	InterrogateKeepModelsInMemory      bool          `json:"interrogate_keep_models_in_memory,omitempty"`
-	InterrogateReturnRanks             bool          `json:"interrogate_return_ranks,omitempty"`
-	InterrogateClipNumBeams            float32       `json:"interrogate_clip_num_beams,omitempty"`
-	InterrogateClipMinLength           float32       `json:"interrogate_clip_min_length,omitempty"`
-	InterrogateClipMaxLength           float32       `json:"interrogate_clip_max_length,omitempty"`
-	InterrogateClipDictLimit           float32       `json:"interrogate_clip_dict_limit,omitempty"`
-	InterrogateClipSkipCategories      []interface{} `json:"interrogate_clip_skip_categories,omitempty"`
-	InterrogateDeepbooruScoreThreshold float32       `json:"interrogate_deepbooru_score_threshold,omitempty"`
-	DeepbooruSortAlpha                 bool          `json:"deepbooru_sort_alpha,omitempty"`
-	DeepbooruUseSpaces                 bool          `json:"deepbooru_use_spaces,omitempty"`
-	DeepbooruEscape                    bool          `json:"deepbooru_escape,omitempty"`
-	DeepbooruFilterTags                string        `json:"deepbooru_filter_tags,omitempty"`
-	ExtraNetworksDefaultView           string        `json:"extra_networks_default_view,omitempty"`
-	ExtraNetworksDefaultMultiplier     float32       `json:"extra_networks_default_multiplier,omitempty"`
-	SdHypernetwork                     string        `json:"sd_hypernetwork,omitempty"`
-	SdLora                             string        `json:"sd_lora,omitempty"`
-	LoraApplyToOutputs                 bool          `json:"lora_apply_to_outputs,omitempty"`
-	ReturnGrid                         bool          `json:"return_grid,omitempty"`
-	DoNotShowImages                    bool          `json:"do_not_show_images,omitempty"`
-	AddModelHashToInfo                 bool          `json:"add_model_hash_to_info,omitempty"`
-	AddModelNameToInfo                 bool          `json:"add_model_name_to_info,omitempty"`
-	DisableWeightsAutoSwap             bool          `json:"disable_weights_auto_swap,omitempty"`
-	SendSeed                           bool          `json:"send_seed,omitempty"`
-	SendSize                           bool          `json:"send_size,omitempty"`
-	Font                               string        `json:"font,omitempty"`
-	JsModalLightbox                    bool          `json:"js_modal_lightbox,omitempty"`
-	JsModalLightboxInitiallyZoomed     bool          `json:"js_modal_lightbox_initially_zoomed,omitempty"`
-	ShowProgressInTitle                bool          `json:"show_progress_in_title,omitempty"`
-	SamplersInDropdown                 bool          `json:"samplers_in_dropdown,omitempty"`
-	DimensionsAndBatchTogether         bool          `json:"dimensions_and_batch_together,omitempty"`
-	KeyeditPrecisionAttention          float32       `json:"keyedit_precision_attention,omitempty"`
-	KeyeditPrecisionExtra              float32       `json:"keyedit_precision_extra,omitempty"`
-	Quicksettings                      string        `json:"quicksettings,omitempty"`
-	UIReorder                          string        `json:"ui_reorder,omitempty"`
-	UIExtraNetworksTabReorder          string        `json:"ui_extra_networks_tab_reorder,omitempty"`
-	Localization                       string        `json:"localization,omitempty"`
-	ShowProgressbar                    bool          `json:"show_progressbar,omitempty"`
-	LivePreviewsEnable                 bool          `json:"live_previews_enable,omitempty"`
-	ShowProgressGrid                   bool          `json:"show_progress_grid,omitempty"`
-	ShowProgressEveryNSteps            float32       `json:"show_progress_every_n_steps,omitempty"`
-	ShowProgressType                   string        `json:"show_progress_type,omitempty"`
-	LivePreviewContent                 string        `json:"live_preview_content,omitempty"`
-	LivePreviewRefreshPeriod           float32       `json:"live_preview_refresh_period,omitempty"`
-	HideSamplers                       []interface{} `json:"hide_samplers,omitempty"`
-	EtaDdim                            float32       `json:"eta_ddim,omitempty"`
-	EtaAncestral                       float32       `json:"eta_ancestral,omitempty"`
-	DdimDiscretize                     string        `json:"ddim_discretize,omitempty"`
-	SChurn                             float32       `json:"s_churn,omitempty"`
-	STmin                              float32       `json:"s_tmin,omitempty"`
-	SNoise                             float32       `json:"s_noise,omitempty"`
-	EtaNoiseSeedDelta                  float32       `json:"eta_noise_seed_delta,omitempty"`
-	AlwaysDiscardNextToLastSigma       bool          `json:"always_discard_next_to_last_sigma,omitempty"`
-	PostprocessingEnableInMainUI       []interface{} `json:"postprocessing_enable_in_main_ui,omitempty"`
-	PostprocessingOperationOrder       []interface{} `json:"postprocessing_operation_order,omitempty"`
-	UpscalingMaxImagesInCache          float32       `json:"upscaling_max_images_in_cache,omitempty"`
-	DisabledExtensions                 []interface{} `json:"disabled_extensions,omitempty"`
-	SdCheckpointHash                   string        `json:"sd_checkpoint_hash,omitempty"`
+	return res, nil
 }
 
 func (c *Client) GetOptions(ctx context.Context) (*OptionsResponse, error) {
@@ -564,6 +559,138 @@ func (c *Client) GetModels(ctx context.Context) ([]*ModelsResponse, error) {
 	return res, nil
 }
 
+type SamplersResponse struct {
+	Name    string            `json:"name"`
+	Aliases []string          `json:"aliases"`
+	Options map[string]string `json:"options"`
+}
+
+func (c *Client) GetSamplers(ctx context.Context) ([]*SamplersResponse, error) {
+	res := []*SamplersResponse{}
+	if err := c.doReq(ctx, "/samplers", http.MethodGet, nil, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type UpscalersResponse struct {
+	Name      string  `json:"name"`
+	ModelName string  `json:"model_name"`
+	ModelPath string  `json:"model_path"`
+	ModelURL  string  `json:"model_url"`
+	Scale     float32 `json:"scale"`
+}
+
+type SchedulersResponse struct {
+	Name           string   `json:"name"`
+	Label          string   `json:"label"`
+	Aliases        []string `json:"aliases"`
+	DefaultRho     float32  `json:"default_rho"`
+	NeedInnerModel bool     `json:"need_inner_model"`
+}
+
+// GetSchedulers lists the noise schedulers, servers older than WebUI 1.9 answer 404.
+func (c *Client) GetSchedulers(ctx context.Context) ([]*SchedulersResponse, error) {
+	res := []*SchedulersResponse{}
+	if err := c.doReq(ctx, "/schedulers", http.MethodGet, nil, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (c *Client) GetUpscalers(ctx context.Context) ([]*UpscalersResponse, error) {
+	res := []*UpscalersResponse{}
+	if err := c.doReq(ctx, "/upscalers", http.MethodGet, nil, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type VAEsResponse struct {
+	ModelName string `json:"model_name"`
+	Filename  string `json:"filename"`
+}
+
+func (c *Client) GetVAEs(ctx context.Context) ([]*VAEsResponse, error) {
+	res := []*VAEsResponse{}
+	if err := c.doReq(ctx, "/sd-vae", http.MethodGet, nil, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type ScriptsResponse struct {
+	Txt2Img []string `json:"txt2img"`
+	Img2Img []string `json:"img2img"`
+}
+
+func (c *Client) GetScripts(ctx context.Context) (*ScriptsResponse, error) {
+	res := new(ScriptsResponse)
+	if err := c.doReq(ctx, "/scripts", http.MethodGet, nil, http.StatusOK, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type LorasResponse struct {
+	Name     string         `json:"name"`
+	Alias    string         `json:"alias"`
+	Path     string         `json:"path"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+func (c *Client) GetLoras(ctx context.Context) ([]*LorasResponse, error) {
+	res := []*LorasResponse{}
+	if err := c.doReq(ctx, "/loras", http.MethodGet, nil, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type HypernetworksResponse struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+func (c *Client) GetHypernetworks(ctx context.Context) ([]*HypernetworksResponse, error) {
+	res := []*HypernetworksResponse{}
+	if err := c.doReq(ctx, "/hypernetworks", http.MethodGet, nil, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type Embedding struct {
+	Step             int    `json:"step"`
+	SDCheckpoint     string `json:"sd_checkpoint"`
+	SDCheckpointName string `json:"sd_checkpoint_name"`
+	Shape            int    `json:"shape"`
+	Vectors          int    `json:"vectors"`
+}
+
+// EmbeddingsResponse holds the textual inversion embeddings by name, Skipped ones are
+// incompatible with the loaded model.
+type EmbeddingsResponse struct {
+	Loaded  map[string]*Embedding `json:"loaded"`
+	Skipped map[string]*Embedding `json:"skipped"`
+}
+
+func (c *Client) GetEmbeddings(ctx context.Context) (*EmbeddingsResponse, error) {
+	res := new(EmbeddingsResponse)
+	if err := c.doReq(ctx, "/embeddings", http.MethodGet, nil, http.StatusOK, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 type MemoryResponse struct {
 	RAM struct {
 		Free  int64 `json:"free"`
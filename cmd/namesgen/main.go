@@ -0,0 +1,143 @@
+// Command namesgen generates constants for the sampler, upscaler and scheduler names of a
+// running WebUI, so a project pins the names its deployment offers and a typo or a removed
+// sampler fails at compile time. Use it from a go:generate directive such as
+//
+//	//go:generate go run github.com/shallowclouds/go-sd-webui-cli/cmd/namesgen -url http://sd:7860 -out sdnames_gen.go
+//
+// The constants are named after the kind and the name, such as SamplerDPMPP2MKarras for
+// "DPM++ 2M Karras", see -prefix to namespace them.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+func main() {
+	var (
+		url      = flag.String("url", "http://127.0.0.1:7860", "URL of a WebUI started with --api")
+		user     = flag.String("user", "", "username of the API basic auth")
+		password = flag.String("password", "", "password of the API basic auth")
+		pkg      = flag.String("pkg", os.Getenv("GOPACKAGE"), "package name of the generated file, the package of the go:generate directive by default")
+		out      = flag.String("out", "", "generated file")
+		prefix   = flag.String("prefix", "", "prefix of the constant names")
+	)
+	flag.Parse()
+
+	if err := run(*url, *user, *password, *pkg, *out, *prefix); err != nil {
+		fmt.Fprintln(os.Stderr, "namesgen:", err)
+		os.Exit(1)
+	}
+}
+
+// group is a const block of the generated file.
+type group struct {
+	kind  string
+	names []string
+}
+
+func run(url, user, password, pkg, out, prefix string) error {
+	if len(out) == 0 || len(pkg) == 0 {
+		return fmt.Errorf("-out and -pkg are required")
+	}
+	cli, err := sdcli.NewClient(url, user, password, &http.Client{Timeout: 30 * time.Second})
+	if err != nil {
+		return err
+	}
+	groups, err := fetch(context.Background(), cli)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by namesgen from %s; DO NOT EDIT.\n\npackage %s\n", url, pkg)
+	used := map[string]bool{}
+	for _, g := range groups {
+		if len(g.names) == 0 {
+			continue
+		}
+		fmt.Fprintf(buf, "\n// Names of the %ss of the server.\nconst (\n", strings.ToLower(g.kind))
+		for _, name := range g.names {
+			fmt.Fprintf(buf, "\t%s = %q\n", ident(prefix+g.kind, name, used), name)
+		}
+		buf.WriteString(")\n")
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return os.WriteFile(out, src, 0o644)
+}
+
+// fetch lists the names of the server, the schedulers are skipped on servers without them.
+func fetch(ctx context.Context, cli *sdcli.Client) ([]*group, error) {
+	samplers, err := cli.GetSamplers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get samplers: %w", err)
+	}
+	upscalers, err := cli.GetUpscalers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upscalers: %w", err)
+	}
+	schedulers, err := cli.GetSchedulers(ctx)
+	var apiErr *sdcli.Error
+	if err != nil && !(errors.As(err, &apiErr) && apiErr.Response != nil && apiErr.Response.StatusCode == http.StatusNotFound) {
+		return nil, fmt.Errorf("failed to get schedulers: %w", err)
+	}
+
+	groups := []*group{{kind: "Sampler"}, {kind: "Upscaler"}, {kind: "Scheduler"}}
+	for _, s := range samplers {
+		groups[0].names = append(groups[0].names, s.Name)
+	}
+	for _, u := range upscalers {
+		if u.Name != "None" {
+			groups[1].names = append(groups[1].names, u.Name)
+		}
+	}
+	for _, s := range schedulers {
+		groups[2].names = append(groups[2].names, s.Name)
+	}
+	return groups, nil
+}
+
+// ident returns the constant name of name, such as SamplerDPMPP2MKarras for "DPM++ 2M Karras",
+// numbered when the name of another constant is the same.
+func ident(prefix, name string, used map[string]bool) string {
+	b := strings.Builder{}
+	b.WriteString(prefix)
+	upper := true
+	for _, r := range strings.ReplaceAll(name, "++", "PP") {
+		switch {
+		case r == '+':
+			b.WriteRune('P')
+			upper = true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+
+	id := b.String()
+	for i := 2; used[id]; i++ {
+		id = fmt.Sprintf("%s%d", b.String(), i)
+	}
+	used[id] = true
+	return id
+}
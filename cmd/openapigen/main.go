@@ -0,0 +1,473 @@
+// Command openapigen generates Go structs from the OpenAPI schema of a running WebUI, see
+// generate.go in the root package for the go:generate directives.
+//
+// The fields of a struct follow the properties of its schema. Fields the package already declares
+// keep their Go name and comment, so regenerating does not break callers relying on established
+// names, and fields tagged json:"-" are kept as is. Field types come from the schema, except the
+// enum types and the types the schema gets wrong, which are listed in typeOverrides. The fields
+// of extraFields follow, unless the schema has them, for the options of newer or forked servers.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+func main() {
+	var (
+		url     = flag.String("url", "http://127.0.0.1:7860/openapi.json", "URL of the OpenAPI schema of a WebUI started with --api")
+		file    = flag.String("file", "", "read the schema from a file instead of -url")
+		pkg     = flag.String("pkg", "sdcli", "package name of the generated file")
+		dir     = flag.String("dir", ".", "directory of the package, scanned for the existing declarations")
+		out     = flag.String("out", "", "generated file, relative to -dir")
+		typeMap = flag.String("types", "", "comma separated Schema=GoType pairs to generate")
+		ptrs    = flag.Bool("pointers", false, "declare scalar fields as pointers, so false and zero values are sent")
+	)
+	flag.Parse()
+
+	if err := run(*url, *file, *pkg, *dir, *out, *typeMap, *ptrs); err != nil {
+		fmt.Fprintln(os.Stderr, "openapigen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(url, file, pkg, dir, out, typeMap string, ptrs bool) error {
+	if len(out) == 0 || len(typeMap) == 0 {
+		return fmt.Errorf("-out and -types are required")
+	}
+	targets, err := parseTypes(typeMap)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if len(file) != 0 {
+		data, err = os.ReadFile(file)
+	} else {
+		data, err = fetch(url)
+	}
+	if err != nil {
+		return err
+	}
+
+	schemas, err := parseSchemas(data)
+	if err != nil {
+		return err
+	}
+	existing, err := parseExisting(dir, filepath.Base(out), targets)
+	if err != nil {
+		return err
+	}
+
+	g := &generator{schemas: schemas, names: map[string]string{}, pointers: ptrs}
+	for _, t := range targets {
+		g.names[t.schema] = t.goType
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by openapigen; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	body := &bytes.Buffer{}
+	for _, t := range targets {
+		if err := g.writeStruct(body, t, existing[t.goType]); err != nil {
+			return err
+		}
+	}
+	if bytes.Contains(body.Bytes(), []byte("json.")) {
+		buf.WriteString("import \"encoding/json\"\n\n")
+	}
+	buf.Write(body.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, out), src, 0o644)
+}
+
+type target struct {
+	schema, goType string
+}
+
+func parseTypes(s string) ([]target, error) {
+	var targets []target
+	for _, pair := range strings.Split(s, ",") {
+		schema, goType, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || len(schema) == 0 || len(goType) == 0 {
+			return nil, fmt.Errorf("invalid type pair %q, expected Schema=GoType", pair)
+		}
+		targets = append(targets, target{schema: schema, goType: goType})
+	}
+	return targets, nil
+}
+
+func fetch(url string) ([]byte, error) {
+	cli := &http.Client{Timeout: 30 * time.Second}
+	resp, err := cli.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schema: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schema: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch schema: %s", resp.Status)
+	}
+	return data, nil
+}
+
+// schema is the subset of a JSON schema used by the FastAPI models of WebUI.
+type schema struct {
+	Type        string             `json:"type"`
+	Ref         string             `json:"$ref"`
+	Description string             `json:"description"`
+	Items       *schema            `json:"items"`
+	AllOf       []*schema          `json:"allOf"`
+	AnyOf       []*schema          `json:"anyOf"`
+	Properties  map[string]*schema `json:"properties"`
+
+	// order is the order of Properties in the document.
+	order []string
+}
+
+func (s *schema) UnmarshalJSON(data []byte) error {
+	type plain schema
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+
+	var raw struct {
+		Properties json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw.Properties) == 0 {
+		return err
+	}
+	order, err := objectKeys(raw.Properties)
+	s.order = order
+	return err
+}
+
+// objectKeys returns the keys of a JSON object in the document order, lost by maps.
+func objectKeys(data []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, tok.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+func parseSchemas(data []byte) (map[string]*schema, error) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]*schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	return doc.Components.Schemas, nil
+}
+
+// field is a declared struct field.
+type field struct {
+	name, typ, doc string
+	tag            string
+}
+
+// declaration is a struct type the package declares, its fields by JSON name.
+type declaration struct {
+	doc    string
+	fields map[string]*field
+	// kept are the fields tagged json:"-".
+	kept []*field
+}
+
+// parseExisting reads the declarations of the target types from the package, the generated
+// file included.
+func parseExisting(dir, out string, targets []target) (map[string]*declaration, error) {
+	wanted := map[string]bool{}
+	for _, t := range targets {
+		wanted[t.goType] = true
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse package: %w", err)
+	}
+
+	decls := map[string]*declaration{}
+	for _, p := range pkgs {
+		for name, f := range p.Files {
+			for _, d := range f.Decls {
+				gen, ok := d.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok || !wanted[ts.Name.Name] {
+						continue
+					}
+					if filepath.Base(name) != out {
+						fmt.Fprintf(os.Stderr, "openapigen: %s is declared in %s, remove it after generating\n", ts.Name.Name, name)
+					}
+					decls[ts.Name.Name] = parseDeclaration(gen, ts, st)
+				}
+			}
+		}
+	}
+	return decls, nil
+}
+
+func parseDeclaration(gen *ast.GenDecl, ts *ast.TypeSpec, st *ast.StructType) *declaration {
+	d := &declaration{fields: map[string]*field{}}
+	if doc := ts.Doc; doc != nil {
+		d.doc = doc.Text()
+	} else if gen.Doc != nil {
+		d.doc = gen.Doc.Text()
+	}
+
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 || f.Tag == nil {
+			continue
+		}
+		tag := reflect.StructTag(strings.Trim(f.Tag.Value, "`"))
+		jsonName, _, _ := strings.Cut(tag.Get("json"), ",")
+		fd := &field{name: f.Names[0].Name, typ: types.ExprString(f.Type), doc: f.Doc.Text(), tag: string(tag)}
+		if jsonName == "-" {
+			d.kept = append(d.kept, fd)
+		} else {
+			d.fields[jsonName] = fd
+		}
+	}
+	return d
+}
+
+// typeOverrides are the Go types of the fields whose schema type is not the one to declare, by
+// Go type and JSON name. Pointers are not added to them.
+var typeOverrides = map[string]map[string]string{
+	"Txt2ImageOption": {
+		"override_settings": "Overrides",
+		"alwayson_scripts":  "AlwaysonScripts",
+	},
+	"Img2ImgOption": {
+		"resize_mode":            "ResizeMode",
+		"inpainting_fill":        "InpaintingFill",
+		"inpainting_mask_invert": "MaskInvert",
+		"override_settings":      "Overrides",
+		"alwayson_scripts":       "AlwaysonScripts",
+	},
+	"ExtraSingleImgOption": {
+		"resize_mode": "ExtrasResizeMode",
+		// Fractions such as a resize of 1.5, float64 like ExtraBatchImgOption.
+		"gfpgan_visibility":            "float64",
+		"codeformer_visibility":        "float64",
+		"codeformer_weight":            "float64",
+		"upscaling_resize":             "float64",
+		"extras_upscaler_2_visibility": "float64",
+	},
+}
+
+// extraField is a field declared whether the schema has it or not.
+type extraField struct {
+	json, name, typ, doc string
+}
+
+// extraFields are the fields of the servers newer than or diverging from the schema generated
+// from, by Go type, in order. The schema declares them instead when it has them.
+var extraFields = map[string][]extraField{
+	"OptionsResponse": {
+		{json: "token_merging_ratio", name: "TokenMergingRatio", typ: "*float32"},
+		{json: "token_merging_ratio_img2img", name: "TokenMergingRatioImg2Img", typ: "*float32"},
+		{json: "token_merging_ratio_hr", name: "TokenMergingRatioHR", typ: "*float32"},
+		{json: "s_min_uncond", name: "SMinUncond", typ: "*float32"},
+		{json: "pad_cond_uncond", name: "PadCondUncond", typ: "*bool"},
+		{json: "persistent_cond_cache", name: "PersistentCondCache", typ: "*bool"},
+		{json: "batch_cond_uncond", name: "BatchCondUncond", typ: "*bool"},
+		{json: "fp8_storage", name: "FP8Storage", typ: "*string"},
+		{json: "cache_fp16_weight", name: "CacheFP16Weight", typ: "*bool"},
+		{json: "forge_preset", name: "ForgePreset", typ: "*string", doc: "Forge only options, ForgeAdditionalModules are the paths of the VAE and text encoders\nloaded along with the checkpoint, see GetSdModules."},
+		{json: "forge_additional_modules", name: "ForgeAdditionalModules", typ: "[]string"},
+		{json: "forge_unet_storage_dtype", name: "ForgeUnetStorageDtype", typ: "*string"},
+		{json: "forge_inference_memory", name: "ForgeInferenceMemory", typ: "*float32"},
+	},
+	"Txt2ImageOption": {
+		{json: "s_min_uncond", name: "SMinUncond", typ: "float32"},
+	},
+	"Img2ImgOption": {
+		{json: "s_min_uncond", name: "SMinUncond", typ: "float32"},
+	},
+}
+
+type generator struct {
+	schemas map[string]*schema
+	// names maps schema names to Go type names.
+	names    map[string]string
+	pointers bool
+}
+
+func (g *generator) writeStruct(w io.Writer, t target, existing *declaration) error {
+	s, ok := g.schemas[t.schema]
+	if !ok {
+		names := make([]string, 0, len(g.schemas))
+		for name := range g.schemas {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("no schema %s, available: %s", t.schema, strings.Join(names, ", "))
+	}
+	if existing == nil {
+		existing = &declaration{fields: map[string]*field{}}
+	}
+
+	writeComment(w, existing.doc, "")
+	fmt.Fprintf(w, "type %s struct {\n", t.goType)
+	for _, name := range s.order {
+		prop := s.Properties[name]
+		f, ok := existing.fields[name]
+		if !ok {
+			f = &field{name: goName(name), tag: fmt.Sprintf(`json:"%s,omitempty"`, name)}
+		}
+		if len(prop.Description) != 0 {
+			f.doc = prop.Description
+		}
+		if typ, ok := typeOverrides[t.goType][name]; ok {
+			f.typ = typ
+		} else if f.typ = g.goType(prop); g.pointers {
+			f.typ = pointer(f.typ)
+		}
+		writeComment(w, f.doc, "\t")
+		fmt.Fprintf(w, "\t%s %s `%s`\n", f.name, f.typ, f.tag)
+	}
+	for _, f := range extraFields[t.goType] {
+		if _, ok := s.Properties[f.json]; ok {
+			continue
+		}
+		writeComment(w, f.doc, "\t")
+		fmt.Fprintf(w, "\t%s %s `json:\"%s,omitempty\"`\n", f.name, f.typ, f.json)
+	}
+	for i, f := range existing.kept {
+		if i == 0 {
+			fmt.Fprintln(w)
+		}
+		writeComment(w, f.doc, "\t")
+		fmt.Fprintf(w, "\t%s %s `%s`\n", f.name, f.typ, f.tag)
+	}
+	fmt.Fprint(w, "}\n\n")
+	return nil
+}
+
+// pointer returns the pointer type of a scalar type, other types distinguish unset values already.
+func pointer(typ string) string {
+	for _, prefix := range []string{"*", "[]", "map[", "interface{}", "any", "json."} {
+		if strings.HasPrefix(typ, prefix) {
+			return typ
+		}
+	}
+	return "*" + typ
+}
+
+func writeComment(w io.Writer, text, indent string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if line = strings.TrimSpace(line); len(line) != 0 {
+			fmt.Fprintf(w, "%s// %s\n", indent, line)
+		}
+	}
+}
+
+// goType maps a property schema to a Go type, numbers are float32 like the hand written structs.
+func (g *generator) goType(s *schema) string {
+	if len(s.Ref) != 0 {
+		return "*" + g.refName(s.Ref)
+	}
+	for _, alts := range [][]*schema{s.AllOf, s.AnyOf} {
+		for _, alt := range alts {
+			if alt.Type != "null" {
+				return g.goType(alt)
+			}
+		}
+	}
+
+	switch s.Type {
+	case "string":
+		return "string"
+	case "boolean":
+		return "bool"
+	case "integer":
+		return "int"
+	case "number":
+		return "float32"
+	case "array":
+		if s.Items == nil {
+			return "[]interface{}"
+		}
+		return "[]" + g.goType(s.Items)
+	case "object":
+		return "map[string]interface{}"
+	default:
+		return "interface{}"
+	}
+}
+
+func (g *generator) refName(ref string) string {
+	name := ref[strings.LastIndexByte(ref, '/')+1:]
+	if goType, ok := g.names[name]; ok {
+		return goType
+	}
+	return name
+}
+
+var initialisms = map[string]string{
+	"id": "ID", "ui": "UI", "url": "URL", "api": "API", "hr": "HR",
+	"fp8": "FP8", "fp16": "FP16", "txt2img": "Txt2Img", "img2img": "Img2Img",
+}
+
+// goName converts a snake case JSON name to a Go name, parts already in upper case such as
+// the ESRGAN of ESRGAN_tile are kept.
+func goName(jsonName string) string {
+	b := &strings.Builder{}
+	for _, part := range strings.Split(jsonName, "_") {
+		if len(part) == 0 {
+			continue
+		}
+		if s, ok := initialisms[part]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shallowclouds/go-sd-webui-cli/batch"
+)
+
+func newBatchCmd(g *globalFlags) *cobra.Command {
+	var (
+		concurrency int
+		reportFile  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "batch <job-file>",
+		Short: "Run the generations described by a YAML or JSON job file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := batch.Load(args[0])
+			if err != nil {
+				return err
+			}
+
+			cli, err := g.client()
+			if err != nil {
+				return err
+			}
+
+			r := &batch.Runner{
+				Client:      cli,
+				Concurrency: concurrency,
+				OutDir:      g.outDir,
+			}
+			report := r.Run(cmd.Context(), f)
+
+			out := cmd.OutOrStdout()
+			if len(reportFile) != 0 {
+				w, err := os.Create(reportFile)
+				if err != nil {
+					return fmt.Errorf("failed to create report: %w", err)
+				}
+				defer w.Close()
+				out = w
+			}
+			if err := printJSON(out, report); err != nil {
+				return err
+			}
+
+			if report.Failed != 0 {
+				return fmt.Errorf("%d of %d jobs failed", report.Failed, len(report.Results))
+			}
+
+			return nil
+		},
+	}
+	cmd.Flags().IntVarP(&concurrency, "concurrency", "c", 0, "jobs to run at the same time, overrides the job file")
+	cmd.Flags().StringVar(&reportFile, "report", "", "write the JSON report to this file instead of stdout")
+
+	return cmd
+}
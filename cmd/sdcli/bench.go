@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// benchPrompt is the fixed prompt of the benchmark generations, so results compare across runs.
+const benchPrompt = "a photograph of an astronaut riding a horse, highly detailed"
+
+// benchReport is the JSON output of bench, one result per sampler, size and batch size.
+type benchReport struct {
+	Time    time.Time      `json:"time"`
+	URL     string         `json:"url"`
+	Model   string         `json:"model,omitempty"`
+	Steps   int            `json:"steps"`
+	Seed    int            `json:"seed"`
+	Runs    int            `json:"runs"`
+	Results []*benchResult `json:"results"`
+}
+
+type benchResult struct {
+	Sampler   string `json:"sampler"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	BatchSize int    `json:"batch_size"`
+	// WallTime is the mean duration of a generation in seconds.
+	WallTime     float64 `json:"wall_time"`
+	ItPerSec     float64 `json:"it_per_sec"`
+	ImagesPerSec float64 `json:"images_per_sec"`
+	// VRAMPeak is the highest GPU memory usage in bytes sampled from /memory during the runs.
+	VRAMPeak int64  `json:"vram_peak"`
+	Error    string `json:"error,omitempty"`
+}
+
+func newBenchCmd(g *globalFlags) *cobra.Command {
+	var (
+		samplers    []string
+		sizes       []string
+		batchSizes  []int
+		steps       int
+		seed        int
+		runs        int
+		warmup      bool
+		memInterval time.Duration
+		asJSON      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Measure the generation throughput of the server",
+		Long: "Run txt2img with a fixed prompt and seed for every combination of samplers, sizes and batch sizes,\n" +
+			"reporting the iterations per second, wall time and VRAM peak.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			type size struct{ width, height int }
+			parsed := make([]size, len(sizes))
+			for i, s := range sizes {
+				w, h, err := parseSize(s)
+				if err != nil {
+					return err
+				}
+				parsed[i] = size{w, h}
+			}
+			if runs <= 0 {
+				runs = 1
+			}
+
+			cli, err := g.client()
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+
+			report := &benchReport{Time: time.Now(), URL: g.url, Steps: steps, Seed: seed, Runs: runs}
+			if opts, err := cli.GetOptions(ctx); err == nil {
+				report.Model = sdcli.Deref(opts.SdModelCheckpoint)
+			}
+
+			for _, sampler := range samplers {
+				for _, s := range parsed {
+					for _, batchSize := range batchSizes {
+						opt := sdcli.Txt2ImageOption{
+							Prompt:      benchPrompt,
+							Steps:       steps,
+							Width:       s.width,
+							Height:      s.height,
+							SamplerName: sampler,
+							Seed:        seed,
+							BatchSize:   batchSize,
+							NIter:       1,
+						}
+						res := runBench(ctx, cli, opt, runs, warmup, memInterval)
+						report.Results = append(report.Results, res)
+						if !asJSON {
+							fmt.Fprintf(cmd.ErrOrStderr(), "%s %dx%d batch %d: %.2f it/s\n",
+								sampler, s.width, s.height, batchSize, res.ItPerSec)
+						}
+					}
+				}
+			}
+
+			if asJSON {
+				return printJSON(cmd.OutOrStdout(), report)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "SAMPLER\tSIZE\tBATCH\tIT/S\tIMAGES/S\tWALL\tVRAM PEAK\tERROR")
+			for _, r := range report.Results {
+				fmt.Fprintf(w, "%s\t%dx%d\t%d\t%.2f\t%.2f\t%.2fs\t%.0f MiB\t%s\n",
+					r.Sampler, r.Width, r.Height, r.BatchSize, r.ItPerSec, r.ImagesPerSec,
+					r.WallTime, float64(r.VRAMPeak)/(1<<20), r.Error)
+			}
+			return w.Flush()
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringSliceVar(&samplers, "samplers", []string{"Euler a", "DPM++ 2M"}, "samplers to benchmark")
+	flags.StringSliceVar(&sizes, "sizes", []string{"512x512", "768x768"}, "image sizes to benchmark as WIDTHxHEIGHT")
+	flags.IntSliceVar(&batchSizes, "batch-sizes", []int{1, 4}, "batch sizes to benchmark")
+	flags.IntVar(&steps, "steps", 20, "sampling steps")
+	flags.IntVar(&seed, "seed", 1234, "seed of every generation")
+	flags.IntVar(&runs, "runs", 3, "measured generations per combination")
+	flags.BoolVar(&warmup, "warmup", true, "run an unmeasured generation before each combination")
+	flags.DurationVar(&memInterval, "memory-interval", 250*time.Millisecond, "interval of the /memory samples")
+	flags.BoolVar(&asJSON, "json", false, "print the results as JSON")
+
+	return cmd
+}
+
+// runBench runs opt runs times, sampling the VRAM usage meanwhile.
+func runBench(ctx context.Context, cli *sdcli.Client, opt sdcli.Txt2ImageOption, runs int, warmup bool, memInterval time.Duration) *benchResult {
+	res := &benchResult{Sampler: opt.SamplerName, Width: opt.Width, Height: opt.Height, BatchSize: opt.BatchSize}
+
+	if warmup {
+		if _, err := cli.Txt2Img(ctx, opt); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+	}
+
+	memCtx, cancel := context.WithCancel(ctx)
+	samples := cli.WatchMemory(memCtx, memInterval)
+	done := make(chan int64)
+	go func() {
+		var peak int64
+		for s := range samples {
+			if s.Err == nil && s.Memory.Cuda.System.Used > peak {
+				peak = s.Memory.Cuda.System.Used
+			}
+		}
+		done <- peak
+	}()
+
+	var total time.Duration
+	for i := 0; i < runs; i++ {
+		start := time.Now()
+		if _, err := cli.Txt2Img(ctx, opt); err != nil {
+			res.Error = err.Error()
+			break
+		}
+		total += time.Since(start)
+	}
+	cancel()
+	res.VRAMPeak = <-done
+
+	if len(res.Error) != 0 || total <= 0 {
+		return res
+	}
+	res.WallTime = total.Seconds() / float64(runs)
+	res.ItPerSec = float64(opt.Steps) / res.WallTime
+	res.ImagesPerSec = float64(opt.BatchSize) / res.WallTime
+	return res
+}
+
+// parseSize parses WIDTHxHEIGHT.
+func parseSize(s string) (int, int, error) {
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if !ok || err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid size %q, want WIDTHxHEIGHT", s)
+	}
+	return width, height, nil
+}
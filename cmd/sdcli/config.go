@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/shallowclouds/go-sd-webui-cli/config"
+)
+
+// loadConfig loads the --config file, or the default config file if any, once.
+func (g *globalFlags) loadConfig() (*config.Config, error) {
+	if g.cfg != nil {
+		return g.cfg, nil
+	}
+
+	path := g.configFile
+	if len(path) == 0 {
+		path = config.DefaultPath()
+	}
+
+	var err error
+	if len(path) == 0 {
+		g.cfg, err = config.FromEnv()
+	} else {
+		g.cfg, err = config.Load(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Explicit flags win over the config, which wins over the flag defaults.
+	if g.flags.Changed("url") || len(g.cfg.URL) == 0 {
+		g.cfg.URL = g.url
+	}
+	if g.flags.Changed("user") || len(g.cfg.User) == 0 {
+		g.cfg.User = g.user
+	}
+	if g.flags.Changed("password") || len(g.cfg.Password) == 0 {
+		g.cfg.Password = g.password
+	}
+	if g.flags.Changed("timeout") || g.cfg.Timeout == 0 {
+		g.cfg.Timeout = config.Duration(g.timeout)
+	}
+
+	return g.cfg, nil
+}
+
+// applyDefaults sets the config defaults and the preset params on generation options,
+// except for the parameters whose flag was given.
+func (g *globalFlags) applyDefaults(flags *pflag.FlagSet, presetName string, opt any) error {
+	cfg, err := g.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	params := map[string]any{}
+	for k, v := range cfg.Defaults {
+		params[k] = v
+	}
+	if len(presetName) != 0 {
+		presetParams, err := cfg.Registry().Params(presetName, nil)
+		if err != nil {
+			return err
+		}
+		for k, v := range presetParams {
+			params[k] = v
+		}
+	}
+
+	data, err := json.Marshal(opt)
+	if err != nil {
+		return fmt.Errorf("failed to encode options: %w", err)
+	}
+	merged := map[string]any{}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return fmt.Errorf("failed to encode options: %w", err)
+	}
+	for k, v := range params {
+		if f := flags.Lookup(flagName(k)); f != nil && f.Changed {
+			continue
+		}
+		merged[k] = v
+	}
+
+	if data, err = json.Marshal(merged); err != nil {
+		return fmt.Errorf("failed to encode options: %w", err)
+	}
+	if err := json.Unmarshal(data, opt); err != nil {
+		return fmt.Errorf("invalid defaults: %w", err)
+	}
+	return nil
+}
+
+// flagName returns the flag of an API parameter.
+func flagName(param string) string {
+	if param == "sampler_name" {
+		return "sampler"
+	}
+	return strings.ReplaceAll(param, "_", "-")
+}
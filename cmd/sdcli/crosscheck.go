@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+	"github.com/shallowclouds/go-sd-webui-cli/crosscheck"
+)
+
+func newCrosscheckCmd(g *globalFlags) *cobra.Command {
+	var (
+		samplers []string
+		size     string
+		steps    int
+		seed     int
+		asJSON   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "crosscheck <other-url>",
+		Short: "Compare the outputs of the server with another one",
+		Long: "Run the same fixed seed txt2img on the server of --url and on other-url with every sampler, reporting\n" +
+			"the image differences, the samplers of a single server and the timings. The other server uses the same\n" +
+			"credentials. Fails when the images differ.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			width, height, err := parseSize(size)
+			if err != nil {
+				return err
+			}
+			cfg, err := g.loadConfig()
+			if err != nil {
+				return err
+			}
+			a, err := cfg.NewClient()
+			if err != nil {
+				return err
+			}
+			other := *cfg
+			other.URL = args[0]
+			b, err := other.NewClient()
+			if err != nil {
+				return err
+			}
+
+			report, err := crosscheck.Check(cmd.Context(), a, b, crosscheck.Options{
+				Txt2Img: sdcli.Txt2ImageOption{
+					Prompt: benchPrompt,
+					Steps:  steps,
+					Width:  width,
+					Height: height,
+					Seed:   seed,
+				},
+				Samplers: samplers,
+			})
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				if err := printJSON(cmd.OutOrStdout(), report); err != nil {
+					return err
+				}
+			} else {
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "SAMPLER\tMATCH\tSSIM\tDIFF\tTIME A\tTIME B\tDELTA\tERROR")
+				for _, r := range report.Results {
+					ssim, diff := "-", "-"
+					if len(r.Diffs) != 0 {
+						ssim = fmt.Sprintf("%.4f", r.Diffs[0].SSIM)
+						diff = fmt.Sprintf("%.2f%%", r.Diffs[0].DiffRatio*100)
+					}
+					var timeA, timeB float64
+					if r.A != nil {
+						timeA, timeB = r.A.Duration.Seconds(), r.B.Duration.Seconds()
+					}
+					fmt.Fprintf(w, "%s\t%t\t%s\t%s\t%.2fs\t%.2fs\t%+.2fs\t%s\n",
+						r.Sampler, r.Match, ssim, diff, timeA, timeB, r.TimeDelta.Seconds(), r.Error)
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				if len(report.OnlyA) != 0 {
+					fmt.Fprintf(cmd.OutOrStdout(), "only on %s: %v\n", cfg.URL, report.OnlyA)
+				}
+				if len(report.OnlyB) != 0 {
+					fmt.Fprintf(cmd.OutOrStdout(), "only on %s: %v\n", args[0], report.OnlyB)
+				}
+			}
+
+			if !report.Match() {
+				return errors.New("server outputs differ")
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringSliceVar(&samplers, "samplers", []string{"Euler a", "DPM++ 2M"}, "samplers to compare")
+	flags.StringVar(&size, "size", "512x512", "image size as WIDTHxHEIGHT")
+	flags.IntVar(&steps, "steps", 20, "sampling steps")
+	flags.IntVar(&seed, "seed", crosscheck.DefaultSeed, "seed of every generation")
+	flags.BoolVar(&asJSON, "json", false, "print the report as JSON")
+
+	return cmd
+}
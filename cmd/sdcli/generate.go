@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// genFlags are the generation parameters shared by txt2img and img2img.
+type genFlags struct {
+	prompt         string
+	negativePrompt string
+	steps          int
+	cfgScale       float32
+	width, height  int
+	sampler        string
+	seed           int
+	batchSize      int
+	nIter          int
+	preset         string
+	snap           int
+}
+
+func (f *genFlags) register(flags *pflag.FlagSet) {
+	flags.StringVarP(&f.prompt, "prompt", "p", "", "prompt")
+	flags.StringVarP(&f.negativePrompt, "negative-prompt", "n", "", "negative prompt")
+	flags.IntVar(&f.steps, "steps", 20, "sampling steps")
+	flags.Float32Var(&f.cfgScale, "cfg-scale", 7, "CFG scale")
+	flags.IntVar(&f.width, "width", 512, "image width")
+	flags.IntVar(&f.height, "height", 512, "image height")
+	flags.StringVar(&f.sampler, "sampler", "", "sampler name, server default if empty")
+	flags.IntVar(&f.seed, "seed", -1, "seed, -1 for random")
+	flags.IntVar(&f.batchSize, "batch-size", 1, "images per batch")
+	flags.IntVar(&f.nIter, "n-iter", 1, "number of batches")
+	flags.StringVar(&f.preset, "preset", "", "named preset of parameters such as draft, quality or sdxl-default")
+	flags.IntVar(&f.snap, "snap", 0, "round the size to multiples of snap such as 8 or 64 for SDXL, 0 to keep it")
+}
+
+// reportSnapped prints the sizes changed by --snap.
+func reportSnapped(cmd *cobra.Command, adjs []sdcli.SizeAdjustment) {
+	for _, a := range adjs {
+		fmt.Fprintf(cmd.ErrOrStderr(), "snapped %s\n", a)
+	}
+}
+
+func newTxt2ImgCmd(g *globalFlags) *cobra.Command {
+	f := &genFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "txt2img",
+		Short: "Generate images from a prompt",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cli, err := g.client()
+			if err != nil {
+				return err
+			}
+
+			opt := sdcli.Txt2ImageOption{
+				Prompt:         f.prompt,
+				NegativePrompt: f.negativePrompt,
+				Steps:          f.steps,
+				CfgScale:       f.cfgScale,
+				Width:          f.width,
+				Height:         f.height,
+				SamplerName:    f.sampler,
+				Seed:           f.seed,
+				BatchSize:      f.batchSize,
+				NIter:          f.nIter,
+			}
+			if err := g.applyDefaults(cmd.Flags(), f.preset, &opt); err != nil {
+				return err
+			}
+			if f.snap > 0 {
+				reportSnapped(cmd, opt.SnapSize(f.snap))
+			}
+
+			api, err := g.recorded(cmd, cli)
+			if err != nil {
+				return err
+			}
+			var res *sdcli.Txt2ImageResponse
+			if err := g.withProgress(cmd, cli, func(ctx context.Context) (err error) {
+				res, err = api.Txt2Img(ctx, opt)
+				return err
+			}); err != nil {
+				return err
+			}
+
+			return writeImages(cmd.OutOrStdout(), g.outDir, "txt2img", res.RawImages, res.Info)
+		},
+	}
+	f.register(cmd.Flags())
+
+	return cmd
+}
+
+func newImg2ImgCmd(g *globalFlags) *cobra.Command {
+	var (
+		f                 = &genFlags{}
+		mask              string
+		maskFromAlpha     bool
+		denoisingStrength float32
+		resizeMode        int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "img2img <init-image>...",
+		Short: "Generate images from init images and a prompt",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cli, err := g.client()
+			if err != nil {
+				return err
+			}
+
+			opt := sdcli.Img2ImgOption{
+				Prompt:            f.prompt,
+				NegativePrompt:    f.negativePrompt,
+				Steps:             f.steps,
+				CfgScale:          f.cfgScale,
+				Width:             f.width,
+				Height:            f.height,
+				SamplerName:       f.sampler,
+				Seed:              f.seed,
+				BatchSize:         f.batchSize,
+				NIter:             f.nIter,
+				DenoisingStrength: denoisingStrength,
+				ResizeMode:        sdcli.ResizeMode(resizeMode),
+			}
+			if err := g.applyDefaults(cmd.Flags(), f.preset, &opt); err != nil {
+				return err
+			}
+			if f.snap > 0 {
+				reportSnapped(cmd, opt.SnapSize(f.snap))
+			}
+			for _, name := range args {
+				img, err := readImageFile(name)
+				if err != nil {
+					return err
+				}
+				opt.InitImages = append(opt.InitImages, img)
+			}
+			switch {
+			case maskFromAlpha && len(mask) != 0:
+				return errors.New("--mask and --mask-from-alpha are exclusive")
+			case maskFromAlpha:
+				if err := splitAlpha(&opt); err != nil {
+					return err
+				}
+			case len(mask) != 0:
+				if opt.Mask, err = readImageFile(mask); err != nil {
+					return err
+				}
+			}
+
+			api, err := g.recorded(cmd, cli)
+			if err != nil {
+				return err
+			}
+			var res *sdcli.Img2ImgResponse
+			if err := g.withProgress(cmd, cli, func(ctx context.Context) (err error) {
+				res, err = api.Img2Img(ctx, opt)
+				return err
+			}); err != nil {
+				return err
+			}
+
+			return writeImages(cmd.OutOrStdout(), g.outDir, "img2img", res.RawImages, res.Info)
+		},
+	}
+	f.register(cmd.Flags())
+	cmd.Flags().StringVar(&mask, "mask", "", "inpainting mask image")
+	cmd.Flags().BoolVar(&maskFromAlpha, "mask-from-alpha", false, "inpaint the transparent area of the first init image")
+	cmd.Flags().Float32Var(&denoisingStrength, "denoising-strength", 0.75, "denoising strength between 0 and 1")
+	cmd.Flags().IntVar(&resizeMode, "resize-mode", 0, "resize mode: 0 just resize, 1 crop and resize, 2 resize and fill, 3 latent upscale")
+
+	return cmd
+}
+
+func newUpscaleCmd(g *globalFlags) *cobra.Command {
+	var (
+		upscaler string
+		scale    float64
+	)
+
+	cmd := &cobra.Command{
+		Use:   "upscale <image>",
+		Short: "Upscale an image with the extras endpoint",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cli, err := g.client()
+			if err != nil {
+				return err
+			}
+
+			img, err := readImageFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			var res *sdcli.ExtraSingleImgResponse
+			if err := g.withProgress(cmd, cli, func(ctx context.Context) (err error) {
+				res, err = cli.ExtraSingleImg(ctx, sdcli.ExtraSingleImgOption{
+					UpscalingResize: scale,
+					Upscaler1:       upscaler,
+					Image:           img,
+				})
+				return err
+			}); err != nil {
+				return err
+			}
+
+			return writeImages(cmd.OutOrStdout(), g.outDir, "upscale", [][]byte{res.RawImage}, "")
+		},
+	}
+	cmd.Flags().StringVar(&upscaler, "upscaler", sdcli.UpscalerLanczos, "upscaler name")
+	cmd.Flags().Float64Var(&scale, "scale", 2, "upscale factor, fractional such as 1.5 allowed")
+
+	return cmd
+}
+
+// splitAlpha replaces the first init image of opt by its opaque base and masks its transparent
+// area, see sdcli.SplitAlpha.
+func splitAlpha(opt *sdcli.Img2ImgOption) error {
+	img, _, err := sdcli.Base642Img(opt.InitImages[0])
+	if err != nil {
+		return fmt.Errorf("failed to decode init image: %w", err)
+	}
+	base, mask := sdcli.SplitAlpha(img)
+	if opt.InitImages[0], err = sdcli.EncodeImage(image.Image(base)); err != nil {
+		return err
+	}
+	if opt.Mask, err = sdcli.EncodeImage(image.Image(mask)); err != nil {
+		return err
+	}
+	opt.InpaintingMaskInvert = sdcli.InpaintMasked
+	return nil
+}
+
+func readImageFile(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+
+	return sdcli.ImgBytes2Base64(data), nil
+}
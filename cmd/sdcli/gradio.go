@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+func newGradioCmd(g *globalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gradio <api-name> [data]",
+		Short: "Run a gradio function of the interface through the queue",
+		Long: `Run the gradio function named api-name with data, a JSON array of its inputs, through the
+gradio queue and print the JSON array of its outputs. With --tui the queue position and the
+progress pushed by the queue are shown instead of polled.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data := []any{}
+			if len(args) == 2 {
+				if err := json.Unmarshal([]byte(args[1]), &data); err != nil {
+					return fmt.Errorf("invalid data, expecting a JSON array: %w", err)
+				}
+			}
+
+			cli, err := g.client()
+			if err != nil {
+				return err
+			}
+			fnIndex, err := cli.GradioFnIndex(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			var out []json.RawMessage
+			if err := g.withQueueProgress(cmd, func(onEvent func(e *sdcli.QueueEvent)) (err error) {
+				out, err = cli.RunGradio(cmd.Context(), fnIndex, data, onEvent)
+				return err
+			}); err != nil {
+				return err
+			}
+
+			return printJSON(cmd.OutOrStdout(), out)
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+	"github.com/shallowclouds/go-sd-webui-cli/history"
+)
+
+// recorded returns api recording its generations to the --history file, if any.
+func (g *globalFlags) recorded(cmd *cobra.Command, api sdcli.API) (sdcli.API, error) {
+	if len(g.history) == 0 {
+		return api, nil
+	}
+	cfg, err := g.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return history.Wrap(api, history.NewFile(g.history),
+		history.WithBackend(cfg.URL),
+		history.WithErrorHandler(func(err error) {
+			fmt.Fprintln(cmd.ErrOrStderr(), "warning:", err)
+		}),
+	), nil
+}
+
+func (g *globalFlags) historyStore() (*history.File, error) {
+	if len(g.history) == 0 {
+		return nil, errors.New("no history file, set --history")
+	}
+	return history.NewFile(g.history), nil
+}
+
+// historyFilter are the flags selecting history entries.
+type historyFilter struct {
+	since   time.Duration
+	mode    string
+	model   string
+	backend string
+	failed  bool
+	limit   int
+}
+
+func (f *historyFilter) register(cmd *cobra.Command, limit int) {
+	flags := cmd.Flags()
+	flags.DurationVar(&f.since, "since", 0, "only the generations of this last duration, such as 24h")
+	flags.StringVar(&f.mode, "mode", "", "only txt2img or img2img generations")
+	flags.StringVar(&f.model, "model", "", "only the generations of this checkpoint")
+	flags.StringVar(&f.backend, "backend", "", "only the generations of this server URL")
+	flags.BoolVar(&f.failed, "failed", false, "only the failed generations")
+	flags.IntVar(&f.limit, "limit", limit, "most recent generations to include, 0 for all")
+}
+
+func (f *historyFilter) filter() history.Filter {
+	filter := history.Filter{
+		Mode:    history.Mode(f.mode),
+		Model:   f.model,
+		Backend: f.backend,
+		Failed:  f.failed,
+		Limit:   f.limit,
+	}
+	if f.since > 0 {
+		filter.Since = time.Now().Add(-f.since)
+	}
+	return filter
+}
+
+func newHistoryCmd(g *globalFlags) *cobra.Command {
+	var (
+		f      historyFilter
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List the recorded generations",
+		Long: "List the txt2img and img2img generations recorded to the --history file, oldest first.\n" +
+			"Use the subcommands to show the parameters of a generation, run it again or report the usage.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := g.historyStore()
+			if err != nil {
+				return err
+			}
+			entries, err := store.Query(f.filter())
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				return printJSON(cmd.OutOrStdout(), entries)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tTIME\tMODE\tMODEL\tDURATION\tIMAGES\tPROMPT")
+			for _, e := range entries {
+				summary := "error: " + e.Error
+				if !e.Failed() {
+					summary = entryPrompt(e)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+					e.ID, e.Time.Format(time.DateTime), e.Mode, e.Model,
+					e.Duration.Round(100*time.Millisecond), len(e.Outputs), truncate(summary, 60))
+			}
+			return w.Flush()
+		},
+	}
+	f.register(cmd, 20)
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the entries as JSON")
+
+	cmd.AddCommand(
+		newHistoryShowCmd(g),
+		newHistoryRerunCmd(g),
+		newHistoryUsageCmd(g),
+	)
+
+	return cmd
+}
+
+func newHistoryShowCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <id>",
+		Short: "Print a recorded generation as JSON",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := g.historyEntry(args[0])
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), e)
+		},
+	}
+}
+
+func newHistoryRerunCmd(g *globalFlags) *cobra.Command {
+	var (
+		sameSeed bool
+		mask     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rerun <id> [init-image]...",
+		Short: "Run a recorded generation again with the same settings",
+		Long: "Run a recorded generation again with the same settings, and the same seed with --same-seed.\n" +
+			"img2img generations need their init images, and --mask if they had one, as the history only keeps their hashes.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := g.historyEntry(args[0])
+			if err != nil {
+				return err
+			}
+			cli, err := g.client()
+			if err != nil {
+				return err
+			}
+			api, err := g.recorded(cmd, cli)
+			if err != nil {
+				return err
+			}
+
+			var images [][]byte
+			var info string
+			switch e.Mode {
+			case history.ModeTxt2Img:
+				if len(args) > 1 {
+					return errors.New("txt2img generations take no init images")
+				}
+				opt, err := e.Txt2Img(sameSeed)
+				if err != nil {
+					return err
+				}
+				var res *sdcli.Txt2ImageResponse
+				if err := g.withProgress(cmd, cli, func(ctx context.Context) (err error) {
+					res, err = api.Txt2Img(ctx, *opt)
+					return err
+				}); err != nil {
+					return err
+				}
+				images, info = res.RawImages, res.Info
+			case history.ModeImg2Img:
+				opt, err := e.Img2Img(sameSeed)
+				if err != nil {
+					return err
+				}
+				for _, name := range args[1:] {
+					img, err := readImageFile(name)
+					if err != nil {
+						return err
+					}
+					opt.InitImages = append(opt.InitImages, img)
+				}
+				if len(mask) != 0 {
+					if opt.Mask, err = readImageFile(mask); err != nil {
+						return err
+					}
+				}
+				if len(opt.InitImages) == 0 {
+					return fmt.Errorf("img2img generation %s needs its init images", e.ID)
+				}
+				var res *sdcli.Img2ImgResponse
+				if err := g.withProgress(cmd, cli, func(ctx context.Context) (err error) {
+					res, err = api.Img2Img(ctx, *opt)
+					return err
+				}); err != nil {
+					return err
+				}
+				images, info = res.RawImages, res.Info
+			default:
+				return fmt.Errorf("unknown mode %q of generation %s", e.Mode, e.ID)
+			}
+
+			return writeImages(cmd.OutOrStdout(), g.outDir, string(e.Mode), images, info)
+		},
+	}
+	cmd.Flags().BoolVar(&sameSeed, "same-seed", false, "reuse the seed of the recorded generation instead of its seed parameter")
+	cmd.Flags().StringVar(&mask, "mask", "", "inpainting mask image of img2img generations")
+
+	return cmd
+}
+
+func newHistoryUsageCmd(g *globalFlags) *cobra.Command {
+	var (
+		f      historyFilter
+		by     string
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Report the usage of the recorded generations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var key func(e *history.Entry) string
+			switch by {
+			case "":
+			case "model":
+				key = func(e *history.Entry) string { return e.Model }
+			case "backend":
+				key = func(e *history.Entry) string { return e.Backend }
+			case "mode":
+				key = func(e *history.Entry) string { return string(e.Mode) }
+			case "day":
+				key = func(e *history.Entry) string { return e.Time.Format(time.DateOnly) }
+			default:
+				return fmt.Errorf("invalid --by %q, want model, backend, mode or day", by)
+			}
+
+			store, err := g.historyStore()
+			if err != nil {
+				return err
+			}
+			entries, err := store.Query(f.filter())
+			if err != nil {
+				return err
+			}
+			total, groups := history.Summarize(entries, key)
+			if asJSON {
+				return printJSON(cmd.OutOrStdout(), struct {
+					Total  *history.Usage   `json:"total"`
+					Groups []*history.Usage `json:"groups,omitempty"`
+				}{total, groups})
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, strings.ToUpper(by)+"\tGENERATIONS\tFAILED\tIMAGES\tDURATION")
+			total.Key = "total"
+			for _, u := range append(groups, total) {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", u.Key, u.Generations, u.Failed, u.Images, u.Duration.Round(time.Second))
+			}
+			return w.Flush()
+		},
+	}
+	f.register(cmd, 0)
+	cmd.Flags().StringVar(&by, "by", "", "group by model, backend, mode or day")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the usage as JSON")
+
+	return cmd
+}
+
+func (g *globalFlags) historyEntry(id string) (*history.Entry, error) {
+	store, err := g.historyStore()
+	if err != nil {
+		return nil, err
+	}
+	e, err := store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("no generation %s in %s", id, g.history)
+	}
+	return e, nil
+}
+
+// entryPrompt returns the prompt of the params of e.
+func entryPrompt(e *history.Entry) string {
+	if opt, err := e.Txt2Img(false); err == nil {
+		return opt.Prompt
+	}
+	if opt, err := e.Img2Img(false); err == nil {
+		return opt.Prompt
+	}
+	return ""
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+func newProgressCmd(g *globalFlags) *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "progress",
+		Short: "Show the progress of the running generation",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cli, err := g.client()
+			if err != nil {
+				return err
+			}
+
+			res, err := cli.GetProgress(cmd.Context(), true)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				return printJSON(cmd.OutOrStdout(), res)
+			}
+
+			if len(res.State.Job) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "idle")
+				return nil
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: %.1f%% (step %d/%d, job %d/%d), ETA %.1fs\n",
+				res.State.Job, res.Progress*100,
+				res.State.SamplingStep, res.State.SamplingSteps,
+				res.State.JobNo+1, res.State.JobCount,
+				res.ETARelative)
+
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the raw JSON response")
+
+	return cmd
+}
+
+func newModelsCmd(g *globalFlags) *cobra.Command {
+	var (
+		asJSON   bool
+		manifest string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "models",
+		Short: "List available checkpoints",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cli, err := g.client()
+			if err != nil {
+				return err
+			}
+
+			models, err := cli.GetModels(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			if len(manifest) != 0 {
+				expected, err := sdcli.LoadModelDigests(manifest)
+				if err != nil {
+					return err
+				}
+				mismatches := sdcli.VerifyModels(models, expected)
+				for _, m := range mismatches {
+					fmt.Fprintln(cmd.OutOrStdout(), m.Error())
+				}
+				if len(mismatches) != 0 {
+					return fmt.Errorf("%d of %d checkpoints failed verification", len(mismatches), len(expected))
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%d checkpoints verified\n", len(expected))
+				return nil
+			}
+
+			if asJSON {
+				return printJSON(cmd.OutOrStdout(), models)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tHASH\tTITLE")
+			for _, m := range models {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", m.ModelName, m.Hash, m.Title)
+			}
+
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the raw JSON response")
+	cmd.Flags().StringVar(&manifest, "verify", "", "verify the checkpoints against a JSON manifest of name, sha256 and hash entries")
+
+	return cmd
+}
+
+func newOptionsCmd(g *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "options",
+		Short: "Print the current server options as JSON",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cli, err := g.client()
+			if err != nil {
+				return err
+			}
+
+			opts, err := cli.GetOptions(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			return printJSON(cmd.OutOrStdout(), opts)
+		},
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+	"github.com/shallowclouds/go-sd-webui-cli/config"
+	"github.com/shallowclouds/go-sd-webui-cli/history"
+)
+
+type globalFlags struct {
+	flags      *pflag.FlagSet
+	configFile string
+	cfg        *config.Config
+
+	url      string
+	user     string
+	password string
+	timeout  time.Duration
+	outDir   string
+	history  string
+	debug    bool
+
+	tui          bool
+	preview      string
+	pollInterval time.Duration
+}
+
+func newRootCmd() *cobra.Command {
+	g := &globalFlags{}
+
+	cmd := &cobra.Command{
+		Use:           "sdcli",
+		Short:         "Command-line client for the StableDiffusion WebUI API",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+	}
+
+	flags := cmd.PersistentFlags()
+	g.flags = flags
+	flags.StringVar(&g.configFile, "config", "", "YAML, TOML or JSON config file (env SD_WEBUI_CONFIG, default <user config dir>/sdcli/config.yaml)")
+	flags.StringVar(&g.url, "url", envOr("SD_WEBUI_URL", "http://127.0.0.1:7860"), "WebUI base URL (env SD_WEBUI_URL)")
+	flags.StringVar(&g.user, "user", os.Getenv("SD_WEBUI_USER"), "API basic auth username (env SD_WEBUI_USER)")
+	flags.StringVar(&g.password, "password", os.Getenv("SD_WEBUI_PASSWORD"), "API basic auth password (env SD_WEBUI_PASSWORD)")
+	flags.DurationVar(&g.timeout, "timeout", 0, "HTTP timeout, 0 means no timeout")
+	flags.StringVarP(&g.outDir, "out", "o", ".", "directory to write images to")
+	flags.StringVar(&g.history, "history", envOr("SD_WEBUI_HISTORY", history.DefaultPath()), "JSON Lines file recording the generations, empty to disable (env SD_WEBUI_HISTORY)")
+	flags.BoolVar(&g.debug, "debug", false, "dump the requests and responses to stderr, with the credentials redacted")
+	flags.BoolVar(&g.tui, "tui", false, "show live progress while generating")
+	flags.StringVar(&g.preview, "preview", previewNone, "live preview in TUI mode: none, kitty or sixel")
+	flags.DurationVar(&g.pollInterval, "poll-interval", 500*time.Millisecond, "progress polling interval in TUI mode")
+
+	cmd.AddCommand(
+		newTxt2ImgCmd(g),
+		newImg2ImgCmd(g),
+		newUpscaleCmd(g),
+		newProgressCmd(g),
+		newModelsCmd(g),
+		newOptionsCmd(g),
+		newBatchCmd(g),
+		newWatchCmd(g),
+		newBenchCmd(g),
+		newHistoryCmd(g),
+		newCrosscheckCmd(g),
+		newGradioCmd(g),
+	)
+
+	return cmd
+}
+
+func (g *globalFlags) client() (*sdcli.Client, error) {
+	cfg, err := g.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if g.debug {
+		return cfg.NewClient(sdcli.WithDebugDump(os.Stderr))
+	}
+	return cfg.NewClient()
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); len(v) != 0 {
+		return v
+	}
+	return def
+}
+
+// writeImages writes image data into the output directory as <prefix>-<timestamp>-<index>.<format>,
+// with the infotexts of the generation info (if any) embedded like WebUI does.
+func writeImages(w io.Writer, dir, prefix string, images [][]byte, info string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var gen *sdcli.GenerationInfo
+	if len(info) != 0 {
+		gen, _ = sdcli.ParseInfo(info)
+	}
+
+	ts := time.Now().Format("20060102-150405")
+	for i, data := range images {
+		if gen != nil && len(gen.Infotext(i)) != 0 {
+			if withParams, err := sdcli.SetParameters(data, gen.Infotext(i)); err == nil {
+				data = withParams
+			}
+		}
+		ext := sdcli.DetectFormat(data)
+		if ext == sdcli.FormatUnknown {
+			ext = "bin"
+		}
+		name := filepath.Join(dir, fmt.Sprintf("%s-%s-%d.%s", prefix, ts, i, ext))
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		fmt.Fprintln(w, name)
+	}
+
+	return nil
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
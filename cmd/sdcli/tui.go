@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+const (
+	previewNone  = "none"
+	previewKitty = "kitty"
+	previewSixel = "sixel"
+
+	// previewMaxSide bounds the preview so terminal output stays small.
+	previewMaxSide = 320
+	progressBarLen = 40
+)
+
+// withProgress runs fn while rendering the live progress of the server when --tui is set.
+func (g *globalFlags) withProgress(cmd *cobra.Command, cli *sdcli.Client, fn func(ctx context.Context) error) error {
+	if !g.tui {
+		return fn(cmd.Context())
+	}
+
+	switch g.preview {
+	case previewNone, previewKitty, previewSixel:
+	default:
+		return fmt.Errorf("unknown preview mode %q, expecting one of none, kitty, sixel", g.preview)
+	}
+
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	t := &tui{w: cmd.ErrOrStderr(), preview: g.preview}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t.run(ctx, cli, g.pollInterval)
+	}()
+
+	err := fn(ctx)
+	cancel()
+	<-done
+
+	return err
+}
+
+type tui struct {
+	w       io.Writer
+	preview string
+
+	lastImage string
+	// lastPreview is the encoded preview of lastImage, written on every frame since frames
+	// clear the screen and previews change less often than the progress.
+	lastPreview []byte
+}
+
+// withQueueProgress runs fn, rendering the progress pushed by the gradio queue when --tui is
+// set. onEvent is nil otherwise.
+func (g *globalFlags) withQueueProgress(cmd *cobra.Command, fn func(onEvent func(e *sdcli.QueueEvent)) error) error {
+	if !g.tui {
+		return fn(nil)
+	}
+
+	t := &tui{w: cmd.ErrOrStderr(), preview: previewNone}
+	defer t.enter()()
+	return fn(sdcli.QueueProgressFunc(t.render))
+}
+
+// enter switches to the alternate screen so the final output is not interleaved with frames,
+// the returned function switches back.
+func (t *tui) enter() func() {
+	fmt.Fprint(t.w, "\x1b[?1049h\x1b[?25l")
+	return func() {
+		fmt.Fprint(t.w, "\x1b[?25h\x1b[?1049l")
+	}
+}
+
+func (t *tui) run(ctx context.Context, cli *sdcli.Client, interval time.Duration) {
+	defer t.enter()()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res, err := cli.GetProgress(ctx, t.preview == previewNone)
+		if err == nil {
+			t.render(res)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *tui) render(res *sdcli.ProgressResponse) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("\x1b[H\x1b[J")
+
+	if len(res.State.Job) == 0 {
+		buf.WriteString("waiting for the server to start the job...\n")
+		_, _ = t.w.Write(buf.Bytes())
+		return
+	}
+
+	filled := int(res.Progress * progressBarLen)
+	if filled > progressBarLen {
+		filled = progressBarLen
+	}
+	fmt.Fprintf(buf, "[%s%s] %5.1f%%\n", strings.Repeat("#", filled), strings.Repeat("-", progressBarLen-filled), res.Progress*100)
+	fmt.Fprintf(buf, "step %d/%d, job %d/%d, ETA %s\n",
+		res.State.SamplingStep, res.State.SamplingSteps,
+		res.State.JobNo+1, res.State.JobCount,
+		res.ETA().Round(100*time.Millisecond))
+	if len(res.TextInfo) != 0 {
+		fmt.Fprintln(buf, res.TextInfo)
+	}
+
+	if len(res.CurrentImage) != 0 && res.CurrentImage != t.lastImage {
+		t.lastImage = res.CurrentImage
+		if img := res.ParsedCurrentImage; img != nil {
+			img = shrink(img, previewMaxSide)
+			preview := &bytes.Buffer{}
+			switch t.preview {
+			case previewKitty:
+				_ = writeKitty(preview, img)
+			case previewSixel:
+				_ = writeSixel(preview, img)
+			}
+			t.lastPreview = preview.Bytes()
+		}
+	}
+	buf.Write(t.lastPreview)
+
+	_, _ = t.w.Write(buf.Bytes())
+}
+
+// shrink scales img down with nearest neighbour sampling so that neither side exceeds maxSide.
+func shrink(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+
+	nw, nh := maxSide, h*maxSide/w
+	if h > w {
+		nw, nh = w*maxSide/h, maxSide
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	for y := 0; y < nh; y++ {
+		for x := 0; x < nw; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*w/nw, b.Min.Y+y*h/nh))
+		}
+	}
+
+	return dst
+}
+
+// writeKitty writes img with the kitty graphics protocol, transmitting PNG data in 4096 byte chunks.
+func writeKitty(w io.Writer, img image.Image) error {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return err
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	const chunk = 4096
+	for i := 0; i < len(data); i += chunk {
+		end := i + chunk
+		more := 1
+		if end >= len(data) {
+			end, more = len(data), 0
+		}
+		if i == 0 {
+			fmt.Fprintf(w, "\x1b_Ga=T,f=100,m=%d;%s\x1b\\", more, data[i:end])
+		} else {
+			fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, data[i:end])
+		}
+	}
+	_, err := fmt.Fprintln(w)
+
+	return err
+}
+
+// writeSixel writes img as sixel graphics quantized to a 6x6x6 color cube.
+func writeSixel(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\x1bPq\"1;1;%d;%d", width, height)
+	for i := 0; i < 216; i++ {
+		fmt.Fprintf(bw, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+
+	idx := make([]uint8, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			idx[y*width+x] = uint8(r*6/0x10000*36 + g*6/0x10000*6 + bl*6/0x10000)
+		}
+	}
+
+	for y0 := 0; y0 < height; y0 += 6 {
+		var used [216]bool
+		for dy := 0; dy < 6 && y0+dy < height; dy++ {
+			for x := 0; x < width; x++ {
+				used[idx[(y0+dy)*width+x]] = true
+			}
+		}
+
+		first := true
+		for c := 0; c < 216; c++ {
+			if !used[c] {
+				continue
+			}
+			if !first {
+				bw.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(bw, "#%d", c)
+
+			var (
+				last byte
+				run  int
+			)
+			for x := 0; x < width; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && y0+dy < height; dy++ {
+					if idx[(y0+dy)*width+x] == uint8(c) {
+						bits |= 1 << dy
+					}
+				}
+				ch := 63 + bits
+				if run > 0 && ch == last {
+					run++
+					continue
+				}
+				writeSixelRun(bw, last, run)
+				last, run = ch, 1
+			}
+			writeSixelRun(bw, last, run)
+		}
+		bw.WriteByte('-')
+	}
+	bw.WriteString("\x1b\\\n")
+
+	return bw.Flush()
+}
+
+func writeSixelRun(w *bufio.Writer, ch byte, run int) {
+	switch {
+	case run <= 0:
+	case run > 3:
+		fmt.Fprintf(w, "!%d%c", run, ch)
+	default:
+		for i := 0; i < run; i++ {
+			w.WriteByte(ch)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/shallowclouds/go-sd-webui-cli/watch"
+)
+
+func newWatchCmd(g *globalFlags) *cobra.Command {
+	var (
+		mode       string
+		paramsFile string
+		interval   time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "watch <dir>",
+		Short: "Run img2img or upscaling on the images dropped into a directory",
+		Long: `Watch a directory and process every new image into the output directory, the out
+subdirectory of the watched one when --out is not given and would be the watched one.
+
+An image may come with sidecar files sharing its base name: a .txt file holding
+the prompt and a .json or .yaml file holding API parameters.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if mode != watch.ModeImg2Img && mode != watch.ModeUpscale {
+				return fmt.Errorf("invalid mode %q, must be %s or %s", mode, watch.ModeImg2Img, watch.ModeUpscale)
+			}
+
+			defaults := map[string]any{}
+			if len(paramsFile) != 0 {
+				data, err := os.ReadFile(paramsFile)
+				if err != nil {
+					return fmt.Errorf("failed to read params: %w", err)
+				}
+				if err := yaml.Unmarshal(data, &defaults); err != nil {
+					return fmt.Errorf("invalid params: %w", err)
+				}
+			}
+
+			cli, err := g.client()
+			if err != nil {
+				return err
+			}
+
+			// The outputs would replace the sources in the default output directory.
+			outDir := g.outDir
+			if !cmd.Flags().Changed("out") && sameDir(args[0], outDir) {
+				outDir = filepath.Join(args[0], "out")
+			}
+
+			out, errOut := cmd.OutOrStdout(), cmd.ErrOrStderr()
+			w := &watch.Watcher{
+				Client:   cli,
+				Dir:      args[0],
+				OutDir:   outDir,
+				Mode:     mode,
+				Defaults: defaults,
+				Interval: interval,
+				OnResult: func(res *watch.Result) {
+					if res.Error != nil {
+						fmt.Fprintf(errOut, "%s: %v\n", res.Source, res.Error)
+						return
+					}
+					for _, f := range res.Files {
+						fmt.Fprintf(out, "%s -> %s\n", res.Source, f)
+					}
+				},
+			}
+
+			return w.Run(cmd.Context())
+		},
+	}
+	cmd.Flags().StringVar(&mode, "mode", watch.ModeImg2Img, "processing of the images: img2img or upscale")
+	cmd.Flags().StringVar(&paramsFile, "params", "", "YAML or JSON file of default API parameters")
+	cmd.Flags().DurationVar(&interval, "interval", watch.DefaultInterval, "polling interval of the directory")
+
+	return cmd
+}
+
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
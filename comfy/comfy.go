@@ -0,0 +1,896 @@
+// Package comfy implements sdcli.API against a ComfyUI server, so applications written for the
+// WebUI client can switch backends without rewriting call sites.
+//
+// Generation options are translated into ComfyUI workflow graphs, queued with the prompt
+// endpoint and polled through the history endpoint until their images are saved. Features
+// without a ComfyUI counterpart, such as scripts, face restoration or hires fix, fail with
+// ErrUnsupported instead of being silently ignored.
+package comfy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+	"github.com/shallowclouds/go-sd-webui-cli/prompt"
+	"github.com/shallowclouds/go-sd-webui-cli/seed"
+)
+
+// ErrUnsupported is returned for options and operations ComfyUI has no counterpart for.
+var ErrUnsupported = errors.New("not supported by ComfyUI")
+
+// DefaultPollInterval is how often the history of a queued prompt is polled.
+const DefaultPollInterval = 500 * time.Millisecond
+
+// Client is a ComfyUI client implementing sdcli.API.
+type Client struct {
+	cli          *http.Client
+	baseURL      string
+	clientID     string
+	pollInterval time.Duration
+
+	// mu guards the settings changed with SetOptions.
+	mu         sync.Mutex
+	checkpoint string
+	vae        string
+}
+
+var _ sdcli.API = (*Client)(nil)
+
+// Option configures optional behaviors of the Client.
+type Option func(c *Client)
+
+// WithCheckpoint sets the checkpoint used when the options override none, the first checkpoint
+// of the server otherwise.
+func WithCheckpoint(name string) Option {
+	return func(c *Client) {
+		c.checkpoint = name
+	}
+}
+
+// WithPollInterval sets how often queued prompts are polled, see DefaultPollInterval.
+func WithPollInterval(d time.Duration) Option {
+	return func(c *Client) {
+		c.pollInterval = d
+	}
+}
+
+// WithClientID sets the client ID the prompts are queued with, to tell them apart in the
+// queue of a shared server.
+func WithClientID(id string) Option {
+	return func(c *Client) {
+		c.clientID = id
+	}
+}
+
+// NewClient creates the ComfyUI client, baseURL defaults to the local ComfyUI port.
+func NewClient(baseURL string, httpCli *http.Client, opts ...Option) (*Client, error) {
+	if len(baseURL) == 0 {
+		baseURL = "http://127.0.0.1:8188"
+	}
+	if httpCli == nil {
+		httpCli = http.DefaultClient
+	}
+	cli := &Client{
+		cli:          httpCli,
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		clientID:     "sdcli",
+		pollInterval: DefaultPollInterval,
+	}
+
+	for _, opt := range opts {
+		opt(cli)
+	}
+
+	return cli, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, result any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if len(contentType) != 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.cli.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s of %s: %s", resp.Status, path, bytes.TrimSpace(data))
+	}
+
+	switch r := result.(type) {
+	case nil:
+	case *[]byte:
+		*r = data
+	default:
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("failed to parse response %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, body, result any) error {
+	if body == nil {
+		return c.do(ctx, method, path, "", nil, result)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	return c.do(ctx, method, path, "application/json", bytes.NewReader(data), result)
+}
+
+// savedImage is an image of the ComfyUI input or output directories.
+type savedImage struct {
+	Filename  string `json:"filename"`
+	Name      string `json:"name"`
+	Subfolder string `json:"subfolder"`
+	Type      string `json:"type"`
+}
+
+// upload stores a base64 or data URI image in the input directory, returning the name
+// LoadImage nodes reference it by. The name gets the hash of the image, so concurrent requests
+// do not overwrite the images of each other.
+func (c *Client) upload(ctx context.Context, name, raw string) (string, error) {
+	data, err := decodeBase64(raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image %s: %w", name, err)
+	}
+	sum := sha256.Sum256(data)
+	name += "-" + hex.EncodeToString(sum[:8])
+
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	part, err := w.CreateFormFile("image", name+"."+sdcli.DetectFormat(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode image %s: %w", name, err)
+	}
+	part.Write(data)
+	w.WriteField("overwrite", "true")
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to encode image %s: %w", name, err)
+	}
+
+	res := new(savedImage)
+	if err := c.do(ctx, http.MethodPost, "/upload/image", w.FormDataContentType(), buf, res); err != nil {
+		return "", err
+	}
+	if len(res.Subfolder) != 0 {
+		return res.Subfolder + "/" + res.Name, nil
+	}
+	return res.Name, nil
+}
+
+func decodeBase64(raw string) ([]byte, error) {
+	if i := strings.IndexByte(raw, ','); i >= 0 {
+		raw = raw[i+1:]
+	}
+	return base64.StdEncoding.DecodeString(raw)
+}
+
+type historyEntry struct {
+	Outputs map[string]struct {
+		Images []*savedImage `json:"images"`
+	} `json:"outputs"`
+	Status struct {
+		StatusStr string            `json:"status_str"`
+		Completed bool              `json:"completed"`
+		Messages  []json.RawMessage `json:"messages"`
+	} `json:"status"`
+}
+
+// run queues a workflow, waits for it and returns the images of the output node. The prompt is
+// deleted from the queue or interrupted when ctx is done first.
+func (c *Client) run(ctx context.Context, g graph, output string) ([][]byte, error) {
+	queued := struct {
+		PromptID string `json:"prompt_id"`
+	}{}
+	body := map[string]any{"prompt": g, "client_id": c.clientID}
+	if err := c.doJSON(ctx, http.MethodPost, "/prompt", body, &queued); err != nil {
+		return nil, fmt.Errorf("failed to queue prompt: %w", err)
+	}
+
+	entry, err := c.wait(ctx, queued.PromptID)
+	if err != nil {
+		if ctx.Err() != nil {
+			c.abort(queued.PromptID)
+		}
+		return nil, err
+	}
+
+	var images [][]byte
+	for _, img := range entry.Outputs[output].Images {
+		q := url.Values{"filename": {img.Filename}, "subfolder": {img.Subfolder}, "type": {img.Type}}
+		var data []byte
+		if err := c.do(ctx, http.MethodGet, "/view?"+q.Encode(), "", nil, &data); err != nil {
+			return nil, fmt.Errorf("failed to fetch image %s: %w", img.Filename, err)
+		}
+		images = append(images, data)
+	}
+	return images, nil
+}
+
+func (c *Client) wait(ctx context.Context, id string) (*historyEntry, error) {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		history := map[string]*historyEntry{}
+		if err := c.doJSON(ctx, http.MethodGet, "/history/"+url.PathEscape(id), nil, &history); err != nil {
+			return nil, fmt.Errorf("failed to get history of prompt %s: %w", id, err)
+		}
+		if entry, ok := history[id]; ok {
+			if entry.Status.StatusStr == "error" {
+				return nil, fmt.Errorf("prompt %s failed: %s", id, bytes.Join(rawMessages(entry.Status.Messages), []byte("; ")))
+			}
+			if entry.Status.Completed || len(entry.Status.StatusStr) == 0 {
+				return entry, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func rawMessages(msgs []json.RawMessage) [][]byte {
+	res := make([][]byte, len(msgs))
+	for i, m := range msgs {
+		res[i] = m
+	}
+	return res
+}
+
+// abort removes a prompt from the queue and interrupts it if it is running, best effort. The
+// server may be shared, so the running prompt of another client is not interrupted; the
+// prompt_id of the interrupt makes the servers supporting it check again.
+func (c *Client) abort(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), sdcli.DefaultInterruptTimeout)
+	defer cancel()
+
+	c.doJSON(ctx, http.MethodPost, "/queue", map[string]any{"delete": []string{id}}, nil)
+
+	q := new(queueResponse)
+	if err := c.doJSON(ctx, http.MethodGet, "/queue", nil, q); err != nil {
+		return
+	}
+	for _, item := range q.Running {
+		var running string
+		if len(item) > 1 && json.Unmarshal(item[1], &running) == nil && running == id {
+			c.doJSON(ctx, http.MethodPost, "/interrupt", map[string]any{"prompt_id": id}, nil)
+			return
+		}
+	}
+}
+
+// unsupported fails with ErrUnsupported naming the first set field.
+func unsupported(fields map[string]bool) error {
+	for _, name := range []string{"enable_hr", "script_name", "alwayson_scripts", "restore_faces", "tiling", "subseed_strength"} {
+		if fields[name] {
+			return fmt.Errorf("%s: %w", name, ErrUnsupported)
+		}
+	}
+	return nil
+}
+
+// prepare resolves the models and networks of a generation into sampling parameters.
+func (c *Client) prepare(ctx context.Context, text, negative, sampler, scheduler string, steps int, cfg float32, s int, settings sdcli.Overrides) (*sampling, error) {
+	smp := &sampling{
+		prompt:   text,
+		original: text,
+		negative: negative,
+		steps:    steps,
+		cfg:      cfg,
+		seed:     s,
+		denoise:  1,
+	}
+	if smp.steps == 0 {
+		smp.steps = sdcli.DefaultSteps
+	}
+	if smp.cfg == 0 {
+		smp.cfg = sdcli.DefaultCfgScale
+	}
+	smp.sampler, smp.scheduler = samplerName(sampler, scheduler)
+
+	c.mu.Lock()
+	smp.checkpoint, smp.vae = c.checkpoint, c.vae
+	c.mu.Unlock()
+	if model := settings.Model(); len(model) != 0 {
+		smp.checkpoint = model
+	}
+	if vae := settings.VAE(); len(vae) != 0 {
+		smp.vae = vae
+	}
+	if smp.vae == sdcli.VAEAutomatic || smp.vae == sdcli.VAENone {
+		smp.vae = ""
+	}
+
+	models, err := c.choices(ctx, "CheckpointLoaderSimple", "ckpt_name")
+	if err != nil {
+		return nil, err
+	}
+	if len(smp.checkpoint) == 0 {
+		if len(models) == 0 {
+			return nil, errors.New("no checkpoint available")
+		}
+		smp.checkpoint = models[0]
+	}
+	smp.checkpoint = matchFile(models, checkpointName(smp.checkpoint))
+
+	if len(smp.vae) != 0 {
+		vaes, err := c.choices(ctx, "VAELoader", "vae_name")
+		if err != nil {
+			return nil, err
+		}
+		smp.vae = matchFile(vaes, smp.vae)
+	}
+
+	// ComfyUI loads LoRAs with nodes rather than prompt tags.
+	if tags := prompt.ParseNetworkTags(text); len(tags) != 0 {
+		loras, err := c.choices(ctx, "LoraLoader", "lora_name")
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tags {
+			if t.Kind != prompt.KindLora {
+				return nil, fmt.Errorf("%s networks: %w", t.Kind, ErrUnsupported)
+			}
+			smp.loras = append(smp.loras, lora{name: matchFile(loras, t.Name), weight: t.Weight})
+		}
+		smp.prompt = prompt.StripNetworkTags(text)
+	}
+	return smp, nil
+}
+
+// generation runs a workflow NIter times, with the seed of every run following the previous
+// run's batch like WebUI, and returns the images and the WebUI style info.
+func (c *Client) generation(ctx context.Context, smp *sampling, width, height, batchSize, nIter int, build func() (graph, string)) ([]string, [][]byte, []image.Image, string, error) {
+	if nIter < 1 {
+		nIter = 1
+	}
+	info := &sdcli.GenerationInfo{
+		Prompt:         smp.original,
+		NegativePrompt: smp.negative,
+		Seed:           int64(smp.seed),
+		Width:          width,
+		Height:         height,
+		SamplerName:    smp.sampler,
+		CfgScale:       smp.cfg,
+		Steps:          smp.steps,
+		BatchSize:      batchSize,
+		SDModelName:    smp.checkpoint,
+		SDVAEName:      smp.vae,
+	}
+	if smp.denoise != 1 {
+		info.DenoisingStrength = smp.denoise
+	}
+
+	var (
+		images []string
+		raws   [][]byte
+		parsed []image.Image
+	)
+	first := smp.seed
+	for i := 0; i < nIter; i++ {
+		smp.seed = first + i*batchSize
+		g, output := build()
+		res, err := c.run(ctx, g, output)
+		if err != nil {
+			return nil, nil, nil, "", err
+		}
+		for j, data := range res {
+			images = append(images, base64.StdEncoding.EncodeToString(data))
+			raws = append(raws, data)
+			if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+				parsed = append(parsed, img)
+				if width == 0 || height == 0 {
+					width, height = img.Bounds().Dx(), img.Bounds().Dy()
+					info.Width, info.Height = width, height
+				}
+			}
+			info.AllPrompts = append(info.AllPrompts, smp.original)
+			info.AllNegativePrompt = append(info.AllNegativePrompt, smp.negative)
+			info.AllSeeds = append(info.AllSeeds, int64(smp.seed+j))
+			info.AllSubseeds = append(info.AllSubseeds, 0)
+			info.Infotexts = append(info.Infotexts, infotext(smp, width, height, smp.seed+j))
+		}
+	}
+	smp.seed = first
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, nil, nil, "", fmt.Errorf("failed to encode info: %w", err)
+	}
+	return images, raws, parsed, string(data), nil
+}
+
+// infotext formats the parameters of an image the way WebUI writes them into PNG files.
+func infotext(smp *sampling, width, height, s int) string {
+	t := &sdcli.Infotext{
+		Prompt:         smp.original,
+		NegativePrompt: smp.negative,
+		Steps:          smp.steps,
+		Sampler:        smp.sampler,
+		ScheduleType:   smp.scheduler,
+		CfgScale:       smp.cfg,
+		Seed:           int64(s),
+		Width:          width,
+		Height:         height,
+		Model:          checkpointName(smp.checkpoint),
+	}
+	if smp.denoise != 1 {
+		t.DenoisingStrength = smp.denoise
+	}
+	return t.String()
+}
+
+func (c *Client) Txt2Img(ctx context.Context, opt sdcli.Txt2ImageOption, opts ...sdcli.RequestOption) (*sdcli.Txt2ImageResponse, error) {
+	ctx, cancel := sdcli.RequestContext(ctx, opts...)
+	defer cancel()
+
+	if err := unsupported(map[string]bool{
+		"enable_hr":        opt.EnableHR,
+		"script_name":      len(opt.ScriptName) != 0,
+		"alwayson_scripts": len(opt.AlwaysonScripts) != 0,
+		"restore_faces":    opt.RestoreFaces,
+		"tiling":           opt.Tiling,
+		"subseed_strength": opt.SubseedStrength != 0,
+	}); err != nil {
+		return nil, err
+	}
+
+	sampler := opt.SamplerName
+	if len(sampler) == 0 {
+		sampler = opt.SamplerIndex
+	}
+	smp, err := c.prepare(ctx, opt.Prompt, opt.NegativePrompt, sampler, opt.Scheduler, opt.Steps, opt.CfgScale, seed.Fixed(randomSeed(opt.Seed)), opt.OverrideSettings)
+	if err != nil {
+		return nil, err
+	}
+	width, height, batchSize := size(opt.Width, opt.Height, opt.BatchSize)
+
+	res := &sdcli.Txt2ImageResponse{Parameters: &opt}
+	res.Images, res.RawImages, res.ParsedImages, res.Info, err = c.generation(ctx, smp, width, height, batchSize, opt.NIter, func() (graph, string) {
+		return txt2imgGraph(smp, width, height, batchSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Client) Img2Img(ctx context.Context, opt sdcli.Img2ImgOption, opts ...sdcli.RequestOption) (*sdcli.Img2ImgResponse, error) {
+	ctx, cancel := sdcli.RequestContext(ctx, opts...)
+	defer cancel()
+
+	if err := unsupported(map[string]bool{
+		"script_name":      len(opt.ScriptName) != 0,
+		"alwayson_scripts": len(opt.AlwaysonScripts) != 0,
+		"restore_faces":    opt.RestoreFaces,
+		"tiling":           opt.Tiling,
+		"subseed_strength": opt.SubseedStrength != 0,
+	}); err != nil {
+		return nil, err
+	}
+	if len(opt.InitImages) == 0 {
+		return nil, errors.New("no init image")
+	}
+
+	sampler := opt.SamplerName
+	if len(sampler) == 0 {
+		sampler = opt.SamplerIndex
+	}
+	smp, err := c.prepare(ctx, opt.Prompt, opt.NegativePrompt, sampler, opt.Scheduler, opt.Steps, opt.CfgScale, seed.Fixed(randomSeed(opt.Seed)), opt.OverrideSettings)
+	if err != nil {
+		return nil, err
+	}
+	smp.denoise = opt.DenoisingStrength
+	if smp.denoise == 0 {
+		smp.denoise = sdcli.DefaultDenoisingStrength
+	}
+
+	initImage, err := c.upload(ctx, filenamePrefix+"-init", opt.InitImages[0])
+	if err != nil {
+		return nil, err
+	}
+	var mask string
+	if len(opt.Mask) != 0 {
+		if mask, err = c.upload(ctx, filenamePrefix+"-mask", opt.Mask); err != nil {
+			return nil, err
+		}
+	}
+	// Without a size the outputs keep the size of the init image.
+	_, _, batchSize := size(0, 0, opt.BatchSize)
+	width, height := opt.Width, opt.Height
+
+	// The parameters are decoded as a Txt2ImageOption from the responses of WebUI too.
+	res := &sdcli.Img2ImgResponse{Parameters: &sdcli.Txt2ImageOption{}}
+	if data, err := json.Marshal(opt); err == nil {
+		json.Unmarshal(data, res.Parameters)
+	}
+	res.Images, res.RawImages, res.ParsedImages, res.Info, err = c.generation(ctx, smp, width, height, batchSize, opt.NIter, func() (graph, string) {
+		return img2imgGraph(smp, initImage, mask, opt.InpaintingMaskInvert == sdcli.InpaintNotMasked, width, height, opt.ResizeMode == sdcli.ResizeCrop, batchSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Client) ExtraSingleImg(ctx context.Context, opt sdcli.ExtraSingleImgOption, opts ...sdcli.RequestOption) (*sdcli.ExtraSingleImgResponse, error) {
+	ctx, cancel := sdcli.RequestContext(ctx, opts...)
+	defer cancel()
+
+	if opt.GfpganVisibility != 0 || opt.CodeformerVisibility != 0 {
+		return nil, fmt.Errorf("face restoration: %w", ErrUnsupported)
+	}
+	if len(opt.Upscaler2) != 0 && !strings.EqualFold(opt.Upscaler2, sdcli.UpscalerNone) {
+		return nil, fmt.Errorf("upscaler_2: %w", ErrUnsupported)
+	}
+
+	name, err := c.upload(ctx, filenamePrefix+"-extras", opt.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	upscaler := opt.Upscaler1
+	if _, builtin := interpolations[strings.ToLower(upscaler)]; !builtin {
+		models, err := c.choices(ctx, "UpscaleModelLoader", "model_name")
+		if err != nil {
+			return nil, err
+		}
+		upscaler = matchFile(models, upscaler)
+	}
+	scale := opt.UpscalingResize
+	if scale == 0 {
+		scale = 2
+	}
+	var width, height int
+	if opt.ResizeMode == sdcli.ExtrasResizeTo {
+		width, height = opt.UpscalingResizeW, opt.UpscalingResizeH
+	}
+
+	g, output := upscaleGraph(name, upscaler, scale, width, height, opt.UpscalingCrop)
+	images, err := c.run(ctx, g, output)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, errors.New("no image returned")
+	}
+
+	res := &sdcli.ExtraSingleImgResponse{
+		Image:    base64.StdEncoding.EncodeToString(images[0]),
+		RawImage: images[0],
+	}
+	res.ParsedImage, _, _ = image.Decode(bytes.NewReader(images[0]))
+	return res, nil
+}
+
+// randomSeed treats the omitted seed of WebUI options as random.
+func randomSeed(s int) int {
+	if s == 0 {
+		return sdcli.SeedRandom
+	}
+	return s
+}
+
+func size(width, height, batchSize int) (int, int, int) {
+	if width == 0 {
+		width = sdcli.DefaultSize
+	}
+	if height == 0 {
+		height = sdcli.DefaultSize
+	}
+	if batchSize == 0 {
+		batchSize = 1
+	}
+	return width, height, batchSize
+}
+
+type queueResponse struct {
+	Running [][]json.RawMessage `json:"queue_running"`
+	Pending [][]json.RawMessage `json:"queue_pending"`
+}
+
+// GetProgress reports the queued and running prompts, ComfyUI only reports sampling steps
+// over websocket so Progress and the step counts stay zero.
+func (c *Client) GetProgress(ctx context.Context, skipCurrentImg bool) (*sdcli.ProgressResponse, error) {
+	q := new(queueResponse)
+	if err := c.doJSON(ctx, http.MethodGet, "/queue", nil, q); err != nil {
+		return nil, err
+	}
+
+	res := &sdcli.ProgressResponse{FetchedAt: time.Now()}
+	res.State.JobCount = len(q.Running) + len(q.Pending)
+	if len(q.Running) != 0 && len(q.Running[0]) > 1 {
+		json.Unmarshal(q.Running[0][1], &res.State.Job)
+	}
+	return res, nil
+}
+
+func (c *Client) Interrupt(ctx context.Context) error {
+	return c.doJSON(ctx, http.MethodPost, "/interrupt", nil, nil)
+}
+
+// Skip is not supported, ComfyUI runs every batch as a whole.
+func (c *Client) Skip(ctx context.Context) error {
+	return fmt.Errorf("skip: %w", ErrUnsupported)
+}
+
+// GetOptions returns the checkpoint and VAE used by default, the only options of the client.
+func (c *Client) GetOptions(ctx context.Context) (*sdcli.OptionsResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return &sdcli.OptionsResponse{SdModelCheckpoint: sdcli.Ptr(c.checkpoint), SdVae: sdcli.Ptr(c.vae)}, nil
+}
+
+// SetOptions sets the checkpoint (sd_model_checkpoint) and VAE (sd_vae) used by default, ComfyUI
+// has no server settings so the other options fail with ErrUnsupported.
+func (c *Client) SetOptions(ctx context.Context, opts map[string]any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, v := range opts {
+		s, ok := v.(string)
+		switch {
+		case k == "sd_model_checkpoint" && ok:
+			c.checkpoint = s
+		case k == "sd_vae" && ok:
+			c.vae = s
+		default:
+			return fmt.Errorf("option %s: %w", k, ErrUnsupported)
+		}
+	}
+	return nil
+}
+
+// choices returns the values of a combo input of a node, such as the checkpoint files.
+func (c *Client) choices(ctx context.Context, class, input string) ([]string, error) {
+	info := map[string]struct {
+		Input struct {
+			Required map[string][]json.RawMessage `json:"required"`
+		} `json:"input"`
+	}{}
+	if err := c.doJSON(ctx, http.MethodGet, "/object_info/"+class, nil, &info); err != nil {
+		return nil, err
+	}
+
+	var res []string
+	if spec := info[class].Input.Required[input]; len(spec) != 0 {
+		if err := json.Unmarshal(spec[0], &res); err != nil {
+			return nil, fmt.Errorf("failed to parse %s choices of %s: %w", input, class, err)
+		}
+	}
+	return res, nil
+}
+
+func (c *Client) GetModels(ctx context.Context) ([]*sdcli.ModelsResponse, error) {
+	names, err := c.choices(ctx, "CheckpointLoaderSimple", "ckpt_name")
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*sdcli.ModelsResponse, len(names))
+	for i, name := range names {
+		res[i] = &sdcli.ModelsResponse{Title: name, ModelName: modelName(name), Filename: name}
+	}
+	return res, nil
+}
+
+// RefreshCheckpoints does nothing, ComfyUI lists the model files on every request.
+func (c *Client) RefreshCheckpoints(ctx context.Context) error {
+	return nil
+}
+
+// GetSamplers lists the ComfyUI samplers, aliased with the WebUI names mapping to them.
+func (c *Client) GetSamplers(ctx context.Context) ([]*sdcli.SamplersResponse, error) {
+	names, err := c.choices(ctx, "KSampler", "sampler_name")
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*sdcli.SamplersResponse, len(names))
+	for i, name := range names {
+		res[i] = &sdcli.SamplersResponse{Name: name}
+		for webui, comfy := range samplers {
+			if comfy != name {
+				continue
+			}
+			res[i].Aliases = append(res[i].Aliases, webui)
+			for suffix := range schedulerSuffixes {
+				res[i].Aliases = append(res[i].Aliases, webui+suffix)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (c *Client) GetSchedulers(ctx context.Context) ([]*sdcli.SchedulersResponse, error) {
+	names, err := c.choices(ctx, "KSampler", "scheduler")
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*sdcli.SchedulersResponse, len(names))
+	for i, name := range names {
+		res[i] = &sdcli.SchedulersResponse{Name: name, Label: name}
+	}
+	return res, nil
+}
+
+// GetUpscalers lists the upscale models and the builtin Lanczos and Nearest interpolations.
+func (c *Client) GetUpscalers(ctx context.Context) ([]*sdcli.UpscalersResponse, error) {
+	names, err := c.choices(ctx, "UpscaleModelLoader", "model_name")
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*sdcli.UpscalersResponse{{Name: sdcli.UpscalerLanczos}, {Name: sdcli.UpscalerNearest}}
+	for _, name := range names {
+		res = append(res, &sdcli.UpscalersResponse{Name: modelName(name), ModelName: name, ModelPath: name})
+	}
+	return res, nil
+}
+
+func (c *Client) GetVAEs(ctx context.Context) ([]*sdcli.VAEsResponse, error) {
+	names, err := c.choices(ctx, "VAELoader", "vae_name")
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*sdcli.VAEsResponse, len(names))
+	for i, name := range names {
+		res[i] = &sdcli.VAEsResponse{ModelName: name, Filename: name}
+	}
+	return res, nil
+}
+
+// GetSdModules returns no modules, ComfyUI loads text encoders and VAEs with nodes.
+func (c *Client) GetSdModules(ctx context.Context) ([]*sdcli.SdModulesResponse, error) {
+	return []*sdcli.SdModulesResponse{}, nil
+}
+
+// RefreshVAEs does nothing, ComfyUI lists the model files on every request.
+func (c *Client) RefreshVAEs(ctx context.Context) error {
+	return nil
+}
+
+// GetScripts returns no scripts, ComfyUI has no scripts.
+func (c *Client) GetScripts(ctx context.Context) (*sdcli.ScriptsResponse, error) {
+	return &sdcli.ScriptsResponse{Txt2Img: []string{}, Img2Img: []string{}}, nil
+}
+
+func (c *Client) GetLoras(ctx context.Context) ([]*sdcli.LorasResponse, error) {
+	names, err := c.choices(ctx, "LoraLoader", "lora_name")
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*sdcli.LorasResponse, len(names))
+	for i, name := range names {
+		res[i] = &sdcli.LorasResponse{Name: modelName(name), Alias: modelName(name), Path: name}
+	}
+	return res, nil
+}
+
+// GetHypernetworks returns no hypernetworks, ComfyUI has no hypernetwork loader.
+func (c *Client) GetHypernetworks(ctx context.Context) ([]*sdcli.HypernetworksResponse, error) {
+	return []*sdcli.HypernetworksResponse{}, nil
+}
+
+// GetEmbeddings lists the embeddings as loaded, ComfyUI does not check them against the model.
+func (c *Client) GetEmbeddings(ctx context.Context) (*sdcli.EmbeddingsResponse, error) {
+	var names []string
+	if err := c.doJSON(ctx, http.MethodGet, "/embeddings", nil, &names); err != nil {
+		return nil, err
+	}
+
+	res := &sdcli.EmbeddingsResponse{Loaded: map[string]*sdcli.Embedding{}, Skipped: map[string]*sdcli.Embedding{}}
+	for _, name := range names {
+		res.Loaded[name] = &sdcli.Embedding{}
+	}
+	return res, nil
+}
+
+type systemStats struct {
+	System struct {
+		RAMTotal int64 `json:"ram_total"`
+		RAMFree  int64 `json:"ram_free"`
+	} `json:"system"`
+	Devices []struct {
+		Name      string `json:"name"`
+		Type      string `json:"type"`
+		VRAMTotal int64  `json:"vram_total"`
+		VRAMFree  int64  `json:"vram_free"`
+	} `json:"devices"`
+}
+
+// GetMemory reports the system memory and the memory of the first CUDA device.
+func (c *Client) GetMemory(ctx context.Context) (*sdcli.MemoryResponse, error) {
+	stats := new(systemStats)
+	if err := c.doJSON(ctx, http.MethodGet, "/system_stats", nil, stats); err != nil {
+		return nil, err
+	}
+
+	res := new(sdcli.MemoryResponse)
+	res.RAM.Total = stats.System.RAMTotal
+	res.RAM.Free = stats.System.RAMFree
+	res.RAM.Used = stats.System.RAMTotal - stats.System.RAMFree
+	for _, d := range stats.Devices {
+		if d.Type == "cuda" {
+			res.Cuda.System.Total = d.VRAMTotal
+			res.Cuda.System.Free = d.VRAMFree
+			res.Cuda.System.Used = d.VRAMTotal - d.VRAMFree
+			break
+		}
+	}
+	return res, nil
+}
+
+// Capabilities fetches the models, samplers, schedulers, upscalers and VAEs, it is not cached.
+func (c *Client) Capabilities(ctx context.Context) (*sdcli.Capabilities, error) {
+	caps := &sdcli.Capabilities{}
+	var err error
+	if caps.Models, err = c.GetModels(ctx); err != nil {
+		return nil, err
+	}
+	if caps.Samplers, err = c.GetSamplers(ctx); err != nil {
+		return nil, err
+	}
+	if caps.Schedulers, err = c.GetSchedulers(ctx); err != nil {
+		return nil, err
+	}
+	if caps.Upscalers, err = c.GetUpscalers(ctx); err != nil {
+		return nil, err
+	}
+	if caps.VAEs, err = c.GetVAEs(ctx); err != nil {
+		return nil, err
+	}
+	if caps.Scripts, err = c.GetScripts(ctx); err != nil {
+		return nil, err
+	}
+	caps.FetchedAt = time.Now()
+	return caps, nil
+}
+
+// modelName strips the directory and extension of a model file.
+func modelName(file string) string {
+	file = strings.ReplaceAll(file, "\\", "/")
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		file = file[i+1:]
+	}
+	if i := strings.LastIndexByte(file, '.'); i > 0 {
+		file = file[:i]
+	}
+	return file
+}
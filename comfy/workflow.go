@@ -0,0 +1,256 @@
+package comfy
+
+import (
+	"strconv"
+	"strings"
+)
+
+// node is a node of a ComfyUI API format workflow.
+type node struct {
+	ClassType string         `json:"class_type"`
+	Inputs    map[string]any `json:"inputs"`
+}
+
+// graph is a workflow in the API format, nodes by ID.
+type graph map[string]*node
+
+// add appends a node and returns its ID.
+func (g graph) add(class string, inputs map[string]any) string {
+	id := strconv.Itoa(len(g) + 1)
+	g[id] = &node{ClassType: class, Inputs: inputs}
+	return id
+}
+
+// link references output index out of node id.
+func link(id string, out int) []any {
+	return []any{id, out}
+}
+
+// sampling holds the parameters shared by the txt2img and img2img workflows.
+type sampling struct {
+	checkpoint string
+	vae        string
+	loras      []lora
+	prompt     string
+	// original is the prompt with its network tags, as written into the infotexts.
+	original  string
+	negative  string
+	sampler   string
+	scheduler string
+	steps     int
+	cfg       float32
+	seed      int
+	denoise   float32
+}
+
+type lora struct {
+	name   string
+	weight float64
+}
+
+// models adds the checkpoint, LoRA chain and VAE nodes, returning the model, clip and vae links.
+func (g graph) models(s *sampling) (model, clip, vae []any) {
+	ckpt := g.add("CheckpointLoaderSimple", map[string]any{"ckpt_name": s.checkpoint})
+	model, clip, vae = link(ckpt, 0), link(ckpt, 1), link(ckpt, 2)
+
+	for _, l := range s.loras {
+		id := g.add("LoraLoader", map[string]any{
+			"model":          model,
+			"clip":           clip,
+			"lora_name":      l.name,
+			"strength_model": l.weight,
+			"strength_clip":  l.weight,
+		})
+		model, clip = link(id, 0), link(id, 1)
+	}
+
+	if len(s.vae) != 0 {
+		vae = link(g.add("VAELoader", map[string]any{"vae_name": s.vae}), 0)
+	}
+	return model, clip, vae
+}
+
+// sample adds the prompt encoders, the sampler, the decoder and the save node for a latent,
+// returning the ID of the save node.
+func (g graph) sample(s *sampling, model, clip, vae, latent []any) string {
+	pos := g.add("CLIPTextEncode", map[string]any{"text": s.prompt, "clip": clip})
+	neg := g.add("CLIPTextEncode", map[string]any{"text": s.negative, "clip": clip})
+	ks := g.add("KSampler", map[string]any{
+		"model":        model,
+		"seed":         s.seed,
+		"steps":        s.steps,
+		"cfg":          s.cfg,
+		"sampler_name": s.sampler,
+		"scheduler":    s.scheduler,
+		"positive":     link(pos, 0),
+		"negative":     link(neg, 0),
+		"latent_image": latent,
+		"denoise":      s.denoise,
+	})
+	dec := g.add("VAEDecode", map[string]any{"samples": link(ks, 0), "vae": vae})
+	return g.add("SaveImage", map[string]any{"images": link(dec, 0), "filename_prefix": filenamePrefix})
+}
+
+// filenamePrefix names the images saved by the workflows in the ComfyUI output directory.
+const filenamePrefix = "sdcli"
+
+func txt2imgGraph(s *sampling, width, height, batchSize int) (graph, string) {
+	g := graph{}
+	model, clip, vae := g.models(s)
+	latent := g.add("EmptyLatentImage", map[string]any{"width": width, "height": height, "batch_size": batchSize})
+	return g, g.sample(s, model, clip, vae, link(latent, 0))
+}
+
+// img2imgGraph encodes the uploaded image, resized to width x height when they are set, and
+// inpaints only the white area of the uploaded mask when mask is set.
+func img2imgGraph(s *sampling, image, mask string, invertMask bool, width, height int, crop bool, batchSize int) (graph, string) {
+	g := graph{}
+	model, clip, vae := g.models(s)
+
+	pixels := g.scaled(link(g.add("LoadImage", map[string]any{"image": image}), 0), width, height, crop)
+	latent := link(g.add("VAEEncode", map[string]any{"pixels": pixels, "vae": vae}), 0)
+	if len(mask) != 0 {
+		loaded := g.scaled(link(g.add("LoadImage", map[string]any{"image": mask}), 0), width, height, crop)
+		m := link(g.add("ImageToMask", map[string]any{"image": loaded, "channel": "red"}), 0)
+		if invertMask {
+			m = link(g.add("InvertMask", map[string]any{"mask": m}), 0)
+		}
+		latent = link(g.add("SetLatentNoiseMask", map[string]any{"samples": latent, "mask": m}), 0)
+	}
+	if batchSize > 1 {
+		latent = link(g.add("RepeatLatentBatch", map[string]any{"samples": latent, "amount": batchSize}), 0)
+	}
+	return g, g.sample(s, model, clip, vae, latent)
+}
+
+// upscaleGraph upscales the uploaded image with an upscale model, or one of the builtin
+// interpolations. The image is resized to width x height when they are set, by scale otherwise,
+// upscale models ignore scale and apply their native factor.
+func upscaleGraph(image, upscaler string, scale float64, width, height int, crop bool) (graph, string) {
+	g := graph{}
+	pixels := link(g.add("LoadImage", map[string]any{"image": image}), 0)
+
+	method, builtin := interpolations[strings.ToLower(upscaler)]
+	switch {
+	case !builtin:
+		m := g.add("UpscaleModelLoader", map[string]any{"model_name": upscaler})
+		pixels = link(g.add("ImageUpscaleWithModel", map[string]any{"upscale_model": link(m, 0), "image": pixels}), 0)
+		method = "lanczos"
+	case width == 0 || height == 0:
+		pixels = link(g.add("ImageScaleBy", map[string]any{"image": pixels, "upscale_method": method, "scale_by": scale}), 0)
+	}
+	pixels = g.scaledWith(pixels, method, width, height, crop)
+	return g, g.add("SaveImage", map[string]any{"images": pixels, "filename_prefix": filenamePrefix})
+}
+
+// scaled resizes pixels to width x height with lanczos unless they are zero, cropping the
+// overflow to keep the aspect ratio when crop is set.
+func (g graph) scaled(pixels []any, width, height int, crop bool) []any {
+	return g.scaledWith(pixels, "lanczos", width, height, crop)
+}
+
+func (g graph) scaledWith(pixels []any, method string, width, height int, crop bool) []any {
+	if width == 0 || height == 0 {
+		return pixels
+	}
+	mode := "disabled"
+	if crop {
+		mode = "center"
+	}
+	return link(g.add("ImageScale", map[string]any{
+		"image": pixels, "upscale_method": method, "width": width, "height": height, "crop": mode,
+	}), 0)
+}
+
+// interpolations maps the builtin WebUI upscalers to the methods of the ImageScaleBy node.
+var interpolations = map[string]string{
+	"lanczos": "lanczos",
+	"nearest": "nearest-exact",
+	"none":    "nearest-exact",
+	"":        "lanczos",
+}
+
+// samplers maps WebUI sampler names to ComfyUI ones, the schedulers are implied by the suffixes
+// of the WebUI names such as " Karras".
+var samplers = map[string]string{
+	"Euler a":      "euler_ancestral",
+	"Euler":        "euler",
+	"LMS":          "lms",
+	"Heun":         "heun",
+	"DPM2":         "dpm_2",
+	"DPM2 a":       "dpm_2_ancestral",
+	"DPM++ 2S a":   "dpmpp_2s_ancestral",
+	"DPM++ 2M":     "dpmpp_2m",
+	"DPM++ SDE":    "dpmpp_sde",
+	"DPM++ 2M SDE": "dpmpp_2m_sde",
+	"DPM++ 3M SDE": "dpmpp_3m_sde",
+	"DPM fast":     "dpm_fast",
+	"DPM adaptive": "dpm_adaptive",
+	"DDIM":         "ddim",
+	"UniPC":        "uni_pc",
+	"LCM":          "lcm",
+}
+
+var schedulerSuffixes = map[string]string{
+	" Karras":      "karras",
+	" Exponential": "exponential",
+}
+
+// DefaultSampler and DefaultScheduler are used when the options name none.
+const (
+	DefaultSampler   = "euler_ancestral"
+	DefaultScheduler = "normal"
+)
+
+// samplerName converts a WebUI sampler and scheduler to ComfyUI names, names unknown to WebUI
+// are passed through so ComfyUI names work as well.
+func samplerName(name, scheduler string) (string, string) {
+	implied := ""
+	for suffix, s := range schedulerSuffixes {
+		if base := strings.TrimSuffix(name, suffix); base != name {
+			name, implied = base, s
+			break
+		}
+	}
+	if s, ok := samplers[name]; ok {
+		name = s
+	}
+	if len(name) == 0 {
+		name = DefaultSampler
+	}
+
+	// WebUI names schedulers "Automatic", "Karras", "SGM Uniform" and so on.
+	scheduler = strings.ReplaceAll(strings.ToLower(scheduler), " ", "_")
+	switch {
+	case len(scheduler) != 0 && scheduler != "automatic":
+	case len(implied) != 0:
+		scheduler = implied
+	default:
+		scheduler = DefaultScheduler
+	}
+	return name, scheduler
+}
+
+// matchFile returns the file of names whose base name without extension is name, as WebUI
+// references models and networks without their directory and extension, or name itself.
+func matchFile(names []string, name string) string {
+	for _, n := range names {
+		if n == name {
+			return n
+		}
+	}
+	for _, n := range names {
+		if modelName(n) == name {
+			return n
+		}
+	}
+	return name
+}
+
+// checkpointName strips the hash WebUI appends to checkpoint titles, as in "model.safetensors [abc123]".
+func checkpointName(title string) string {
+	if i := strings.LastIndex(title, " ["); i > 0 && strings.HasSuffix(title, "]") {
+		return title[:i]
+	}
+	return title
+}
@@ -0,0 +1,244 @@
+// Package config loads the client settings and the default generation parameters from a
+// YAML, TOML or JSON file, with environment variables overriding the file.
+//
+//	url: http://127.0.0.1:7860
+//	timeout: 2m
+//	retries: 3
+//	defaults:
+//	  steps: 30
+//	  sampler_name: DPM++ 2M Karras
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+	"github.com/shallowclouds/go-sd-webui-cli/preset"
+)
+
+// Environment variables overriding the file settings.
+const (
+	EnvURL      = "SD_WEBUI_URL"
+	EnvUser     = "SD_WEBUI_USER"
+	EnvPassword = "SD_WEBUI_PASSWORD"
+	EnvTimeout  = "SD_WEBUI_TIMEOUT"
+	EnvRetries  = "SD_WEBUI_RETRIES"
+	// EnvConfig is the config file used by the CLI when --config is not given.
+	EnvConfig = "SD_WEBUI_CONFIG"
+)
+
+// Config holds the client settings, zero values keep the client defaults.
+type Config struct {
+	URL      string `json:"url,omitempty" yaml:"url,omitempty" toml:"url,omitempty"`
+	User     string `json:"user,omitempty" yaml:"user,omitempty" toml:"user,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty" toml:"password,omitempty"`
+	// Timeout is the HTTP timeout such as "2m", no timeout if unset.
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+	// Retries and RetryBackoff are passed to sdcli.WithRetry.
+	Retries      int      `json:"retries,omitempty" yaml:"retries,omitempty" toml:"retries,omitempty"`
+	RetryBackoff Duration `json:"retry_backoff,omitempty" yaml:"retry_backoff,omitempty" toml:"retry_backoff,omitempty"`
+	// MaxResponseBytes is passed to sdcli.WithMaxResponseBytes.
+	MaxResponseBytes int64 `json:"max_response_bytes,omitempty" yaml:"max_response_bytes,omitempty" toml:"max_response_bytes,omitempty"`
+	// CacheTTL enables sdcli.WithCache for the default paths.
+	CacheTTL Duration `json:"cache_ttl,omitempty" yaml:"cache_ttl,omitempty" toml:"cache_ttl,omitempty"`
+
+	// Defaults are API parameters using the WebUI JSON names applied to every generation.
+	Defaults map[string]any `json:"defaults,omitempty" yaml:"defaults,omitempty" toml:"defaults,omitempty"`
+	// Presets are registered along with the builtin presets, see Registry.
+	Presets []*preset.Preset `json:"presets,omitempty" yaml:"presets,omitempty" toml:"presets,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "1m30s".
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Load reads a config file, the format is chosen by extension: .toml, .yaml or .yml, and JSON
+// otherwise. The environment overrides the file.
+func Load(name string) (*Config, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	c, err := Parse(data, strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), "."))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ApplyEnv(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Parse parses a config in the given format: "toml", "yaml" or "yml", and JSON otherwise.
+// Unknown keys are rejected, so misspelled settings do not go unnoticed.
+func Parse(data []byte, format string) (*Config, error) {
+	c := &Config{}
+	var err error
+	switch format {
+	case "toml":
+		var md toml.MetaData
+		if md, err = toml.Decode(string(data), c); err == nil {
+			if undecoded := md.Undecoded(); len(undecoded) != 0 {
+				keys := make([]string, len(undecoded))
+				for i, key := range undecoded {
+					keys[i] = key.String()
+				}
+				err = fmt.Errorf("unknown keys %s", strings.Join(keys, ", "))
+			}
+		}
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		// An empty document decodes to io.EOF, it is an empty config.
+		if err = dec.Decode(c); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	return c, nil
+}
+
+// FromEnv returns a config holding the environment settings only.
+func FromEnv() (*Config, error) {
+	c := &Config{}
+	if err := c.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ApplyEnv overrides the settings with the environment variables that are set.
+func (c *Config) ApplyEnv() error {
+	if v := os.Getenv(EnvURL); len(v) != 0 {
+		c.URL = v
+	}
+	if v := os.Getenv(EnvUser); len(v) != 0 {
+		c.User = v
+	}
+	if v := os.Getenv(EnvPassword); len(v) != 0 {
+		c.Password = v
+	}
+	if v := os.Getenv(EnvTimeout); len(v) != 0 {
+		if err := c.Timeout.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvTimeout, err)
+		}
+	}
+	if v := os.Getenv(EnvRetries); len(v) != 0 {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvRetries, err)
+		}
+		c.Retries = n
+	}
+
+	return nil
+}
+
+// Options returns the client options of the settings.
+func (c *Config) Options() []sdcli.Option {
+	var opts []sdcli.Option
+	if c.Retries > 0 {
+		opts = append(opts, sdcli.WithRetry(c.Retries, time.Duration(c.RetryBackoff)))
+	}
+	if c.MaxResponseBytes > 0 {
+		opts = append(opts, sdcli.WithMaxResponseBytes(c.MaxResponseBytes))
+	}
+	if c.CacheTTL > 0 {
+		opts = append(opts, sdcli.WithCache(time.Duration(c.CacheTTL)))
+	}
+	return opts
+}
+
+// NewClient creates a client from the settings, opts are applied after the config options.
+func (c *Config) NewClient(opts ...sdcli.Option) (*sdcli.Client, error) {
+	httpCli := &http.Client{Timeout: time.Duration(c.Timeout)}
+	return sdcli.NewClient(c.URL, c.User, c.Password, httpCli, append(c.Options(), opts...)...)
+}
+
+// Registry returns the builtin presets along with the config presets.
+func (c *Config) Registry() *preset.Registry {
+	r := preset.NewRegistry(preset.Builtin()...)
+	for _, p := range c.Presets {
+		r.Register(p)
+	}
+	return r
+}
+
+// Txt2Img returns the default txt2img options.
+func (c *Config) Txt2Img() (sdcli.Txt2ImageOption, error) {
+	var opt sdcli.Txt2ImageOption
+	err := c.decodeDefaults(&opt)
+	return opt, err
+}
+
+// Img2Img returns the default img2img options.
+func (c *Config) Img2Img() (sdcli.Img2ImgOption, error) {
+	var opt sdcli.Img2ImgOption
+	err := c.decodeDefaults(&opt)
+	return opt, err
+}
+
+func (c *Config) decodeDefaults(opt any) error {
+	data, err := json.Marshal(c.Defaults)
+	if err != nil {
+		return fmt.Errorf("failed to encode defaults: %w", err)
+	}
+	if err := json.Unmarshal(data, opt); err != nil {
+		return fmt.Errorf("invalid defaults: %w", err)
+	}
+	return nil
+}
+
+// DefaultPath returns the config file used when none is given: $SD_WEBUI_CONFIG, or the first
+// of config.yaml, config.yml, config.toml and config.json found in the sdcli directory of the
+// user config directory. It returns "" if there is none.
+func DefaultPath() string {
+	if v := os.Getenv(EnvConfig); len(v) != 0 {
+		return v
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{"config.yaml", "config.yml", "config.toml", "config.json"} {
+		path := filepath.Join(dir, "sdcli", name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
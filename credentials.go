@@ -0,0 +1,30 @@
+package sdcli
+
+import "context"
+
+// CredentialProvider returns fresh basic auth credentials, e.g. a short-lived proxy token.
+// It must be safe for concurrent use.
+type CredentialProvider func(ctx context.Context) (username, password string, err error)
+
+// WithCredentialProvider refreshes the credentials with provider when the server answers
+// 401 Unauthorized, and retries the request once with the new credentials, which are kept
+// for the following requests.
+func WithCredentialProvider(provider CredentialProvider) Option {
+	return func(c *Client) {
+		c.credentialProvider = provider
+	}
+}
+
+func (c *Client) credentials() (username, password string) {
+	c.credMu.RLock()
+	defer c.credMu.RUnlock()
+
+	return c.username, c.password
+}
+
+func (c *Client) setCredentials(username, password string) {
+	c.credMu.Lock()
+	defer c.credMu.Unlock()
+
+	c.username, c.password = username, password
+}
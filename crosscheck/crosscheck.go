@@ -0,0 +1,194 @@
+// Package crosscheck runs the same fixed seed generations on two backends and reports how they
+// differ: the perceptual difference of the images, the samplers only one of them offers and the
+// generation times, e.g. before and after a WebUI upgrade or across the members of a pool.
+package crosscheck
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sort"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+	"github.com/shallowclouds/go-sd-webui-cli/imagediff"
+)
+
+// DefaultSeed is the seed of the generations when the options have a random one.
+const DefaultSeed = 1234
+
+// Options configure Check.
+type Options struct {
+	// Txt2Img is the generation run on both backends, its random seed is replaced by DefaultSeed.
+	Txt2Img sdcli.Txt2ImageOption
+	// Samplers are compared one by one, the sampler of Txt2Img when empty.
+	Samplers []string
+	// Tolerance decides whether the images match, imagediff.DefaultTolerance when zero.
+	Tolerance imagediff.Tolerance
+}
+
+// Report is the result of Check.
+type Report struct {
+	Seed int `json:"seed"`
+	// OnlyA and OnlyB are the samplers offered by a single backend.
+	OnlyA []string `json:"only_a,omitempty"`
+	OnlyB []string `json:"only_b,omitempty"`
+	// Results are the comparisons of the samplers, in the order of Options.Samplers.
+	Results []*Result `json:"results"`
+}
+
+// Match reports whether every sampler gave matching images on both backends.
+func (r *Report) Match() bool {
+	for _, res := range r.Results {
+		if !res.Match {
+			return false
+		}
+	}
+	return true
+}
+
+// Result is the comparison of a sampler.
+type Result struct {
+	Sampler string `json:"sampler"`
+	A       *Run   `json:"a"`
+	B       *Run   `json:"b"`
+	// Diffs are the differences of the images of both backends, in batch order.
+	Diffs []*imagediff.Result `json:"diffs,omitempty"`
+	// Match is set when both backends generated images within the tolerance.
+	Match bool `json:"match"`
+	// TimeDelta is the generation time of B minus the one of A.
+	TimeDelta time.Duration `json:"time_delta"`
+	// Error explains a mismatch, or why the sampler was not compared.
+	Error string `json:"error,omitempty"`
+}
+
+// Run is the generation of a backend.
+type Run struct {
+	Duration time.Duration `json:"duration"`
+	Model    string        `json:"model,omitempty"`
+	Error    string        `json:"error,omitempty"`
+
+	images []image.Image
+}
+
+// Check runs opt on a then b for every sampler and compares the outputs. Generation errors are
+// reported in the results, only failing to list the samplers of a backend fails the check.
+func Check(ctx context.Context, a, b sdcli.API, opt Options) (*Report, error) {
+	samplersA, err := samplerNames(ctx, a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get samplers of backend a: %w", err)
+	}
+	samplersB, err := samplerNames(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get samplers of backend b: %w", err)
+	}
+
+	tol := opt.Tolerance
+	if tol == (imagediff.Tolerance{}) {
+		tol = imagediff.DefaultTolerance
+	}
+	req := opt.Txt2Img
+	if req.Seed <= 0 {
+		req.Seed = DefaultSeed
+	}
+	report := &Report{
+		Seed:  req.Seed,
+		OnlyA: missing(samplersA, samplersB),
+		OnlyB: missing(samplersB, samplersA),
+	}
+
+	samplers := opt.Samplers
+	if len(samplers) == 0 {
+		samplers = []string{req.SamplerName}
+	}
+	for _, sampler := range samplers {
+		res := &Result{Sampler: sampler}
+		report.Results = append(report.Results, res)
+		if len(sampler) != 0 && (!samplersA[sampler] || !samplersB[sampler]) {
+			res.Error = "sampler not offered by both backends"
+			continue
+		}
+
+		req.SamplerName = sampler
+		res.A, res.B = run(ctx, a, req), run(ctx, b, req)
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		res.compare(tol)
+	}
+	return report, nil
+}
+
+// compare fills the differences of the runs.
+func (r *Result) compare(tol imagediff.Tolerance) {
+	switch {
+	case len(r.A.Error) != 0 || len(r.B.Error) != 0:
+		r.Error = "generation failed"
+		return
+	case len(r.A.images) != len(r.B.images):
+		r.Error = fmt.Sprintf("backends returned %d and %d images", len(r.A.images), len(r.B.images))
+		return
+	}
+	r.TimeDelta = r.B.Duration - r.A.Duration
+
+	r.Match = true
+	for i := range r.A.images {
+		diff, err := imagediff.Compare(r.A.images[i], r.B.images[i], tol)
+		if diff != nil {
+			r.Diffs = append(r.Diffs, diff)
+		}
+		if err != nil && r.Match {
+			r.Match = false
+			r.Error = fmt.Sprintf("image %d: %v", i, err)
+		}
+	}
+}
+
+// run generates opt on api, decoding the images from the response so clients skipping the
+// decoding compare too.
+func run(ctx context.Context, api sdcli.API, opt sdcli.Txt2ImageOption) *Run {
+	r := &Run{}
+	start := time.Now()
+	res, err := api.Txt2Img(ctx, opt)
+	r.Duration = time.Since(start)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if info, err := sdcli.ParseInfo(res.Info); err == nil {
+		r.Model = info.SDModelName
+	}
+	for _, raw := range res.Images {
+		img, _, err := sdcli.Base642Img(raw)
+		if err != nil {
+			r.Error = fmt.Sprintf("failed to decode image: %v", err)
+			return r
+		}
+		r.images = append(r.images, img)
+	}
+	return r
+}
+
+func samplerNames(ctx context.Context, api sdcli.API) (map[string]bool, error) {
+	samplers, err := api.GetSamplers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(samplers))
+	for _, s := range samplers {
+		names[s.Name] = true
+	}
+	return names, nil
+}
+
+// missing returns the names of a not in b, sorted.
+func missing(a, b map[string]bool) []string {
+	var names []string
+	for name := range a {
+		if !b[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,149 @@
+package sdcli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debugTruncate is the number of characters of the base64 fields kept in the dumps.
+const debugTruncate = 16
+
+// debugRedactedHeaders are the credential headers hidden from the dumps.
+var debugRedactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// WithDebugDump writes every request and response to w, with the JSON bodies indented, the
+// credential headers redacted and the base64 images cut to their first characters followed by
+// their length, to see what the client actually sent and got back. Streamed responses are
+// dumped without their body. Dumps are written whole, w may be shared by concurrent requests.
+func WithDebugDump(w io.Writer) Option {
+	return func(c *Client) {
+		c.debug = &debugDumper{w: w}
+	}
+}
+
+type debugDumper struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// dump writes an exchange, resp and respBody are nil when the request failed with err. resp and
+// err are nil for a response of WithCache, respBody holding it.
+func (d *debugDumper) dump(req *http.Request, payload []byte, resp *http.Response, respBody []byte, elapsed time.Duration, err error) {
+	if d == nil {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "> %s %s\n", req.Method, req.URL.Redacted())
+	dumpHeader(buf, "> ", req.Header)
+	dumpBody(buf, req.Header.Get("Content-Type"), payload)
+
+	switch {
+	case err != nil:
+		fmt.Fprintf(buf, "< error after %s: %v\n", elapsed, err)
+	case resp != nil:
+		fmt.Fprintf(buf, "< %s (%s)\n", resp.Status, elapsed)
+		dumpHeader(buf, "< ", resp.Header)
+		if respBody == nil {
+			buf.WriteString("[streamed body]\n")
+		} else {
+			dumpBody(buf, resp.Header.Get("Content-Type"), respBody)
+		}
+	default:
+		buf.WriteString("< cached response\n")
+		dumpBody(buf, "application/json", respBody)
+	}
+	buf.WriteByte('\n')
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, _ = d.w.Write(buf.Bytes())
+}
+
+func dumpHeader(buf *bytes.Buffer, prefix string, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := strings.Join(header[key], ", ")
+		if debugRedactedHeaders[http.CanonicalHeaderKey(key)] {
+			value = "[redacted]"
+		}
+		fmt.Fprintf(buf, "%s%s: %s\n", prefix, key, value)
+	}
+}
+
+func dumpBody(buf *bytes.Buffer, contentType string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		if strings.HasPrefix(contentType, "text/") {
+			buf.Write(body)
+			buf.WriteByte('\n')
+		} else {
+			fmt.Fprintf(buf, "[%d bytes of %s]\n", len(body), contentType)
+		}
+		return
+	}
+
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(truncateBase64(v))
+}
+
+// truncateBase64 cuts the base64 strings of a decoded JSON value.
+func truncateBase64(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = truncateBase64(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = truncateBase64(e)
+		}
+	case string:
+		prefix, raw := "", v
+		if strings.HasPrefix(v, "data:") {
+			if i := strings.Index(v, ";base64,"); i > 0 {
+				prefix, raw = v[:i+len(";base64,")], v[i+len(";base64,"):]
+			}
+		}
+		if len(raw) > debugTruncate && (len(prefix) != 0 || isBase64(raw)) {
+			return fmt.Sprintf("%s%s...[%d bytes]", prefix, raw[:debugTruncate], len(raw))
+		}
+	}
+	return v
+}
+
+// isBase64 reports whether s is a long base64 string, shorter ones are likely text.
+func isBase64(s string) bool {
+	if len(s) < 256 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9', b == '+', b == '/', b == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package sdcli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// InputLimit configures WithInputDownscale, a zero field is no bound.
+type InputLimit struct {
+	// MaxDimension bounds the width and height of the images.
+	MaxDimension int
+	// MaxMegapixels bounds the area of the images, in millions of pixels.
+	MaxMegapixels float64
+}
+
+// WithInputDownscale scales the init images and mask of img2img down to fit in l before sending
+// them, keeping their aspect ratio, so full resolution photos are not uploaded to be resized by
+// the server anyway. JPEG images stay JPEG, the others are sent as PNG, and the formats the
+// client cannot decode are sent as is. A Width and Height equal to the size of the first init
+// image are replaced by its scaled size, rounded down to a multiple of SizeMultiple.
+func WithInputDownscale(l InputLimit) Option {
+	return func(c *Client) {
+		c.inputLimit = &l
+	}
+}
+
+// scale returns the factor fitting size in l, 1 if it fits.
+func (l *InputLimit) scale(size image.Point) float64 {
+	s := 1.0
+	if l.MaxDimension > 0 {
+		if d := max(size.X, size.Y); d > l.MaxDimension {
+			s = float64(l.MaxDimension) / float64(d)
+		}
+	}
+	if l.MaxMegapixels > 0 {
+		if mp := float64(size.X*size.Y) / 1e6; mp > l.MaxMegapixels {
+			s = min(s, math.Sqrt(l.MaxMegapixels/mp))
+		}
+	}
+	return s
+}
+
+// downscaleInputs applies the InputLimit to the images of opt.
+func (c *Client) downscaleInputs(opt *Img2ImgOption) error {
+	if c.inputLimit == nil {
+		return nil
+	}
+
+	var from, to image.Point
+	// The slice is shared with the caller.
+	opt.InitImages = append([]string(nil), opt.InitImages...)
+	for i, raw := range opt.InitImages {
+		scaled, size, scaledSize, err := c.inputLimit.downscale(raw)
+		if err != nil {
+			return fmt.Errorf("failed to downscale init image %d: %w", i, err)
+		}
+		if i == 0 {
+			from, to = size, scaledSize
+		}
+		opt.InitImages[i] = scaled
+	}
+	if len(opt.Mask) != 0 {
+		scaled, _, _, err := c.inputLimit.downscale(opt.Mask)
+		if err != nil {
+			return fmt.Errorf("failed to downscale mask: %w", err)
+		}
+		opt.Mask = scaled
+	}
+
+	if from != to && opt.Width == from.X && opt.Height == from.Y {
+		opt.Width, opt.Height = roundSize(to.X), roundSize(to.Y)
+	}
+	return nil
+}
+
+// downscale returns the encoded image scaled to fit in l, raw itself if it fits, along with the
+// original and scaled sizes.
+func (l *InputLimit) downscale(raw string) (string, image.Point, image.Point, error) {
+	data, err := decodeBase64(raw)
+	if err != nil {
+		return "", image.Point{}, image.Point{}, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return raw, image.Point{}, image.Point{}, nil
+	} else if err != nil {
+		return "", image.Point{}, image.Point{}, err
+	}
+	size := image.Pt(cfg.Width, cfg.Height)
+	s := l.scale(size)
+	if s >= 1 {
+		return raw, size, size, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", image.Point{}, image.Point{}, err
+	}
+	scaled := image.Pt(max(1, int(float64(size.X)*s)), max(1, int(float64(size.Y)*s)))
+	dst := image.NewRGBA(image.Rectangle{Max: scaled})
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+
+	buf := &bytes.Buffer{}
+	if DetectFormat(data) == FormatJPEG {
+		err = jpeg.Encode(buf, dst, &jpeg.Options{Quality: 95})
+	} else {
+		err = png.Encode(buf, dst)
+	}
+	if err != nil {
+		return "", image.Point{}, image.Point{}, err
+	}
+	encoded, err := EncodeImage(buf.Bytes())
+	return encoded, size, scaled, err
+}
@@ -0,0 +1,91 @@
+package sdcli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrDryRun is returned by the calls made with WithDryRun, their request was built but not sent.
+var ErrDryRun = errors.New("dry run, request not sent")
+
+// PreparedRequest is a request built by a dry run.
+type PreparedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Header has the headers of the request, the credentials redacted.
+	Header http.Header `json:"header"`
+	// Body is the exact payload, JSON for most endpoints.
+	Body []byte `json:"body,omitempty"`
+}
+
+// Curl returns the request as a curl command, without the credentials.
+func (r *PreparedRequest) Curl() string {
+	b := &strings.Builder{}
+	b.WriteString("curl -X " + r.Method + " " + shellQuote(r.URL))
+	keys := make([]string, 0, len(r.Header))
+	for key := range r.Header {
+		if !debugRedactedHeaders[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range r.Header[key] {
+			b.WriteString(" -H " + shellQuote(key+": "+value))
+		}
+	}
+	if len(r.Body) != 0 {
+		b.WriteString(" --data-binary " + shellQuote(string(r.Body)))
+	}
+	return b.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// WithDryRun builds the request of a single call into dst instead of sending it, the call then
+// fails with ErrDryRun. The lookups of WithPreflight are still sent, see ContextWithDryRun for
+// the calls without request options.
+func WithDryRun(dst *PreparedRequest) RequestOption {
+	return func(r *requestConfig) {
+		r.dryRun = dst
+	}
+}
+
+type dryRunKey struct{}
+
+// ContextWithDryRun makes the requests sent with ctx other than GETs dry runs into dst, see
+// WithDryRun.
+func ContextWithDryRun(ctx context.Context, dst *PreparedRequest) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, dst)
+}
+
+// dryRun fills the dry run of ctx if any, GET requests are sent as they do not change anything.
+func (c *Client) dryRun(ctx context.Context, requestID, path, method, contentType string, payload []byte) (bool, error) {
+	dst, _ := ctx.Value(dryRunKey{}).(*PreparedRequest)
+	if dst == nil || method == http.MethodGet {
+		return false, nil
+	}
+
+	req, err := c.newRequest(ctx, requestID, path, method, contentType, payload)
+	if err != nil {
+		return true, err
+	}
+	header := req.Header.Clone()
+	for key := range header {
+		if debugRedactedHeaders[key] {
+			header.Set(key, "[redacted]")
+		}
+	}
+	*dst = PreparedRequest{
+		Method: method,
+		URL:    req.URL.String(),
+		Header: header,
+		Body:   payload,
+	}
+	return true, ErrDryRun
+}
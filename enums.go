@@ -0,0 +1,81 @@
+package sdcli
+
+// ResizeMode is how img2img fits the init images to the requested size.
+type ResizeMode int
+
+const (
+	// ResizeJust stretches the images to the size.
+	ResizeJust ResizeMode = iota
+	// ResizeCrop resizes keeping the aspect ratio and crops the overflow.
+	ResizeCrop
+	// ResizeFill resizes keeping the aspect ratio and pads with the edge colors.
+	ResizeFill
+	// ResizeLatent stretches in latent space.
+	ResizeLatent
+)
+
+// InpaintingFill is what the masked area starts from before denoising.
+type InpaintingFill int
+
+const (
+	// InpaintFill fills the mask with the surrounding colors.
+	InpaintFill InpaintingFill = iota
+	// InpaintOriginal keeps the original content, the WebUI default.
+	InpaintOriginal
+	// InpaintLatentNoise fills the mask with noise.
+	InpaintLatentNoise
+	// InpaintLatentNothing fills the mask with zeros in latent space.
+	InpaintLatentNothing
+)
+
+// MaskInvert tells which side of the mask is repainted.
+type MaskInvert int
+
+const (
+	// InpaintMasked repaints the white area of the mask.
+	InpaintMasked MaskInvert = iota
+	// InpaintNotMasked repaints the black area of the mask.
+	InpaintNotMasked
+)
+
+// ExtrasResizeMode is how the extras endpoint sizes the upscaled image.
+type ExtrasResizeMode int
+
+const (
+	// ExtrasResizeBy upscales by UpscalingResize.
+	ExtrasResizeBy ExtrasResizeMode = iota
+	// ExtrasResizeTo upscales to UpscalingResizeW x UpscalingResizeH.
+	ExtrasResizeTo
+)
+
+// Names of the samplers shipped with WebUI, extensions may add more.
+const (
+	SamplerEulerA           = "Euler a"
+	SamplerEuler            = "Euler"
+	SamplerLMS              = "LMS"
+	SamplerHeun             = "Heun"
+	SamplerDPM2             = "DPM2"
+	SamplerDPM2A            = "DPM2 a"
+	SamplerDPMPP2SA         = "DPM++ 2S a"
+	SamplerDPMPP2M          = "DPM++ 2M"
+	SamplerDPMPPSDE         = "DPM++ SDE"
+	SamplerDPMPP2MSDE       = "DPM++ 2M SDE"
+	SamplerDPMFast          = "DPM fast"
+	SamplerDPMAdaptive      = "DPM adaptive"
+	SamplerLMSKarras        = "LMS Karras"
+	SamplerDPM2Karras       = "DPM2 Karras"
+	SamplerDPM2AKarras      = "DPM2 a Karras"
+	SamplerDPMPP2SAKarras   = "DPM++ 2S a Karras"
+	SamplerDPMPP2MKarras    = "DPM++ 2M Karras"
+	SamplerDPMPPSDEKarras   = "DPM++ SDE Karras"
+	SamplerDPMPP2MSDEKarras = "DPM++ 2M SDE Karras"
+	SamplerDDIM             = "DDIM"
+	SamplerPLMS             = "PLMS"
+	SamplerUniPC            = "UniPC"
+	SamplerLCM              = "LCM"
+	SamplerRestart          = "Restart"
+	SamplerDPMPP3MSDE       = "DPM++ 3M SDE"
+	SamplerDPMPP3MSDEKarras = "DPM++ 3M SDE Karras"
+	SamplerDPMPP2MSDEExpo   = "DPM++ 2M SDE Exponential"
+	SamplerDPMPP3MSDEExpo   = "DPM++ 3M SDE Exponential"
+)
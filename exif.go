@@ -0,0 +1,338 @@
+package sdcli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// EXIF tags used to store the infotext, like WebUI does for JPEG and WebP outputs.
+const (
+	exifIFDPointerTag = 0x8769
+	userCommentTag    = 0x9286
+)
+
+var (
+	exifHeader        = []byte("Exif\x00\x00")
+	unicodeCommentTag = []byte("UNICODE\x00")
+	asciiCommentTag   = []byte("ASCII\x00\x00\x00")
+)
+
+// SetParameters stores the infotext in PNG, JPEG or WebP data the way WebUI does so the PNG Info
+// tab reads it back: the parameters text chunk of PNG files and the EXIF UserComment of the others.
+func SetParameters(data []byte, parameters string) ([]byte, error) {
+	switch DetectFormat(data) {
+	case FormatPNG:
+		return SetPNGParameters(data, parameters)
+	case FormatJPEG:
+		return setJPEGExif(data, userCommentExif(parameters))
+	case FormatWEBP:
+		return setWebPExif(data, userCommentExif(parameters)[len(exifHeader):])
+	}
+
+	return nil, fmt.Errorf("unsupported format for parameters")
+}
+
+// Parameters returns the infotext stored in PNG, JPEG or WebP data.
+func Parameters(data []byte) (string, bool) {
+	switch DetectFormat(data) {
+	case FormatPNG:
+		return PNGParameters(data)
+	case FormatJPEG:
+		if exif := jpegExif(data); exif != nil {
+			return userComment(exif[len(exifHeader):])
+		}
+	case FormatWEBP:
+		if exif := webpChunk(data, "EXIF"); exif != nil {
+			return userComment(bytes.TrimPrefix(exif, exifHeader))
+		}
+	}
+
+	return "", false
+}
+
+// userCommentExif returns an APP1 EXIF payload holding only the UserComment, encoded as UTF-16
+// big endian text like piexif does for WebUI.
+func userCommentExif(comment string) []byte {
+	u := utf16.Encode([]rune(comment))
+	value := make([]byte, len(unicodeCommentTag)+2*len(u))
+	copy(value, unicodeCommentTag)
+	for i, c := range u {
+		binary.BigEndian.PutUint16(value[len(unicodeCommentTag)+2*i:], c)
+	}
+
+	// TIFF header, IFD0 with the Exif IFD pointer, the Exif IFD with the UserComment, then its value.
+	const ifd0, exifIFD, valueOffset = 8, 8 + 18, 8 + 18 + 18
+	buf := &bytes.Buffer{}
+	buf.Write(exifHeader)
+	buf.WriteString("MM\x00\x2a")
+	be := func(v any) { _ = binary.Write(buf, binary.BigEndian, v) }
+	be(uint32(ifd0))
+
+	be(uint16(1))
+	be([]uint16{exifIFDPointerTag, 4})
+	be([]uint32{1, exifIFD})
+	be(uint32(0))
+
+	be(uint16(1))
+	be([]uint16{userCommentTag, 7})
+	be([]uint32{uint32(len(value)), valueOffset})
+	be(uint32(0))
+
+	buf.Write(value)
+	return buf.Bytes()
+}
+
+// userComment reads the UserComment of a TIFF structured EXIF blob.
+func userComment(tiff []byte) (string, bool) {
+	if len(tiff) < 8 {
+		return "", false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "MM":
+		order = binary.BigEndian
+	case "II":
+		order = binary.LittleEndian
+	default:
+		return "", false
+	}
+
+	entry := func(ifd uint32, tag uint16) (count, offset uint32, ok bool) {
+		if uint64(ifd)+2 > uint64(len(tiff)) {
+			return 0, 0, false
+		}
+		n := order.Uint16(tiff[ifd:])
+		for i := uint32(0); i < uint32(n); i++ {
+			p := ifd + 2 + 12*i
+			if uint64(p)+12 > uint64(len(tiff)) {
+				return 0, 0, false
+			}
+			if order.Uint16(tiff[p:]) == tag {
+				return order.Uint32(tiff[p+4:]), order.Uint32(tiff[p+8:]), true
+			}
+		}
+		return 0, 0, false
+	}
+
+	_, exifIFD, ok := entry(order.Uint32(tiff[4:]), exifIFDPointerTag)
+	if !ok {
+		return "", false
+	}
+	count, offset, ok := entry(exifIFD, userCommentTag)
+	if !ok || count < 8 || uint64(offset)+uint64(count) > uint64(len(tiff)) {
+		return "", false
+	}
+	value := tiff[offset : offset+count]
+
+	switch {
+	case bytes.HasPrefix(value, unicodeCommentTag):
+		value = value[len(unicodeCommentTag):]
+		u := make([]uint16, len(value)/2)
+		for i := range u {
+			// piexif encodes in big endian whatever the byte order of the TIFF structure.
+			u[i] = binary.BigEndian.Uint16(value[2*i:])
+		}
+		return string(utf16.Decode(u)), true
+	case bytes.HasPrefix(value, asciiCommentTag):
+		return string(bytes.TrimRight(value[len(asciiCommentTag):], "\x00")), true
+	}
+
+	return string(bytes.TrimRight(value[8:], "\x00")), true
+}
+
+// setJPEGExif replaces the APP1 EXIF segment of a JPEG file, placing it after the SOI and JFIF segments.
+func setJPEGExif(data, exif []byte) ([]byte, error) {
+	if len(exif)+2 > 0xffff {
+		return nil, fmt.Errorf("exif data too large for a jpeg segment")
+	}
+
+	segments, rest, err := jpegSegments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)+len(exif)+4))
+	out.Write([]byte{0xff, 0xd8})
+	written := false
+	for _, s := range segments {
+		if s[1] == 0xe1 && bytes.HasPrefix(s[4:], exifHeader) {
+			continue
+		}
+		if !written && s[1] != 0xe0 {
+			writeJPEGSegment(out, 0xe1, exif)
+			written = true
+		}
+		out.Write(s)
+	}
+	if !written {
+		writeJPEGSegment(out, 0xe1, exif)
+	}
+	out.Write(rest)
+
+	return out.Bytes(), nil
+}
+
+func jpegExif(data []byte) []byte {
+	segments, _, err := jpegSegments(data)
+	if err != nil {
+		return nil
+	}
+	for _, s := range segments {
+		if s[1] == 0xe1 && bytes.HasPrefix(s[4:], exifHeader) {
+			return s[4:]
+		}
+	}
+	return nil
+}
+
+// jpegSegments splits the marker segments of a JPEG file up to the start of scan,
+// rest is the scan and everything after it.
+func jpegSegments(data []byte) (segments [][]byte, rest []byte, err error) {
+	if !bytes.HasPrefix(data, []byte{0xff, 0xd8}) {
+		return nil, nil, fmt.Errorf("invalid jpeg: missing SOI")
+	}
+
+	p := 2
+	for p+4 <= len(data) {
+		if data[p] != 0xff {
+			return nil, nil, fmt.Errorf("invalid jpeg: bad marker")
+		}
+		if data[p+1] == 0xda {
+			return segments, data[p:], nil
+		}
+		n := int(binary.BigEndian.Uint16(data[p+2:]))
+		if p+2+n > len(data) {
+			return nil, nil, fmt.Errorf("invalid jpeg: truncated segment")
+		}
+		segments = append(segments, data[p:p+2+n])
+		p += 2 + n
+	}
+
+	return nil, nil, fmt.Errorf("invalid jpeg: missing SOS")
+}
+
+func writeJPEGSegment(w *bytes.Buffer, marker byte, payload []byte) {
+	w.Write([]byte{0xff, marker})
+	_ = binary.Write(w, binary.BigEndian, uint16(len(payload)+2))
+	w.Write(payload)
+}
+
+// WebP VP8X flags.
+const webpFlagExif = 0x08
+
+// setWebPExif replaces the EXIF chunk of a WebP file, converting simple files to the extended format.
+func setWebPExif(data, exif []byte) ([]byte, error) {
+	chunks, err := webpChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var vp8x []byte
+	for _, c := range chunks {
+		if c.typ == "VP8X" {
+			vp8x = append([]byte(nil), c.data...)
+		}
+	}
+	if vp8x == nil {
+		w, h, err := webpSize(chunks)
+		if err != nil {
+			return nil, err
+		}
+		vp8x = make([]byte, 10)
+		putUint24(vp8x[4:], uint32(w-1))
+		putUint24(vp8x[7:], uint32(h-1))
+	}
+	vp8x[0] |= webpFlagExif
+
+	body := &bytes.Buffer{}
+	body.WriteString("WEBP")
+	writeWebPChunk(body, "VP8X", vp8x)
+	for _, c := range chunks {
+		if c.typ == "VP8X" || c.typ == "EXIF" {
+			continue
+		}
+		writeWebPChunk(body, c.typ, c.data)
+	}
+	writeWebPChunk(body, "EXIF", exif)
+
+	out := bytes.NewBuffer(make([]byte, 0, body.Len()+8))
+	out.WriteString("RIFF")
+	_ = binary.Write(out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+
+	return out.Bytes(), nil
+}
+
+func webpChunk(data []byte, typ string) []byte {
+	chunks, err := webpChunks(data)
+	if err != nil {
+		return nil
+	}
+	for _, c := range chunks {
+		if c.typ == typ {
+			return c.data
+		}
+	}
+	return nil
+}
+
+type webpChunkData struct {
+	typ  string
+	data []byte
+}
+
+func webpChunks(data []byte) ([]webpChunkData, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("invalid webp: bad header")
+	}
+
+	var chunks []webpChunkData
+	p := 12
+	for p+8 <= len(data) {
+		n := int(binary.LittleEndian.Uint32(data[p+4:]))
+		if p+8+n > len(data) {
+			return nil, fmt.Errorf("invalid webp: truncated chunk")
+		}
+		chunks = append(chunks, webpChunkData{typ: string(data[p : p+4]), data: data[p+8 : p+8+n]})
+		p += 8 + n + n%2
+	}
+
+	return chunks, nil
+}
+
+// webpSize reads the canvas size from the VP8 or VP8L bitstream of a simple WebP file.
+func webpSize(chunks []webpChunkData) (int, int, error) {
+	for _, c := range chunks {
+		switch c.typ {
+		case "VP8 ":
+			if len(c.data) < 10 {
+				return 0, 0, fmt.Errorf("invalid webp: short VP8 chunk")
+			}
+			w := int(binary.LittleEndian.Uint16(c.data[6:]) & 0x3fff)
+			h := int(binary.LittleEndian.Uint16(c.data[8:]) & 0x3fff)
+			return w, h, nil
+		case "VP8L":
+			if len(c.data) < 5 || c.data[0] != 0x2f {
+				return 0, 0, fmt.Errorf("invalid webp: bad VP8L chunk")
+			}
+			bits := binary.LittleEndian.Uint32(c.data[1:])
+			return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid webp: missing image data")
+}
+
+func writeWebPChunk(w *bytes.Buffer, typ string, data []byte) {
+	w.WriteString(typ)
+	_ = binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+	if len(data)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
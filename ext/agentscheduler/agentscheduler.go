@@ -0,0 +1,252 @@
+// Package agentscheduler is a client for the task queue extension (sd-webui-agent-scheduler),
+// which keeps the tasks and their results on the server: a generation survives the connection
+// of its client and is retrieved once finished.
+package agentscheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Statuses of a task.
+const (
+	StatusPending     = "pending"
+	StatusRunning     = "running"
+	StatusDone        = "done"
+	StatusFailed      = "failed"
+	StatusInterrupted = "interrupted"
+	StatusSaved       = "saved"
+)
+
+// DefaultPollInterval is how often Wait checks the status of a task.
+const DefaultPollInterval = time.Second
+
+// Client calls the /agent-scheduler/v1 routes through a sdcli.Client.
+type Client struct {
+	cli sdcli.Doer
+	// PollInterval is the interval of Wait, DefaultPollInterval if 0.
+	PollInterval time.Duration
+}
+
+func New(cli sdcli.Doer) *Client {
+	return &Client{cli: cli}
+}
+
+var _ sdcli.ResultRecoverer = (*Client)(nil)
+
+// QueueOption are the scheduler fields added to the generation payload.
+type QueueOption struct {
+	// Checkpoint and VAE run the task with these models, the current ones if empty.
+	Checkpoint string `json:"checkpoint,omitempty"`
+	VAE        string `json:"vae,omitempty"`
+	// CallbackURL is called by the server when the task finishes.
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+type queueResponse struct {
+	TaskID string `json:"task_id"`
+}
+
+// QueueTxt2Img adds a txt2img task to the queue and returns its ID.
+func (c *Client) QueueTxt2Img(ctx context.Context, opt sdcli.Txt2ImageOption, qopt QueueOption) (string, error) {
+	return c.queue(ctx, "txt2img", &opt, qopt)
+}
+
+// QueueImg2Img adds an img2img task to the queue and returns its ID.
+func (c *Client) QueueImg2Img(ctx context.Context, opt sdcli.Img2ImgOption, qopt QueueOption) (string, error) {
+	return c.queue(ctx, "img2img", &opt, qopt)
+}
+
+func (c *Client) queue(ctx context.Context, mode string, payload any, qopt QueueOption) (string, error) {
+	body, err := merge(payload, qopt)
+	if err != nil {
+		return "", err
+	}
+	res := new(queueResponse)
+	if err := c.cli.Do(ctx, http.MethodPost, "/agent-scheduler/v1/queue/"+mode, body, res); err != nil {
+		return "", err
+	}
+	if len(res.TaskID) == 0 {
+		return "", errors.New("agent scheduler returned no task ID")
+	}
+	return res.TaskID, nil
+}
+
+// merge adds the fields of extra to the JSON object of payload.
+func merge(payload, extra any) (map[string]json.RawMessage, error) {
+	body := map[string]json.RawMessage{}
+	for _, v := range []any{payload, extra} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode task: %w", err)
+		}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, fmt.Errorf("failed to encode task: %w", err)
+		}
+	}
+	return body, nil
+}
+
+// Task is a task of the queue or history.
+type Task struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Type      string          `json:"type"`
+	Status    string          `json:"status"`
+	Priority  int64           `json:"priority"`
+	Params    json.RawMessage `json:"params"`
+	Result    string          `json:"result"`
+	CreatedAt int64           `json:"created_at"`
+	UpdatedAt int64           `json:"updated_at"`
+}
+
+// Finished reports whether the task will not run anymore.
+func (t *Task) Finished() bool {
+	switch t.Status {
+	case StatusDone, StatusFailed, StatusInterrupted, StatusSaved:
+		return true
+	}
+	return false
+}
+
+type envelope[T any] struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Data    T      `json:"data"`
+}
+
+func (e *envelope[T]) err(what string) error {
+	if e.Success {
+		return nil
+	}
+	msg := e.Message
+	if len(msg) == 0 {
+		msg = "unknown error"
+	}
+	return fmt.Errorf("failed to get %s: %s", what, msg)
+}
+
+// Task returns the task of id.
+func (c *Client) Task(ctx context.Context, id string) (*Task, error) {
+	res := new(envelope[*Task])
+	if err := c.cli.Do(ctx, http.MethodGet, "/agent-scheduler/v1/task/"+url.PathEscape(id), nil, res); err != nil {
+		return nil, err
+	}
+	if err := res.err("task " + id); err != nil {
+		return nil, err
+	}
+	if res.Data == nil {
+		return nil, fmt.Errorf("task %s not found", id)
+	}
+	return res.Data, nil
+}
+
+// Image is an output of a task.
+type Image struct {
+	// Image is a base64 data URI.
+	Image    string `json:"image"`
+	Infotext string `json:"infotext"`
+}
+
+// Results returns the images of a finished task.
+func (c *Client) Results(ctx context.Context, id string) ([]*Image, error) {
+	res := new(envelope[[]*Image])
+	if err := c.cli.Do(ctx, http.MethodGet, "/agent-scheduler/v1/task/"+url.PathEscape(id)+"/results", nil, res); err != nil {
+		return nil, err
+	}
+	if err := res.err("results of task " + id); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// Wait polls the task until it finishes. Polling errors are retried until ctx is done, the
+// server may restart or the network drop while the task runs.
+func (c *Client) Wait(ctx context.Context, id string) (*Task, error) {
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		task, err := c.Task(ctx, id)
+		if err == nil && task.Finished() {
+			return task, nil
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return nil, fmt.Errorf("failed to wait for task %s: %w", id, errors.Join(ctx.Err(), lastErr))
+			}
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunTxt2Img queues a txt2img task, waits for it and returns its images.
+func (c *Client) RunTxt2Img(ctx context.Context, opt sdcli.Txt2ImageOption, qopt QueueOption) (*Task, []*Image, error) {
+	id, err := c.QueueTxt2Img(ctx, opt, qopt)
+	if err != nil {
+		return nil, nil, err
+	}
+	task, err := c.Wait(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if task.Status != StatusDone && task.Status != StatusSaved {
+		return task, nil, fmt.Errorf("task %s %s: %s", id, task.Status, task.Result)
+	}
+	images, err := c.Results(ctx, id)
+	return task, images, err
+}
+
+// RecoverResult implements sdcli.ResultRecoverer for the tasks run by the agent scheduler,
+// waiting for the ones not finished yet. The info is built from the infotexts of the images.
+func (c *Client) RecoverResult(ctx context.Context, taskID string) ([]string, string, error) {
+	// Look the task up once, so unknown tasks fail instead of being waited for.
+	task, err := c.Task(ctx, taskID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !task.Finished() {
+		if task, err = c.Wait(ctx, taskID); err != nil {
+			return nil, "", err
+		}
+	}
+	if task.Status != StatusDone && task.Status != StatusSaved {
+		return nil, "", fmt.Errorf("task %s %s", taskID, task.Status)
+	}
+	results, err := c.Results(ctx, taskID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	images := make([]string, len(results))
+	infotexts := make([]string, len(results))
+	for i, r := range results {
+		images[i], infotexts[i] = r.Image, r.Infotext
+	}
+	info := map[string]any{"infotexts": infotexts}
+	if len(infotexts) != 0 {
+		info["prompt"] = strings.SplitN(infotexts[0], "\n", 2)[0]
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode info: %w", err)
+	}
+	return images, string(data), nil
+}
@@ -0,0 +1,119 @@
+// Package animatediff provides typed alwayson script arguments for the sd-webui-animatediff extension.
+package animatediff
+
+import (
+	"fmt"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Output formats, the video formats are returned in the Videos of generation responses
+// and FormatFrame returns every frame in ParsedImages.
+const (
+	FormatGIF   = "GIF"
+	FormatMP4   = "MP4"
+	FormatWEBP  = "WEBP"
+	FormatWEBM  = "WEBM"
+	FormatPNG   = "PNG"
+	FormatTXT   = "TXT"
+	FormatFrame = "Frame"
+)
+
+// ClosedLoop controls how the animation loops back to its first frame.
+type ClosedLoop string
+
+const (
+	// ClosedLoopNone does not close the loop.
+	ClosedLoopNone ClosedLoop = "N"
+	// ClosedLoopReducePrompt closes the loop by reducing the prompt travel.
+	ClosedLoopReducePrompt ClosedLoop = "R-P"
+	// ClosedLoopReduceAddPrompt closes the loop and keeps the prompt travel.
+	ClosedLoopReduceAddPrompt ClosedLoop = "R+P"
+	// ClosedLoopAutomatic lets the extension choose.
+	ClosedLoopAutomatic ClosedLoop = "A"
+)
+
+const DefaultModel = "mm_sd15_v3.safetensors"
+
+// Args is the AnimateDiff alwayson script arguments, sent as a single JSON object.
+type Args struct {
+	Enable bool `json:"enable"`
+	// Model is the motion module file name.
+	Model  string   `json:"model"`
+	Format []string `json:"format"`
+	// VideoLength is the number of frames.
+	VideoLength int `json:"video_length"`
+	FPS         int `json:"fps"`
+	// LoopNumber is the number of loops of GIF/WEBP outputs, 0 loops forever.
+	LoopNumber int        `json:"loop_number"`
+	ClosedLoop ClosedLoop `json:"closed_loop"`
+	// BatchSize is the context batch size, the number of frames the motion module sees at once.
+	BatchSize int `json:"batch_size"`
+	Stride    int `json:"stride"`
+	// Overlap between context batches, -1 uses a quarter of BatchSize.
+	Overlap int `json:"overlap"`
+	// Interp is the frame interpolation, "Off" or "FILM".
+	Interp  string `json:"interp"`
+	InterpX int    `json:"interp_x"`
+
+	// VideoSource is a base64 video used as ControlNet source, VideoPath a server side path.
+	VideoSource string `json:"video_source,omitempty"`
+	VideoPath   string `json:"video_path,omitempty"`
+
+	// LatentPower and LatentScale control the img2img frame latents.
+	LatentPower     float32 `json:"latent_power"`
+	LatentScale     float32 `json:"latent_scale"`
+	LastFrame       string  `json:"last_frame,omitempty"`
+	LatentPowerLast float32 `json:"latent_power_last"`
+	LatentScaleLast float32 `json:"latent_scale_last"`
+
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// New returns enabled arguments with the extension defaults, producing a 16 frame GIF at 8 fps.
+func New() *Args {
+	return &Args{
+		Enable:          true,
+		Model:           DefaultModel,
+		Format:          []string{FormatGIF},
+		VideoLength:     16,
+		FPS:             8,
+		ClosedLoop:      ClosedLoopReducePrompt,
+		BatchSize:       16,
+		Stride:          1,
+		Overlap:         -1,
+		Interp:          "Off",
+		InterpX:         10,
+		LatentPower:     1,
+		LatentScale:     32,
+		LatentPowerLast: 1,
+		LatentScaleLast: 32,
+	}
+}
+
+var _ sdcli.Script = (*Args)(nil)
+
+func (a *Args) ScriptName() string {
+	return "animatediff"
+}
+
+func (a *Args) ScriptArgs(img2img bool) ([]interface{}, error) {
+	if a.Enable && a.VideoLength < 0 {
+		return nil, fmt.Errorf("animatediff: video length must not be negative")
+	}
+	if a.Enable && a.BatchSize <= 0 {
+		return nil, fmt.Errorf("animatediff: context batch size must be positive")
+	}
+
+	return []interface{}{a}, nil
+}
+
+// Video returns the first video of format (one of the sdcli.Format* constants) in the response videos.
+func Video(videos []*sdcli.Video, format string) *sdcli.Video {
+	for _, v := range videos {
+		if v.Format == format {
+			return v
+		}
+	}
+	return nil
+}
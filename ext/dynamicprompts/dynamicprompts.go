@@ -0,0 +1,89 @@
+// Package dynamicprompts provides typed alwayson script arguments for the sd-dynamic-prompts extension.
+package dynamicprompts
+
+import (
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// DefaultTitle is the script title of sd-dynamic-prompts 2.17.1, the title carries the extension
+// version so set Args.Title when running another version.
+const DefaultTitle = "Dynamic Prompts v2.17.1"
+
+// DefaultMagicModel is the default MagicPrompt model.
+const DefaultMagicModel = "Gustavosta/MagicPrompt-Stable-Diffusion"
+
+// Args is the alwayson script arguments of sd-dynamic-prompts, use New for the extension defaults.
+type Args struct {
+	// Title overrides DefaultTitle.
+	Title string
+
+	Enabled bool
+	// Combinatorial generates every combination instead of random ones.
+	Combinatorial bool
+	// CombinatorialBatches repeats the combinatorial generation.
+	CombinatorialBatches int
+	// MaxGenerations caps the combinatorial prompts, 0 means all.
+	MaxGenerations int
+
+	MagicPrompt          bool
+	MagicPromptLength    int
+	MagicTemperature     float32
+	MagicModel           string
+	MagicBlocklistRegex  string
+	FeelingLucky         bool
+	AttentionGrabber     bool
+	MinAttention         float32
+	MaxAttention         float32
+	UseFixedSeed         bool
+	UnlinkSeedFromPrompt bool
+	// DisableNegativePrompt skips wildcard processing of the negative prompt.
+	DisableNegativePrompt bool
+	EnableJinjaTemplates  bool
+	// NoImageGeneration only expands the prompts, the result info holds them.
+	NoImageGeneration bool
+}
+
+// New returns enabled arguments with the extension defaults.
+func New() *Args {
+	return &Args{
+		Enabled:              true,
+		CombinatorialBatches: 1,
+		MagicPromptLength:    100,
+		MagicTemperature:     0.7,
+		MagicModel:           DefaultMagicModel,
+		MinAttention:         1.1,
+		MaxAttention:         1.5,
+	}
+}
+
+var _ sdcli.Script = (*Args)(nil)
+
+func (a *Args) ScriptName() string {
+	if len(a.Title) != 0 {
+		return a.Title
+	}
+	return DefaultTitle
+}
+
+func (a *Args) ScriptArgs(img2img bool) ([]interface{}, error) {
+	return []interface{}{
+		a.Enabled,
+		a.Combinatorial,
+		a.CombinatorialBatches,
+		a.MagicPrompt,
+		a.FeelingLucky,
+		a.AttentionGrabber,
+		a.MinAttention,
+		a.MaxAttention,
+		a.MagicPromptLength,
+		a.MagicTemperature,
+		a.UseFixedSeed,
+		a.UnlinkSeedFromPrompt,
+		a.DisableNegativePrompt,
+		a.EnableJinjaTemplates,
+		a.NoImageGeneration,
+		a.MaxGenerations,
+		a.MagicModel,
+		a.MagicBlocklistRegex,
+	}, nil
+}
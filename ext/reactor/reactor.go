@@ -0,0 +1,164 @@
+// Package reactor is a client for the ReActor face swap extension API and provides its alwayson script arguments.
+package reactor
+
+import (
+	"context"
+	"image"
+	"net/http"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+const (
+	DefaultModel = "inswapper_128.onnx"
+
+	RestorerNone       = "None"
+	RestorerCodeFormer = "CodeFormer"
+	RestorerGFPGAN     = "GFPGAN"
+
+	DeviceCPU  = "CPU"
+	DeviceCUDA = "CUDA"
+)
+
+// Gender filters the detected faces.
+type Gender int
+
+const (
+	GenderAny Gender = iota
+	GenderFemale
+	GenderMale
+)
+
+// Source selects where the source face comes from.
+type Source int
+
+const (
+	SourceImage Source = iota
+	SourceFaceModel
+	SourceFolder
+)
+
+// Client calls the /reactor routes through a sdcli.Client.
+type Client struct {
+	cli sdcli.Doer
+}
+
+func New(cli sdcli.Doer) *Client {
+	return &Client{cli: cli}
+}
+
+// ImageOption is the request of /reactor/image, images are base64 strings such as produced by sdcli.Img2Base64.
+type ImageOption struct {
+	SourceImage string `json:"source_image"`
+	TargetImage string `json:"target_image"`
+	// SourceFacesIndex and FaceIndex select faces of the source and target images, 0 is the first face.
+	SourceFacesIndex []int `json:"source_faces_index"`
+	FaceIndex        []int `json:"face_index"`
+
+	Upscaler          string  `json:"upscaler"`
+	Scale             float32 `json:"scale"`
+	UpscaleVisibility float32 `json:"upscale_visibility"`
+	UpscaleForce      bool    `json:"upscale_force"`
+
+	FaceRestorer       string  `json:"face_restorer"`
+	RestorerVisibility float32 `json:"restorer_visibility"`
+	CodeformerWeight   float32 `json:"codeformer_weight"`
+	// RestoreFirst restores the face before upscaling.
+	RestoreFirst bool `json:"restore_first"`
+
+	Model        string `json:"model"`
+	GenderSource Gender `json:"gender_source"`
+	GenderTarget Gender `json:"gender_target"`
+	Device       string `json:"device"`
+	MaskFace     bool   `json:"mask_face"`
+
+	SelectSource Source `json:"select_source"`
+	// FaceModel is a face model file name from models/reactor/faces, used with SourceFaceModel.
+	FaceModel string `json:"face_model"`
+	// SourceFolder is a server side folder of source images, used with SourceFolder.
+	SourceFolder string `json:"source_folder"`
+	RandomImage  bool   `json:"random_image"`
+
+	SaveToFile     bool   `json:"save_to_file"`
+	ResultFilePath string `json:"result_file_path,omitempty"`
+}
+
+// NewImageOption returns an option swapping the first face of source onto the first face of target
+// with the extension defaults.
+func NewImageOption(source, target image.Image) ImageOption {
+	return ImageOption{
+		SourceImage:        sdcli.Img2Base64(source),
+		TargetImage:        sdcli.Img2Base64(target),
+		SourceFacesIndex:   []int{0},
+		FaceIndex:          []int{0},
+		Upscaler:           "None",
+		Scale:              1,
+		UpscaleVisibility:  1,
+		FaceRestorer:       RestorerNone,
+		RestorerVisibility: 1,
+		CodeformerWeight:   0.5,
+		RestoreFirst:       true,
+		Model:              DefaultModel,
+		Device:             DeviceCPU,
+		FaceModel:          "None",
+	}
+}
+
+type ImageResponse struct {
+	Image string `json:"image"`
+
+	ParsedImage image.Image `json:"-"`
+	RawImage    []byte      `json:"-"`
+}
+
+// SwapImage swaps faces between two images.
+func (c *Client) SwapImage(ctx context.Context, opt ImageOption) (*ImageResponse, error) {
+	res := new(ImageResponse)
+	if err := c.cli.Do(ctx, http.MethodPost, "/reactor/image", &opt, res); err != nil {
+		return nil, err
+	}
+
+	img, raw, err := sdcli.Base642Img(res.Image)
+	if err == nil {
+		res.ParsedImage = img
+	}
+	res.RawImage = raw
+
+	return res, nil
+}
+
+// Models lists the swap models.
+func (c *Client) Models(ctx context.Context) ([]string, error) {
+	var res struct {
+		Models []string `json:"models"`
+	}
+	if err := c.cli.Do(ctx, http.MethodGet, "/reactor/models", nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Models, nil
+}
+
+// Upscalers lists the upscalers usable by ReActor.
+func (c *Client) Upscalers(ctx context.Context) ([]string, error) {
+	var res struct {
+		Upscalers []string `json:"upscalers"`
+	}
+	if err := c.cli.Do(ctx, http.MethodGet, "/reactor/upscalers", nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Upscalers, nil
+}
+
+// FaceModels lists the saved face models.
+func (c *Client) FaceModels(ctx context.Context) ([]string, error) {
+	var res struct {
+		Facemodels []string `json:"facemodels"`
+	}
+	if err := c.cli.Do(ctx, http.MethodGet, "/reactor/facemodels", nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Facemodels, nil
+}
@@ -0,0 +1,134 @@
+package reactor
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Script is the alwayson script arguments swapping faces on txt2img and img2img results.
+type Script struct {
+	Enabled bool
+	// SourceImage is the base64 face source, used with SourceImage selection.
+	SourceImage      string
+	SourceFacesIndex []int
+	FaceIndex        []int
+	// Model is the model path or file name.
+	Model string
+
+	FaceRestorer       string
+	RestorerVisibility float32
+	CodeformerWeight   float32
+	RestoreFirst       bool
+
+	Upscaler          string
+	Scale             float32
+	UpscaleVisibility float32
+	ForceUpscale      bool
+
+	SwapInSource    bool
+	SwapInGenerated bool
+	// ConsoleLogLevel is 0 (min), 1 (medium) or 2 (max).
+	ConsoleLogLevel int
+	GenderSource    Gender
+	GenderTarget    Gender
+	SaveOriginal    bool
+	SourceHashCheck bool
+	TargetHashCheck bool
+	Device          string
+	MaskFace        bool
+
+	SelectSource Source
+	FaceModel    string
+	SourceFolder string
+	RandomImage  bool
+
+	DetectionThreshold float32
+	// MaxFaces is the maximum number of detected faces, 0 is unlimited.
+	MaxFaces int
+}
+
+// NewScript returns enabled script arguments swapping the first face of source into the generated images.
+func NewScript(source image.Image) *Script {
+	return &Script{
+		Enabled:            true,
+		SourceImage:        sdcli.Img2RawBase64(source),
+		SourceFacesIndex:   []int{0},
+		FaceIndex:          []int{0},
+		Model:              DefaultModel,
+		FaceRestorer:       RestorerCodeFormer,
+		RestorerVisibility: 1,
+		CodeformerWeight:   0.5,
+		RestoreFirst:       true,
+		Upscaler:           "None",
+		Scale:              1,
+		UpscaleVisibility:  1,
+		SwapInGenerated:    true,
+		ConsoleLogLevel:    1,
+		SourceHashCheck:    true,
+		Device:             DeviceCPU,
+		FaceModel:          "None",
+		DetectionThreshold: 0.5,
+	}
+}
+
+var _ sdcli.Script = (*Script)(nil)
+
+func (s *Script) ScriptName() string {
+	return "reactor"
+}
+
+func (s *Script) ScriptArgs(img2img bool) ([]interface{}, error) {
+	if s.SelectSource == SourceImage && s.Enabled && len(s.SourceImage) == 0 {
+		return nil, fmt.Errorf("reactor: source image is required")
+	}
+
+	return []interface{}{
+		s.SourceImage,
+		s.Enabled,
+		joinIndexes(s.SourceFacesIndex),
+		joinIndexes(s.FaceIndex),
+		s.Model,
+		s.FaceRestorer,
+		s.RestorerVisibility,
+		s.RestoreFirst,
+		s.Upscaler,
+		s.Scale,
+		s.UpscaleVisibility,
+		s.SwapInSource,
+		s.SwapInGenerated,
+		s.ConsoleLogLevel,
+		int(s.GenderSource),
+		int(s.GenderTarget),
+		s.SaveOriginal,
+		s.CodeformerWeight,
+		s.SourceHashCheck,
+		s.TargetHashCheck,
+		s.Device,
+		s.MaskFace,
+		int(s.SelectSource),
+		s.FaceModel,
+		s.SourceFolder,
+		nil, // Multiple source images, not usable through the API.
+		s.RandomImage,
+		s.ForceUpscale,
+		s.DetectionThreshold,
+		s.MaxFaces,
+	}, nil
+}
+
+func joinIndexes(idx []int) string {
+	if len(idx) == 0 {
+		return "0"
+	}
+
+	s := make([]string, len(idx))
+	for i, v := range idx {
+		s[i] = strconv.Itoa(v)
+	}
+
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,242 @@
+// Package regionalprompter provides typed alwayson script arguments for the Regional Prompter extension
+// and builds region prompts with its BREAK/ADDCOL/ADDROW/ADDBASE/ADDCOMM keywords.
+package regionalprompter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Mode is the region division mode.
+type Mode string
+
+const (
+	ModeMatrix Mode = "Matrix"
+	ModeMask   Mode = "Mask"
+	ModePrompt Mode = "Prompt"
+)
+
+// MatrixMode is the split direction of ModeMatrix.
+type MatrixMode string
+
+const (
+	MatrixHorizontal MatrixMode = "Horizontal"
+	MatrixVertical   MatrixMode = "Vertical"
+	MatrixColumns    MatrixMode = "Columns"
+	MatrixRows       MatrixMode = "Rows"
+)
+
+// PromptMode is the sub mode of ModePrompt.
+type PromptMode string
+
+const (
+	PromptModePrompt   PromptMode = "Prompt"
+	PromptModePromptEx PromptMode = "Prompt-Ex"
+)
+
+// CalcMode is how regions are applied.
+type CalcMode string
+
+const (
+	CalcAttention CalcMode = "Attention"
+	CalcLatent    CalcMode = "Latent"
+)
+
+// Prompt keywords.
+const (
+	KeywordBreak  = "BREAK"
+	KeywordAddCol = "ADDCOL"
+	KeywordAddRow = "ADDROW"
+	KeywordBase   = "ADDBASE"
+	KeywordCommon = "ADDCOMM"
+)
+
+// Args is the Regional Prompter alwayson script arguments.
+type Args struct {
+	Active     bool
+	Debug      bool
+	Mode       Mode
+	MatrixMode MatrixMode
+	PromptMode PromptMode
+	// Ratios is the division ratio, e.g. "1,1,1" or "1,2,1;1,1" for a two rows matrix, see Layout.
+	Ratios     string
+	BaseRatios string
+	UseBase    bool
+	UseCommon  bool
+	// UseNegCommon applies the first part of the negative prompt to every region.
+	UseNegCommon bool
+	CalcMode     CalcMode
+	// NotChangeAND keeps AND in the prompt instead of converting it to BREAK.
+	NotChangeAND    bool
+	LoRATextEncoder string
+	LoRAUNet        string
+	// Threshold is the mask threshold used by ModePrompt.
+	Threshold string
+	// Mask is the base64 region mask used by ModeMask.
+	Mask          string
+	LoRAStopStep  string
+	LoRAHiresStop string
+	Flip          bool
+}
+
+// New returns active arguments using the vertical matrix mode.
+func New() *Args {
+	return &Args{
+		Active:          true,
+		Mode:            ModeMatrix,
+		MatrixMode:      MatrixVertical,
+		PromptMode:      PromptModePrompt,
+		Ratios:          "1,1",
+		CalcMode:        CalcAttention,
+		LoRATextEncoder: "0",
+		LoRAUNet:        "0",
+		Threshold:       "0",
+		LoRAStopStep:    "0",
+		LoRAHiresStop:   "0",
+	}
+}
+
+var _ sdcli.Script = (*Args)(nil)
+
+func (a *Args) ScriptName() string {
+	return "Regional Prompter"
+}
+
+func (a *Args) ScriptArgs(img2img bool) ([]interface{}, error) {
+	if a.Active && a.Mode == ModeMatrix && len(a.Ratios) == 0 {
+		return nil, fmt.Errorf("regional prompter: matrix mode requires ratios")
+	}
+
+	return []interface{}{
+		a.Active,
+		a.Debug,
+		string(a.Mode),
+		string(a.MatrixMode),
+		"Mask",
+		string(a.PromptMode),
+		a.Ratios,
+		a.BaseRatios,
+		a.UseBase,
+		a.UseCommon,
+		a.UseNegCommon,
+		string(a.CalcMode),
+		a.NotChangeAND,
+		a.LoRATextEncoder,
+		a.LoRAUNet,
+		a.Threshold,
+		a.Mask,
+		a.LoRAStopStep,
+		a.LoRAHiresStop,
+		a.Flip,
+	}, nil
+}
+
+// Cell is a region with its relative size.
+type Cell struct {
+	Weight float32
+	Prompt string
+}
+
+// Row is a row of regions with its relative height.
+type Row struct {
+	Weight float32
+	Cells  []Cell
+}
+
+// Layout describes regions laid out as a matrix, with optional common and base prompts.
+type Layout struct {
+	// Common is prepended to every region.
+	Common string
+	// Base is blended into every region with BaseRatio.
+	Base      string
+	BaseRatio float32
+	Rows      []Row
+}
+
+// Columns creates a single row layout of equally sized regions, split left to right.
+func Columns(prompts ...string) *Layout {
+	row := Row{Weight: 1}
+	for _, p := range prompts {
+		row.Cells = append(row.Cells, Cell{Weight: 1, Prompt: p})
+	}
+	return &Layout{Rows: []Row{row}}
+}
+
+// Prompt renders the prompt with region keywords, a single row is split with BREAK.
+func (l *Layout) Prompt() string {
+	var b strings.Builder
+	if len(l.Common) != 0 {
+		b.WriteString(l.Common)
+		b.WriteString(" " + KeywordCommon + "\n")
+	}
+	if len(l.Base) != 0 {
+		b.WriteString(l.Base)
+		b.WriteString(" " + KeywordBase + "\n")
+	}
+
+	colSep := " " + KeywordAddCol + "\n"
+	if len(l.Rows) == 1 {
+		colSep = " " + KeywordBreak + "\n"
+	}
+	for i, row := range l.Rows {
+		if i > 0 {
+			b.WriteString(" " + KeywordAddRow + "\n")
+		}
+		for j, cell := range row.Cells {
+			if j > 0 {
+				b.WriteString(colSep)
+			}
+			b.WriteString(cell.Prompt)
+		}
+	}
+
+	return b.String()
+}
+
+// Ratios renders the division ratios matching Prompt, "1,1" for a single row or
+// "<row weight>,<cell weights>;..." for several rows.
+func (l *Layout) Ratios() string {
+	if len(l.Rows) == 1 {
+		return joinWeights(l.Rows[0].Cells)
+	}
+
+	rows := make([]string, len(l.Rows))
+	for i, row := range l.Rows {
+		rows[i] = formatWeight(row.Weight) + "," + joinWeights(row.Cells)
+	}
+	return strings.Join(rows, ";")
+}
+
+// Apply sets the ratios and base/common flags of args and returns the prompt to send.
+func (l *Layout) Apply(args *Args) string {
+	args.Mode = ModeMatrix
+	args.Ratios = l.Ratios()
+	args.UseCommon = len(l.Common) != 0
+	args.UseBase = len(l.Base) != 0
+	if args.UseBase {
+		args.BaseRatios = formatWeight(l.BaseRatio)
+	}
+	if len(l.Rows) > 1 && args.MatrixMode != MatrixColumns && args.MatrixMode != MatrixRows {
+		args.MatrixMode = MatrixColumns
+	}
+
+	return l.Prompt()
+}
+
+func joinWeights(cells []Cell) string {
+	w := make([]string, len(cells))
+	for i, c := range cells {
+		w[i] = formatWeight(c.Weight)
+	}
+	return strings.Join(w, ",")
+}
+
+func formatWeight(w float32) string {
+	if w <= 0 {
+		w = 1
+	}
+	return strconv.FormatFloat(float64(w), 'f', -1, 32)
+}
@@ -0,0 +1,82 @@
+// Package rembg is a client for the background removal extension (stable-diffusion-webui-rembg).
+package rembg
+
+import (
+	"context"
+	"image"
+	"net/http"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+const (
+	ModelU2Net         = "u2net"
+	ModelU2NetP        = "u2netp"
+	ModelU2NetHumanSeg = "u2net_human_seg"
+	ModelU2NetClothSeg = "u2net_cloth_seg"
+	ModelSilueta       = "silueta"
+	ModelISNetGeneral  = "isnet-general-use"
+	ModelISNetAnime    = "isnet-anime"
+)
+
+// Client calls the /rembg route through a sdcli.Client.
+type Client struct {
+	cli sdcli.Doer
+}
+
+func New(cli sdcli.Doer) *Client {
+	return &Client{cli: cli}
+}
+
+type Option struct {
+	// InputImage is a base64 string such as produced by sdcli.Img2Base64.
+	InputImage string `json:"input_image"`
+	// Model defaults to ModelU2Net.
+	Model string `json:"model"`
+	// ReturnMask returns the black and white mask instead of the cut out image.
+	ReturnMask bool `json:"return_mask"`
+
+	AlphaMatting                    bool `json:"alpha_matting"`
+	AlphaMattingForegroundThreshold int  `json:"alpha_matting_foreground_threshold"`
+	AlphaMattingBackgroundThreshold int  `json:"alpha_matting_background_threshold"`
+	AlphaMattingErodeSize           int  `json:"alpha_matting_erode_size"`
+}
+
+// NewOption returns the option removing the background of img with the extension defaults.
+func NewOption(img image.Image) Option {
+	return Option{
+		InputImage:                      sdcli.Img2Base64(img),
+		Model:                           ModelU2Net,
+		AlphaMattingForegroundThreshold: 240,
+		AlphaMattingBackgroundThreshold: 10,
+		AlphaMattingErodeSize:           10,
+	}
+}
+
+type Response struct {
+	Image string `json:"image"`
+
+	ParsedImage image.Image `json:"-"`
+	RawImage    []byte      `json:"-"`
+}
+
+// RemoveBackground removes the background of the input image, ParsedImage can be passed on to
+// img2img with sdcli.Img2Base64.
+func (c *Client) RemoveBackground(ctx context.Context, opt Option) (*Response, error) {
+	if len(opt.Model) == 0 {
+		opt.Model = ModelU2Net
+	}
+
+	res := new(Response)
+	if err := c.cli.Do(ctx, http.MethodPost, "/rembg", &opt, res); err != nil {
+		return nil, err
+	}
+
+	img, raw, err := sdcli.Base642Img(res.Image)
+	if err == nil {
+		res.ParsedImage = img
+	}
+	res.RawImage = raw
+
+	return res, nil
+}
@@ -0,0 +1,168 @@
+// Package tagger is a client for the WD14 tagger extension API (stable-diffusion-webui-wd14-tagger).
+package tagger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+const (
+	DefaultModel     = "wd14-vit-v2-git"
+	DefaultThreshold = 0.35
+)
+
+// ratingNames are the rating labels older tagger versions mix into the tag map.
+var ratingNames = map[string]bool{
+	"general":      true,
+	"sensitive":    true,
+	"questionable": true,
+	"explicit":     true,
+}
+
+// Client calls the /tagger/v1 routes through a sdcli.Client.
+type Client struct {
+	cli sdcli.Doer
+}
+
+func New(cli sdcli.Doer) *Client {
+	return &Client{cli: cli}
+}
+
+type InterrogateOption struct {
+	// Image is a base64 string such as produced by sdcli.Img2Base64.
+	Image string `json:"image"`
+	// Model defaults to DefaultModel.
+	Model string `json:"model"`
+	// Threshold drops tags under this confidence, defaults to DefaultThreshold.
+	Threshold float32 `json:"threshold"`
+}
+
+// Tag is a tag with its confidence between 0 and 1.
+type Tag struct {
+	Name       string  `json:"name"`
+	Confidence float32 `json:"confidence"`
+}
+
+// Tags are sorted by decreasing confidence, ties by name.
+type Tags []Tag
+
+// Names returns the tag names in order.
+func (t Tags) Names() []string {
+	names := make([]string, len(t))
+	for i, tag := range t {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+// Prompt joins the tag names into a prompt, replacing underscores with spaces.
+func (t Tags) Prompt() string {
+	names := t.Names()
+	for i, name := range names {
+		names[i] = strings.ReplaceAll(name, "_", " ")
+	}
+	return strings.Join(names, ", ")
+}
+
+type InterrogateResponse struct {
+	Tags    Tags
+	Ratings Tags
+}
+
+// Interrogate tags an image, tags under the threshold are dropped client-side as well since
+// some tagger versions ignore it.
+func (c *Client) Interrogate(ctx context.Context, opt InterrogateOption) (*InterrogateResponse, error) {
+	if len(opt.Model) == 0 {
+		opt.Model = DefaultModel
+	}
+	if opt.Threshold == 0 {
+		opt.Threshold = DefaultThreshold
+	}
+
+	var raw struct {
+		Caption map[string]json.RawMessage `json:"caption"`
+	}
+	if err := c.cli.Do(ctx, http.MethodPost, "/tagger/v1/interrogate", &opt, &raw); err != nil {
+		return nil, err
+	}
+
+	res := &InterrogateResponse{}
+	tagMap, hasTags := raw.Caption["tag"]
+	ratingMap, hasRatings := raw.Caption["rating"]
+	if hasTags || hasRatings {
+		// Newer versions split tags and ratings.
+		tags, ratings := map[string]float32{}, map[string]float32{}
+		if hasTags {
+			if err := json.Unmarshal(tagMap, &tags); err != nil {
+				return nil, fmt.Errorf("failed to parse tags: %w", err)
+			}
+		}
+		if hasRatings {
+			if err := json.Unmarshal(ratingMap, &ratings); err != nil {
+				return nil, fmt.Errorf("failed to parse ratings: %w", err)
+			}
+		}
+		res.Tags = sortTags(tags, opt.Threshold)
+		res.Ratings = sortTags(ratings, 0)
+
+		return res, nil
+	}
+
+	tags, ratings := map[string]float32{}, map[string]float32{}
+	for name, v := range raw.Caption {
+		var confidence float32
+		if err := json.Unmarshal(v, &confidence); err != nil {
+			return nil, fmt.Errorf("failed to parse tag %s: %w", name, err)
+		}
+		if ratingNames[name] {
+			ratings[name] = confidence
+		} else {
+			tags[name] = confidence
+		}
+	}
+	res.Tags = sortTags(tags, opt.Threshold)
+	res.Ratings = sortTags(ratings, 0)
+
+	return res, nil
+}
+
+// Interrogators lists the available tagger models.
+func (c *Client) Interrogators(ctx context.Context) ([]string, error) {
+	var res struct {
+		Models []string `json:"models"`
+	}
+	if err := c.cli.Do(ctx, http.MethodGet, "/tagger/v1/interrogators", nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Models, nil
+}
+
+// Unload unloads all tagger models from memory.
+func (c *Client) Unload(ctx context.Context) error {
+	return c.cli.Do(ctx, http.MethodPost, "/tagger/v1/unload-interrogators", nil, nil)
+}
+
+func sortTags(m map[string]float32, threshold float32) Tags {
+	tags := make(Tags, 0, len(m))
+	for name, confidence := range m {
+		if confidence < threshold {
+			continue
+		}
+		tags = append(tags, Tag{Name: name, Confidence: confidence})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Confidence != tags[j].Confidence {
+			return tags[i].Confidence > tags[j].Confidence
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags
+}
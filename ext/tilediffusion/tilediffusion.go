@@ -0,0 +1,257 @@
+// Package tilediffusion provides typed alwayson script arguments for the Tiled Diffusion and Tiled VAE
+// scripts of the multidiffusion-upscaler extension.
+package tilediffusion
+
+import (
+	"fmt"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Method is the tiled diffusion method.
+type Method string
+
+const (
+	MethodMultiDiffusion     Method = "MultiDiffusion"
+	MethodMixtureOfDiffusers Method = "Mixture of Diffusers"
+)
+
+// BlendMode is how a region is blended with the rest of the image.
+type BlendMode string
+
+const (
+	BlendBackground BlendMode = "Background"
+	BlendForeground BlendMode = "Foreground"
+)
+
+// MaxRegions is the number of region controls of the extension.
+const MaxRegions = 8
+
+// Latent tile size bounds of Tiled Diffusion, in latent pixels (1/8 of the image size).
+const (
+	MinTileSize = 16
+	MaxTileSize = 256
+)
+
+// Region is a region prompt control, the coordinates are ratios of the image size in [0, 1].
+type Region struct {
+	Enable         bool
+	X, Y, W, H     float32
+	Prompt         string
+	NegativePrompt string
+	BlendMode      BlendMode
+	// FeatherRatio softens the edge of foreground regions.
+	FeatherRatio float32
+	// Seed of the region, -1 for random.
+	Seed int64
+}
+
+func (r *Region) validate() error {
+	for _, v := range []float32{r.X, r.Y, r.W, r.H} {
+		if v < 0 || v > 1 {
+			return fmt.Errorf("region coordinates must be ratios in [0, 1]")
+		}
+	}
+	if r.X+r.W > 1 || r.Y+r.H > 1 {
+		return fmt.Errorf("region exceeds the image")
+	}
+	if r.FeatherRatio < 0 || r.FeatherRatio > 1 {
+		return fmt.Errorf("feather ratio must be in [0, 1]")
+	}
+	return nil
+}
+
+// Args is the Tiled Diffusion alwayson script arguments.
+type Args struct {
+	Enabled bool
+	Method  Method
+	// OverwriteSize replaces the output size of txt2img with ImageWidth and ImageHeight,
+	// which may exceed the 2048 limit of WebUI.
+	OverwriteSize bool
+	// KeepInputSize keeps the size of the img2img input image.
+	KeepInputSize bool
+	ImageWidth    int
+	ImageHeight   int
+	// TileWidth, TileHeight and Overlap are in latent pixels.
+	TileWidth     int
+	TileHeight    int
+	Overlap       int
+	TileBatchSize int
+	// Upscaler and ScaleFactor upscale the img2img input before the diffusion.
+	Upscaler    string
+	ScaleFactor float32
+
+	NoiseInverse                bool
+	NoiseInverseSteps           int
+	NoiseInverseRetouch         float32
+	NoiseInverseRenoiseStrength float32
+	NoiseInverseRenoiseKernel   int
+
+	ControlTensorCPU  bool
+	EnableBBoxControl bool
+	DrawBackground    bool
+	CausalLayers      bool
+	// Regions are only used with EnableBBoxControl, at most MaxRegions.
+	Regions []Region
+}
+
+// New returns enabled arguments with the extension defaults.
+func New() *Args {
+	return &Args{
+		Enabled:                     true,
+		Method:                      MethodMultiDiffusion,
+		KeepInputSize:               true,
+		ImageWidth:                  1024,
+		ImageHeight:                 1024,
+		TileWidth:                   96,
+		TileHeight:                  96,
+		Overlap:                     48,
+		TileBatchSize:               4,
+		Upscaler:                    "None",
+		ScaleFactor:                 2,
+		NoiseInverseSteps:           10,
+		NoiseInverseRetouch:         1,
+		NoiseInverseRenoiseStrength: 1,
+		NoiseInverseRenoiseKernel:   64,
+	}
+}
+
+var _ sdcli.Script = (*Args)(nil)
+
+func (a *Args) ScriptName() string {
+	return "Tiled Diffusion"
+}
+
+// Validate checks the tile settings, WebUI only logs invalid ones and may run out of memory.
+func (a *Args) Validate() error {
+	if a.TileWidth < MinTileSize || a.TileWidth > MaxTileSize || a.TileHeight < MinTileSize || a.TileHeight > MaxTileSize {
+		return fmt.Errorf("tiled diffusion: tile size must be in [%d, %d]", MinTileSize, MaxTileSize)
+	}
+	if a.Overlap < 0 || a.Overlap >= a.TileWidth || a.Overlap >= a.TileHeight {
+		return fmt.Errorf("tiled diffusion: overlap must be smaller than the tile size")
+	}
+	if a.TileBatchSize < 1 {
+		return fmt.Errorf("tiled diffusion: tile batch size must be positive")
+	}
+	if a.OverwriteSize && (a.ImageWidth <= 0 || a.ImageHeight <= 0) {
+		return fmt.Errorf("tiled diffusion: image size must be positive")
+	}
+	if len(a.Regions) > MaxRegions {
+		return fmt.Errorf("tiled diffusion: at most %d regions", MaxRegions)
+	}
+	for i := range a.Regions {
+		if err := a.Regions[i].validate(); err != nil {
+			return fmt.Errorf("tiled diffusion: region %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (a *Args) ScriptArgs(img2img bool) ([]interface{}, error) {
+	if a.Enabled {
+		if err := a.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	args := []interface{}{
+		a.Enabled,
+		string(a.Method),
+		a.OverwriteSize,
+		a.KeepInputSize,
+		a.ImageWidth,
+		a.ImageHeight,
+		a.TileWidth,
+		a.TileHeight,
+		a.Overlap,
+		a.TileBatchSize,
+		a.Upscaler,
+		a.ScaleFactor,
+		a.NoiseInverse,
+		a.NoiseInverseSteps,
+		a.NoiseInverseRetouch,
+		a.NoiseInverseRenoiseStrength,
+		a.NoiseInverseRenoiseKernel,
+		a.ControlTensorCPU,
+		a.EnableBBoxControl,
+		a.DrawBackground,
+		a.CausalLayers,
+	}
+	for i := 0; i < MaxRegions; i++ {
+		r := Region{W: 0.2, H: 0.2, BlendMode: BlendBackground, FeatherRatio: 0.2, Seed: -1}
+		if i < len(a.Regions) {
+			r = a.Regions[i]
+		}
+		args = append(args, r.Enable, r.X, r.Y, r.W, r.H, r.Prompt, r.NegativePrompt, string(r.BlendMode), r.FeatherRatio, r.Seed)
+	}
+
+	return args, nil
+}
+
+// VAE tile size bounds of Tiled VAE, in image pixels for the encoder and latent pixels for the decoder.
+const (
+	MinEncoderTileSize = 256
+	MaxEncoderTileSize = 4096
+	MinDecoderTileSize = 48
+	MaxDecoderTileSize = 512
+)
+
+// VAE is the Tiled VAE alwayson script arguments.
+type VAE struct {
+	Enabled         bool
+	EncoderTileSize int
+	DecoderTileSize int
+	// VAEToGPU moves the VAE to the GPU if it was offloaded.
+	VAEToGPU    bool
+	FastDecoder bool
+	FastEncoder bool
+	// ColorFix keeps the colors of fast encoder outputs.
+	ColorFix bool
+}
+
+// NewVAE returns enabled Tiled VAE arguments with the extension defaults for 12GB of VRAM.
+func NewVAE() *VAE {
+	return &VAE{
+		Enabled:         true,
+		EncoderTileSize: 1536,
+		DecoderTileSize: 96,
+		VAEToGPU:        true,
+		FastDecoder:     true,
+		FastEncoder:     true,
+	}
+}
+
+var _ sdcli.Script = (*VAE)(nil)
+
+func (v *VAE) ScriptName() string {
+	return "Tiled VAE"
+}
+
+// Validate checks the encoder and decoder tile sizes.
+func (v *VAE) Validate() error {
+	if v.EncoderTileSize < MinEncoderTileSize || v.EncoderTileSize > MaxEncoderTileSize {
+		return fmt.Errorf("tiled vae: encoder tile size must be in [%d, %d]", MinEncoderTileSize, MaxEncoderTileSize)
+	}
+	if v.DecoderTileSize < MinDecoderTileSize || v.DecoderTileSize > MaxDecoderTileSize {
+		return fmt.Errorf("tiled vae: decoder tile size must be in [%d, %d]", MinDecoderTileSize, MaxDecoderTileSize)
+	}
+	return nil
+}
+
+func (v *VAE) ScriptArgs(img2img bool) ([]interface{}, error) {
+	if v.Enabled {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return []interface{}{
+		v.Enabled,
+		v.EncoderTileSize,
+		v.DecoderTileSize,
+		v.VAEToGPU,
+		v.FastDecoder,
+		v.FastEncoder,
+		v.ColorFix,
+	}, nil
+}
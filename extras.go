@@ -0,0 +1,239 @@
+package sdcli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ExtraBatchImgOption are the parameters of the extra-batch-images endpoint, the fields of
+// ExtraSingleImgOption applied to every image of ImageList.
+type ExtraBatchImgOption struct {
+	ResizeMode                ExtrasResizeMode `json:"resize_mode,omitempty"`
+	ShowExtrasResults         bool             `json:"show_extras_results,omitempty"`
+	GfpganVisibility          float64          `json:"gfpgan_visibility,omitempty"`
+	CodeformerVisibility      float64          `json:"codeformer_visibility,omitempty"`
+	CodeformerWeight          float64          `json:"codeformer_weight,omitempty"`
+	UpscalingResize           float64          `json:"upscaling_resize,omitempty"`
+	UpscalingResizeW          int              `json:"upscaling_resize_w,omitempty"`
+	UpscalingResizeH          int              `json:"upscaling_resize_h,omitempty"`
+	UpscalingCrop             bool             `json:"upscaling_crop,omitempty"`
+	Upscaler1                 string           `json:"upscaler_1,omitempty"`
+	Upscaler2                 string           `json:"upscaler_2,omitempty"`
+	ExtrasUpscaler2Visibility float64          `json:"extras_upscaler_2_visibility,omitempty"`
+	UpscaleFirst              bool             `json:"upscale_first,omitempty"`
+	ImageList                 []*BatchImage    `json:"imageList"`
+}
+
+// BatchImage is an image of ExtraBatchImgOption, Data is base64 encoded, see EncodeImage.
+type BatchImage struct {
+	Data string `json:"data"`
+	Name string `json:"name"`
+}
+
+// batchOption returns the batch options with the parameters of opt.
+func batchOption(opt ExtraSingleImgOption) ExtraBatchImgOption {
+	return ExtraBatchImgOption{
+		ResizeMode:                opt.ResizeMode,
+		ShowExtrasResults:         opt.ShowExtrasResults,
+		GfpganVisibility:          opt.GfpganVisibility,
+		CodeformerVisibility:      opt.CodeformerVisibility,
+		CodeformerWeight:          opt.CodeformerWeight,
+		UpscalingResize:           opt.UpscalingResize,
+		UpscalingResizeW:          opt.UpscalingResizeW,
+		UpscalingResizeH:          opt.UpscalingResizeH,
+		UpscalingCrop:             opt.UpscalingCrop,
+		Upscaler1:                 opt.Upscaler1,
+		Upscaler2:                 opt.Upscaler2,
+		ExtrasUpscaler2Visibility: opt.ExtrasUpscaler2Visibility,
+		UpscaleFirst:              opt.UpscaleFirst,
+	}
+}
+
+type ExtraBatchImgResponse struct {
+	HTMLInfo string   `json:"html_info"`
+	Images   []string `json:"images"`
+
+	ParsedImages []image.Image `json:"-"`
+	RawImages    [][]byte      `json:"-"`
+}
+
+// ExtraBatchImages runs the extras on every image of opt.ImageList, the images of the response
+// are in the order of the list.
+func (c *Client) ExtraBatchImages(ctx context.Context, opt ExtraBatchImgOption, opts ...RequestOption) (*ExtraBatchImgResponse, error) {
+	ctx, cancel := requestContext(ctx, opts)
+	defer cancel()
+
+	if err := c.preflightExtras(ctx, &ExtraSingleImgOption{Upscaler1: opt.Upscaler1, Upscaler2: opt.Upscaler2}); err != nil {
+		return nil, err
+	}
+
+	res := new(ExtraBatchImgResponse)
+	if err := c.generate(ctx, "/extra-batch-images", &opt, res); err != nil {
+		return nil, err
+	}
+	res.ParsedImages, res.RawImages, _ = decodeImages(res.Images, !c.skipDecode)
+
+	return res, nil
+}
+
+// DefaultUpscaleBatchBytes is the size budget of the requests of UpscaleFS.
+const DefaultUpscaleBatchBytes = 32 << 20
+
+// UpscaleDirOption configures UpscaleDir and UpscaleFS.
+type UpscaleDirOption struct {
+	// Extras are the parameters applied to every image, its Image is ignored.
+	Extras ExtraSingleImgOption
+	// OutDir is where the outputs are written, under the path of their input with the extension
+	// of their format. It is required.
+	OutDir string
+	// MaxBatchBytes bounds the size of the files uploaded per request, DefaultUpscaleBatchBytes
+	// if 0. Larger files are sent alone.
+	MaxBatchBytes int
+	// Extensions are the file extensions processed, .png, .jpg, .jpeg and .webp if empty.
+	Extensions []string
+}
+
+// UpscaleFileResult is the outcome of a file of UpscaleDir.
+type UpscaleFileResult struct {
+	// Name is the slash separated path of the input.
+	Name string
+	// Output is the file written, empty on error.
+	Output string
+	Err    error
+}
+
+// UpscaleDir runs the extras on the images of dir and its subdirectories, see UpscaleFS.
+func (c *Client) UpscaleDir(ctx context.Context, dir string, opt UpscaleDirOption, opts ...RequestOption) ([]*UpscaleFileResult, error) {
+	return c.UpscaleFS(ctx, os.DirFS(dir), opt, opts...)
+}
+
+// UpscaleFS runs the extras on the images of fsys through extra-batch-images, packing the files
+// in requests within the size budget, and writes the outputs under opt.OutDir keeping their
+// names. When a request fails its files are sent one by one, so the results report the error
+// of every file. Only failing to list fsys or a canceled ctx fail the whole run.
+func (c *Client) UpscaleFS(ctx context.Context, fsys fs.FS, opt UpscaleDirOption, opts ...RequestOption) ([]*UpscaleFileResult, error) {
+	if len(opt.OutDir) == 0 {
+		return nil, errors.New("upscale output directory is required")
+	}
+	budget := opt.MaxBatchBytes
+	if budget <= 0 {
+		budget = DefaultUpscaleBatchBytes
+	}
+	exts := opt.Extensions
+	if len(exts) == 0 {
+		exts = []string{".png", ".jpg", ".jpeg", ".webp"}
+	}
+
+	var names []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && hasExt(name, exts) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	results := make([]*UpscaleFileResult, 0, len(names))
+	var (
+		batch []*BatchImage
+		size  int
+	)
+	flush := func() {
+		if len(batch) != 0 {
+			results = append(results, c.upscaleBatch(ctx, batch, opt, opts)...)
+			batch, size = nil, 0
+		}
+	}
+	for _, name := range names {
+		if ctx.Err() != nil {
+			break
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			results = append(results, &UpscaleFileResult{Name: name, Err: fmt.Errorf("failed to read image: %w", err)})
+			continue
+		}
+		encoded, err := EncodeImage(data)
+		if err != nil {
+			results = append(results, &UpscaleFileResult{Name: name, Err: err})
+			continue
+		}
+		if size+len(encoded) > budget {
+			flush()
+		}
+		batch = append(batch, &BatchImage{Data: encoded, Name: name})
+		size += len(encoded)
+	}
+	flush()
+
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// upscaleBatch sends a batch, then its files one by one if it fails.
+func (c *Client) upscaleBatch(ctx context.Context, batch []*BatchImage, opt UpscaleDirOption, opts []RequestOption) []*UpscaleFileResult {
+	req := batchOption(opt.Extras)
+	req.ImageList = batch
+	res, err := c.ExtraBatchImages(ctx, req, opts...)
+	if err == nil && len(res.RawImages) != len(batch) {
+		err = fmt.Errorf("extras returned %d images for %d files", len(res.RawImages), len(batch))
+	}
+
+	if err != nil && len(batch) > 1 && ctx.Err() == nil {
+		results := make([]*UpscaleFileResult, 0, len(batch))
+		for _, img := range batch {
+			results = append(results, c.upscaleBatch(ctx, []*BatchImage{img}, opt, opts)...)
+		}
+		return results
+	}
+
+	results := make([]*UpscaleFileResult, len(batch))
+	for i, img := range batch {
+		r := &UpscaleFileResult{Name: img.Name, Err: err}
+		if err == nil {
+			r.Output, r.Err = writeUpscaled(opt.OutDir, img.Name, res.RawImages[i])
+		}
+		results[i] = r
+	}
+	return results
+}
+
+// writeUpscaled writes the output of name under dir with the extension of its format.
+func writeUpscaled(dir, name string, data []byte) (string, error) {
+	ext := DetectFormat(data)
+	if ext == FormatUnknown {
+		ext = "bin"
+	}
+	out := filepath.Join(dir, filepath.FromSlash(strings.TrimSuffix(name, path.Ext(name))+"."+ext))
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(out, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	return out, nil
+}
+
+func hasExt(name string, exts []string) bool {
+	ext := path.Ext(name)
+	for _, e := range exts {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
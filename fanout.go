@@ -0,0 +1,80 @@
+package sdcli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ItemError is the error of one item of GenerateAll, Index is its index in the options.
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// ItemErrors are the errors of the failed items of GenerateAll, in item order.
+type ItemErrors []*ItemError
+
+func (e ItemErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d items failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// GenerateAll runs txt2img for every option with at most concurrency requests in flight, e.g.
+// against a Client or any other API spreading requests over servers. The responses are in the
+// order of opts, nil for the failed items whose errors are returned as ItemErrors. A failed item
+// does not stop the others, cancel ctx to stop early: the items not started by then fail with
+// the context error.
+func GenerateAll(ctx context.Context, api API, opts []Txt2ImageOption, concurrency int, reqOpts ...RequestOption) ([]*Txt2ImageResponse, error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	res := make([]*Txt2ImageResponse, len(opts))
+	errs := make([]error, len(opts))
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	for i := range opts {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res[i], errs[i] = api.Txt2Img(ctx, opts[i], reqOpts...)
+		}(i)
+	}
+	wg.Wait()
+
+	var itemErrs ItemErrors
+	for i, err := range errs {
+		if err != nil {
+			itemErrs = append(itemErrs, &ItemError{Index: i, Err: err})
+		}
+	}
+	if len(itemErrs) != 0 {
+		return res, itemErrs
+	}
+	return res, nil
+}
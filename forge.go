@@ -0,0 +1,96 @@
+package sdcli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// SdModulesResponse is a module (VAE or text encoder) that Forge loads next to a checkpoint.
+type SdModulesResponse struct {
+	ModelName string `json:"model_name"`
+	Filename  string `json:"filename"`
+}
+
+// GetSdModules lists the additional modules of Forge, A1111 answers 404.
+func (c *Client) GetSdModules(ctx context.Context) ([]*SdModulesResponse, error) {
+	res := []*SdModulesResponse{}
+	if err := c.doReq(ctx, "/sd-modules", http.MethodGet, nil, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+var (
+	optionsFieldsOnce sync.Once
+	optionsFieldsMap  map[string]int
+)
+
+// optionsFields maps the JSON names of the OptionsResponse fields to their index.
+func optionsFields() map[string]int {
+	optionsFieldsOnce.Do(func() {
+		optionsFieldsMap = map[string]int{}
+		t := reflect.TypeOf(OptionsResponse{})
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if len(name) != 0 && name != "-" {
+				optionsFieldsMap[name] = i
+			}
+		}
+	})
+	return optionsFieldsMap
+}
+
+// UnmarshalJSON decodes the options one by one, so an option of an unexpected type lands in
+// Extra instead of failing the whole decoding.
+func (o *OptionsResponse) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*o = OptionsResponse{}
+	v := reflect.ValueOf(o).Elem()
+	fields := optionsFields()
+	for name, value := range raw {
+		if i, ok := fields[name]; ok {
+			field := reflect.New(v.Field(i).Type())
+			if err := json.Unmarshal(value, field.Interface()); err == nil {
+				v.Field(i).Set(field.Elem())
+				continue
+			}
+		}
+		if o.Extra == nil {
+			o.Extra = map[string]json.RawMessage{}
+		}
+		o.Extra[name] = value
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the fields along with Extra, fields win over Extra.
+func (o OptionsResponse) MarshalJSON() ([]byte, error) {
+	type plain OptionsResponse
+	data, err := json.Marshal(plain(o))
+	if err != nil || len(o.Extra) == 0 {
+		return data, err
+	}
+
+	merged := map[string]json.RawMessage{}
+	for k, v := range o.Extra {
+		merged[k] = v
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return json.Marshal(merged)
+}
@@ -0,0 +1,90 @@
+package sdcli
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// FormFile is a file of a multipart form, ContentType defaults to application/octet-stream.
+type FormFile struct {
+	Field       string
+	Name        string
+	Data        []byte
+	ContentType string
+}
+
+// Form is a multipart/form-data request body for the routes taking file uploads rather than
+// base64 JSON, pass a *Form as the body of Do.
+type Form struct {
+	Fields map[string]string
+	Files  []FormFile
+}
+
+// NewForm returns an empty form.
+func NewForm() *Form {
+	return &Form{Fields: map[string]string{}}
+}
+
+// SetField sets a text field.
+func (f *Form) SetField(name, value string) *Form {
+	if f.Fields == nil {
+		f.Fields = map[string]string{}
+	}
+	f.Fields[name] = value
+	return f
+}
+
+// AddFile adds a file, its content type is sniffed from the image formats of DetectFormat.
+func (f *Form) AddFile(field, name string, data []byte) *Form {
+	ct := ""
+	if format := DetectFormat(data); format != FormatUnknown {
+		ct = "image/" + format
+	}
+	f.Files = append(f.Files, FormFile{Field: field, Name: name, Data: data, ContentType: ct})
+	return f
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// encode returns the body and its content type, fields are written in name order.
+func (f *Form) encode() ([]byte, string, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+
+	names := make([]string, 0, len(f.Fields))
+	for name := range f.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := w.WriteField(name, f.Fields[name]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, file := range f.Files {
+		ct := file.ContentType
+		if len(ct) == 0 {
+			ct = "application/octet-stream"
+		}
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(file.Field), quoteEscaper.Replace(file.Name)))
+		h.Set("Content-Type", ct)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(file.Data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
@@ -0,0 +1,7 @@
+package sdcli
+
+// The option and payload structs follow the OpenAPI schema of WebUI, regenerate them with a
+// WebUI started with --api listening on the default port. Existing fields keep their names
+// and types, see cmd/openapigen.
+//go:generate go run ./cmd/openapigen -out options_gen.go -pointers -types Options=OptionsResponse
+//go:generate go run ./cmd/openapigen -out payloads_gen.go -types StableDiffusionProcessingTxt2Img=Txt2ImageOption,StableDiffusionProcessingImg2Img=Img2ImgOption,ExtrasSingleImageRequest=ExtraSingleImgOption
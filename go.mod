@@ -1,3 +1,18 @@
 module github.com/shallowclouds/go-sd-webui-cli
 
-go 1.20
+go 1.22
+
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/gorilla/websocket v1.5.3
+	github.com/spf13/cobra v1.8.1
+	github.com/spf13/pflag v1.0.5
+	go.etcd.io/bbolt v1.3.11
+	golang.org/x/image v0.18.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+)
@@ -0,0 +1,59 @@
+package sdcli
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// WithCookieJar sets the cookie jar keeping the session of LoginGradio, a new in-memory jar if
+// nil. The http.Client given to NewClient is copied and left untouched.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(c *Client) {
+		if jar == nil {
+			// cookiejar.New only fails on invalid options.
+			jar, _ = cookiejar.New(nil)
+		}
+		cli := http.Client{}
+		if c.cli != nil {
+			cli = *c.cli
+		}
+		cli.Jar = jar
+		c.cli = &cli
+	}
+}
+
+// LoginGradio logs into a server started with --gradio-auth, the session cookie is then sent
+// with every request. The client needs a cookie jar, set up with WithCookieJar when creating it
+// as the HTTP client is shared by the running requests.
+func (c *Client) LoginGradio(ctx context.Context, username, password string) error {
+	if c.cli == nil || c.cli.Jar == nil {
+		return wrapError(nil, nil, "no cookie jar to keep the gradio session, create the client with WithCookieJar")
+	}
+
+	form := url.Values{"username": {username}, "password": {password}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return wrapError(err, nil, "failed to initialize request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.cli.Do(req)
+	if err != nil {
+		return wrapError(err, nil, "failed to do request")
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return wrapError(err, resp, "failed to read response body")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return wrapError(nil, resp, "gradio login failed with status %d, body: %s", resp.StatusCode, string(data))
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package sdcli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Messages of the gradio queue, in the order of an event.
+const (
+	QueueMsgSendHash          = "send_hash"
+	QueueMsgQueueFull         = "queue_full"
+	QueueMsgEstimation        = "estimation"
+	QueueMsgSendData          = "send_data"
+	QueueMsgProcessStarts     = "process_starts"
+	QueueMsgProgress          = "progress"
+	QueueMsgProcessGenerating = "process_generating"
+	QueueMsgProcessCompleted  = "process_completed"
+)
+
+// QueueEvent is a message pushed by the gradio queue while an event waits and runs.
+type QueueEvent struct {
+	Msg string `json:"msg"`
+	// Rank is the position in the queue of estimation messages, 0 when next.
+	Rank      int `json:"rank"`
+	QueueSize int `json:"queue_size"`
+	// RankETA is the estimated seconds until the event starts.
+	RankETA float64 `json:"rank_eta"`
+	// ProgressData are the progress bars of progress messages, outermost first.
+	ProgressData []*QueueProgress `json:"progress_data"`
+	// Output is the result of process_generating and process_completed messages.
+	Output  *QueueOutput `json:"output"`
+	Success bool         `json:"success"`
+}
+
+// QueueProgress is a progress bar of a gradio function, with either Index out of Length units or
+// a Progress fraction.
+type QueueProgress struct {
+	Index    *int     `json:"index"`
+	Length   *int     `json:"length"`
+	Unit     string   `json:"unit"`
+	Progress *float64 `json:"progress"`
+	Desc     string   `json:"desc"`
+}
+
+// QueueOutput is the output of a gradio function.
+type QueueOutput struct {
+	Data []json.RawMessage `json:"data"`
+	// Error is set when the function failed.
+	Error string `json:"error"`
+}
+
+// ProgressResponse converts the event to a progress reading, so the ProgressFunc adapters also
+// draw the pushed progress: queued events report no progress with the queue position as text and
+// the ETA of their turn, and progress events report the steps of their innermost bar.
+func (e *QueueEvent) ProgressResponse() *ProgressResponse {
+	p := &ProgressResponse{FetchedAt: time.Now()}
+	// An event is a single job.
+	p.State.JobCount = 1
+	switch e.Msg {
+	case QueueMsgEstimation:
+		p.State.Job = "queued"
+		p.ETARelative = float32(e.RankETA)
+		p.TextInfo = fmt.Sprintf("position %d of %d in queue", e.Rank+1, e.QueueSize)
+	case QueueMsgProcessStarts:
+		p.State.Job = "started"
+	case QueueMsgProgress:
+		p.State.Job = "running"
+		if len(e.ProgressData) == 0 {
+			break
+		}
+		bar := e.ProgressData[len(e.ProgressData)-1]
+		p.TextInfo = bar.Desc
+		switch {
+		case bar.Index != nil && bar.Length != nil && *bar.Length > 0:
+			p.State.SamplingStep, p.State.SamplingSteps = *bar.Index, *bar.Length
+			p.Progress = float32(*bar.Index) / float32(*bar.Length)
+		case bar.Progress != nil:
+			p.Progress = float32(*bar.Progress)
+		}
+	case QueueMsgProcessCompleted:
+		p.State.Job = "completed"
+		p.Progress = 1
+	}
+	return p
+}
+
+// QueueProgressFunc calls progress with the readings of the queue events, see
+// QueueEvent.ProgressResponse.
+func QueueProgressFunc(progress ProgressFunc) func(e *QueueEvent) {
+	return func(e *QueueEvent) {
+		switch e.Msg {
+		case QueueMsgEstimation, QueueMsgProcessStarts, QueueMsgProgress, QueueMsgProcessCompleted:
+			progress(e.ProgressResponse())
+		}
+	}
+}
+
+// GradioFnIndex returns the index of the gradio function named apiName in the /config of the
+// server, the fn_index of RunGradio.
+func (c *Client) GradioFnIndex(ctx context.Context, apiName string) (int, error) {
+	cfg := struct {
+		Dependencies []struct {
+			APIName any `json:"api_name"`
+		} `json:"dependencies"`
+	}{}
+	if err := c.do(ctx, "/config", http.MethodGet, nil, http.StatusOK, &cfg); err != nil {
+		return 0, err
+	}
+	for i, dep := range cfg.Dependencies {
+		if name, ok := dep.APIName.(string); ok && name == apiName {
+			return i, nil
+		}
+	}
+	return 0, wrapError(nil, nil, "no gradio function named %q", apiName)
+}
+
+// RunGradio runs the gradio function fnIndex with data through the /queue/join WebSocket of
+// gradio 3, the queue of the WebUI interface, and returns the data of its output. The queue
+// pushes the position of the event while it waits and the progress bars of the function while it
+// runs, onEvent receives every message if not nil. Unlike WithProgress nothing is polled.
+//
+// Only the gradio functions of the interface go through the queue, the /sdapi routes such as
+// Txt2Img and Img2Img do not, so their progress is still polled with WithProgress.
+func (c *Client) RunGradio(ctx context.Context, fnIndex int, data []any, onEvent func(e *QueueEvent)) ([]json.RawMessage, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, wrapError(err, nil, "invalid base URL")
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/queue/join"
+
+	header := http.Header{}
+	for key, values := range headerFromContext(ctx) {
+		header[key] = values
+	}
+	if username, password := c.credentials(); len(username) != 0 && len(password) != 0 {
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	}
+	dialer := *websocket.DefaultDialer
+	if c.cli != nil {
+		dialer.Jar = c.cli.Jar
+		if t, ok := c.cli.Transport.(*http.Transport); ok {
+			dialer.TLSClientConfig = t.TLSClientConfig
+			dialer.Proxy = t.Proxy
+		}
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		return nil, wrapError(err, resp, "failed to join gradio queue")
+	}
+	defer conn.Close()
+
+	// Unblock the reads when the context is done.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	sessionHash := newSessionHash()
+	for {
+		e := new(QueueEvent)
+		if err := conn.ReadJSON(e); err != nil {
+			if ctx.Err() != nil {
+				return nil, wrapError(ctx.Err(), nil, "gradio queue canceled")
+			}
+			return nil, wrapError(err, nil, "failed to read gradio queue message")
+		}
+		if onEvent != nil {
+			onEvent(e)
+		}
+
+		switch e.Msg {
+		case QueueMsgSendHash:
+			err = conn.WriteJSON(map[string]any{"fn_index": fnIndex, "session_hash": sessionHash})
+		case QueueMsgSendData:
+			err = conn.WriteJSON(map[string]any{
+				"fn_index":     fnIndex,
+				"session_hash": sessionHash,
+				"data":         data,
+				"event_data":   nil,
+			})
+		case QueueMsgQueueFull:
+			return nil, wrapError(nil, nil, "gradio queue is full")
+		case QueueMsgProcessCompleted:
+			if !e.Success || e.Output == nil {
+				msg := "unknown error"
+				if e.Output != nil && len(e.Output.Error) != 0 {
+					msg = e.Output.Error
+				}
+				return nil, wrapError(nil, nil, "gradio function %d failed: %s", fnIndex, msg)
+			}
+			return e.Output.Data, nil
+		}
+		if err != nil {
+			return nil, wrapError(err, nil, "failed to write gradio queue message")
+		}
+	}
+}
+
+// newSessionHash returns a random session hash like the ones of the gradio frontend.
+func newSessionHash() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)[:11]
+}
@@ -0,0 +1,152 @@
+// Package grid composes generated images into a labeled grid like the WebUI grid output,
+// for batches generated with return_grid disabled or merged client side, and splits grids
+// back into their images for responses returning only the grid.
+package grid
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Options of a grid, the zero value lays the images out like WebUI without labels.
+type Options struct {
+	// Rows of the grid, when 0 it is round(sqrt(n)) like WebUI. Cols is derived from Rows when 0.
+	Rows int
+	Cols int
+	// ColLabels are drawn above the columns and RowLabels left of the rows, e.g. axis values.
+	ColLabels []string
+	RowLabels []string
+	// Captions are drawn under every image, e.g. seeds, see SeedCaptions.
+	Captions []string
+	// Margin between the images.
+	Margin int
+	// Background defaults to white, Foreground (the text color) to black.
+	Background color.Color
+	Foreground color.Color
+	// Face defaults to basicfont.Face7x13.
+	Face font.Face
+}
+
+// Layout returns the rows and columns of a grid of n images.
+func (o *Options) Layout(n int) (rows, cols int) {
+	rows, cols = o.Rows, o.Cols
+	switch {
+	case rows <= 0 && cols <= 0:
+		rows = int(math.Round(math.Sqrt(float64(n))))
+	case rows <= 0:
+		rows = (n + cols - 1) / cols
+	}
+	if rows > n {
+		rows = n
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	if cols <= 0 {
+		cols = (n + rows - 1) / rows
+	}
+	return rows, cols
+}
+
+// SeedCaptions returns the captions of images generated with seeds.
+func SeedCaptions(seeds []int) []string {
+	res := make([]string, len(seeds))
+	for i, s := range seeds {
+		res[i] = "seed " + strconv.Itoa(s)
+	}
+	return res
+}
+
+// Compose draws images row by row into a grid, every cell has the size of the first image.
+func Compose(images []image.Image, opt Options) (image.Image, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images to compose")
+	}
+
+	rows, cols := opt.Layout(len(images))
+	if rows*cols < len(images) {
+		return nil, fmt.Errorf("%d images do not fit in a %dx%d grid", len(images), rows, cols)
+	}
+	face := opt.Face
+	if face == nil {
+		face = basicfont.Face7x13
+	}
+	bg, fg := opt.Background, opt.Foreground
+	if bg == nil {
+		bg = color.White
+	}
+	if fg == nil {
+		fg = color.Black
+	}
+
+	metrics := face.Metrics()
+	lineHeight := (metrics.Ascent + metrics.Descent).Ceil()
+	pad := lineHeight / 2
+
+	cellW, cellH := images[0].Bounds().Dx(), images[0].Bounds().Dy()
+	top, left, caption := 0, 0, 0
+	if len(opt.ColLabels) != 0 {
+		top = lineHeight + 2*pad
+	}
+	for _, l := range opt.RowLabels {
+		if w := font.MeasureString(face, l).Ceil() + 2*pad; w > left {
+			left = w
+		}
+	}
+	if len(opt.Captions) != 0 {
+		caption = lineHeight + pad
+	}
+
+	stepX, stepY := cellW+opt.Margin, cellH+caption+opt.Margin
+	width := left + cols*stepX - opt.Margin
+	height := top + rows*stepY - opt.Margin
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	d := &font.Drawer{Dst: dst, Src: image.NewUniform(fg), Face: face}
+	text := func(s string, x, y, w int) {
+		s = fit(face, s, w)
+		d.Dot = fixed.P(x+(w-font.MeasureString(face, s).Ceil())/2, y+metrics.Ascent.Ceil())
+		d.DrawString(s)
+	}
+
+	for c := 0; c < cols && c < len(opt.ColLabels); c++ {
+		text(opt.ColLabels[c], left+c*stepX, pad, cellW)
+	}
+	for r := 0; r < rows && r < len(opt.RowLabels); r++ {
+		text(opt.RowLabels[r], pad, top+r*stepY+(cellH-lineHeight)/2, left-2*pad)
+	}
+	for i, img := range images {
+		x, y := left+(i%cols)*stepX, top+(i/cols)*stepY
+		b := img.Bounds()
+		draw.Draw(dst, image.Rect(x, y, x+cellW, y+cellH), img, b.Min, draw.Over)
+		if i < len(opt.Captions) {
+			text(opt.Captions[i], x, y+cellH+pad/2, cellW)
+		}
+	}
+
+	return dst, nil
+}
+
+// fit truncates s with an ellipsis so it is at most w pixels wide.
+func fit(face font.Face, s string, w int) string {
+	if font.MeasureString(face, s).Ceil() <= w {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 {
+		r = r[:len(r)-1]
+		if t := string(r) + "..."; font.MeasureString(face, t).Ceil() <= w {
+			return t
+		}
+	}
+	return ""
+}
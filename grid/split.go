@@ -0,0 +1,80 @@
+package grid
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// SplitOptions describe the layout of a grid to cut back into its images.
+type SplitOptions struct {
+	Rows, Cols int
+	// Count is the number of images, the last row being partial when lower than Rows*Cols.
+	// It defaults to Rows*Cols.
+	Count int
+	// Margin between the cells, such as the MarginSize of an X/Y/Z plot.
+	Margin int
+	// CellWidth and CellHeight are the size of the images, usually the requested width and
+	// height. When set, the space left above and left of the cells is taken as the labels of
+	// an annotated grid, otherwise the grid is split evenly.
+	CellWidth, CellHeight int
+}
+
+// ForBatch returns the layout of the grid WebUI returns for n images, with its default of
+// round(sqrt(n)) rows.
+func ForBatch(n int) SplitOptions {
+	rows, cols := (&Options{}).Layout(n)
+	return SplitOptions{Rows: rows, Cols: cols, Count: n}
+}
+
+// ForXYZ returns the layout of the grid of an X/Y/Z plot with x and y axis values, the
+// main grid when the Z axis is unused or the sub grid of a Z value. Set CellWidth and
+// CellHeight when the legend is drawn.
+func ForXYZ(x, y, margin int) SplitOptions {
+	return SplitOptions{Rows: max(y, 1), Cols: max(x, 1), Margin: margin}
+}
+
+// Split cuts a grid into its images, row by row.
+func Split(grid image.Image, opt SplitOptions) ([]image.Image, error) {
+	if opt.Rows <= 0 || opt.Cols <= 0 {
+		return nil, fmt.Errorf("invalid grid layout %dx%d", opt.Rows, opt.Cols)
+	}
+	count := opt.Count
+	if count <= 0 {
+		count = opt.Rows * opt.Cols
+	}
+	if count > opt.Rows*opt.Cols {
+		return nil, fmt.Errorf("%d images do not fit in a %dx%d grid", count, opt.Rows, opt.Cols)
+	}
+
+	b := grid.Bounds()
+	width := b.Dx() - (opt.Cols-1)*opt.Margin
+	height := b.Dy() - (opt.Rows-1)*opt.Margin
+	cellW, cellH := opt.CellWidth, opt.CellHeight
+	if cellW <= 0 {
+		if width%opt.Cols != 0 {
+			return nil, fmt.Errorf("grid width %d does not split into %d columns", b.Dx(), opt.Cols)
+		}
+		cellW = width / opt.Cols
+	}
+	if cellH <= 0 {
+		if height%opt.Rows != 0 {
+			return nil, fmt.Errorf("grid height %d does not split into %d rows", b.Dy(), opt.Rows)
+		}
+		cellH = height / opt.Rows
+	}
+	left, top := width-opt.Cols*cellW, height-opt.Rows*cellH
+	if left < 0 || top < 0 {
+		return nil, fmt.Errorf("%dx%d cells of %dx%d do not fit in a %dx%d grid", opt.Cols, opt.Rows, cellW, cellH, b.Dx(), b.Dy())
+	}
+
+	images := make([]image.Image, count)
+	for i := range images {
+		x := b.Min.X + left + (i%opt.Cols)*(cellW+opt.Margin)
+		y := b.Min.Y + top + (i/opt.Cols)*(cellH+opt.Margin)
+		cell := image.NewRGBA(image.Rect(0, 0, cellW, cellH))
+		draw.Draw(cell, cell.Bounds(), grid, image.Pt(x, y), draw.Src)
+		images[i] = cell
+	}
+	return images, nil
+}
@@ -0,0 +1,141 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File is a Store appending the entries to a JSON Lines file, safe for concurrent use within
+// the process. Queries read the whole file.
+type File struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFile returns the store of the file at path, created with its directory on the first Add.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// DefaultPath is the history file of the CLI, <user config dir>/sdcli/history.jsonl, or empty
+// if there is no user config dir.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sdcli", "history.jsonl")
+}
+
+func (f *File) Add(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return file.Close()
+}
+
+func (f *File) Query(filter Filter) ([]*Entry, error) {
+	entries, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	return filter.apply(entries), nil
+}
+
+func (f *File) Get(id string) (*Entry, error) {
+	entries, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *File) read() ([]*Entry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e := new(Entry)
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("invalid history entry at line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entries, nil
+}
+
+// Memory is a Store keeping the entries in memory, for tests and short-lived processes.
+type Memory struct {
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+func (m *Memory) Add(e *Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+func (m *Memory) Query(filter Filter) ([]*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return filter.apply(m.entries), nil
+}
+
+func (m *Memory) Get(id string) (*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
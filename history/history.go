@@ -0,0 +1,273 @@
+// Package history records the generations run through an sdcli.API: their parameters, duration,
+// backend and output hashes, so they can be queried for usage reports or run again with the same
+// settings.
+package history
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Mode is the endpoint of a generation.
+type Mode string
+
+const (
+	ModeTxt2Img Mode = "txt2img"
+	ModeImg2Img Mode = "img2img"
+)
+
+// Entry is a recorded generation.
+type Entry struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Mode    Mode      `json:"mode"`
+	Backend string    `json:"backend,omitempty"`
+	// Model is the checkpoint reported in the generation info.
+	Model string `json:"model,omitempty"`
+	// Params are the request options, without the init images and mask of img2img.
+	Params json.RawMessage `json:"params"`
+	// Inputs are the SHA-256 hashes of the init images and mask of img2img.
+	Inputs []string `json:"inputs,omitempty"`
+	// Seeds are the seeds the server used, -1 in the params is resolved here.
+	Seeds    []int64       `json:"seeds,omitempty"`
+	Duration time.Duration `json:"duration"`
+	// Outputs are the SHA-256 hashes of the images, in the order of the response.
+	Outputs []string `json:"outputs,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Failed reports whether the generation failed.
+func (e *Entry) Failed() bool {
+	return len(e.Error) != 0
+}
+
+// Txt2Img returns the options of a txt2img entry. With fixSeed the seed of the first image is
+// set, so the generation is reproduced instead of varied.
+func (e *Entry) Txt2Img(fixSeed bool) (*sdcli.Txt2ImageOption, error) {
+	if e.Mode != ModeTxt2Img {
+		return nil, fmt.Errorf("entry %s is a %s generation", e.ID, e.Mode)
+	}
+	opt := new(sdcli.Txt2ImageOption)
+	if err := json.Unmarshal(e.Params, opt); err != nil {
+		return nil, fmt.Errorf("invalid params of entry %s: %w", e.ID, err)
+	}
+	if fixSeed && len(e.Seeds) != 0 {
+		opt.Seed = int(e.Seeds[0])
+	}
+	return opt, nil
+}
+
+// Img2Img returns the options of an img2img entry, the caller sets the init images and mask
+// matching the Inputs. With fixSeed the seed of the first image is set.
+func (e *Entry) Img2Img(fixSeed bool) (*sdcli.Img2ImgOption, error) {
+	if e.Mode != ModeImg2Img {
+		return nil, fmt.Errorf("entry %s is a %s generation", e.ID, e.Mode)
+	}
+	opt := new(sdcli.Img2ImgOption)
+	if err := json.Unmarshal(e.Params, opt); err != nil {
+		return nil, fmt.Errorf("invalid params of entry %s: %w", e.ID, err)
+	}
+	if fixSeed && len(e.Seeds) != 0 {
+		opt.Seed = int(e.Seeds[0])
+	}
+	return opt, nil
+}
+
+// Filter selects entries, zero fields match everything.
+type Filter struct {
+	Since, Until time.Time
+	Mode         Mode
+	Backend      string
+	Model        string
+	// Failed selects the failed generations only.
+	Failed bool
+	// Limit keeps the most recent entries.
+	Limit int
+}
+
+func (f *Filter) match(e *Entry) bool {
+	switch {
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+	case len(f.Mode) != 0 && e.Mode != f.Mode:
+	case len(f.Backend) != 0 && e.Backend != f.Backend:
+	case len(f.Model) != 0 && e.Model != f.Model:
+	case f.Failed && !e.Failed():
+	default:
+		return true
+	}
+	return false
+}
+
+// apply returns the entries matching f, oldest first.
+func (f *Filter) apply(entries []*Entry) []*Entry {
+	var out []*Entry
+	for _, e := range entries {
+		if f.match(e) {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Time.Before(out[j].Time)
+	})
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out
+}
+
+// Store keeps the entries.
+type Store interface {
+	Add(e *Entry) error
+	// Query returns the entries matching the filter, oldest first.
+	Query(f Filter) ([]*Entry, error)
+	// Get returns the entry of id, or nil if there is none.
+	Get(id string) (*Entry, error)
+}
+
+// Option configures a Recorder.
+type Option func(r *Recorder)
+
+// WithBackend names the backend in the entries, such as the name of a pool member.
+func WithBackend(name string) Option {
+	return func(r *Recorder) {
+		r.backend = name
+	}
+}
+
+// WithErrorHandler calls fn with the errors of the store, which are ignored by default so a
+// broken history does not fail the generations.
+func WithErrorHandler(fn func(err error)) Option {
+	return func(r *Recorder) {
+		r.onError = fn
+	}
+}
+
+// Recorder is an sdcli.API adding its txt2img and img2img generations to a Store, the other
+// methods are passed through.
+type Recorder struct {
+	sdcli.API
+
+	store   Store
+	backend string
+	onError func(err error)
+}
+
+// Wrap returns api recording its generations to store.
+func Wrap(api sdcli.API, store Store, opts ...Option) *Recorder {
+	r := &Recorder{
+		API:   api,
+		store: store,
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+func (r *Recorder) Txt2Img(ctx context.Context, opt sdcli.Txt2ImageOption, opts ...sdcli.RequestOption) (*sdcli.Txt2ImageResponse, error) {
+	e := r.entry(ModeTxt2Img, opt)
+	res, err := r.API.Txt2Img(ctx, opt, opts...)
+	if err == nil {
+		r.finish(e, res.RawImages, res.Info, nil)
+	} else {
+		r.finish(e, nil, "", err)
+	}
+	return res, err
+}
+
+func (r *Recorder) Img2Img(ctx context.Context, opt sdcli.Img2ImgOption, opts ...sdcli.RequestOption) (*sdcli.Img2ImgResponse, error) {
+	params := opt
+	params.InitImages, params.Mask = nil, ""
+	e := r.entry(ModeImg2Img, params)
+	for _, img := range opt.InitImages {
+		e.Inputs = append(e.Inputs, hashBase64(img))
+	}
+	if len(opt.Mask) != 0 {
+		e.Inputs = append(e.Inputs, hashBase64(opt.Mask))
+	}
+
+	res, err := r.API.Img2Img(ctx, opt, opts...)
+	if err == nil {
+		r.finish(e, res.RawImages, res.Info, nil)
+	} else {
+		r.finish(e, nil, "", err)
+	}
+	return res, err
+}
+
+func (r *Recorder) entry(mode Mode, params any) *Entry {
+	e := &Entry{
+		ID:      newID(),
+		Time:    time.Now(),
+		Mode:    mode,
+		Backend: r.backend,
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		r.fail(fmt.Errorf("failed to encode params: %w", err))
+	}
+	e.Params = data
+	return e
+}
+
+func (r *Recorder) finish(e *Entry, images [][]byte, info string, err error) {
+	e.Duration = time.Since(e.Time)
+	if err != nil {
+		e.Error = err.Error()
+	}
+	for _, data := range images {
+		e.Outputs = append(e.Outputs, Hash(data))
+	}
+	if len(info) != 0 {
+		if gen, err := sdcli.ParseInfo(info); err == nil {
+			e.Model, e.Seeds = gen.SDModelName, gen.AllSeeds
+		}
+	}
+
+	if err := r.store.Add(e); err != nil {
+		r.fail(fmt.Errorf("failed to record generation: %w", err))
+	}
+}
+
+func (r *Recorder) fail(err error) {
+	if r.onError != nil {
+		r.onError(err)
+	}
+}
+
+// Hash is the hex SHA-256 hash of data, as in the Inputs and Outputs of the entries.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashBase64 hashes the data of a base64 image, with or without a data URI prefix.
+func hashBase64(s string) string {
+	raw := s
+	if i := strings.IndexByte(raw, ','); i >= 0 {
+		raw = raw[i+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		// Hash the string itself, entries still match on the same input.
+		data = []byte(s)
+	}
+	return Hash(data)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,49 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// Usage sums up entries.
+type Usage struct {
+	Key         string        `json:"key,omitempty"`
+	Generations int           `json:"generations"`
+	Failed      int           `json:"failed"`
+	Images      int           `json:"images"`
+	Duration    time.Duration `json:"duration"`
+}
+
+func (u *Usage) add(e *Entry) {
+	u.Generations++
+	if e.Failed() {
+		u.Failed++
+	}
+	u.Images += len(e.Outputs)
+	u.Duration += e.Duration
+}
+
+// Summarize sums up the entries, in total and grouped by the key of every entry, such as its
+// Model or Backend. The groups are sorted by key.
+func Summarize(entries []*Entry, key func(e *Entry) string) (total *Usage, groups []*Usage) {
+	total = &Usage{}
+	byKey := map[string]*Usage{}
+	for _, e := range entries {
+		total.add(e)
+		if key == nil {
+			continue
+		}
+		k := key(e)
+		u, ok := byKey[k]
+		if !ok {
+			u = &Usage{Key: k}
+			byKey[k] = u
+			groups = append(groups, u)
+		}
+		u.add(e)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Key < groups[j].Key
+	})
+	return total, groups
+}
@@ -0,0 +1,226 @@
+// Package imagediff compares images for regression tests, so suites generating with a fixed seed
+// can assert that outputs did not drift across model, sampler or server changes.
+package imagediff
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Result of a comparison.
+type Result struct {
+	// DiffPixels is the number of pixels differing by more than the pixel threshold
+	// and DiffRatio their ratio to the total number of pixels.
+	DiffPixels int
+	DiffRatio  float64
+	// MaxDelta is the largest channel difference and MeanDelta the mean one, in [0, 255].
+	MaxDelta  uint8
+	MeanDelta float64
+	// SSIM is the structural similarity in [-1, 1], 1 for identical images.
+	SSIM float64
+}
+
+// Tolerance of a comparison, the zero value requires identical images.
+type Tolerance struct {
+	// PixelThreshold is the channel difference under which pixels are considered equal.
+	PixelThreshold uint8
+	// MaxDiffRatio is the ratio of differing pixels allowed.
+	MaxDiffRatio float64
+	// MinSSIM is the minimum structural similarity, unchecked when 0.
+	MinSSIM float64
+}
+
+// DefaultTolerance absorbs the nondeterminism of GPU kernels and lossy encodings,
+// while catching changes visible to the eye.
+var DefaultTolerance = Tolerance{
+	PixelThreshold: 8,
+	MaxDiffRatio:   0.01,
+	MinSSIM:        0.98,
+}
+
+// Compare compares two images of the same size and fails if they differ more than tol allows.
+// The result is returned in both cases.
+func Compare(a, b image.Image, tol Tolerance) (*Result, error) {
+	res, err := PixelDiff(a, b, tol.PixelThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if res.SSIM, err = SSIM(a, b); err != nil {
+		return nil, err
+	}
+
+	if res.DiffRatio > tol.MaxDiffRatio {
+		return res, fmt.Errorf("%.2f%% of pixels differ, more than %.2f%%", res.DiffRatio*100, tol.MaxDiffRatio*100)
+	}
+	if tol.MinSSIM != 0 && res.SSIM < tol.MinSSIM {
+		return res, fmt.Errorf("ssim %.4f is lower than %.4f", res.SSIM, tol.MinSSIM)
+	}
+
+	return res, nil
+}
+
+// PixelDiff counts the pixels differing by more than threshold on any channel, SSIM is not computed.
+func PixelDiff(a, b image.Image, threshold uint8) (*Result, error) {
+	if err := sameSize(a, b); err != nil {
+		return nil, err
+	}
+
+	res := new(Result)
+	ab, bb := a.Bounds(), b.Bounds()
+	w, h := ab.Dx(), ab.Dy()
+	var sum float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			ca := color.NRGBAModel.Convert(a.At(ab.Min.X+x, ab.Min.Y+y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)).(color.NRGBA)
+
+			var pixelMax uint8
+			for _, d := range [4]uint8{delta(ca.R, cb.R), delta(ca.G, cb.G), delta(ca.B, cb.B), delta(ca.A, cb.A)} {
+				sum += float64(d)
+				if d > pixelMax {
+					pixelMax = d
+				}
+			}
+			if pixelMax > res.MaxDelta {
+				res.MaxDelta = pixelMax
+			}
+			if pixelMax > threshold {
+				res.DiffPixels++
+			}
+		}
+	}
+
+	if n := w * h; n > 0 {
+		res.DiffRatio = float64(res.DiffPixels) / float64(n)
+		res.MeanDelta = sum / float64(n*4)
+	}
+
+	return res, nil
+}
+
+// SSIM window size and constants for 8-bit luma.
+const (
+	ssimWindow = 8
+	ssimC1     = (0.01 * 255) * (0.01 * 255)
+	ssimC2     = (0.03 * 255) * (0.03 * 255)
+)
+
+// SSIM returns the mean structural similarity of the luma of two images over 8x8 windows.
+func SSIM(a, b image.Image) (float64, error) {
+	if err := sameSize(a, b); err != nil {
+		return 0, err
+	}
+
+	la, lb := luma(a), luma(b)
+	w, h := a.Bounds().Dx(), a.Bounds().Dy()
+	win := ssimWindow
+	if w < win || h < win {
+		win = w
+		if h < win {
+			win = h
+		}
+	}
+	if win == 0 {
+		return 1, nil
+	}
+
+	var total float64
+	windows := 0
+	step := win / 2
+	if step == 0 {
+		step = 1
+	}
+	for y := 0; y+win <= h; y += step {
+		for x := 0; x+win <= w; x += step {
+			total += windowSSIM(la, lb, w, x, y, win)
+			windows++
+		}
+	}
+
+	return total / float64(windows), nil
+}
+
+func windowSSIM(la, lb []float64, stride, x0, y0, win int) float64 {
+	n := float64(win * win)
+	var ma, mb float64
+	for y := y0; y < y0+win; y++ {
+		for x := x0; x < x0+win; x++ {
+			ma += la[y*stride+x]
+			mb += lb[y*stride+x]
+		}
+	}
+	ma, mb = ma/n, mb/n
+
+	var va, vb, cov float64
+	for y := y0; y < y0+win; y++ {
+		for x := x0; x < x0+win; x++ {
+			da, db := la[y*stride+x]-ma, lb[y*stride+x]-mb
+			va += da * da
+			vb += db * db
+			cov += da * db
+		}
+	}
+	va, vb, cov = va/n, vb/n, cov/n
+
+	return ((2*ma*mb + ssimC1) * (2*cov + ssimC2)) / ((ma*ma + mb*mb + ssimC1) * (va + vb + ssimC2))
+}
+
+// Diff returns an image highlighting in red the pixels differing by more than threshold over a
+// faded copy of a, to be saved as a test artifact.
+func Diff(a, b image.Image, threshold uint8) (image.Image, error) {
+	if err := sameSize(a, b); err != nil {
+		return nil, err
+	}
+
+	ab, bb := a.Bounds(), b.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, ab.Dx(), ab.Dy()))
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			ca := color.NRGBAModel.Convert(a.At(ab.Min.X+x, ab.Min.Y+y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)).(color.NRGBA)
+			d := delta(ca.R, cb.R)
+			for _, v := range [3]uint8{delta(ca.G, cb.G), delta(ca.B, cb.B), delta(ca.A, cb.A)} {
+				if v > d {
+					d = v
+				}
+			}
+			if d > threshold {
+				dst.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+				continue
+			}
+			g := color.GrayModel.Convert(ca).(color.Gray).Y
+			g = 192 + g/4
+			dst.SetNRGBA(x, y, color.NRGBA{R: g, G: g, B: g, A: 255})
+		}
+	}
+
+	return dst, nil
+}
+
+func luma(img image.Image) []float64 {
+	b := img.Bounds()
+	res := make([]float64, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			res = append(res, (0.299*float64(r)+0.587*float64(g)+0.114*float64(bl))/257)
+		}
+	}
+	return res
+}
+
+func sameSize(a, b image.Image) error {
+	if a.Bounds().Dx() != b.Bounds().Dx() || a.Bounds().Dy() != b.Bounds().Dy() {
+		return fmt.Errorf("image sizes differ: %dx%d and %dx%d",
+			a.Bounds().Dx(), a.Bounds().Dy(), b.Bounds().Dx(), b.Bounds().Dy())
+	}
+	return nil
+}
+
+func delta(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
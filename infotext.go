@@ -0,0 +1,454 @@
+package sdcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Infotext is a parsed A1111 parameters string, as stored in the PNG files of WebUI and shown
+// by its PNG Info tab. Zero fields were not in the infotext.
+type Infotext struct {
+	Prompt         string
+	NegativePrompt string
+
+	Steps        int
+	Sampler      string
+	ScheduleType string
+	CfgScale     float32
+	Seed         int64
+	Width        int
+	Height       int
+	ModelHash    string
+	Model        string
+	VAEHash      string
+	VAE          string
+	ClipSkip     int
+	// DenoisingStrength is the strength of img2img, or of the second pass of the hires fix.
+	DenoisingStrength float32
+
+	VariationSeed         int64
+	VariationSeedStrength float32
+
+	HiresUpscale  float32
+	HiresResize   [2]int
+	HiresUpscaler string
+	HiresSteps    int
+
+	// LoraHashes and TIHashes are the short hashes of the LoRAs and embeddings of the prompt by
+	// name, Hashes the hashes of the other resources such as "vae" or "lora:name".
+	LoraHashes map[string]string
+	TIHashes   map[string]string
+	Hashes     map[string]string
+	// ControlNets are the units of the ControlNet extension, in unit order.
+	ControlNets []*InfotextControlNet
+
+	Version string
+	// Params are all the fields of the parameters line with their values unquoted, including the
+	// typed ones above.
+	Params map[string]string
+}
+
+// InfotextControlNet is a "ControlNet N" block of an infotext.
+type InfotextControlNet struct {
+	Unit   int
+	Module string
+	Model  string
+	Weight float32
+	// Params are all the fields of the block.
+	Params map[string]string
+}
+
+// reInfotextParam matches a field of the parameters line, with a JSON quoted or plain value.
+var reInfotextParam = regexp.MustCompile(`\s*(\w[\w \-/]+):\s*("(?:\\.|[^\\"])+"|[^,]*)(?:,|$)`)
+
+// ParseInfotext parses an A1111 parameters string: the prompt, an optional line starting with
+// "Negative prompt:" and the "Steps: 20, Sampler: Euler a, ..." parameters line. Unknown fields
+// are kept in Params, so infotexts of extensions and newer versions parse. Only an empty
+// infotext or an invalid value of a typed field fail.
+func ParseInfotext(s string) (*Infotext, error) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
+	if len(s) == 0 {
+		return nil, wrapError(nil, nil, "empty infotext")
+	}
+
+	lines := strings.Split(s, "\n")
+	t := &Infotext{Params: map[string]string{}}
+	// The last line is the parameters line when it has at least 3 fields, like WebUI reads it.
+	if last := lines[len(lines)-1]; len(reInfotextParam.FindAllString(last, 3)) >= 3 {
+		for _, m := range reInfotextParam.FindAllStringSubmatch(last, -1) {
+			t.Params[strings.TrimSpace(m[1])] = unquoteInfotext(strings.TrimSpace(m[2]))
+		}
+		lines = lines[:len(lines)-1]
+	}
+
+	var prompt, negative []string
+	inNegative := false
+	for _, line := range lines {
+		if rest, ok := strings.CutPrefix(line, "Negative prompt:"); ok && !inNegative {
+			inNegative = true
+			line = strings.TrimSpace(rest)
+		}
+		if inNegative {
+			negative = append(negative, line)
+		} else {
+			prompt = append(prompt, line)
+		}
+	}
+	t.Prompt = strings.TrimSpace(strings.Join(prompt, "\n"))
+	t.NegativePrompt = strings.TrimSpace(strings.Join(negative, "\n"))
+
+	if err := t.parseParams(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Infotext) parseParams() error {
+	p := infotextParams{params: t.Params}
+	p.int("Steps", &t.Steps)
+	p.string("Sampler", &t.Sampler)
+	p.string("Schedule type", &t.ScheduleType)
+	p.float("CFG scale", &t.CfgScale)
+	p.int64("Seed", &t.Seed)
+	p.size("Size", &t.Width, &t.Height)
+	p.string("Model hash", &t.ModelHash)
+	p.string("Model", &t.Model)
+	p.string("VAE hash", &t.VAEHash)
+	p.string("VAE", &t.VAE)
+	p.int("Clip skip", &t.ClipSkip)
+	p.float("Denoising strength", &t.DenoisingStrength)
+	p.int64("Variation seed", &t.VariationSeed)
+	p.float("Variation seed strength", &t.VariationSeedStrength)
+	p.float("Hires upscale", &t.HiresUpscale)
+	p.size("Hires resize", &t.HiresResize[0], &t.HiresResize[1])
+	p.string("Hires upscaler", &t.HiresUpscaler)
+	p.int("Hires steps", &t.HiresSteps)
+	p.string("Version", &t.Version)
+	t.LoraHashes = p.hashList("Lora hashes")
+	t.TIHashes = p.hashList("TI hashes")
+	if v, ok := t.Params["Hashes"]; ok {
+		if err := json.Unmarshal([]byte(v), &t.Hashes); err != nil {
+			p.fail("Hashes", v)
+		}
+	}
+
+	var units []int
+	for key := range t.Params {
+		if n, ok := strings.CutPrefix(key, "ControlNet "); ok {
+			if unit, err := strconv.Atoi(n); err == nil {
+				units = append(units, unit)
+			}
+		}
+	}
+	sort.Ints(units)
+	for _, unit := range units {
+		cn := &InfotextControlNet{Unit: unit, Params: map[string]string{}}
+		for _, m := range reInfotextParam.FindAllStringSubmatch(t.Params["ControlNet "+strconv.Itoa(unit)], -1) {
+			cn.Params[strings.TrimSpace(m[1])] = unquoteInfotext(strings.TrimSpace(m[2]))
+		}
+		cp := infotextParams{params: cn.Params}
+		cp.string("Module", &cn.Module)
+		cp.string("Model", &cn.Model)
+		cp.float("Weight", &cn.Weight)
+		if cp.err != nil {
+			return cp.err
+		}
+		t.ControlNets = append(t.ControlNets, cn)
+	}
+
+	return p.err
+}
+
+// infotextParams reads typed fields, keeping the first error.
+type infotextParams struct {
+	params map[string]string
+	err    error
+}
+
+func (p *infotextParams) fail(key, value string) {
+	if p.err == nil {
+		p.err = wrapError(nil, nil, "invalid infotext %s %q", key, value)
+	}
+}
+
+func (p *infotextParams) string(key string, dst *string) {
+	if v, ok := p.params[key]; ok {
+		*dst = v
+	}
+}
+
+func (p *infotextParams) int(key string, dst *int) {
+	if v, ok := p.params[key]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			p.fail(key, v)
+		}
+		*dst = n
+	}
+}
+
+func (p *infotextParams) int64(key string, dst *int64) {
+	if v, ok := p.params[key]; ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			p.fail(key, v)
+		}
+		*dst = n
+	}
+}
+
+func (p *infotextParams) float(key string, dst *float32) {
+	if v, ok := p.params[key]; ok {
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			p.fail(key, v)
+		}
+		*dst = float32(f)
+	}
+}
+
+func (p *infotextParams) size(key string, width, height *int) {
+	v, ok := p.params[key]
+	if !ok {
+		return
+	}
+	w, h, found := strings.Cut(v, "x")
+	var err1, err2 error
+	*width, err1 = strconv.Atoi(w)
+	*height, err2 = strconv.Atoi(h)
+	if !found || err1 != nil || err2 != nil {
+		p.fail(key, v)
+	}
+}
+
+// hashList parses a "name: hash, name: hash" field.
+func (p *infotextParams) hashList(key string) map[string]string {
+	v, ok := p.params[key]
+	if !ok {
+		return nil
+	}
+	hashes := map[string]string{}
+	for _, item := range strings.Split(v, ",") {
+		name, hash, found := strings.Cut(item, ":")
+		if !found {
+			p.fail(key, v)
+			return nil
+		}
+		hashes[strings.TrimSpace(name)] = strings.TrimSpace(hash)
+	}
+	return hashes
+}
+
+// unquoteInfotext decodes the JSON quoted values of the parameters line.
+func unquoteInfotext(v string) string {
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return v
+	}
+	var s string
+	if err := json.Unmarshal([]byte(v), &s); err != nil {
+		return v
+	}
+	return s
+}
+
+// Txt2Img returns the txt2img options recreating the image, with the checkpoint and clip skip
+// as override settings and the hires fix enabled when the infotext has one.
+func (t *Infotext) Txt2Img() Txt2ImageOption {
+	opt := Txt2ImageOption{
+		Prompt:          t.Prompt,
+		NegativePrompt:  t.NegativePrompt,
+		Steps:           t.Steps,
+		SamplerName:     t.Sampler,
+		Scheduler:       t.ScheduleType,
+		CfgScale:        t.CfgScale,
+		Seed:            int(t.Seed),
+		Width:           t.Width,
+		Height:          t.Height,
+		Subseed:         int(t.VariationSeed),
+		SubseedStrength: t.VariationSeedStrength,
+	}
+	if len(t.Model) != 0 {
+		opt.OverrideSettings = Overrides{}.SetModel(t.Model)
+	}
+	if t.ClipSkip != 0 {
+		if opt.OverrideSettings == nil {
+			opt.OverrideSettings = Overrides{}
+		}
+		opt.OverrideSettings.SetCLIPSkip(t.ClipSkip)
+	}
+	if t.HiresUpscale != 0 || t.HiresResize != [2]int{} {
+		opt.EnableHR = true
+		opt.HRScale = t.HiresUpscale
+		opt.HrResizeX, opt.HrResizeY = t.HiresResize[0], t.HiresResize[1]
+		opt.HrUpscaler = t.HiresUpscaler
+		opt.HrSecondPassSteps = t.HiresSteps
+		opt.DenoisingStrength = t.DenoisingStrength
+	}
+	return opt
+}
+
+// NewInfotext returns the infotext of an image generated with opt, seed being the seed resolved
+// by the server for random seeds and model the checkpoint used, as reported by the generation
+// info. Empty arguments keep the ones of opt.
+func NewInfotext(opt Txt2ImageOption, seed int64, model string) *Infotext {
+	t := &Infotext{
+		Prompt:                opt.Prompt,
+		NegativePrompt:        opt.NegativePrompt,
+		Steps:                 opt.Steps,
+		Sampler:               opt.SamplerName,
+		ScheduleType:          opt.Scheduler,
+		CfgScale:              opt.CfgScale,
+		Seed:                  int64(opt.Seed),
+		Width:                 opt.Width,
+		Height:                opt.Height,
+		VariationSeed:         int64(opt.Subseed),
+		VariationSeedStrength: opt.SubseedStrength,
+		Model:                 model,
+	}
+	if seed != 0 && seed != SeedRandom {
+		t.Seed = seed
+	}
+	if len(t.Model) == 0 {
+		t.Model, _ = opt.OverrideSettings[OptionModel].(string)
+	}
+	if clipSkip, ok := opt.OverrideSettings[OptionCLIPSkip].(int); ok {
+		t.ClipSkip = clipSkip
+	}
+	if t.VariationSeedStrength == 0 {
+		t.VariationSeed = 0
+	}
+	if opt.EnableHR {
+		t.DenoisingStrength = opt.DenoisingStrength
+		t.HiresUpscale = opt.HRScale
+		t.HiresResize = [2]int{opt.HrResizeX, opt.HrResizeY}
+		t.HiresUpscaler = opt.HrUpscaler
+		t.HiresSteps = opt.HrSecondPassSteps
+	}
+	return t
+}
+
+// String renders the infotext in the format of WebUI, which its PNG Info tab and galleries read
+// back: the typed fields in the order WebUI writes them, then the other Params by name.
+func (t *Infotext) String() string {
+	b := &strings.Builder{}
+	b.WriteString(t.Prompt)
+	if len(t.NegativePrompt) != 0 {
+		b.WriteString("\nNegative prompt: ")
+		b.WriteString(t.NegativePrompt)
+	}
+
+	w := &infotextWriter{b: b, written: map[string]bool{}}
+	w.int("Steps", int64(t.Steps))
+	w.string("Sampler", t.Sampler)
+	w.string("Schedule type", t.ScheduleType)
+	w.float("CFG scale", t.CfgScale)
+	w.int("Seed", t.Seed)
+	if t.Width != 0 && t.Height != 0 {
+		w.string("Size", fmt.Sprintf("%dx%d", t.Width, t.Height))
+	}
+	w.string("Model hash", t.ModelHash)
+	w.string("Model", t.Model)
+	w.string("VAE hash", t.VAEHash)
+	w.string("VAE", t.VAE)
+	w.int("Variation seed", t.VariationSeed)
+	w.float("Variation seed strength", t.VariationSeedStrength)
+	w.float("Denoising strength", t.DenoisingStrength)
+	w.int("Clip skip", int64(t.ClipSkip))
+	w.float("Hires upscale", t.HiresUpscale)
+	if t.HiresResize[0] != 0 && t.HiresResize[1] != 0 {
+		w.string("Hires resize", fmt.Sprintf("%dx%d", t.HiresResize[0], t.HiresResize[1]))
+	}
+	w.int("Hires steps", int64(t.HiresSteps))
+	w.string("Hires upscaler", t.HiresUpscaler)
+	w.string("Lora hashes", hashList(t.LoraHashes))
+	w.string("TI hashes", hashList(t.TIHashes))
+	for _, cn := range t.ControlNets {
+		block := &infotextWriter{b: &strings.Builder{}, written: map[string]bool{}}
+		block.string("Module", cn.Module)
+		block.string("Model", cn.Model)
+		block.float("Weight", cn.Weight)
+		block.rest(cn.Params)
+		w.string("ControlNet "+strconv.Itoa(cn.Unit), strings.TrimPrefix(block.b.String(), "\n"))
+	}
+	if len(t.Hashes) != 0 {
+		data, _ := json.Marshal(t.Hashes)
+		w.string("Hashes", string(data))
+	}
+	w.string("Version", t.Version)
+	w.rest(t.Params)
+	return b.String()
+}
+
+// infotextWriter writes the fields of a parameters line.
+type infotextWriter struct {
+	b       *strings.Builder
+	written map[string]bool
+}
+
+func (w *infotextWriter) string(key, value string) {
+	if len(value) == 0 || w.written[key] {
+		return
+	}
+	w.written[key] = true
+	if len(w.written) == 1 {
+		w.b.WriteString("\n")
+	} else {
+		w.b.WriteString(", ")
+	}
+	w.b.WriteString(key)
+	w.b.WriteString(": ")
+	w.b.WriteString(quoteInfotext(value))
+}
+
+func (w *infotextWriter) int(key string, value int64) {
+	if value != 0 {
+		w.string(key, strconv.FormatInt(value, 10))
+	}
+}
+
+func (w *infotextWriter) float(key string, value float32) {
+	if value != 0 {
+		w.string(key, strconv.FormatFloat(float64(value), 'f', -1, 32))
+	}
+}
+
+// rest writes the params not written yet, sorted by name, skipping the ControlNet blocks which
+// are written from their typed units.
+func (w *infotextWriter) rest(params map[string]string) {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if !w.written[key] && !strings.HasPrefix(key, "ControlNet ") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		w.string(key, params[key])
+	}
+}
+
+// hashList formats a "name: hash, name: hash" field, sorted by name.
+func hashList(hashes map[string]string) string {
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = name + ": " + hashes[name]
+	}
+	return strings.Join(names, ", ")
+}
+
+// quoteInfotext quotes values with separators as JSON, like WebUI does.
+func quoteInfotext(v string) string {
+	if !strings.ContainsAny(v, ",:\n") {
+		return v
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
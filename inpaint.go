@@ -0,0 +1,175 @@
+package sdcli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// InpaintOption configures Inpaint, NewInpaintOption returns the WebUI defaults.
+type InpaintOption struct {
+	Prompt         string
+	NegativePrompt string
+	// DenoisingStrength is how much the masked area changes, between 0 and 1.
+	DenoisingStrength float32
+	// Fill is the masked content, unlike Img2ImgOption.InpaintingFill it is always sent so
+	// the zero value means InpaintFill.
+	Fill InpaintingFill
+	// MaskBlur feathers the mask edges by this many pixels.
+	MaskBlur int
+	// InvertMask repaints the black area of the mask instead of the white one.
+	InvertMask bool
+	// OnlyMasked renders the masked area alone at full resolution, with Padding pixels of context,
+	// instead of the whole picture.
+	OnlyMasked bool
+	Padding    int
+	// Width and Height default to the image size rounded down to a multiple of 8, the image is
+	// cropped when the aspect ratio differs.
+	Width, Height int
+
+	// Base holds the other img2img parameters such as steps, sampler or seed. Its images, mask,
+	// size and inpainting fields are replaced.
+	Base Img2ImgOption
+}
+
+// NewInpaintOption returns the WebUI inpainting defaults: original content, 0.75 denoising
+// strength, 4 pixels of blur and the whole picture rendered.
+func NewInpaintOption(prompt string) InpaintOption {
+	return InpaintOption{
+		Prompt:            prompt,
+		DenoisingStrength: DefaultDenoisingStrength,
+		Fill:              InpaintOriginal,
+		MaskBlur:          4,
+		Padding:           32,
+	}
+}
+
+// Inpaint repaints the white area of mask in img, mask must be as large as img. Mask colors
+// are converted to grayscale, so soft edges are kept.
+func (c *Client) Inpaint(ctx context.Context, img, mask image.Image, opt InpaintOption, opts ...RequestOption) (*Img2ImgResponse, error) {
+	o, err := inpaintOption(img, mask, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := requestContext(ctx, opts)
+	defer cancel()
+
+	return c.img2img(ctx, &o, inpaintBody{&o})
+}
+
+// inpaintBody encodes an inpainting request, sending inpainting_fill even if 0.
+type inpaintBody struct {
+	opt *Img2ImgOption
+}
+
+func (b inpaintBody) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(b.opt)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]any{}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	params["inpainting_fill"] = b.opt.InpaintingFill
+	return json.Marshal(params)
+}
+
+// inpaintOption returns the img2img options of an inpainting.
+func inpaintOption(img, mask image.Image, opt InpaintOption) (Img2ImgOption, error) {
+	size := img.Bounds().Size()
+	if mask.Bounds().Size() != size {
+		return Img2ImgOption{}, fmt.Errorf("mask size %v does not match image size %v", mask.Bounds().Size(), size)
+	}
+	if opt.Fill < InpaintFill || opt.Fill > InpaintLatentNothing {
+		return Img2ImgOption{}, fmt.Errorf("invalid inpainting fill %d", opt.Fill)
+	}
+
+	o := opt.Base
+	o.Prompt = opt.Prompt
+	o.NegativePrompt = opt.NegativePrompt
+	o.DenoisingStrength = opt.DenoisingStrength
+	o.MaskBlur = opt.MaskBlur
+	o.InpaintFullRes = opt.OnlyMasked
+	o.InpaintFullResPadding = opt.Padding
+	o.InpaintingMaskInvert = InpaintMasked
+	if opt.InvertMask {
+		o.InpaintingMaskInvert = InpaintNotMasked
+	}
+
+	// Just resize keeps the mask aligned when the aspect ratio is kept, crop and resize otherwise.
+	o.Width, o.Height = opt.Width, opt.Height
+	o.ResizeMode = ResizeJust
+	if o.Width == 0 || o.Height == 0 {
+		o.Width, o.Height = roundSize(size.X), roundSize(size.Y)
+	} else if o.Width*size.Y != o.Height*size.X {
+		o.ResizeMode = ResizeCrop
+	}
+
+	initImage, err := EncodeImage(img)
+	if err != nil {
+		return Img2ImgOption{}, err
+	}
+	o.InitImages = []string{initImage}
+	gray := image.NewGray(mask.Bounds())
+	draw.Draw(gray, gray.Bounds(), mask, mask.Bounds().Min, draw.Src)
+	if o.Mask, err = EncodeImage(image.Image(gray)); err != nil {
+		return Img2ImgOption{}, err
+	}
+
+	o.InpaintingFill = opt.Fill
+
+	return o, nil
+}
+
+// roundSize rounds an image size down to a multiple of SizeMultiple.
+func roundSize(n int) int {
+	if n < SizeMultiple {
+		return SizeMultiple
+	}
+	return n - n%SizeMultiple
+}
+
+// SplitAlpha splits an image whose area to repaint was erased in an image editor into an opaque
+// base and the mask of the transparent area, white where fully transparent. Partially
+// transparent pixels give gray so soft edges are kept, and keep their color in the base.
+func SplitAlpha(img image.Image) (*image.RGBA, *image.Gray) {
+	b := img.Bounds()
+	base := image.NewRGBA(b)
+	mask := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			base.SetRGBA(x, y, color.RGBA{R: c.R, G: c.G, B: c.B, A: 0xff})
+			mask.SetGray(x, y, color.Gray{Y: 0xff - c.A})
+		}
+	}
+	return base, mask
+}
+
+// InpaintUpload repaints the transparent area of img, the inpaint upload flow of the WebUI
+// where the mask comes with the image: img is split by SplitAlpha and the mask is sent with
+// the masked area repainted, whatever opt.InvertMask. Images without transparency fail.
+func (c *Client) InpaintUpload(ctx context.Context, img image.Image, opt InpaintOption, opts ...RequestOption) (*Img2ImgResponse, error) {
+	base, mask := SplitAlpha(img)
+	if !hasMask(mask) {
+		return nil, errors.New("image has no transparent area to inpaint")
+	}
+	opt.InvertMask = false
+	return c.Inpaint(ctx, base, mask, opt, opts...)
+}
+
+// hasMask reports whether mask has a non-black pixel.
+func hasMask(mask *image.Gray) bool {
+	for _, v := range mask.Pix {
+		if v != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package sdcli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+)
+
+// EncodeImage encodes an image for the API as a base64 data URI. src is an image.Image (encoded
+// as PNG), the []byte of an encoded image, an io.Reader of one, or a string passed as is, being
+// already base64 encoded.
+func EncodeImage(src any) (string, error) {
+	var data []byte
+	switch v := src.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		data = v
+	case image.Image:
+		buf := &bytes.Buffer{}
+		if err := png.Encode(buf, v); err != nil {
+			return "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		data = buf.Bytes()
+	case io.Reader:
+		var err error
+		if data, err = io.ReadAll(v); err != nil {
+			return "", fmt.Errorf("failed to read image: %w", err)
+		}
+	case nil:
+		return "", fmt.Errorf("nil image")
+	default:
+		return "", fmt.Errorf("unsupported image type %T", src)
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("empty image")
+	}
+
+	mime := "image/png"
+	if format := DetectFormat(data); format != FormatUnknown {
+		mime = "image/" + format
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// AddInitImage appends an init image, see EncodeImage for the accepted types.
+func (o *Img2ImgOption) AddInitImage(src any) error {
+	img, err := EncodeImage(src)
+	if err != nil {
+		return err
+	}
+	o.InitImages = append(o.InitImages, img)
+	return nil
+}
+
+// SetInitImages replaces the init images, see EncodeImage for the accepted types.
+func (o *Img2ImgOption) SetInitImages(srcs ...any) error {
+	images := make([]string, 0, len(srcs))
+	for _, src := range srcs {
+		img, err := EncodeImage(src)
+		if err != nil {
+			return err
+		}
+		images = append(images, img)
+	}
+	o.InitImages = images
+	return nil
+}
+
+// SetMask sets the inpainting mask, see EncodeImage for the accepted types.
+func (o *Img2ImgOption) SetMask(src any) error {
+	mask, err := EncodeImage(src)
+	if err != nil {
+		return err
+	}
+	o.Mask = mask
+	return nil
+}
+
+// SetImage sets the image to process, see EncodeImage for the accepted types.
+func (o *ExtraSingleImgOption) SetImage(src any) error {
+	img, err := EncodeImage(src)
+	if err != nil {
+		return err
+	}
+	o.Image = img
+	return nil
+}
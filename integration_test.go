@@ -0,0 +1,53 @@
+//go:build integration
+
+package sdcli_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shallowclouds/go-sd-webui-cli/config"
+	"github.com/shallowclouds/go-sd-webui-cli/sdclitest"
+)
+
+// Run against a live server with:
+//
+//	SD_WEBUI_URL=http://127.0.0.1:7860 go test -tags integration -run Integration -v .
+//
+// SD_WEBUI_USER and SD_WEBUI_PASSWORD set the credentials, and SDCLI_SKIP_GENERATION=1 skips the
+// endpoints using the GPU.
+func TestIntegration(t *testing.T) {
+	cfg, err := config.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.URL) == 0 {
+		t.Skipf("%s is not set", config.EnvURL)
+	}
+	cli, err := cfg.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	h := sdclitest.NewHarness(cli)
+	h.SkipGeneration = len(os.Getenv("SDCLI_SKIP_GENERATION")) != 0
+	report := h.Run(ctx)
+	t.Logf("compatibility of %s:\n%s", cfg.URL, report)
+
+	for _, res := range report {
+		res := res
+		t.Run(res.Name, func(t *testing.T) {
+			switch res.Status {
+			case sdclitest.StatusFailed:
+				t.Error(res.Err)
+			case sdclitest.StatusUnsupported, sdclitest.StatusSkipped:
+				t.Skip(res.Status)
+			}
+		})
+	}
+}
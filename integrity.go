@@ -0,0 +1,124 @@
+package sdcli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ModelDigest is the expected digest of a checkpoint, e.g. from a deployment manifest or the
+// Civitai metadata of a model version, whose SHA256 and AutoV2 hashes are SHA256 and Hash.
+type ModelDigest struct {
+	// Name matches a title, model name or hash, see FindModel.
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Hash is the short hash shown by WebUI, the first 10 hex digits of the SHA256.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Reasons of model mismatches.
+const (
+	// MismatchMissing is a checkpoint the server does not have.
+	MismatchMissing = "missing"
+	// MismatchUnhashed is a checkpoint the server has not hashed yet, WebUI computes the
+	// SHA256 when loading a checkpoint unless started with --no-hashing.
+	MismatchUnhashed = "unhashed"
+	// MismatchDigest is a checkpoint whose digest differs from the expected one.
+	MismatchDigest = "digest"
+)
+
+// ModelMismatch is a checkpoint failing verification, Model is nil if it is missing.
+type ModelMismatch struct {
+	Expected ModelDigest
+	Model    *ModelsResponse
+	Reason   string
+}
+
+func (m *ModelMismatch) Error() string {
+	switch m.Reason {
+	case MismatchMissing:
+		return fmt.Sprintf("checkpoint %s is missing", m.Expected.Name)
+	case MismatchUnhashed:
+		return fmt.Sprintf("checkpoint %s is not hashed", m.Model.Title)
+	default:
+		return fmt.Sprintf("checkpoint %s has sha256 %q and hash %q, expected sha256 %q and hash %q",
+			m.Model.Title, m.Model.Sha256, m.Model.Hash, m.Expected.SHA256, m.Expected.Hash)
+	}
+}
+
+// ModelMismatches are all the checkpoints failing verification.
+type ModelMismatches []*ModelMismatch
+
+func (e ModelMismatches) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "model verification failed: " + strings.Join(msgs, "; ")
+}
+
+// VerifyModels checks the digests of checkpoints against the expected ones. Digests are compared
+// case-insensitively, the short hash of a checkpoint is checked against the expected SHA256
+// when the server only reports the former.
+func VerifyModels(models []*ModelsResponse, expected []ModelDigest) ModelMismatches {
+	var res ModelMismatches
+	for _, want := range expected {
+		m := FindModel(models, want.Name)
+		if m == nil {
+			res = append(res, &ModelMismatch{Expected: want, Reason: MismatchMissing})
+			continue
+		}
+
+		ok, hashed := true, false
+		if len(want.SHA256) != 0 {
+			switch {
+			case len(m.Sha256) != 0:
+				hashed = true
+				ok = ok && strings.EqualFold(m.Sha256, want.SHA256)
+			case len(m.Hash) != 0:
+				hashed = true
+				ok = ok && len(want.SHA256) >= len(m.Hash) && strings.EqualFold(m.Hash, want.SHA256[:len(m.Hash)])
+			}
+		}
+		if len(want.Hash) != 0 && len(m.Hash) != 0 {
+			hashed = true
+			ok = ok && strings.EqualFold(m.Hash, want.Hash)
+		}
+
+		switch {
+		case !hashed && (len(want.SHA256) != 0 || len(want.Hash) != 0):
+			res = append(res, &ModelMismatch{Expected: want, Model: m, Reason: MismatchUnhashed})
+		case !ok:
+			res = append(res, &ModelMismatch{Expected: want, Model: m, Reason: MismatchDigest})
+		}
+	}
+	return res
+}
+
+// LoadModelDigests reads a JSON manifest, a list of ModelDigest.
+func LoadModelDigests(name string) ([]ModelDigest, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, wrapError(err, nil, "failed to read manifest")
+	}
+	var res []ModelDigest
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, wrapError(err, nil, "failed to parse manifest %s", name)
+	}
+	return res, nil
+}
+
+// VerifyModels checks the checkpoints of the server against the expected digests, failing with
+// ModelMismatches, see the VerifyModels function.
+func (c *Client) VerifyModels(ctx context.Context, expected ...ModelDigest) error {
+	models, err := c.GetModels(ctx)
+	if err != nil {
+		return err
+	}
+	if res := VerifyModels(models, expected); len(res) != 0 {
+		return res
+	}
+	return nil
+}
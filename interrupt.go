@@ -0,0 +1,57 @@
+package sdcli
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DefaultInterruptTimeout bounds the interrupt request of WithInterruptOnCancel.
+const DefaultInterruptTimeout = 5 * time.Second
+
+// WithInterruptOnCancel interrupts the server job when the context of a generation request is
+// done while it is in flight, so the GPU stops working on an image nobody waits for anymore.
+// The interrupt is best effort, bounded by timeout (DefaultInterruptTimeout if not positive).
+// WebUI interrupts whatever job is running, leave it off when sharing a server with other clients.
+func WithInterruptOnCancel(timeout time.Duration) Option {
+	return func(c *Client) {
+		if timeout <= 0 {
+			timeout = DefaultInterruptTimeout
+		}
+		c.interruptTimeout = timeout
+	}
+}
+
+// Interrupt stops the running job, the generation request then returns the images done so far.
+func (c *Client) Interrupt(ctx context.Context) error {
+	return c.doReq(ctx, "/interrupt", http.MethodPost, nil, http.StatusOK, nil)
+}
+
+// Skip skips the current image of the running job, moving on to the next one of the batch.
+func (c *Client) Skip(ctx context.Context) error {
+	return c.doReq(ctx, "/skip", http.MethodPost, nil, http.StatusOK, nil)
+}
+
+// generate sends a generation request, recovering its result if WithRecovery is set and
+// interrupting it if ctx is done first and WithInterruptOnCancel is set.
+func (c *Client) generate(ctx context.Context, path string, body, result any) error {
+	var err error
+	if c.recovery != nil {
+		err = c.generateRecoverable(ctx, path, body, result)
+	} else {
+		err = c.doReq(ctx, path, http.MethodPost, body, http.StatusOK, result)
+	}
+	if err != nil {
+		c.interruptCanceled(ctx)
+	}
+	return err
+}
+
+// interruptCanceled interrupts the server job if ctx is done and WithInterruptOnCancel is set.
+func (c *Client) interruptCanceled(ctx context.Context) {
+	if c.interruptTimeout > 0 && ctx.Err() != nil {
+		ictx, cancel := context.WithTimeout(ContextWithRequestID(context.Background(), RequestIDFromContext(ctx)), c.interruptTimeout)
+		defer cancel()
+		_ = c.Interrupt(ictx)
+	}
+}
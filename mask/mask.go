@@ -0,0 +1,172 @@
+// Package mask builds inpainting masks, grayscale images where white is repainted and black
+// is kept. The results can be passed to sdcli.Img2ImgOption.SetMask or sdcli.Client.Inpaint.
+package mask
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// New returns an empty (black) mask.
+func New(bounds image.Rectangle) *image.Gray {
+	return image.NewGray(bounds)
+}
+
+// Rect marks a rectangle.
+func Rect(m *image.Gray, r image.Rectangle) {
+	draw.Draw(m, r.Intersect(m.Bounds()), image.White, image.Point{}, draw.Src)
+}
+
+// Circle marks a disc.
+func Circle(m *image.Gray, center image.Point, radius int) {
+	r := image.Rect(center.X-radius, center.Y-radius, center.X+radius+1, center.Y+radius+1).Intersect(m.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dx, dy := x-center.X, y-center.Y
+			if dx*dx+dy*dy <= radius*radius {
+				m.SetGray(x, y, color.Gray{Y: 0xff})
+			}
+		}
+	}
+}
+
+// Polygon marks the inside of a polygon using the even-odd rule, pixels are inside when
+// their center is.
+func Polygon(m *image.Gray, points ...image.Point) {
+	if len(points) < 3 {
+		return
+	}
+
+	b := m.Bounds()
+	xs := make([]float64, 0, len(points))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		cy := float64(y) + 0.5
+		xs = xs[:0]
+		for i, p := range points {
+			q := points[(i+1)%len(points)]
+			y0, y1 := float64(p.Y), float64(q.Y)
+			if (y0 <= cy) == (y1 <= cy) {
+				continue
+			}
+			xs = append(xs, float64(p.X)+(cy-y0)*float64(q.X-p.X)/(y1-y0))
+		}
+		sort.Float64s(xs)
+
+		for i := 0; i+1 < len(xs); i += 2 {
+			from := int(math.Ceil(xs[i] - 0.5))
+			to := int(math.Ceil(xs[i+1] - 0.5))
+			if from < b.Min.X {
+				from = b.Min.X
+			}
+			if to > b.Max.X {
+				to = b.Max.X
+			}
+			for x := from; x < to; x++ {
+				m.SetGray(x, y, color.Gray{Y: 0xff})
+			}
+		}
+	}
+}
+
+// Invert swaps the repainted and kept areas.
+func Invert(m *image.Gray) {
+	for i, v := range m.Pix {
+		m.Pix[i] = 0xff - v
+	}
+}
+
+// FromAlpha returns the mask of the transparent area of img, as edited out in an image
+// editor. Partially transparent pixels give gray, so soft edges are kept.
+func FromAlpha(img image.Image) *image.Gray {
+	b := img.Bounds()
+	m := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			m.SetGray(x, y, color.Gray{Y: 0xff - uint8(a>>8)})
+		}
+	}
+	return m
+}
+
+// FromDiff returns the mask of the pixels of two images of the same size having a channel
+// differing by more than threshold, e.g. to repaint what was edited in a copy of an output.
+func FromDiff(a, b image.Image, threshold uint8) (*image.Gray, error) {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return nil, fmt.Errorf("image sizes differ: %v and %v", a.Bounds().Size(), b.Bounds().Size())
+	}
+
+	ba, bb := a.Bounds(), b.Bounds()
+	m := image.NewGray(ba)
+	for y := 0; y < ba.Dy(); y++ {
+		for x := 0; x < ba.Dx(); x++ {
+			r1, g1, b1, a1 := a.At(ba.Min.X+x, ba.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			for _, d := range [4]uint8{delta(r1, r2), delta(g1, g2), delta(b1, b2), delta(a1, a2)} {
+				if d > threshold {
+					m.SetGray(ba.Min.X+x, ba.Min.Y+y, color.Gray{Y: 0xff})
+					break
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+// Feather returns a copy of the mask with edges softened over radius pixels, by three box
+// blur passes approximating a gaussian blur.
+func Feather(m *image.Gray, radius int) *image.Gray {
+	b := m.Bounds()
+	res := image.NewGray(b)
+	draw.Draw(res, b, m, b.Min, draw.Src)
+	if radius <= 0 {
+		return res
+	}
+
+	w, h := b.Dx(), b.Dy()
+	buf := make([]uint8, len(res.Pix))
+	box := radius / 2
+	if box < 1 {
+		box = 1
+	}
+	for pass := 0; pass < 3; pass++ {
+		boxBlur(res.Pix, buf, w, h, res.Stride, 1, box)
+		boxBlur(buf, res.Pix, h, w, 1, res.Stride, box)
+	}
+	return res
+}
+
+// boxBlur blurs every line of src into dst, lines are n pixels long, pixels of a line are step
+// apart and lines are stride apart.
+func boxBlur(src, dst []uint8, n, lines, stride, step, radius int) {
+	for l := 0; l < lines; l++ {
+		base := l * stride
+		sum, count := 0, 0
+		for i := 0; i < radius && i < n; i++ {
+			sum += int(src[base+i*step])
+			count++
+		}
+		for i := 0; i < n; i++ {
+			if j := i + radius; j < n {
+				sum += int(src[base+j*step])
+				count++
+			}
+			if j := i - radius - 1; j >= 0 {
+				sum -= int(src[base+j*step])
+				count--
+			}
+			dst[base+i*step] = uint8((sum + count/2) / count)
+		}
+	}
+}
+
+func delta(a, b uint32) uint8 {
+	if a > b {
+		return uint8((a - b) >> 8)
+	}
+	return uint8((b - a) >> 8)
+}
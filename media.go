@@ -0,0 +1,119 @@
+package sdcli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/gif"
+
+	// WebP outputs, such as the ones of AnimateDiff or of the samples format setting.
+	_ "golang.org/x/image/webp"
+)
+
+// Formats of the outputs found in the images of generation responses.
+const (
+	FormatPNG     = "png"
+	FormatJPEG    = "jpeg"
+	FormatWEBP    = "webp"
+	FormatGIF     = "gif"
+	FormatMP4     = "mp4"
+	FormatWEBM    = "webm"
+	FormatUnknown = ""
+)
+
+// Video is an animated output such as the GIF, animated WebP, APNG or MP4 produced by AnimateDiff.
+type Video struct {
+	Format string
+	Data   []byte
+}
+
+// DetectFormat sniffs the format of output data from its magic bytes.
+func DetectFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return FormatJPEG
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return FormatWEBP
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF
+	case len(data) >= 8 && bytes.Equal(data[4:8], []byte("ftyp")):
+		return FormatMP4
+	case bytes.HasPrefix(data, []byte("\x1a\x45\xdf\xa3")):
+		return FormatWEBM
+	}
+
+	return FormatUnknown
+}
+
+// decodeImages decodes the base64 outputs of a generation response. Animations and videos are
+// returned as is in videos, the still images in raws and, unless parse is false, parsed in imgs
+// at the same index. Still images that fail to decode are left out of both when parsing.
+func decodeImages(images []string, parse bool) (imgs []image.Image, raws [][]byte, videos []*Video) {
+	imgs = make([]image.Image, 0, len(images))
+	raws = make([][]byte, 0, len(images))
+
+	for _, raw := range images {
+		data, err := decodeBase64(raw)
+		if err != nil {
+			// Should not happen.
+			continue
+		}
+
+		if format := DetectFormat(data); animated(format, data) {
+			videos = append(videos, &Video{Format: format, Data: data})
+			continue
+		}
+
+		if !parse {
+			raws = append(raws, data)
+			continue
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			// Should not happen.
+			continue
+		}
+
+		imgs = append(imgs, img)
+		raws = append(raws, data)
+	}
+
+	return imgs, raws, videos
+}
+
+// animated reports whether data of format is a video or has several frames.
+func animated(format string, data []byte) bool {
+	switch format {
+	case FormatMP4, FormatWEBM:
+		return true
+	case FormatGIF:
+		cfg, err := gif.DecodeAll(bytes.NewReader(data))
+		return err == nil && len(cfg.Image) > 1
+	case FormatWEBP:
+		// The animation flag of the extended format header.
+		return len(data) >= 21 && bytes.Equal(data[12:16], []byte("VP8X")) && data[20]&0x02 != 0
+	case FormatPNG:
+		return isAPNG(data)
+	}
+	return false
+}
+
+// isAPNG reports whether a PNG has an animation control chunk, which comes before the image data.
+func isAPNG(data []byte) bool {
+	for i := 8; i+8 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		switch string(data[i+4 : i+8]) {
+		case "acTL":
+			return true
+		case "IDAT", "IEND":
+			return false
+		}
+		if n < 0 || n > len(data) {
+			return false
+		}
+		i += 12 + n
+	}
+	return false
+}
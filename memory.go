@@ -0,0 +1,96 @@
+package sdcli
+
+import (
+	"context"
+	"time"
+)
+
+// VRAMUsage is the used ratio of the GPU memory, 0 without CUDA.
+func (m *MemoryResponse) VRAMUsage() float64 {
+	if m.Cuda.System.Total <= 0 {
+		return 0
+	}
+	return float64(m.Cuda.System.Used) / float64(m.Cuda.System.Total)
+}
+
+// RAMUsage is the used ratio of the system memory.
+func (m *MemoryResponse) RAMUsage() float64 {
+	if m.RAM.Total <= 0 {
+		return 0
+	}
+	return float64(m.RAM.Used) / float64(m.RAM.Total)
+}
+
+// MemorySample is a memory reading of WatchMemory, Err is set when the reading failed.
+type MemorySample struct {
+	Time   time.Time
+	Memory *MemoryResponse
+	Err    error
+}
+
+// MemoryThreshold calls OnAbove when the VRAM usage reaches Ratio and OnBelow when it drops
+// back under it, e.g. to pause dispatching jobs before running out of memory.
+type MemoryThreshold struct {
+	Ratio   float64
+	OnAbove func(MemorySample)
+	OnBelow func(MemorySample)
+}
+
+// DefaultMemoryInterval is the reading interval of WatchMemory when given none.
+const DefaultMemoryInterval = time.Second
+
+// WatchMemory reads the server memory every interval (DefaultMemoryInterval if not positive)
+// until ctx is done, then closes the returned channel. Samples are dropped when the receiver
+// lags behind, thresholds are checked for every successful reading in the watcher goroutine.
+func (c *Client) WatchMemory(ctx context.Context, interval time.Duration, thresholds ...MemoryThreshold) <-chan MemorySample {
+	if interval <= 0 {
+		interval = DefaultMemoryInterval
+	}
+	ch := make(chan MemorySample, 1)
+	above := make([]bool, len(thresholds))
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			mem, err := c.GetMemory(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			sample := MemorySample{Time: time.Now(), Memory: mem, Err: err}
+
+			if err == nil {
+				usage := mem.VRAMUsage()
+				for i, t := range thresholds {
+					switch {
+					case usage >= t.Ratio && !above[i]:
+						above[i] = true
+						if t.OnAbove != nil {
+							t.OnAbove(sample)
+						}
+					case usage < t.Ratio && above[i]:
+						above[i] = false
+						if t.OnBelow != nil {
+							t.OnBelow(sample)
+						}
+					}
+				}
+			}
+
+			select {
+			case ch <- sample:
+			default:
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return ch
+}
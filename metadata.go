@@ -0,0 +1,216 @@
+package sdcli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"unicode/utf8"
+)
+
+// ParametersKey is the PNG text chunk keyword WebUI stores the infotext under, read back by its PNG Info tab.
+const ParametersKey = "parameters"
+
+// GenerationInfo is the decoded Info of generation responses.
+type GenerationInfo struct {
+	Prompt            string   `json:"prompt"`
+	AllPrompts        []string `json:"all_prompts"`
+	NegativePrompt    string   `json:"negative_prompt"`
+	AllNegativePrompt []string `json:"all_negative_prompts"`
+	Seed              int64    `json:"seed"`
+	AllSeeds          []int64  `json:"all_seeds"`
+	Subseed           int64    `json:"subseed"`
+	AllSubseeds       []int64  `json:"all_subseeds"`
+	SubseedStrength   float32  `json:"subseed_strength"`
+	Width             int      `json:"width"`
+	Height            int      `json:"height"`
+	SamplerName       string   `json:"sampler_name"`
+	CfgScale          float32  `json:"cfg_scale"`
+	Steps             int      `json:"steps"`
+	BatchSize         int      `json:"batch_size"`
+	SDModelName       string   `json:"sd_model_name"`
+	SDModelHash       string   `json:"sd_model_hash"`
+	SDVAEName         string   `json:"sd_vae_name"`
+	DenoisingStrength float32  `json:"denoising_strength"`
+	Styles            []string `json:"styles"`
+	// IndexOfFirstImage is 1 when the first image is the grid.
+	IndexOfFirstImage int `json:"index_of_first_image"`
+	// Infotexts are the parameters of every image, as shown by WebUI, in the order of the images.
+	Infotexts []string `json:"infotexts"`
+}
+
+// ParseInfo decodes the Info of a generation response.
+func ParseInfo(info string) (*GenerationInfo, error) {
+	res := new(GenerationInfo)
+	if err := json.Unmarshal([]byte(info), res); err != nil {
+		return nil, wrapError(err, nil, "failed to decode generation info")
+	}
+	return res, nil
+}
+
+// Infotext returns the infotext of image i, or an empty string.
+func (g *GenerationInfo) Infotext(i int) string {
+	if i < 0 || i >= len(g.Infotexts) {
+		return ""
+	}
+	return g.Infotexts[i]
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// EncodePNG encodes img as PNG with the infotext in its parameters chunk.
+func EncodePNG(w io.Writer, img image.Image, parameters string) error {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return err
+	}
+	data, err := SetPNGParameters(buf.Bytes(), parameters)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// SetPNGParameters stores the infotext in the parameters text chunk of PNG data like WebUI does,
+// replacing an existing one. Latin-1 text is stored as tEXt and other text as iTXt.
+func SetPNGParameters(data []byte, parameters string) ([]byte, error) {
+	chunks, err := pngChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)+len(parameters)+32))
+	out.Write(pngSignature)
+	written := false
+	for _, c := range chunks {
+		if isParametersChunk(c.typ, c.data) {
+			continue
+		}
+		if !written && c.typ == "IDAT" {
+			writeParametersChunk(out, parameters)
+			written = true
+		}
+		writePNGChunk(out, c.typ, c.data)
+	}
+	if !written {
+		return nil, fmt.Errorf("invalid png: missing IDAT chunk")
+	}
+
+	return out.Bytes(), nil
+}
+
+// PNGParameters returns the infotext stored in PNG data.
+func PNGParameters(data []byte) (string, bool) {
+	chunks, err := pngChunks(data)
+	if err != nil {
+		return "", false
+	}
+
+	for _, c := range chunks {
+		if !isParametersChunk(c.typ, c.data) {
+			continue
+		}
+		text := c.data[len(ParametersKey)+1:]
+		switch c.typ {
+		case "tEXt":
+			return latin1ToUTF8(text), true
+		case "iTXt":
+			// Compression flag and method, then the language tag and translated keyword.
+			if len(text) < 2 || text[0] != 0 {
+				return "", false
+			}
+			rest := text[2:]
+			for i := 0; i < 2; i++ {
+				idx := bytes.IndexByte(rest, 0)
+				if idx < 0 {
+					return "", false
+				}
+				rest = rest[idx+1:]
+			}
+			return string(rest), true
+		}
+	}
+
+	return "", false
+}
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+func pngChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("invalid png: bad signature")
+	}
+
+	var chunks []pngChunk
+	rest := data[len(pngSignature):]
+	for len(rest) >= 12 {
+		n := binary.BigEndian.Uint32(rest[:4])
+		if uint64(n)+12 > uint64(len(rest)) {
+			return nil, fmt.Errorf("invalid png: truncated chunk")
+		}
+		chunks = append(chunks, pngChunk{typ: string(rest[4:8]), data: rest[8 : 8+n]})
+		rest = rest[12+n:]
+	}
+
+	return chunks, nil
+}
+
+func isParametersChunk(typ string, data []byte) bool {
+	if typ != "tEXt" && typ != "iTXt" && typ != "zTXt" {
+		return false
+	}
+	return bytes.HasPrefix(data, []byte(ParametersKey+"\x00"))
+}
+
+func writeParametersChunk(w *bytes.Buffer, parameters string) {
+	if latin1, ok := utf8ToLatin1(parameters); ok {
+		writePNGChunk(w, "tEXt", append([]byte(ParametersKey+"\x00"), latin1...))
+		return
+	}
+
+	// Uncompressed, no language tag nor translated keyword.
+	data := append([]byte(ParametersKey+"\x00"), 0, 0, 0, 0)
+	writePNGChunk(w, "iTXt", append(data, parameters...))
+}
+
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(data)))
+	copy(hdr[4:], typ)
+	w.Write(hdr[:])
+	w.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(hdr[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
+
+func utf8ToLatin1(s string) ([]byte, bool) {
+	res := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return nil, false
+		}
+		res = append(res, byte(r))
+	}
+	return res, true
+}
+
+func latin1ToUTF8(b []byte) string {
+	res := make([]byte, 0, len(b))
+	for _, c := range b {
+		res = utf8.AppendRune(res, rune(c))
+	}
+	return string(res)
+}
@@ -0,0 +1,149 @@
+package sdcli
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// DefaultSwitchTimeout bounds SwitchModel and SwitchVAE when ctx has no deadline, loading a large checkpoint
+// from a slow disk takes minutes.
+const DefaultSwitchTimeout = 5 * time.Minute
+
+// switchPollInterval is how often the options are read while waiting for a switch.
+const switchPollInterval = 500 * time.Millisecond
+
+// FindModel returns the checkpoint matching a title, model name, short hash or SHA256, or nil.
+func FindModel(models []*ModelsResponse, name string) *ModelsResponse {
+	for _, m := range models {
+		if m.Title == name || m.ModelName == name ||
+			(len(m.Hash) != 0 && m.Hash == name) || (len(m.Sha256) != 0 && strings.EqualFold(m.Sha256, name)) {
+			return m
+		}
+	}
+	return nil
+}
+
+// SwitchModel loads a checkpoint by title, model name or hash and waits until the server
+// reports it as the current checkpoint, so the next generation does not race the load. It
+// returns the checkpoint, and fails if it is unknown or not loaded before ctx is done, within
+// DefaultSwitchTimeout if ctx has no deadline.
+func (c *Client) SwitchModel(ctx context.Context, titleOrHash string) (*ModelsResponse, error) {
+	models, err := c.GetModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := FindModel(models, titleOrHash)
+	if m == nil {
+		names := make([]string, len(models))
+		for i, m := range models {
+			names[i] = m.Title
+		}
+		return nil, &ValidationError{
+			Field: OptionModel,
+			Msg:   "unknown checkpoint " + titleOrHash + ", available: " + strings.Join(names, ", "),
+		}
+	}
+
+	ctx, cancel := switchContext(ctx)
+	defer cancel()
+
+	if err := c.SetOptions(ctx, map[string]any{OptionModel: m.Title}); err != nil {
+		return nil, err
+	}
+	err = c.waitOptions(ctx, func(o *OptionsResponse) bool {
+		if hash := Deref(o.SdCheckpointHash); len(hash) != 0 && len(m.Sha256) != 0 {
+			return strings.EqualFold(hash, m.Sha256)
+		}
+		return Deref(o.SdModelCheckpoint) == m.Title
+	})
+	if err != nil {
+		return nil, wrapError(err, nil, "checkpoint %s did not load", m.Title)
+	}
+	c.InvalidateCapabilities()
+
+	return m, nil
+}
+
+// Builtin choices of the sd_vae option besides the VAE files: Automatic uses the VAE next to the
+// checkpoint if any, None the VAE baked into the checkpoint.
+const (
+	VAEAutomatic = "Automatic"
+	VAENone      = "None"
+)
+
+// FindVAE returns the VAE matching a model name or filename, or nil.
+func FindVAE(vaes []*VAEsResponse, name string) *VAEsResponse {
+	for _, v := range vaes {
+		if v.ModelName == name || v.Filename == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// SwitchVAE sets the VAE by model name or filename, or VAEAutomatic and VAENone, and waits
+// until the server reports it, like SwitchModel. The returned VAE is nil for the builtin choices.
+func (c *Client) SwitchVAE(ctx context.Context, name string) (*VAEsResponse, error) {
+	var v *VAEsResponse
+	option := name
+	if name != VAEAutomatic && name != VAENone {
+		vaes, err := c.GetVAEs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if v = FindVAE(vaes, name); v == nil {
+			names := []string{VAEAutomatic, VAENone}
+			for _, v := range vaes {
+				names = append(names, v.ModelName)
+			}
+			return nil, &ValidationError{
+				Field: OptionVAE,
+				Msg:   "unknown VAE " + name + ", available: " + strings.Join(names, ", "),
+			}
+		}
+		option = v.ModelName
+	}
+
+	ctx, cancel := switchContext(ctx)
+	defer cancel()
+
+	if err := c.SetOptions(ctx, map[string]any{OptionVAE: option}); err != nil {
+		return nil, err
+	}
+	err := c.waitOptions(ctx, func(o *OptionsResponse) bool {
+		return Deref(o.SdVae) == option
+	})
+	if err != nil {
+		return nil, wrapError(err, nil, "VAE %s did not load", option)
+	}
+
+	return v, nil
+}
+
+func switchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, DefaultSwitchTimeout)
+}
+
+// waitOptions reads the options until done reports true or ctx is done. Reading errors are
+// retried as the server may stall while loading models.
+func (c *Client) waitOptions(ctx context.Context, done func(o *OptionsResponse) bool) error {
+	ticker := time.NewTicker(switchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		c.InvalidateCache("/options")
+		if o, err := c.GetOptions(ctx); err == nil && done(o) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package sdcli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"strconv"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// NSFWClassifier scores how likely an image is not safe for work, from 0 to 1. Implement it
+// with a local model or use HTTPClassifier for a remote service.
+type NSFWClassifier interface {
+	Classify(ctx context.Context, out *Output) (float64, error)
+}
+
+// NSFWClassifierFunc adapts a function to NSFWClassifier.
+type NSFWClassifierFunc func(ctx context.Context, out *Output) (float64, error)
+
+func (f NSFWClassifierFunc) Classify(ctx context.Context, out *Output) (float64, error) {
+	return f(ctx, out)
+}
+
+// HTTPClassifier posts the encoded images to URL, which answers a JSON object with the score in
+// a "score" field, e.g. a small service wrapping an open source NSFW model.
+type HTTPClassifier struct {
+	URL    string
+	Client *http.Client
+	// Header is added to the requests, e.g. for authentication.
+	Header http.Header
+}
+
+func (h *HTTPClassifier) Classify(ctx context.Context, out *Output) (float64, error) {
+	data, err := out.Data()
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range h.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "image/"+out.Format)
+
+	cli := h.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to classify image: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read classifier response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("classifier answered status %d: %s", resp.StatusCode, body)
+	}
+
+	var res struct {
+		Score *float64 `json:"score"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil || res.Score == nil {
+		return 0, fmt.Errorf("invalid classifier response: %s", body)
+	}
+	return *res.Score, nil
+}
+
+// NSFWAction is what NSFWFilter does with the images scoring at least the threshold.
+type NSFWAction int
+
+const (
+	// NSFWTag only tags the image.
+	NSFWTag NSFWAction = iota
+	// NSFWBlur tags the image and replaces it with a heavily blurred version.
+	NSFWBlur
+	// NSFWDrop removes the image from the response.
+	NSFWDrop
+)
+
+// Tags set by NSFWFilter on every image.
+const (
+	TagNSFW      = "nsfw"
+	TagNSFWScore = "nsfw_score"
+)
+
+// DefaultNSFWThreshold is the score from which NSFWFilter acts when the policy leaves it 0.
+const DefaultNSFWThreshold = 0.5
+
+// NSFWPolicy configures NSFWFilter.
+type NSFWPolicy struct {
+	Threshold float64
+	Action    NSFWAction
+}
+
+// NSFWFilter is the post-processing stage classifying every image, tagging it with TagNSFW
+// ("true" or "false") and TagNSFWScore and applying the action to the flagged ones.
+func NSFWFilter(classifier NSFWClassifier, policy NSFWPolicy) ImageProcessor {
+	if policy.Threshold == 0 {
+		policy.Threshold = DefaultNSFWThreshold
+	}
+
+	return ImageProcessorFunc(func(ctx context.Context, out *Output) error {
+		score, err := classifier.Classify(ctx, out)
+		if err != nil {
+			return err
+		}
+		flagged := score >= policy.Threshold
+		out.Tag(TagNSFW, strconv.FormatBool(flagged))
+		out.Tag(TagNSFWScore, strconv.FormatFloat(score, 'f', 4, 64))
+		if !flagged {
+			return nil
+		}
+
+		switch policy.Action {
+		case NSFWBlur:
+			img, err := out.Image()
+			if err != nil {
+				return err
+			}
+			out.SetImage(blur(img))
+		case NSFWDrop:
+			out.Drop = true
+		}
+		return nil
+	})
+}
+
+// blurSize is the longest side images are shrunk to before being scaled back up by blur.
+const blurSize = 16
+
+// blur shrinks img and scales it back up, leaving only the colors of large areas.
+func blur(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := blurSize, blurSize
+	if b.Dx() > b.Dy() {
+		h = b.Dy()*blurSize/b.Dx() + 1
+	} else {
+		w = b.Dx()*blurSize/b.Dy() + 1
+	}
+	small := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.ApproxBiLinear.Scale(small, small.Bounds(), img, b, xdraw.Src, nil)
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	xdraw.BiLinear.Scale(dst, dst.Bounds(), small, small.Bounds(), xdraw.Src, nil)
+	return dst
+}
@@ -0,0 +1,139 @@
+package sdcli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// oomMessages are the lowercased fragments of the CUDA, MPS and DirectML out of memory errors.
+var oomMessages = []string{"out of memory", "outofmemoryerror", "not enough memory"}
+
+// IsOOM reports whether err is an out of memory error answered by the server.
+func IsOOM(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) || e.Response == nil {
+		return false
+	}
+	msg := strings.ToLower(e.Error())
+	for _, m := range oomMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// OOMPolicy is how WithOOMRecovery degrades a generation that ran out of memory. Every retry
+// applies the first applicable step: disabling the hires fix, halving the batch size (doubling
+// the batch count to keep the number of images close) and scaling the size down.
+type OOMPolicy struct {
+	// Retries is the maximum number of degraded retries.
+	Retries int
+	// DisableHR turns the hires fix of txt2img off.
+	DisableHR bool
+	// ReduceBatch halves the batch size.
+	ReduceBatch bool
+	// Scale multiplies the width and height, rounded down to a multiple of 8, 0 keeps the size.
+	Scale float64
+	// MinSize is the smallest width or height Scale goes to.
+	MinSize int
+}
+
+// DefaultOOMPolicy tries every step, down to 512 pixels.
+var DefaultOOMPolicy = OOMPolicy{
+	Retries:     3,
+	DisableHR:   true,
+	ReduceBatch: true,
+	Scale:       0.75,
+	MinSize:     512,
+}
+
+// WithOOMRecovery retries txt2img and img2img generations failing with an out of memory error
+// with degraded parameters, the responses list the degradations in Degraded.
+func WithOOMRecovery(policy OOMPolicy) Option {
+	return func(c *Client) {
+		c.oomPolicy = &policy
+	}
+}
+
+// Degradation is a parameter changed by WithOOMRecovery.
+type Degradation struct {
+	Param string `json:"param"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+func (d Degradation) String() string {
+	return fmt.Sprintf("%s %v -> %v", d.Param, d.From, d.To)
+}
+
+// degradable are the fields of an option that OOMPolicy changes, enableHR is nil for img2img.
+type degradable struct {
+	enableHR         *bool
+	batchSize, nIter *int
+	width, height    *int
+}
+
+// degrade applies the next step of the policy, returning nil when none is left.
+func (p *OOMPolicy) degrade(f degradable) []Degradation {
+	switch {
+	case p.DisableHR && f.enableHR != nil && *f.enableHR:
+		*f.enableHR = false
+		return []Degradation{{Param: "enable_hr", From: true, To: false}}
+	case p.ReduceBatch && *f.batchSize > 1:
+		nIter := *f.nIter
+		if nIter <= 0 {
+			nIter = 1
+		}
+		d := []Degradation{
+			{Param: "batch_size", From: *f.batchSize, To: *f.batchSize / 2},
+			{Param: "n_iter", From: nIter, To: nIter * 2},
+		}
+		*f.batchSize, *f.nIter = *f.batchSize/2, nIter*2
+		return d
+	}
+
+	// Img2img sizes of 0 follow the init image and cannot be scaled here.
+	if p.Scale <= 0 || p.Scale >= 1 || *f.width <= 0 || *f.height <= 0 {
+		return nil
+	}
+	width, height := scaleSize(*f.width, p.Scale, p.MinSize), scaleSize(*f.height, p.Scale, p.MinSize)
+	if width == *f.width && height == *f.height {
+		return nil
+	}
+	d := []Degradation{
+		{Param: "width", From: *f.width, To: width},
+		{Param: "height", From: *f.height, To: height},
+	}
+	*f.width, *f.height = width, height
+	return d
+}
+
+func scaleSize(size int, scale float64, minSize int) int {
+	if size <= minSize {
+		return size
+	}
+	scaled := int(float64(size)*scale) / 8 * 8
+	if scaled < minSize {
+		scaled = minSize
+	}
+	return scaled
+}
+
+// generateOOM is generate with the retries of WithOOMRecovery, f points into body.
+func (c *Client) generateOOM(ctx context.Context, path string, body any, f degradable, result any) ([]Degradation, error) {
+	var degraded []Degradation
+	for attempt := 0; ; attempt++ {
+		err := c.generate(ctx, path, body, result)
+		if err == nil || c.oomPolicy == nil || attempt >= c.oomPolicy.Retries || !IsOOM(err) || ctx.Err() != nil {
+			return degraded, err
+		}
+		d := c.oomPolicy.degrade(f)
+		if d == nil {
+			return degraded, err
+		}
+		degraded = append(degraded, d...)
+	}
+}
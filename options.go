@@ -0,0 +1,121 @@
+package sdcli
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Ptr returns a pointer to v, to set the fields of OptionsResponse.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Deref returns the value p points to, or the zero value if p is nil.
+func Deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
+// Map returns the set options by JSON name, Extra included, e.g. to pass them to SetOptions.
+func (o *OptionsResponse) Map() (map[string]any, error) {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode options: %w", err)
+	}
+	res := map[string]any{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("failed to encode options: %w", err)
+	}
+	return res, nil
+}
+
+// Option names of the Overrides setters.
+const (
+	OptionModel                    = "sd_model_checkpoint"
+	OptionVAE                      = "sd_vae"
+	OptionCLIPSkip                 = "CLIP_stop_at_last_layers"
+	OptionTokenMergingRatio        = "token_merging_ratio"
+	OptionTokenMergingRatioImg2Img = "token_merging_ratio_img2img"
+	OptionTokenMergingRatioHR      = "token_merging_ratio_hr"
+	OptionFP8Storage               = "fp8_storage"
+	OptionCacheFP16Weight          = "cache_fp16_weight"
+)
+
+// MaxTokenMergingRatio is the largest token merging ratio of the WebUI settings.
+const MaxTokenMergingRatio = 0.9
+
+// Values of the fp8_storage option of WebUI 1.8, storing the weights in FP8 to save VRAM.
+const (
+	FP8Disable    = "Disable"
+	FP8EnableSDXL = "Enable for SDXL"
+	FP8Enable     = "Enable"
+)
+
+// Overrides are server options applied to a single generation, by JSON name. Only the given
+// options are sent, unlike a whole OptionsResponse. The setters need a non nil map and return
+// it for chaining:
+//
+//	opt.OverrideSettings = sdcli.Overrides{}.SetModel("sdxl.safetensors").SetCLIPSkip(2)
+type Overrides map[string]any
+
+// Set sets an option by JSON name.
+func (o Overrides) Set(name string, value any) Overrides {
+	o[name] = value
+	return o
+}
+
+// SetModel sets the checkpoint, by title, name or hash.
+func (o Overrides) SetModel(name string) Overrides {
+	return o.Set(OptionModel, name)
+}
+
+// SetVAE sets the VAE, by name or VAEAutomatic and VAENone.
+func (o Overrides) SetVAE(name string) Overrides {
+	return o.Set(OptionVAE, name)
+}
+
+// SetCLIPSkip sets how many of the last CLIP layers are skipped, 1 skipping none.
+func (o Overrides) SetCLIPSkip(n int) Overrides {
+	return o.Set(OptionCLIPSkip, n)
+}
+
+// SetTokenMerging sets the ratio of tokens merged by ToMe, trading details for speed, 0
+// disables it and WebUI allows up to 0.9. Img2img uses it unless the img2img ratio is set.
+func (o Overrides) SetTokenMerging(ratio float32) Overrides {
+	return o.Set(OptionTokenMergingRatio, ratio)
+}
+
+// SetTokenMergingImg2Img sets the ratio of tokens merged in img2img, see SetTokenMerging.
+func (o Overrides) SetTokenMergingImg2Img(ratio float32) Overrides {
+	return o.Set(OptionTokenMergingRatioImg2Img, ratio)
+}
+
+// SetTokenMergingHR sets the ratio of tokens merged in the hires pass, see SetTokenMerging.
+func (o Overrides) SetTokenMergingHR(ratio float32) Overrides {
+	return o.Set(OptionTokenMergingRatioHR, ratio)
+}
+
+// SetFP8Storage sets whether the weights are stored in FP8, see FP8Enable.
+func (o Overrides) SetFP8Storage(mode string) Overrides {
+	return o.Set(OptionFP8Storage, mode)
+}
+
+// SetCacheFP16Weight sets whether FP16 weights are kept to restore them after FP8 storage.
+func (o Overrides) SetCacheFP16Weight(cache bool) Overrides {
+	return o.Set(OptionCacheFP16Weight, cache)
+}
+
+// Model returns the overridden checkpoint, or an empty string.
+func (o Overrides) Model() string {
+	s, _ := o[OptionModel].(string)
+	return s
+}
+
+// VAE returns the overridden VAE, or an empty string.
+func (o Overrides) VAE() string {
+	s, _ := o[OptionVAE].(string)
+	return s
+}
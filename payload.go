@@ -0,0 +1,110 @@
+package sdcli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// DefaultMaxPayloadBytes is the default client_max_body_size of nginx, the usual proxy in front
+// of a WebUI.
+const DefaultMaxPayloadBytes = 1 << 20
+
+// PayloadLimit configures WithPayloadLimit.
+type PayloadLimit struct {
+	// MaxBytes is the size of the encoded request bodies above which requests are flagged,
+	// DefaultMaxPayloadBytes if 0.
+	MaxBytes int64
+	// Warn is called with the oversized requests, which are then sent. Without it they fail
+	// with the error instead.
+	Warn func(ctx context.Context, err *PayloadTooLargeError)
+}
+
+// WithPayloadLimit checks the size of the encoded request bodies before sending them, since
+// proxies answer oversized bodies such as large init images with a 413 or drop them, which
+// often surfaces as a timeout. Set it to the body size limit of the proxy.
+func WithPayloadLimit(l PayloadLimit) Option {
+	return func(c *Client) {
+		if l.MaxBytes <= 0 {
+			l.MaxBytes = DefaultMaxPayloadBytes
+		}
+		c.payloadLimit = &l
+	}
+}
+
+// PayloadTooLargeError is a request body larger than the PayloadLimit.
+type PayloadTooLargeError struct {
+	Path  string
+	Size  int64
+	Limit int64
+	// Largest is the JSON path of the largest string of the body, such as init_images[0], and
+	// LargestSize its length. Empty for the bodies that are not JSON.
+	Largest     string
+	LargestSize int64
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	msg := fmt.Sprintf("body of %s is %d bytes, over the limit of %d bytes", e.Path, e.Size, e.Limit)
+	if len(e.Largest) != 0 {
+		msg += fmt.Sprintf(", largest field %s is %d bytes", e.Largest, e.LargestSize)
+	}
+	return msg
+}
+
+// PayloadSize returns the size of body encoded as a request, e.g. an Img2ImgOption, to check
+// it against a proxy limit before sending it.
+func PayloadSize(body any) (int64, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode body: %w", err)
+	}
+	return int64(len(data)), nil
+}
+
+// checkPayload applies the PayloadLimit to an encoded body.
+func (c *Client) checkPayload(ctx context.Context, path string, payload []byte) error {
+	if c.payloadLimit == nil || int64(len(payload)) <= c.payloadLimit.MaxBytes {
+		return nil
+	}
+
+	e := &PayloadTooLargeError{Path: path, Size: int64(len(payload)), Limit: c.payloadLimit.MaxBytes}
+	var v any
+	if json.Unmarshal(payload, &v) == nil {
+		e.Largest, e.LargestSize = largestString("", v)
+	}
+	if c.payloadLimit.Warn != nil {
+		c.payloadLimit.Warn(ctx, e)
+		return nil
+	}
+	return e
+}
+
+// largestString returns the path and length of the largest string of a decoded JSON value.
+func largestString(path string, v any) (string, int64) {
+	var (
+		largest string
+		size    int64
+	)
+	switch v := v.(type) {
+	case string:
+		return path, int64(len(v))
+	case map[string]any:
+		for key, e := range v {
+			p := key
+			if len(path) != 0 {
+				p = path + "." + key
+			}
+			if lp, ls := largestString(p, e); ls > size {
+				largest, size = lp, ls
+			}
+		}
+	case []any:
+		for i, e := range v {
+			if lp, ls := largestString(path+"["+strconv.Itoa(i)+"]", e); ls > size {
+				largest, size = lp, ls
+			}
+		}
+	}
+	return largest, size
+}
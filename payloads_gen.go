@@ -0,0 +1,121 @@
+// Code generated by openapigen; DO NOT EDIT.
+
+package sdcli
+
+type Txt2ImageOption struct {
+	Prompt                            string          `json:"prompt,omitempty"`
+	NegativePrompt                    string          `json:"negative_prompt,omitempty"`
+	Steps                             int             `json:"steps,omitempty"`
+	CfgScale                          float32         `json:"cfg_scale,omitempty"`
+	Width                             int             `json:"width,omitempty"`
+	Height                            int             `json:"height,omitempty"`
+	SamplerIndex                      string          `json:"sampler_index,omitempty"`
+	OverrideSettings                  Overrides       `json:"override_settings,omitempty"`
+	EnableHR                          bool            `json:"enable_hr,omitempty"`
+	DenoisingStrength                 float32         `json:"denoising_strength,omitempty"`
+	FirstPhaseWidth                   int             `json:"firstphase_width,omitempty"`
+	FirstPhaseHeight                  int             `json:"firstphase_height,omitempty"`
+	HRScale                           float32         `json:"hr_scale,omitempty"`
+	HrUpscaler                        string          `json:"hr_upscaler,omitempty"`
+	HrSecondPassSteps                 int             `json:"hr_second_pass_steps,omitempty"`
+	HrResizeX                         int             `json:"hr_resize_x,omitempty"`
+	HrResizeY                         int             `json:"hr_resize_y,omitempty"`
+	Styles                            []string        `json:"styles,omitempty"`
+	Seed                              int             `json:"seed,omitempty"`
+	Subseed                           int             `json:"subseed,omitempty"`
+	SubseedStrength                   float32         `json:"subseed_strength,omitempty"`
+	SeedResizeFromH                   int             `json:"seed_resize_from_h,omitempty"`
+	SeedResizeFromW                   int             `json:"seed_resize_from_w,omitempty"`
+	SamplerName                       string          `json:"sampler_name,omitempty"`
+	Scheduler                         string          `json:"scheduler,omitempty"`
+	BatchSize                         int             `json:"batch_size,omitempty"`
+	NIter                             int             `json:"n_iter,omitempty"`
+	RestoreFaces                      bool            `json:"restore_faces,omitempty"`
+	Tiling                            bool            `json:"tiling,omitempty"`
+	Eta                               float32         `json:"eta,omitempty"`
+	SChurn                            float32         `json:"s_churn,omitempty"`
+	STmax                             float32         `json:"s_tmax,omitempty"`
+	STmin                             float32         `json:"s_tmin,omitempty"`
+	SNoise                            float32         `json:"s_noise,omitempty"`
+	OverrideSettingsRestoreAfterwards bool            `json:"override_settings_restore_afterwards,omitempty"`
+	ScriptArgs                        []interface{}   `json:"script_args,omitempty"`
+	ScriptName                        string          `json:"script_name,omitempty"`
+	AlwaysonScripts                   AlwaysonScripts `json:"alwayson_scripts,omitempty"`
+	SMinUncond                        float32         `json:"s_min_uncond,omitempty"`
+}
+
+type Img2ImgOption struct {
+	InitImages                        []string        `json:"init_images,omitempty"`
+	ResizeMode                        ResizeMode      `json:"resize_mode,omitempty"`
+	DenoisingStrength                 float32         `json:"denoising_strength,omitempty"`
+	ImageCfgScale                     float32         `json:"image_cfg_scale,omitempty"`
+	Mask                              string          `json:"mask,omitempty"`
+	MaskBlur                          int             `json:"mask_blur,omitempty"`
+	InpaintingFill                    InpaintingFill  `json:"inpainting_fill,omitempty"`
+	InpaintFullRes                    bool            `json:"inpaint_full_res,omitempty"`
+	InpaintFullResPadding             int             `json:"inpaint_full_res_padding,omitempty"`
+	InpaintingMaskInvert              MaskInvert      `json:"inpainting_mask_invert,omitempty"`
+	InitialNoiseMultiplier            float32         `json:"initial_noise_multiplier,omitempty"`
+	Prompt                            string          `json:"prompt,omitempty"`
+	Styles                            []string        `json:"styles,omitempty"`
+	Seed                              int             `json:"seed,omitempty"`
+	Subseed                           int             `json:"subseed,omitempty"`
+	SubseedStrength                   float32         `json:"subseed_strength,omitempty"`
+	SeedResizeFromH                   int             `json:"seed_resize_from_h,omitempty"`
+	SeedResizeFromW                   int             `json:"seed_resize_from_w,omitempty"`
+	SamplerName                       string          `json:"sampler_name,omitempty"`
+	Scheduler                         string          `json:"scheduler,omitempty"`
+	BatchSize                         int             `json:"batch_size,omitempty"`
+	NIter                             int             `json:"n_iter,omitempty"`
+	Steps                             int             `json:"steps,omitempty"`
+	CfgScale                          float32         `json:"cfg_scale,omitempty"`
+	Width                             int             `json:"width,omitempty"`
+	Height                            int             `json:"height,omitempty"`
+	RestoreFaces                      bool            `json:"restore_faces,omitempty"`
+	Tiling                            bool            `json:"tiling,omitempty"`
+	NegativePrompt                    string          `json:"negative_prompt,omitempty"`
+	Eta                               float32         `json:"eta,omitempty"`
+	SChurn                            float32         `json:"s_churn,omitempty"`
+	STmax                             float32         `json:"s_tmax,omitempty"`
+	STmin                             float32         `json:"s_tmin,omitempty"`
+	SNoise                            float32         `json:"s_noise,omitempty"`
+	OverrideSettings                  Overrides       `json:"override_settings,omitempty"`
+	OverrideSettingsRestoreAfterwards bool            `json:"override_settings_restore_afterwards,omitempty"`
+	ScriptArgs                        []interface{}   `json:"script_args,omitempty"`
+	SamplerIndex                      string          `json:"sampler_index,omitempty"`
+	IncludeInitImages                 bool            `json:"include_init_images,omitempty"`
+	ScriptName                        string          `json:"script_name,omitempty"`
+	AlwaysonScripts                   AlwaysonScripts `json:"alwayson_scripts,omitempty"`
+	SMinUncond                        float32         `json:"s_min_uncond,omitempty"`
+}
+
+type ExtraSingleImgOption struct {
+	// Sets the resize mode: ExtrasResizeBy to upscale by upscaling_resize amount, ExtrasResizeTo to upscale up to upscaling_resize_h x upscaling_resize_w.
+	ResizeMode ExtrasResizeMode `json:"resize_mode,omitempty"`
+	// Should the backend return the generated image?
+	ShowExtrasResults bool `json:"show_extras_results,omitempty"`
+	// Sets the visibility of GFPGAN, values should be between 0 and 1.
+	GfpganVisibility float64 `json:"gfpgan_visibility,omitempty"`
+	// Sets the visibility of CodeFormer, values should be between 0 and 1.
+	CodeformerVisibility float64 `json:"codeformer_visibility,omitempty"`
+	// Sets the weight of CodeFormer, values should be between 0 and 1.
+	CodeformerWeight float64 `json:"codeformer_weight,omitempty"`
+	// By how much to upscale the image, only used when resize_mode=0.
+	UpscalingResize float64 `json:"upscaling_resize,omitempty"`
+	// Target width for the upscaler to hit. Only used when resize_mode=1.
+	UpscalingResizeW int `json:"upscaling_resize_w,omitempty"`
+	// Target height for the upscaler to hit. Only used when resize_mode=1.
+	UpscalingResizeH int `json:"upscaling_resize_h,omitempty"`
+	// Should the upscaler crop the image to fit in the chosen size?
+	UpscalingCrop bool `json:"upscaling_crop,omitempty"`
+	// The name of the main upscaler to use, it has to be one of this list: None , Lanczos , Nearest , ESRGAN_4x , R-ESRGAN 4x+ , R-ESRGAN 4x+ Anime6B , LDSR , ScuNET GAN , ScuNET PSNR , SwinIR 4x
+	Upscaler1 string `json:"upscaler_1,omitempty"`
+	// The name of the secondary upscaler to use, it has to be one of this list: None , Lanczos , Nearest , ESRGAN_4x , R-ESRGAN 4x+ , R-ESRGAN 4x+ Anime6B , LDSR , ScuNET GAN , ScuNET PSNR , SwinIR 4x
+	Upscaler2 string `json:"upscaler_2,omitempty"`
+	// Sets the visibility of secondary upscaler, values should be between 0 and 1.
+	ExtrasUpscaler2Visibility float64 `json:"extras_upscaler_2_visibility,omitempty"`
+	// Should the upscaler run before restoring faces?
+	UpscaleFirst bool `json:"upscale_first,omitempty"`
+	// Image to work on, must be a Base64 string containing the image's data.
+	Image string `json:"image,omitempty"`
+}
@@ -0,0 +1,470 @@
+// Package pool spreads generations over several WebUI backends, dispatching each job to a
+// backend with the free VRAM it likely needs.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// ErrInsufficientVRAM is returned when no idle backend has the free VRAM a job needs, waiting
+// would not help as nothing else runs on the pool.
+var ErrInsufficientVRAM = errors.New("no backend has enough free VRAM for the job")
+
+// Backend is a member of the pool.
+type Backend struct {
+	// Name identifies the backend in errors.
+	Name string
+	API  sdcli.API
+	// MaxConcurrency is the most jobs running on the backend at the same time, 1 if 0 as WebUI
+	// runs one job at a time. Raise it for servers running jobs in parallel, such as several
+	// WebUI instances behind a load balancer.
+	MaxConcurrency int
+	// Rate is the most jobs started per second, unlimited if 0, allowing bursts of Burst jobs
+	// (1 if 0).
+	Rate  float64
+	Burst int
+}
+
+// CostFunc estimates the VRAM in bytes a generation of width x height with batchSize images needs
+// on top of the loaded model.
+type CostFunc func(width, height, batchSize int) int64
+
+// BytesPerPixel is the VRAM per image pixel of DefaultCost, measured on SD 1.5 and SDXL with
+// the default attention optimizations and rounded up.
+const BytesPerPixel = 4 << 10
+
+// DefaultCost is BytesPerPixel for every pixel of the batch.
+func DefaultCost(width, height, batchSize int) int64 {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	return int64(width) * int64(height) * int64(batchSize) * BytesPerPixel
+}
+
+// Defaults of the pool options.
+const (
+	DefaultMemoryTTL    = time.Second
+	DefaultPollInterval = time.Second
+)
+
+// Option configures the Pool.
+type Option func(p *Pool)
+
+// WithCost replaces DefaultCost, a function returning 0 disables the VRAM checks.
+func WithCost(fn CostFunc) Option {
+	return func(p *Pool) {
+		p.cost = fn
+	}
+}
+
+// WithMemoryTTL sets how long a /memory reading of a backend is used for dispatching.
+func WithMemoryTTL(ttl time.Duration) Option {
+	return func(p *Pool) {
+		p.memoryTTL = ttl
+	}
+}
+
+// WithPollInterval sets how often held jobs check again for free VRAM, which may be released
+// by other processes sharing the GPU.
+func WithPollInterval(d time.Duration) Option {
+	return func(p *Pool) {
+		p.pollInterval = d
+	}
+}
+
+type backend struct {
+	*Backend
+
+	// running and reserved are the jobs in flight and their estimated costs.
+	running  int
+	reserved int64
+	memory   *sdcli.MemoryResponse
+	readAt   time.Time
+	// tokens is the token bucket of Rate, as of filledAt.
+	tokens   float64
+	filledAt time.Time
+}
+
+func (b *backend) maxConcurrency() int {
+	return max(b.MaxConcurrency, 1)
+}
+
+// refill adds the tokens earned since the last refill and returns how long until a token is
+// available, 0 if one is.
+func (b *backend) refill(now time.Time) time.Duration {
+	if b.Rate <= 0 {
+		return 0
+	}
+	burst := float64(max(b.Burst, 1))
+	if b.filledAt.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(burst, b.tokens+now.Sub(b.filledAt).Seconds()*b.Rate)
+	}
+	b.filledAt = now
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.Rate * float64(time.Second))
+}
+
+// Pool dispatches generations to its backends, up to the MaxConcurrency and Rate of every
+// backend. A job goes to a backend with room whose free VRAM, minus the estimated costs of its
+// running jobs, covers the estimated cost of the job, preferring the one with the most free
+// VRAM, and is held until one frees up otherwise. Backends not reporting CUDA memory, such as
+// CPU or ComfyUI backends, take any job.
+//
+// Pool implements sdcli.API: the other reads are answered by the first backend that succeeds,
+// and option changes, refreshes and interrupts go to every backend.
+type Pool struct {
+	backends     []*backend
+	cost         CostFunc
+	memoryTTL    time.Duration
+	pollInterval time.Duration
+
+	mu sync.Mutex
+	// released is closed and replaced whenever a job finishes.
+	released chan struct{}
+}
+
+var _ sdcli.API = (*Pool)(nil)
+
+// New creates a pool of backends.
+func New(backends []*Backend, opts ...Option) (*Pool, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("pool needs at least one backend")
+	}
+
+	p := &Pool{
+		cost:         DefaultCost,
+		memoryTTL:    DefaultMemoryTTL,
+		pollInterval: DefaultPollInterval,
+		released:     make(chan struct{}),
+	}
+	for i, b := range backends {
+		if b.API == nil {
+			return nil, fmt.Errorf("backend %d has no API", i)
+		}
+		if b.MaxConcurrency < 0 || b.Rate < 0 || b.Burst < 0 {
+			return nil, fmt.Errorf("backend %d has a negative limit", i)
+		}
+		p.backends = append(p.backends, &backend{Backend: b})
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p, nil
+}
+
+// acquire waits for a backend to run a job of cost, the returned release must be called when
+// the job is done.
+func (p *Pool) acquire(ctx context.Context, cost int64) (*backend, func(), error) {
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		b, released, wait, err := p.pick(ctx, cost)
+		if err != nil {
+			return nil, nil, err
+		}
+		if b != nil {
+			return b, func() { p.release(b, cost) }, nil
+		}
+
+		var (
+			timer *time.Timer
+			token <-chan time.Time
+		)
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			token = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-released:
+		case <-token:
+		case <-ticker.C:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// pick reserves the backend with room and the most free VRAM covering cost. When none does it
+// returns nil, the channel closed on the next release and how long until a rate limited
+// backend covering cost gets a token, if any.
+func (p *Pool) pick(ctx context.Context, cost int64) (*backend, <-chan struct{}, time.Duration, error) {
+	p.refreshMemory(ctx, cost)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var (
+		best     *backend
+		bestFree int64
+		wait     time.Duration
+	)
+	now := time.Now()
+	for _, b := range p.backends {
+		if b.running >= b.maxConcurrency() {
+			continue
+		}
+		free, known := b.free()
+		if known && free < cost {
+			continue
+		}
+		if !known {
+			free = 1<<63 - 1
+		}
+		if d := b.refill(now); d > 0 {
+			if wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		if best == nil || free > bestFree {
+			best, bestFree = b, free
+		}
+	}
+
+	if best != nil {
+		best.running++
+		best.reserved += cost
+		if best.Rate > 0 {
+			best.tokens--
+		}
+		return best, nil, 0, nil
+	}
+	if wait == 0 && p.allIdle() {
+		return nil, nil, 0, fmt.Errorf("%w: needs %d MiB", ErrInsufficientVRAM, cost>>20)
+	}
+	return nil, p.released, wait, nil
+}
+
+func (p *Pool) release(b *backend, cost int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b.running--
+	b.reserved -= cost
+	// The reading predates the job, read the memory again for the next one.
+	b.readAt = time.Time{}
+	close(p.released)
+	p.released = make(chan struct{})
+}
+
+func (p *Pool) allIdle() bool {
+	for _, b := range p.backends {
+		if b.running != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// free is the free VRAM of the last reading minus the reservations, known is false when the
+// backend does not report CUDA memory.
+func (b *backend) free() (free int64, known bool) {
+	if b.memory == nil || b.memory.Cuda.System.Total <= 0 {
+		return 0, false
+	}
+	return b.memory.Cuda.System.Free - b.reserved, true
+}
+
+// refreshMemory reads the memory of the idle backends whose reading expired, unless cost is 0.
+func (p *Pool) refreshMemory(ctx context.Context, cost int64) {
+	if cost <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	var stale []*backend
+	for _, b := range p.backends {
+		if b.running == 0 && time.Since(b.readAt) >= p.memoryTTL {
+			stale = append(stale, b)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, b := range stale {
+		// A failed reading counts as unknown memory, the backend errors surface on the job.
+		mem, _ := b.API.GetMemory(ctx)
+		p.mu.Lock()
+		b.memory, b.readAt = mem, time.Now()
+		p.mu.Unlock()
+	}
+}
+
+// run runs fn on a backend with room for a job of width x height x batchSize.
+func run[T any](ctx context.Context, p *Pool, width, height, batchSize int, fn func(api sdcli.API) (T, error)) (T, error) {
+	var zero T
+	b, release, err := p.acquire(ctx, p.cost(width, height, batchSize))
+	if err != nil {
+		return zero, err
+	}
+	defer release()
+
+	res, err := fn(b.API)
+	if err != nil {
+		return zero, fmt.Errorf("backend %s: %w", b.Name, err)
+	}
+	return res, nil
+}
+
+// txt2imgSize is the final size of a txt2img generation, after the hires fix if enabled.
+func txt2imgSize(opt *sdcli.Txt2ImageOption) (int, int) {
+	width, height := opt.Width, opt.Height
+	if width == 0 {
+		width = 512
+	}
+	if height == 0 {
+		height = 512
+	}
+	switch {
+	case !opt.EnableHR:
+	case opt.HrResizeX != 0 && opt.HrResizeY != 0:
+		width, height = opt.HrResizeX, opt.HrResizeY
+	case opt.HRScale > 0:
+		width, height = int(float32(width)*opt.HRScale), int(float32(height)*opt.HRScale)
+	}
+	return width, height
+}
+
+// Txt2Img runs txt2img on a backend with enough free VRAM.
+func (p *Pool) Txt2Img(ctx context.Context, opt sdcli.Txt2ImageOption, opts ...sdcli.RequestOption) (*sdcli.Txt2ImageResponse, error) {
+	width, height := txt2imgSize(&opt)
+	return run(ctx, p, width, height, opt.BatchSize, func(api sdcli.API) (*sdcli.Txt2ImageResponse, error) {
+		return api.Txt2Img(ctx, opt, opts...)
+	})
+}
+
+// Img2Img runs img2img on a backend with enough free VRAM.
+func (p *Pool) Img2Img(ctx context.Context, opt sdcli.Img2ImgOption, opts ...sdcli.RequestOption) (*sdcli.Img2ImgResponse, error) {
+	width, height := opt.Width, opt.Height
+	if width == 0 || height == 0 {
+		width, height = 512, 512
+	}
+	return run(ctx, p, width, height, opt.BatchSize, func(api sdcli.API) (*sdcli.Img2ImgResponse, error) {
+		return api.Img2Img(ctx, opt, opts...)
+	})
+}
+
+// ExtraSingleImg upscales on an idle backend, the cost of upscalers is not estimated.
+func (p *Pool) ExtraSingleImg(ctx context.Context, opt sdcli.ExtraSingleImgOption, opts ...sdcli.RequestOption) (*sdcli.ExtraSingleImgResponse, error) {
+	return run(ctx, p, 0, 0, 0, func(api sdcli.API) (*sdcli.ExtraSingleImgResponse, error) {
+		return api.ExtraSingleImg(ctx, opt, opts...)
+	})
+}
+
+// first returns the answer of the first backend that succeeds, or the errors of all of them.
+func first[T any](p *Pool, fn func(api sdcli.API) (T, error)) (T, error) {
+	var errs []error
+	for _, b := range p.backends {
+		res, err := fn(b.API)
+		if err == nil {
+			return res, nil
+		}
+		errs = append(errs, fmt.Errorf("backend %s: %w", b.Name, err))
+	}
+	var zero T
+	return zero, errors.Join(errs...)
+}
+
+// each calls fn on every backend, joining the errors.
+func (p *Pool) each(fn func(api sdcli.API) error) error {
+	var errs []error
+	for _, b := range p.backends {
+		if err := fn(b.API); err != nil {
+			errs = append(errs, fmt.Errorf("backend %s: %w", b.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Pool) GetProgress(ctx context.Context, skipCurrentImg bool) (*sdcli.ProgressResponse, error) {
+	return first(p, func(api sdcli.API) (*sdcli.ProgressResponse, error) { return api.GetProgress(ctx, skipCurrentImg) })
+}
+
+func (p *Pool) Interrupt(ctx context.Context) error {
+	return p.each(func(api sdcli.API) error { return api.Interrupt(ctx) })
+}
+
+func (p *Pool) Skip(ctx context.Context) error {
+	return p.each(func(api sdcli.API) error { return api.Skip(ctx) })
+}
+
+func (p *Pool) GetOptions(ctx context.Context) (*sdcli.OptionsResponse, error) {
+	return first(p, func(api sdcli.API) (*sdcli.OptionsResponse, error) { return api.GetOptions(ctx) })
+}
+
+func (p *Pool) SetOptions(ctx context.Context, opts map[string]any) error {
+	return p.each(func(api sdcli.API) error { return api.SetOptions(ctx, opts) })
+}
+
+func (p *Pool) GetModels(ctx context.Context) ([]*sdcli.ModelsResponse, error) {
+	return first(p, func(api sdcli.API) ([]*sdcli.ModelsResponse, error) { return api.GetModels(ctx) })
+}
+
+func (p *Pool) RefreshCheckpoints(ctx context.Context) error {
+	return p.each(func(api sdcli.API) error { return api.RefreshCheckpoints(ctx) })
+}
+
+func (p *Pool) GetSamplers(ctx context.Context) ([]*sdcli.SamplersResponse, error) {
+	return first(p, func(api sdcli.API) ([]*sdcli.SamplersResponse, error) { return api.GetSamplers(ctx) })
+}
+
+func (p *Pool) GetSchedulers(ctx context.Context) ([]*sdcli.SchedulersResponse, error) {
+	return first(p, func(api sdcli.API) ([]*sdcli.SchedulersResponse, error) { return api.GetSchedulers(ctx) })
+}
+
+func (p *Pool) GetUpscalers(ctx context.Context) ([]*sdcli.UpscalersResponse, error) {
+	return first(p, func(api sdcli.API) ([]*sdcli.UpscalersResponse, error) { return api.GetUpscalers(ctx) })
+}
+
+func (p *Pool) GetVAEs(ctx context.Context) ([]*sdcli.VAEsResponse, error) {
+	return first(p, func(api sdcli.API) ([]*sdcli.VAEsResponse, error) { return api.GetVAEs(ctx) })
+}
+
+func (p *Pool) GetSdModules(ctx context.Context) ([]*sdcli.SdModulesResponse, error) {
+	return first(p, func(api sdcli.API) ([]*sdcli.SdModulesResponse, error) { return api.GetSdModules(ctx) })
+}
+
+func (p *Pool) RefreshVAEs(ctx context.Context) error {
+	return p.each(func(api sdcli.API) error { return api.RefreshVAEs(ctx) })
+}
+
+func (p *Pool) GetScripts(ctx context.Context) (*sdcli.ScriptsResponse, error) {
+	return first(p, func(api sdcli.API) (*sdcli.ScriptsResponse, error) { return api.GetScripts(ctx) })
+}
+
+func (p *Pool) GetLoras(ctx context.Context) ([]*sdcli.LorasResponse, error) {
+	return first(p, func(api sdcli.API) ([]*sdcli.LorasResponse, error) { return api.GetLoras(ctx) })
+}
+
+func (p *Pool) GetHypernetworks(ctx context.Context) ([]*sdcli.HypernetworksResponse, error) {
+	return first(p, func(api sdcli.API) ([]*sdcli.HypernetworksResponse, error) { return api.GetHypernetworks(ctx) })
+}
+
+func (p *Pool) GetEmbeddings(ctx context.Context) (*sdcli.EmbeddingsResponse, error) {
+	return first(p, func(api sdcli.API) (*sdcli.EmbeddingsResponse, error) { return api.GetEmbeddings(ctx) })
+}
+
+func (p *Pool) GetMemory(ctx context.Context) (*sdcli.MemoryResponse, error) {
+	return first(p, func(api sdcli.API) (*sdcli.MemoryResponse, error) { return api.GetMemory(ctx) })
+}
+
+func (p *Pool) Capabilities(ctx context.Context) (*sdcli.Capabilities, error) {
+	return first(p, func(api sdcli.API) (*sdcli.Capabilities, error) { return api.Capabilities(ctx) })
+}
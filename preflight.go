@@ -0,0 +1,129 @@
+package sdcli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// WithPreflight checks the sampler, scheduler, upscalers, checkpoint and VAE of generation
+// requests against Capabilities before sending them, failing with a ValidationErrors naming
+// the available values instead of an opaque server traceback. Capabilities are cached, see
+// WithCapabilitiesTTL.
+func WithPreflight() Option {
+	return func(c *Client) {
+		c.preflight = true
+	}
+}
+
+func (c *Client) preflightTxt2Img(ctx context.Context, opt *Txt2ImageOption) error {
+	if !c.preflight {
+		return nil
+	}
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return err
+	}
+
+	v := &validator{}
+	checkSampling(v, caps, opt.SamplerName, opt.SamplerIndex, opt.Scheduler)
+	if opt.EnableHR {
+		checkUpscaler(v, caps, "hr_upscaler", opt.HrUpscaler)
+	}
+	checkSettings(v, caps, opt.OverrideSettings)
+	return v.err()
+}
+
+func (c *Client) preflightImg2Img(ctx context.Context, opt *Img2ImgOption) error {
+	if !c.preflight {
+		return nil
+	}
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return err
+	}
+
+	v := &validator{}
+	checkSampling(v, caps, opt.SamplerName, opt.SamplerIndex, opt.Scheduler)
+	checkSettings(v, caps, opt.OverrideSettings)
+	return v.err()
+}
+
+func (c *Client) preflightExtras(ctx context.Context, opt *ExtraSingleImgOption) error {
+	if !c.preflight {
+		return nil
+	}
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return err
+	}
+
+	v := &validator{}
+	checkUpscaler(v, caps, "upscaler_1", opt.Upscaler1)
+	checkUpscaler(v, caps, "upscaler_2", opt.Upscaler2)
+	return v.err()
+}
+
+func checkSampling(v *validator, caps *Capabilities, sampler, samplerIndex, scheduler string) {
+	if len(sampler) != 0 && !caps.HasSampler(sampler) {
+		v.add("sampler_name", "unknown sampler %q, available: %s", sampler, samplerNames(caps))
+	}
+	if len(samplerIndex) != 0 && !caps.HasSampler(samplerIndex) {
+		v.add("sampler_index", "unknown sampler %q, available: %s", samplerIndex, samplerNames(caps))
+	}
+	// Servers without the schedulers endpoint ignore the field.
+	if len(scheduler) != 0 && caps.Schedulers != nil && !caps.HasScheduler(scheduler) {
+		names := make([]string, len(caps.Schedulers))
+		for i, s := range caps.Schedulers {
+			names[i] = s.Name
+		}
+		v.add("scheduler", "unknown scheduler %q, available: %s", scheduler, strings.Join(names, ", "))
+	}
+}
+
+// latentUpscalerPrefix starts the names of the latent upscale modes of the hires pass,
+// which are not listed by the upscalers endpoint.
+const latentUpscalerPrefix = "Latent"
+
+func checkUpscaler(v *validator, caps *Capabilities, field, name string) {
+	if len(name) == 0 || strings.HasPrefix(name, latentUpscalerPrefix) || caps.HasUpscaler(name) {
+		return
+	}
+	names := make([]string, len(caps.Upscalers))
+	for i, u := range caps.Upscalers {
+		names[i] = u.Name
+	}
+	v.add(field, "unknown upscaler %q, available: %s", name, strings.Join(names, ", "))
+}
+
+func checkSettings(v *validator, caps *Capabilities, settings Overrides) {
+	if model := settings.Model(); len(model) != 0 && !caps.HasModel(model) {
+		names := make([]string, len(caps.Models))
+		for i, m := range caps.Models {
+			names[i] = m.Title
+		}
+		v.add("override_settings.sd_model_checkpoint", "unknown checkpoint %q, available: %s", model, strings.Join(names, ", "))
+	}
+	if vae := settings.VAE(); len(vae) != 0 && vae != VAEAutomatic && vae != VAENone && !caps.HasVAE(vae) {
+		names := make([]string, len(caps.VAEs))
+		for i, m := range caps.VAEs {
+			names[i] = m.ModelName
+		}
+		v.add("override_settings.sd_vae", "unknown VAE %q, available: %s", vae, strings.Join(names, ", "))
+	}
+}
+
+func samplerNames(caps *Capabilities) string {
+	names := make([]string, len(caps.Samplers))
+	for i, s := range caps.Samplers {
+		names[i] = s.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// isNotFound reports whether err is a 404 answer, e.g. from an endpoint the server predates.
+func isNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Response != nil && e.Response.StatusCode == http.StatusNotFound
+}
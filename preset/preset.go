@@ -0,0 +1,220 @@
+// Package preset names generation settings, such as "draft" or "quality", so that services
+// share them instead of repeating API parameters.
+//
+// A preset holds API parameters using the WebUI JSON names, as in batch job files, merged
+// with per call overrides when converted to options.
+package preset
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Names of the builtin presets.
+const (
+	Draft       = "draft"
+	Quality     = "quality"
+	SDXLDefault = "sdxl-default"
+)
+
+// Preset is a named set of API parameters.
+type Preset struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Extends names a preset whose params are overridden by these.
+	Extends string         `json:"extends,omitempty" yaml:"extends,omitempty"`
+	Params  map[string]any `json:"params" yaml:"params"`
+}
+
+// Builtin returns the builtin presets.
+func Builtin() []*Preset {
+	return []*Preset{
+		{
+			Name:        Draft,
+			Description: "fast low step previews",
+			Params: map[string]any{
+				"steps": 12, "cfg_scale": 6, "width": 512, "height": 512, "sampler_name": sdcli.SamplerEulerA,
+			},
+		},
+		{
+			Name:        Quality,
+			Description: "slower high step renders",
+			Params: map[string]any{
+				"steps": 40, "cfg_scale": 7, "width": 768, "height": 768, "sampler_name": sdcli.SamplerDPMPP2MKarras,
+			},
+		},
+		{
+			Name:        SDXLDefault,
+			Description: "the native resolution of SDXL checkpoints",
+			Params: map[string]any{
+				"steps": 30, "cfg_scale": 7, "width": 1024, "height": 1024, "sampler_name": sdcli.SamplerDPMPP2MKarras,
+			},
+		},
+	}
+}
+
+// Registry holds presets by name, it is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	presets map[string]*Preset
+}
+
+// NewRegistry returns a registry holding presets, see Builtin.
+func NewRegistry(presets ...*Preset) *Registry {
+	r := &Registry{presets: map[string]*Preset{}}
+	for _, p := range presets {
+		r.Register(p)
+	}
+	return r
+}
+
+// Register adds a preset, replacing any preset of the same name.
+func (r *Registry) Register(p *Preset) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.presets[p.Name] = p
+}
+
+// Names returns the sorted preset names.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.presets))
+	for name := range r.presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns a preset as registered.
+func (r *Registry) Get(name string) (*Preset, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.presets[name]
+	return p, ok
+}
+
+// Params returns the params of a preset with the presets it extends resolved, merged with
+// overrides.
+func (r *Registry) Params(name string, overrides map[string]any) (map[string]any, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var chain []*Preset
+	seen := map[string]bool{}
+	for next := name; len(next) != 0; {
+		if seen[next] {
+			return nil, fmt.Errorf("preset %s extends itself", next)
+		}
+		seen[next] = true
+		p, ok := r.presets[next]
+		if !ok {
+			return nil, fmt.Errorf("unknown preset %s", next)
+		}
+		chain = append(chain, p)
+		next = p.Extends
+	}
+
+	params := map[string]any{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for k, v := range chain[i].Params {
+			params[k] = v
+		}
+	}
+	for k, v := range overrides {
+		params[k] = v
+	}
+	return params, nil
+}
+
+// Txt2Img returns the options of a preset, the non zero fields of override win.
+func (r *Registry) Txt2Img(name string, override sdcli.Txt2ImageOption) (sdcli.Txt2ImageOption, error) {
+	var opt sdcli.Txt2ImageOption
+	err := r.decode(name, override, &opt)
+	return opt, err
+}
+
+// Img2Img returns the options of a preset, the non zero fields of override win.
+func (r *Registry) Img2Img(name string, override sdcli.Img2ImgOption) (sdcli.Img2ImgOption, error) {
+	var opt sdcli.Img2ImgOption
+	err := r.decode(name, override, &opt)
+	return opt, err
+}
+
+func (r *Registry) decode(name string, override, opt any) error {
+	data, err := json.Marshal(override)
+	if err != nil {
+		return fmt.Errorf("failed to encode overrides: %w", err)
+	}
+	var overrides map[string]any
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return fmt.Errorf("failed to encode overrides: %w", err)
+	}
+
+	params, err := r.Params(name, overrides)
+	if err != nil {
+		return err
+	}
+	if data, err = json.Marshal(params); err != nil {
+		return fmt.Errorf("failed to encode preset %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, opt); err != nil {
+		return fmt.Errorf("invalid preset %s: %w", name, err)
+	}
+	return nil
+}
+
+// Load registers the presets of a file, a list of presets in YAML (.yaml or .yml) or JSON.
+func (r *Registry) Load(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("failed to read presets: %w", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	presets, err := Parse(data, ext == ".yaml" || ext == ".yml")
+	if err != nil {
+		return err
+	}
+	for _, p := range presets {
+		r.Register(p)
+	}
+	return nil
+}
+
+// Parse parses a list of presets from YAML or JSON data.
+func Parse(data []byte, isYAML bool) ([]*Preset, error) {
+	var presets []*Preset
+	if isYAML {
+		if err := yaml.Unmarshal(data, &presets); err != nil {
+			return nil, fmt.Errorf("failed to parse presets: %w", err)
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&presets); err != nil {
+			return nil, fmt.Errorf("failed to parse presets: %w", err)
+		}
+	}
+
+	for i, p := range presets {
+		if p == nil || len(p.Name) == 0 {
+			return nil, fmt.Errorf("preset %d has no name", i)
+		}
+	}
+	return presets, nil
+}
@@ -0,0 +1,243 @@
+package sdcli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Output is a still image of a generation response going through the ImageProcessors. The image
+// is decoded and encoded on demand, processors changing it with SetImage get it encoded back in
+// Format once the chain is done.
+type Output struct {
+	// Index is the index of the image in the response.
+	Index int
+	// Format is the format the image is encoded in, one of FormatPNG, FormatJPEG and FormatWEBP.
+	// Processors may change it, WebP is encoded as PNG as there is no WebP encoder.
+	Format string
+	// Quality is the JPEG quality, jpeg.DefaultQuality if 0.
+	Quality int
+	// Infotext is the generation parameters of the image, stamped by StampParameters.
+	Infotext string
+	// Tags are free-form annotations of the processors, such as a classifier label.
+	Tags map[string]string
+	// Drop removes the image from the response, the remaining processors are skipped.
+	Drop bool
+
+	data []byte
+	img  image.Image
+}
+
+// Image returns the decoded image.
+func (o *Output) Image() (image.Image, error) {
+	if o.img == nil {
+		img, _, err := image.Decode(bytes.NewReader(o.data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		o.img = img
+	}
+	return o.img, nil
+}
+
+// SetImage replaces the image.
+func (o *Output) SetImage(img image.Image) {
+	o.img, o.data = img, nil
+}
+
+// Data returns the encoded image, encoding it in Format if it was replaced.
+func (o *Output) Data() ([]byte, error) {
+	if o.data != nil {
+		return o.data, nil
+	}
+
+	buf := &bytes.Buffer{}
+	var err error
+	switch o.Format {
+	case FormatJPEG:
+		quality := o.Quality
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(buf, o.img, &jpeg.Options{Quality: quality})
+	default:
+		o.Format = FormatPNG
+		err = png.Encode(buf, o.img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	o.data = buf.Bytes()
+	return o.data, nil
+}
+
+// SetData replaces the encoded image, Format follows the data.
+func (o *Output) SetData(data []byte) {
+	o.data, o.img = data, nil
+	o.Format = DetectFormat(data)
+}
+
+// Tag sets a tag.
+func (o *Output) Tag(key, value string) {
+	if o.Tags == nil {
+		o.Tags = map[string]string{}
+	}
+	o.Tags[key] = value
+}
+
+// ImageProcessor is a stage of the post-processing chain run over the still images of generation
+// responses, see WithImageProcessors.
+type ImageProcessor interface {
+	Process(ctx context.Context, out *Output) error
+}
+
+// ImageProcessorFunc adapts a function to ImageProcessor.
+type ImageProcessorFunc func(ctx context.Context, out *Output) error
+
+func (f ImageProcessorFunc) Process(ctx context.Context, out *Output) error {
+	return f(ctx, out)
+}
+
+// WithImageProcessors runs the processors in order over every still image of the txt2img and
+// img2img responses. RawImages, ParsedImages and Outputs then hold the processed images while
+// Images keeps the server answer. A processor error fails the generation.
+func WithImageProcessors(processors ...ImageProcessor) Option {
+	return func(c *Client) {
+		c.processors = append(c.processors, processors...)
+	}
+}
+
+// Resize scales the images to fit in width x height keeping the aspect ratio, smaller images
+// are left as is.
+func Resize(width, height int) ImageProcessor {
+	return ImageProcessorFunc(func(ctx context.Context, out *Output) error {
+		img, err := out.Image()
+		if err != nil {
+			return err
+		}
+		size := img.Bounds().Size()
+		if size.X <= width && size.Y <= height {
+			return nil
+		}
+
+		scale := float64(width) / float64(size.X)
+		if s := float64(height) / float64(size.Y); s < scale {
+			scale = s
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, int(float64(size.X)*scale), int(float64(size.Y)*scale)))
+		xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+		out.SetImage(dst)
+		return nil
+	})
+}
+
+// ConvertFormat encodes the images as format, FormatPNG or FormatJPEG with quality.
+func ConvertFormat(format string, quality int) ImageProcessor {
+	return ImageProcessorFunc(func(ctx context.Context, out *Output) error {
+		if out.Format == format && out.Quality == quality {
+			return nil
+		}
+		img, err := out.Image()
+		if err != nil {
+			return err
+		}
+		out.Format, out.Quality = format, quality
+		out.SetImage(img)
+		return nil
+	})
+}
+
+// StampParameters embeds the infotexts in the images like WebUI does, see SetParameters. Use it
+// after the processors changing the images, as encoding drops the metadata.
+func StampParameters() ImageProcessor {
+	return ImageProcessorFunc(func(ctx context.Context, out *Output) error {
+		if len(out.Infotext) == 0 {
+			return nil
+		}
+		data, err := out.Data()
+		if err != nil {
+			return err
+		}
+		stamped, err := SetParameters(data, out.Infotext)
+		if err != nil {
+			return err
+		}
+		out.SetData(stamped)
+		return nil
+	})
+}
+
+// postProcess runs the processors, if any, over the still images among raws and replaces them
+// and the parsed images with the results. Images in other formats such as single frame GIFs are
+// kept as is.
+func (c *Client) postProcess(ctx context.Context, info string, raws *[][]byte, parsed *[]image.Image) ([]*Output, error) {
+	if len(c.processors) == 0 {
+		return nil, nil
+	}
+
+	var gen *GenerationInfo
+	if len(info) != 0 {
+		gen, _ = ParseInfo(info)
+	}
+
+	var outputs []*Output
+	processed := make([][]byte, 0, len(*raws))
+	// The parsed images are at the index of their raw data.
+	orig := *parsed
+	*parsed = nil
+	for i, data := range *raws {
+		format := DetectFormat(data)
+		switch format {
+		case FormatPNG, FormatJPEG, FormatWEBP:
+		default:
+			processed = append(processed, data)
+			if i < len(orig) {
+				*parsed = append(*parsed, orig[i])
+			}
+			continue
+		}
+
+		out := &Output{Index: i, Format: format, data: data}
+		if gen != nil {
+			out.Infotext = gen.Infotext(i)
+		}
+		if err := c.process(ctx, out); err != nil {
+			return nil, fmt.Errorf("failed to process image %d: %w", i, err)
+		}
+		if out.Drop {
+			continue
+		}
+
+		outputs = append(outputs, out)
+		processed = append(processed, out.data)
+		if !c.skipDecode {
+			img, err := out.Image()
+			if err != nil {
+				return nil, fmt.Errorf("failed to process image %d: %w", i, err)
+			}
+			*parsed = append(*parsed, img)
+		}
+	}
+	*raws = processed
+
+	return outputs, nil
+}
+
+// process runs the chain over out and encodes the result.
+func (c *Client) process(ctx context.Context, out *Output) error {
+	for _, p := range c.processors {
+		if err := p.Process(ctx, out); err != nil {
+			return err
+		}
+		if out.Drop {
+			return nil
+		}
+	}
+	_, err := out.Data()
+	return err
+}
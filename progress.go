@@ -0,0 +1,54 @@
+package sdcli
+
+import "time"
+
+// JobTimestampLayout is the layout of the job_timestamp of the progress state, in the server
+// local time.
+const JobTimestampLayout = "20060102150405"
+
+// ETA is the remaining time estimated by the server.
+func (p *ProgressResponse) ETA() time.Duration {
+	return time.Duration(float64(p.ETARelative) * float64(time.Second))
+}
+
+// ExpectedCompletion is when the current task should complete, based on FetchedAt.
+func (p *ProgressResponse) ExpectedCompletion() time.Time {
+	return p.FetchedAt.Add(p.ETA())
+}
+
+// Started parses the start time of the current task, assuming the server shares the client
+// time zone. It returns false when idle.
+func (p *ProgressResponse) Started() (time.Time, bool) {
+	if len(p.State.JobTimestamp) == 0 || p.State.JobTimestamp == "0" {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(JobTimestampLayout, p.State.JobTimestamp, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Elapsed is the time spent on the current task at FetchedAt, 0 when idle.
+func (p *ProgressResponse) Elapsed() time.Duration {
+	started, ok := p.Started()
+	if !ok || p.FetchedAt.Before(started) {
+		return 0
+	}
+	return p.FetchedAt.Sub(started)
+}
+
+// StepsDone is the number of sampling steps completed by the current task, over all its jobs.
+func (p *ProgressResponse) StepsDone() int {
+	return p.State.JobNo*p.State.SamplingSteps + p.State.SamplingStep
+}
+
+// StepsPerSecond is the sampling throughput of the current task, 0 when unknown. It includes
+// the model loading and decoding time, so it is lower than the sampler speed.
+func (p *ProgressResponse) StepsPerSecond() float64 {
+	elapsed := p.Elapsed().Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.StepsDone()) / elapsed
+}
@@ -0,0 +1,102 @@
+package sdcli
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// DefaultProgressInterval is the polling interval of WithProgress when given none.
+const DefaultProgressInterval = 500 * time.Millisecond
+
+// ProgressFunc receives the progress readings of a running generation.
+type ProgressFunc func(p *ProgressResponse)
+
+// WithProgress runs fn while polling the progress of the server every interval, calling
+// progress with the readings of a running job. Once fn succeeds progress gets a last reading at
+// 100%, so bars end full. Polling errors are ignored, the server may be too busy to answer.
+func WithProgress(ctx context.Context, api API, interval time.Duration, progress ProgressFunc, fn func(ctx context.Context) error) error {
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+
+	pollCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	var last *ProgressResponse
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if p, err := api.GetProgress(pollCtx, true); err == nil && pollCtx.Err() == nil && len(p.State.Job) != 0 {
+				last = p
+				progress(p)
+			}
+
+			select {
+			case <-pollCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	err := fn(ctx)
+	cancel()
+	<-done
+
+	if err == nil {
+		final := &ProgressResponse{Progress: 1, FetchedAt: time.Now()}
+		if last != nil {
+			final.State = last.State
+			final.State.JobNo = final.State.JobCount - 1
+			final.State.SamplingStep = final.State.SamplingSteps
+		}
+		progress(final)
+	}
+	return err
+}
+
+// ProgressUnits is the total ProgressWriter advances over a generation.
+const ProgressUnits = 1000
+
+// ProgressWriter adapts an io.Writer progress bar, such as the bytes bars of the progress
+// libraries created with a total of ProgressUnits: it writes as many bytes as the progress
+// advanced in thousandths.
+func ProgressWriter(w io.Writer) ProgressFunc {
+	written := 0
+	return func(p *ProgressResponse) {
+		units := int(p.Progress * ProgressUnits)
+		if units > ProgressUnits {
+			units = ProgressUnits
+		}
+		// A new job of a batch may restart the progress, bars only move forward.
+		if units <= written {
+			return
+		}
+		_, _ = w.Write(make([]byte, units-written))
+		written = units
+	}
+}
+
+// ProgressSteps adapts a progress bar taking the current and total values, such as the
+// SetCurrent and SetTotal methods of most libraries, reporting the sampling steps over all the
+// jobs of the task.
+func ProgressSteps(set func(current, total int64)) ProgressFunc {
+	return func(p *ProgressResponse) {
+		total := int64(p.State.SamplingSteps)
+		if p.State.JobCount > 1 {
+			total *= int64(p.State.JobCount)
+		}
+		current := int64(p.StepsDone())
+		if total <= 0 {
+			// No step count yet, report the progress in ProgressUnits.
+			total, current = ProgressUnits, int64(p.Progress*ProgressUnits)
+		}
+		if current > total {
+			current = total
+		}
+		set(current, total)
+	}
+}
@@ -0,0 +1,117 @@
+package prompt
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultSeparator separates the parts of a Builder.
+const DefaultSeparator = ", "
+
+// Builder builds a prompt in the A1111 attention syntax, text is escaped so user input
+// cannot break the syntax. Modifiers such as Weight apply to the last added part:
+//
+//	prompt.NewBuilder().Text("cat").Weight(1.2).Alternate("red", "blue").String()
+//	// (cat:1.2), [red|blue]
+//
+// The zero value is ready to use.
+type Builder struct {
+	parts []string
+	sep   string
+}
+
+// NewBuilder returns an empty builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Separator sets the separator of the parts, DefaultSeparator by default.
+func (b *Builder) Separator(sep string) *Builder {
+	b.sep = sep
+	return b
+}
+
+// Text adds escaped text.
+func (b *Builder) Text(s string) *Builder {
+	return b.Raw(Escape(s))
+}
+
+// Raw adds text as is, for prompt fragments already in the attention syntax.
+func (b *Builder) Raw(s string) *Builder {
+	b.parts = append(b.parts, s)
+	return b
+}
+
+// Group adds the prompt of another builder as a single part, so modifiers apply to all of it.
+func (b *Builder) Group(other *Builder) *Builder {
+	return b.Raw(other.String())
+}
+
+// Weight sets the attention weight of the last part: (part:w).
+func (b *Builder) Weight(w float64) *Builder {
+	return b.wrapLast(func(s string) string {
+		return "(" + s + ":" + formatFloat(w) + ")"
+	})
+}
+
+// Emphasize multiplies the attention of the last part by 1.1 n times: ((part)).
+func (b *Builder) Emphasize(n int) *Builder {
+	return b.wrapLast(func(s string) string {
+		return strings.Repeat("(", n) + s + strings.Repeat(")", n)
+	})
+}
+
+// Deemphasize divides the attention of the last part by 1.1 n times: [[part]].
+func (b *Builder) Deemphasize(n int) *Builder {
+	return b.wrapLast(func(s string) string {
+		return strings.Repeat("[", n) + s + strings.Repeat("]", n)
+	})
+}
+
+// Alternate adds escaped options swapped every step: [a|b].
+func (b *Builder) Alternate(options ...string) *Builder {
+	escaped := make([]string, len(options))
+	for i, o := range options {
+		escaped[i] = escapeEdit(o)
+	}
+	return b.Raw("[" + strings.Join(escaped, "|") + "]")
+}
+
+// ScheduledEdit adds escaped text replaced from with to after when, a fraction of the steps
+// when less than 1 and a step number otherwise: [from:to:when].
+func (b *Builder) ScheduledEdit(from, to string, when float64) *Builder {
+	return b.Raw("[" + escapeEdit(from) + ":" + escapeEdit(to) + ":" + formatFloat(when) + "]")
+}
+
+// Len returns the number of parts.
+func (b *Builder) Len() int {
+	return len(b.parts)
+}
+
+// String renders the prompt.
+func (b *Builder) String() string {
+	sep := b.sep
+	if len(sep) == 0 {
+		sep = DefaultSeparator
+	}
+	return strings.Join(b.parts, sep)
+}
+
+func (b *Builder) wrapLast(fn func(string) string) *Builder {
+	if len(b.parts) == 0 {
+		return b
+	}
+	b.parts[len(b.parts)-1] = fn(b.parts[len(b.parts)-1])
+	return b
+}
+
+// escapeEdit escapes text used inside prompt editing, where : and | are separators.
+func escapeEdit(s string) string {
+	s = Escape(s)
+	s = strings.ReplaceAll(s, ":", `\:`)
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
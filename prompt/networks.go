@@ -0,0 +1,216 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Kinds of extra network tags.
+const (
+	KindLora     = "lora"
+	KindHypernet = "hypernet"
+)
+
+// Weight bounds extra network tags are clamped to.
+const (
+	MinNetworkWeight = -2
+	MaxNetworkWeight = 2
+)
+
+var networkTagRe = regexp.MustCompile(`<(\w+):([^<>:]+)(?::([^<>]*))?>`)
+
+// NetworkTag is an extra network tag such as <lora:name:0.8>.
+type NetworkTag struct {
+	Kind   string
+	Name   string
+	Weight float64
+}
+
+// LoRA returns a LoRA tag, its weight is clamped.
+func LoRA(name string, weight float64) NetworkTag {
+	return NetworkTag{Kind: KindLora, Name: name, Weight: clampWeight(weight)}
+}
+
+// Hypernet returns a hypernetwork tag, its weight is clamped.
+func Hypernet(name string, weight float64) NetworkTag {
+	return NetworkTag{Kind: KindHypernet, Name: name, Weight: clampWeight(weight)}
+}
+
+func (t NetworkTag) String() string {
+	return "<" + t.Kind + ":" + t.Name + ":" + formatFloat(t.Weight) + ">"
+}
+
+// ParseNetworkTags returns the extra network tags of a prompt in order, a missing weight is 1.
+func ParseNetworkTags(prompt string) []NetworkTag {
+	var tags []NetworkTag
+	for _, m := range networkTagRe.FindAllStringSubmatch(prompt, -1) {
+		t := NetworkTag{Kind: m[1], Name: m[2], Weight: 1}
+		// Only the first parameter is the weight, LoRA accepts more such as the U-Net weight.
+		if w, _, _ := strings.Cut(m[3], ":"); len(w) != 0 {
+			if f, err := strconv.ParseFloat(w, 64); err == nil {
+				t.Weight = f
+			}
+		}
+		tags = append(tags, t)
+	}
+	return tags
+}
+
+// StripNetworkTags removes the extra network tags of a prompt, e.g. for backends loading
+// networks by other means.
+func StripNetworkTags(prompt string) string {
+	s := networkTagRe.ReplaceAllString(prompt, "")
+	parts := strings.Split(s, ",")
+	kept := parts[:0]
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); len(p) != 0 {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, ", ")
+}
+
+// DuplicateTags returns the tags referencing the same network more than once in a prompt.
+func DuplicateTags(prompt string) []NetworkTag {
+	seen := map[string]bool{}
+	var dups []NetworkTag
+	for _, t := range ParseNetworkTags(prompt) {
+		key := t.Kind + ":" + t.Name
+		if seen[key] {
+			dups = append(dups, t)
+		}
+		seen[key] = true
+	}
+	return dups
+}
+
+// AppendTag appends tag to prompt, failing if the prompt already references the same network.
+func AppendTag(prompt string, tag NetworkTag) (string, error) {
+	for _, t := range ParseNetworkTags(prompt) {
+		if t.Kind == tag.Kind && t.Name == tag.Name {
+			return prompt, fmt.Errorf("%s %q is already in the prompt", tag.Kind, tag.Name)
+		}
+	}
+	if len(strings.TrimSpace(prompt)) == 0 {
+		return tag.String(), nil
+	}
+	return prompt + ", " + tag.String(), nil
+}
+
+// Networks validates extra network tags and embeddings against those installed on the server.
+type Networks struct {
+	loras      map[string]string
+	hypernets  map[string]bool
+	embeddings map[string]bool
+}
+
+// NewNetworks indexes the results of GetLoras, GetHypernetworks and GetEmbeddings, any may be nil.
+// LoRAs are known by name and alias.
+func NewNetworks(loras []*sdcli.LorasResponse, hypernets []*sdcli.HypernetworksResponse, embeddings *sdcli.EmbeddingsResponse) *Networks {
+	n := &Networks{
+		loras:      map[string]string{},
+		hypernets:  map[string]bool{},
+		embeddings: map[string]bool{},
+	}
+	for _, l := range loras {
+		n.loras[l.Name] = l.Name
+		if len(l.Alias) != 0 {
+			n.loras[l.Alias] = l.Name
+		}
+	}
+	for _, h := range hypernets {
+		n.hypernets[h.Name] = true
+	}
+	if embeddings != nil {
+		for name := range embeddings.Loaded {
+			n.embeddings[name] = true
+		}
+	}
+	return n
+}
+
+// LoadNetworks fetches the installed networks from the server.
+func LoadNetworks(ctx context.Context, cli sdcli.API) (*Networks, error) {
+	loras, err := cli.GetLoras(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loras: %w", err)
+	}
+	hypernets, err := cli.GetHypernetworks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hypernetworks: %w", err)
+	}
+	embeddings, err := cli.GetEmbeddings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embeddings: %w", err)
+	}
+
+	return NewNetworks(loras, hypernets, embeddings), nil
+}
+
+// LoRA returns the tag of an installed LoRA, name may be its alias.
+func (n *Networks) LoRA(name string, weight float64) (NetworkTag, error) {
+	if _, ok := n.loras[name]; !ok {
+		return NetworkTag{}, fmt.Errorf("unknown lora %q", name)
+	}
+	return LoRA(name, weight), nil
+}
+
+// Hypernet returns the tag of an installed hypernetwork.
+func (n *Networks) Hypernet(name string, weight float64) (NetworkTag, error) {
+	if !n.hypernets[name] {
+		return NetworkTag{}, fmt.Errorf("unknown hypernetwork %q", name)
+	}
+	return Hypernet(name, weight), nil
+}
+
+// HasEmbedding reports whether a textual inversion embedding is loaded, embeddings are used
+// by writing their name in the prompt.
+func (n *Networks) HasEmbedding(name string) bool {
+	return n.embeddings[name]
+}
+
+// Check validates the extra network tags of a prompt: unknown networks, duplicates and
+// weights out of [MinNetworkWeight, MaxNetworkWeight].
+func (n *Networks) Check(prompt string) error {
+	var problems []string
+	for _, t := range ParseNetworkTags(prompt) {
+		switch t.Kind {
+		case KindLora, "lyco":
+			if _, ok := n.loras[t.Name]; !ok {
+				problems = append(problems, fmt.Sprintf("unknown lora %q", t.Name))
+			}
+		case KindHypernet:
+			if !n.hypernets[t.Name] {
+				problems = append(problems, fmt.Sprintf("unknown hypernetwork %q", t.Name))
+			}
+		default:
+			continue
+		}
+		if t.Weight < MinNetworkWeight || t.Weight > MaxNetworkWeight {
+			problems = append(problems, fmt.Sprintf("%s %q weight %v out of range", t.Kind, t.Name, t.Weight))
+		}
+	}
+	for _, t := range DuplicateTags(prompt) {
+		problems = append(problems, fmt.Sprintf("duplicate %s %q", t.Kind, t.Name))
+	}
+	if len(problems) != 0 {
+		return fmt.Errorf("invalid extra networks: %s", strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+func clampWeight(w float64) float64 {
+	if w < MinNetworkWeight {
+		return MinNetworkWeight
+	}
+	if w > MaxNetworkWeight {
+		return MaxNetworkWeight
+	}
+	return w
+}
@@ -0,0 +1,247 @@
+package prompt
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// StylePlaceholder in a style prompt is replaced by the prompt the style is applied to,
+// without it the style is appended.
+const StylePlaceholder = "{prompt}"
+
+// Style is a named prompt and negative prompt, as in the styles.csv of WebUI.
+type Style struct {
+	Name           string `json:"name"`
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negative_prompt"`
+}
+
+// Styles is a client side style library. The API only applies the styles of the server,
+// Styles applies local ones to the prompts before sending them.
+type Styles struct {
+	styles []*Style
+	index  map[string]int
+}
+
+// NewStyles returns a library of styles, later styles replace earlier ones with the same name.
+func NewStyles(styles ...*Style) *Styles {
+	s := &Styles{index: map[string]int{}}
+	for _, st := range styles {
+		s.Add(st)
+	}
+	return s
+}
+
+// LoadStyles reads a styles.csv or, with a .json extension, a JSON array of styles.
+func LoadStyles(name string) (*Styles, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open styles: %w", err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		return ReadStylesJSON(f)
+	}
+	return ReadStylesCSV(f)
+}
+
+// ReadStylesCSV reads styles in the WebUI styles.csv format: a header with the name, prompt
+// and negative_prompt columns, in any order. The legacy text column is read as prompt.
+func ReadStylesCSV(r io.Reader) (*Styles, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read styles csv: %w", err)
+	}
+	if len(records) == 0 {
+		return NewStyles(), nil
+	}
+
+	cols := map[string]int{}
+	for i, h := range records[0] {
+		cols[strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))] = i
+	}
+	if _, ok := cols["prompt"]; !ok {
+		if i, ok := cols["text"]; ok {
+			cols["prompt"] = i
+		}
+	}
+	if _, ok := cols["name"]; !ok {
+		return nil, fmt.Errorf("failed to read styles csv: missing name column")
+	}
+	field := func(rec []string, col string) string {
+		i, ok := cols[col]
+		if !ok || i >= len(rec) {
+			return ""
+		}
+		return rec[i]
+	}
+
+	s := NewStyles()
+	for _, rec := range records[1:] {
+		name := field(rec, "name")
+		if len(name) == 0 {
+			continue
+		}
+		s.Add(&Style{Name: name, Prompt: field(rec, "prompt"), NegativePrompt: field(rec, "negative_prompt")})
+	}
+
+	return s, nil
+}
+
+// ReadStylesJSON reads a JSON array of styles.
+func ReadStylesJSON(r io.Reader) (*Styles, error) {
+	var styles []*Style
+	if err := json.NewDecoder(r).Decode(&styles); err != nil {
+		return nil, fmt.Errorf("failed to read styles json: %w", err)
+	}
+	return NewStyles(styles...), nil
+}
+
+// Save writes the styles to a file, in JSON with a .json extension and in CSV otherwise.
+func (s *Styles) Save(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create styles: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		err = s.WriteJSON(f)
+	} else {
+		err = s.WriteCSV(f)
+	}
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to write styles: %w", cerr)
+	}
+
+	return err
+}
+
+// WriteCSV writes the styles in the WebUI styles.csv format.
+func (s *Styles) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	records := [][]string{{"name", "prompt", "negative_prompt"}}
+	for _, st := range s.styles {
+		records = append(records, []string{st.Name, st.Prompt, st.NegativePrompt})
+	}
+	if err := cw.WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write styles csv: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON writes the styles as a JSON array.
+func (s *Styles) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s.List()); err != nil {
+		return fmt.Errorf("failed to write styles json: %w", err)
+	}
+	return nil
+}
+
+// Add adds a style or replaces the one with the same name.
+func (s *Styles) Add(st *Style) {
+	if i, ok := s.index[st.Name]; ok {
+		s.styles[i] = st
+		return
+	}
+	s.index[st.Name] = len(s.styles)
+	s.styles = append(s.styles, st)
+}
+
+// Get returns a style by name.
+func (s *Styles) Get(name string) (*Style, bool) {
+	i, ok := s.index[name]
+	if !ok {
+		return nil, false
+	}
+	return s.styles[i], true
+}
+
+// List returns the styles in file order.
+func (s *Styles) List() []*Style {
+	if s.styles == nil {
+		return []*Style{}
+	}
+	return s.styles
+}
+
+// Names returns the style names in file order.
+func (s *Styles) Names() []string {
+	names := make([]string, len(s.styles))
+	for i, st := range s.styles {
+		names[i] = st.Name
+	}
+	return names
+}
+
+// Apply merges the named styles into prompt and negative in order, like WebUI does.
+func (s *Styles) Apply(prompt, negative string, names ...string) (string, string, error) {
+	for _, name := range names {
+		st, ok := s.Get(name)
+		if !ok {
+			return "", "", fmt.Errorf("unknown style %q", name)
+		}
+		prompt = mergeStyle(prompt, st.Prompt)
+		negative = mergeStyle(negative, st.NegativePrompt)
+	}
+	return prompt, negative, nil
+}
+
+// ApplyTxt2Img applies the named styles to the prompts of opt. Without names, the styles of
+// opt.Styles are applied and removed so the server does not apply them again.
+func (s *Styles) ApplyTxt2Img(opt *sdcli.Txt2ImageOption, names ...string) error {
+	fromOpt := len(names) == 0
+	if fromOpt {
+		names = opt.Styles
+	}
+	p, n, err := s.Apply(opt.Prompt, opt.NegativePrompt, names...)
+	if err != nil {
+		return err
+	}
+	opt.Prompt, opt.NegativePrompt = p, n
+	if fromOpt {
+		opt.Styles = nil
+	}
+	return nil
+}
+
+// ApplyImg2Img is like ApplyTxt2Img for img2img options.
+func (s *Styles) ApplyImg2Img(opt *sdcli.Img2ImgOption, names ...string) error {
+	fromOpt := len(names) == 0
+	if fromOpt {
+		names = opt.Styles
+	}
+	p, n, err := s.Apply(opt.Prompt, opt.NegativePrompt, names...)
+	if err != nil {
+		return err
+	}
+	opt.Prompt, opt.NegativePrompt = p, n
+	if fromOpt {
+		opt.Styles = nil
+	}
+	return nil
+}
+
+func mergeStyle(prompt, style string) string {
+	if strings.Contains(style, StylePlaceholder) {
+		return strings.ReplaceAll(style, StylePlaceholder, prompt)
+	}
+	if len(style) == 0 {
+		return prompt
+	}
+	if len(prompt) == 0 {
+		return style
+	}
+	return prompt + ", " + style
+}
@@ -0,0 +1,150 @@
+// Package prompt helps building StableDiffusion prompts: templates, escaping and A1111 attention syntax.
+package prompt
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var placeholderRe = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Template is a prompt template, either a Go text/template (when it contains "{{")
+// or a simple one using {name} placeholders.
+type Template struct {
+	text string
+	tmpl *template.Template
+}
+
+// Parse parses a prompt template.
+func Parse(text string) (*Template, error) {
+	t := &Template{text: text}
+	if strings.Contains(text, "{{") {
+		tmpl, err := template.New("prompt").Option("missingkey=error").Funcs(template.FuncMap{
+			"escape": Escape,
+			"join":   strings.Join,
+		}).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prompt template: %w", err)
+		}
+		t.tmpl = tmpl
+	}
+
+	return t, nil
+}
+
+// MustParse is like Parse but panics on errors.
+func MustParse(text string) *Template {
+	t, err := Parse(text)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render substitutes vars into the template, values are inserted verbatim,
+// use Escape (or the escape template function) for user input.
+func (t *Template) Render(vars map[string]any) (string, error) {
+	if t.tmpl != nil {
+		buf := &bytes.Buffer{}
+		if err := t.tmpl.Execute(buf, vars); err != nil {
+			return "", fmt.Errorf("failed to render prompt template: %w", err)
+		}
+		return buf.String(), nil
+	}
+
+	var missing []string
+	res := placeholderRe.ReplaceAllStringFunc(t.text, func(s string) string {
+		name := s[1 : len(s)-1]
+		v, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+			return s
+		}
+		return fmt.Sprint(v)
+	})
+	if len(missing) != 0 {
+		return "", fmt.Errorf("missing prompt variables: %s", strings.Join(missing, ", "))
+	}
+
+	return res, nil
+}
+
+// Expand renders the template once for every combination of vars, see Combinations.
+func (t *Template) Expand(vars map[string]any) ([]string, error) {
+	combos := Combinations(vars)
+	res := make([]string, 0, len(combos))
+	for _, c := range combos {
+		s, err := t.Render(c)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+
+	return res, nil
+}
+
+// Combinations returns the cartesian product of all slice valued vars, each combination binds
+// every slice valued var to one of its elements while scalar vars are kept as is.
+// Vars are iterated in name order with the first name varying slowest, an empty slice yields no combinations.
+func Combinations(vars map[string]any) []map[string]any {
+	var (
+		lists = map[string][]any{}
+		names []string
+	)
+	for k, v := range vars {
+		rv := reflect.ValueOf(v)
+		if v == nil || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+			continue
+		}
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		lists[k] = items
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	res := []map[string]any{{}}
+	for k, v := range vars {
+		if _, ok := lists[k]; !ok {
+			res[0][k] = v
+		}
+	}
+
+	for _, name := range names {
+		next := make([]map[string]any, 0, len(res)*len(lists[name]))
+		for _, base := range res {
+			for _, item := range lists[name] {
+				c := make(map[string]any, len(base)+1)
+				for k, v := range base {
+					c[k] = v
+				}
+				c[name] = item
+				next = append(next, c)
+			}
+		}
+		res = next
+	}
+
+	return res
+}
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	`(`, `\(`,
+	`)`, `\)`,
+	`[`, `\[`,
+	`]`, `\]`,
+)
+
+// Escape escapes the attention syntax characters ()[] and backslashes so s is taken literally by the WebUI.
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,110 @@
+package prompt
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ChunkSize is the number of tokens of a CLIP chunk, WebUI splits longer prompts into
+// several chunks of 75 tokens, prompts should stay within a multiple of it.
+const ChunkSize = 75
+
+var (
+	extraNetworkRe = regexp.MustCompile(`<[^<>:]+:[^<>]*>`)
+	weightRe       = regexp.MustCompile(`:\s*-?[0-9]*\.?[0-9]+\s*([)\]])`)
+	breakRe        = regexp.MustCompile(`\bBREAK\b`)
+	// clipWordRe mirrors the pre-tokenizer pattern of the CLIP tokenizer.
+	clipWordRe = regexp.MustCompile(`'s|'t|'re|'ve|'m|'ll|'d|\p{L}+|\p{N}|[^\s\p{L}\p{N}]+`)
+)
+
+// StripAttention removes the attention syntax from a prompt, leaving the text CLIP tokenizes:
+// unescaped brackets and weights such as (word:1.2) or [word], and extra network tags
+// such as <lora:name:1>. Escaped brackets are kept as literal characters.
+func StripAttention(s string) string {
+	s = extraNetworkRe.ReplaceAllString(s, "")
+	s = weightRe.ReplaceAllString(s, "$1")
+
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if escaped {
+			b.WriteRune(r)
+			escaped = false
+			continue
+		}
+		switch r {
+		case '\\':
+			escaped = true
+		case '(', ')', '[', ']':
+		case ':', '|':
+			// Separators of prompt editing [from:to:when] and alternation [a|b].
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// CountTokens estimates the CLIP token count of a prompt as WebUI displays it, after
+// stripping the attention syntax. BREAK pads the current chunk, so the count of a prompt
+// with BREAK is at least a multiple of ChunkSize for every chunk before the last one.
+//
+// The count is an estimate: common words are single tokens while rare and long words
+// are split by the CLIP vocabulary, which is not embedded here.
+func CountTokens(prompt string) int {
+	total := 0
+	parts := breakRe.Split(prompt, -1)
+	for i, part := range parts {
+		n := countChunkTokens(StripAttention(part))
+		if i < len(parts)-1 {
+			n = TokenLimit(n)
+		}
+		total += n
+	}
+	return total
+}
+
+// TokenLimit returns the token limit WebUI displays for count tokens, 75, 150...
+func TokenLimit(count int) int {
+	if count <= 0 {
+		return ChunkSize
+	}
+	return (count + ChunkSize - 1) / ChunkSize * ChunkSize
+}
+
+// ExceedsChunk reports whether the prompt spills over limit tokens, e.g. ChunkSize.
+func ExceedsChunk(prompt string, limit int) bool {
+	return CountTokens(prompt) > limit
+}
+
+func countChunkTokens(s string) int {
+	n := 0
+	for _, w := range clipWordRe.FindAllString(strings.ToLower(s), -1) {
+		n += estimateWordTokens(w)
+	}
+	return n
+}
+
+// estimateWordTokens estimates the BPE tokens of a pre-tokenized word, words up to
+// 7 letters are usually in the vocabulary, longer ones are split every ~4 letters.
+func estimateWordTokens(w string) int {
+	r, _ := utf8.DecodeRuneInString(w)
+	if !unicode.IsLetter(r) {
+		// Digits are single tokens, punctuation runs mostly are.
+		return 1
+	}
+	if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) {
+		// CJK characters are byte level encoded and take about one token per byte pair.
+		return (len(w) + 1) / 2
+	}
+
+	l := utf8.RuneCountInString(w)
+	if l <= 7 {
+		return 1
+	}
+	return 1 + (l-7+3)/4
+}
@@ -0,0 +1,156 @@
+package prompt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// MaxWildcardDepth bounds the nesting of wildcards referencing other wildcards.
+const MaxWildcardDepth = 10
+
+var wildcardRe = regexp.MustCompile(`__([\w\-/.]+?)__`)
+
+// Wildcards resolves __name__ tokens from text files named name.txt, one option per line.
+// Empty lines and lines starting with # are ignored, options may contain further wildcards.
+type Wildcards struct {
+	fsys fs.FS
+
+	mu    sync.Mutex
+	cache map[string][]string
+}
+
+// NewWildcards creates Wildcards reading files from fsys, a wildcard __colors/warm__ reads colors/warm.txt.
+func NewWildcards(fsys fs.FS) *Wildcards {
+	return &Wildcards{
+		fsys:  fsys,
+		cache: map[string][]string{},
+	}
+}
+
+// NewWildcardsDir creates Wildcards reading files from a local directory.
+func NewWildcardsDir(dir string) *Wildcards {
+	return NewWildcards(os.DirFS(dir))
+}
+
+// Options returns the options of a wildcard.
+func (w *Wildcards) Options(name string) ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if opts, ok := w.cache[name]; ok {
+		return opts, nil
+	}
+
+	data, err := fs.ReadFile(w.fsys, path.Clean(name)+".txt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wildcard %s: %w", name, err)
+	}
+
+	var opts []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		opts = append(opts, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read wildcard %s: %w", name, err)
+	}
+	if len(opts) == 0 {
+		return nil, fmt.Errorf("wildcard %s has no options", name)
+	}
+	w.cache[name] = opts
+
+	return opts, nil
+}
+
+// Random replaces every wildcard in s with a random option chosen by rng,
+// pass a rand.New(rand.NewSource(seed)) for reproducible results.
+func (w *Wildcards) Random(s string, rng *rand.Rand) (string, error) {
+	return w.random(s, rng, 0)
+}
+
+func (w *Wildcards) random(s string, rng *rand.Rand, depth int) (string, error) {
+	if depth > MaxWildcardDepth {
+		return "", fmt.Errorf("wildcards nested deeper than %d", MaxWildcardDepth)
+	}
+
+	var (
+		b    strings.Builder
+		last int
+	)
+	for _, m := range wildcardRe.FindAllStringSubmatchIndex(s, -1) {
+		opts, err := w.Options(s[m[2]:m[3]])
+		if err != nil {
+			return "", err
+		}
+		opt, err := w.random(opts[rng.Intn(len(opts))], rng, depth+1)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(opt)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+
+	return b.String(), nil
+}
+
+// Exhaustive returns every combination of the wildcard options in s, in file order.
+// It fails when there are more than limit combinations, a non-positive limit means no limit.
+func (w *Wildcards) Exhaustive(s string, limit int) ([]string, error) {
+	return w.exhaustive(s, limit, 0)
+}
+
+func (w *Wildcards) exhaustive(s string, limit, depth int) ([]string, error) {
+	if depth > MaxWildcardDepth {
+		return nil, fmt.Errorf("wildcards nested deeper than %d", MaxWildcardDepth)
+	}
+
+	res := []string{""}
+	last := 0
+	for _, m := range wildcardRe.FindAllStringSubmatchIndex(s, -1) {
+		opts, err := w.Options(s[m[2]:m[3]])
+		if err != nil {
+			return nil, err
+		}
+
+		var expanded []string
+		for _, opt := range opts {
+			sub, err := w.exhaustive(opt, limit, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, sub...)
+		}
+
+		if limit > 0 && len(res)*len(expanded) > limit {
+			return nil, fmt.Errorf("wildcards expand to more than %d prompts", limit)
+		}
+		next := make([]string, 0, len(res)*len(expanded))
+		for _, prefix := range res {
+			for _, opt := range expanded {
+				next = append(next, prefix+s[last:m[0]]+opt)
+			}
+		}
+		res = next
+		last = m[1]
+	}
+
+	for i := range res {
+		res[i] += s[last:]
+	}
+
+	return res, nil
+}
@@ -0,0 +1,632 @@
+// Package queue runs generation jobs by priority against an API, such as a single
+// WebUI server or a pool of them, with operator controls to cancel and drain the queue.
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+	"github.com/shallowclouds/go-sd-webui-cli/sink"
+)
+
+var (
+	// ErrCanceled is the error of the jobs canceled by Cancel, CancelAll or Drain.
+	ErrCanceled = errors.New("job canceled")
+	// ErrClosed is returned by Submit once the queue is drained.
+	ErrClosed = errors.New("queue is closed")
+	// ErrKeyConflict is returned by Submit when the key of the request was submitted with
+	// different parameters.
+	ErrKeyConflict = errors.New("idempotency key reused with a different request")
+)
+
+// State is the state of a job.
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateRunning  State = "running"
+	StateDone     State = "done"
+	StateFailed   State = "failed"
+	StateCanceled State = "canceled"
+)
+
+// Priority orders the pending jobs, higher first and in submission order within a priority.
+type Priority int
+
+const (
+	PriorityBatch       Priority = -10
+	PriorityNormal      Priority = 0
+	PriorityInteractive Priority = 10
+)
+
+// Request is the generation of a job, exactly one of Txt2Img and Img2Img is set.
+type Request struct {
+	Txt2Img  *sdcli.Txt2ImageOption `json:"txt2img,omitempty"`
+	Img2Img  *sdcli.Img2ImgOption   `json:"img2img,omitempty"`
+	Priority Priority               `json:"priority,omitempty"`
+	// Key is an optional idempotency key, see Submit.
+	Key string `json:"key,omitempty"`
+	// Caller identifies the submitter for WithQuotas.
+	Caller string `json:"caller,omitempty"`
+}
+
+func (r *Request) validate() error {
+	if (r.Txt2Img == nil) == (r.Img2Img == nil) {
+		return errors.New("request needs exactly one of txt2img and img2img")
+	}
+	return nil
+}
+
+// Result is the output of a job.
+type Result struct {
+	Images [][]byte
+	Info   string
+	// Files are the names of the images in the sink of WithSink.
+	Files []string
+}
+
+// Job is a submitted request, its fields are read through the methods as they change while
+// the job runs.
+type Job struct {
+	ID      string
+	Request Request
+
+	mu        sync.Mutex
+	state     State
+	result    *Result
+	err       error
+	submitted time.Time
+	started   time.Time
+	finished  time.Time
+	cancel    context.CancelFunc
+	// preempted is set when the job is interrupted to run a higher priority one.
+	preempted   bool
+	preemptions int
+	done        chan struct{}
+}
+
+// State returns the state of the job.
+func (j *Job) State() State {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// Result returns the result and error of a finished job.
+func (j *Job) Result() (*Result, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.result, j.err
+}
+
+// Times returns when the job was submitted, started and finished, zero until then.
+func (j *Job) Times() (submitted, started, finished time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.submitted, j.started, j.finished
+}
+
+// Done is closed when the job is finished.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Wait waits for the job to finish and returns its result.
+func (j *Job) Wait(ctx context.Context) (*Result, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-j.done:
+		return j.Result()
+	}
+}
+
+// finish records the outcome unless the job already finished, e.g. canceled while pending.
+func (j *Job) finish(state State, res *Result, err error) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.finished.IsZero() {
+		return false
+	}
+	j.state, j.result, j.err, j.finished = state, res, err, time.Now()
+	close(j.done)
+	return true
+}
+
+// Option configures the Queue.
+type Option func(q *Queue)
+
+// WithWorkers sets how many jobs run at the same time, 1 by default as WebUI runs one job at a
+// time. Use the number of backends for a pool.
+func WithWorkers(n int) Option {
+	return func(q *Queue) {
+		if n > 0 {
+			q.workers = n
+		}
+	}
+}
+
+// WithPreemption cancels the running job with the lowest priority when a job of a higher
+// priority is submitted while all workers are busy, the canceled job goes back to the front of
+// its priority. The server only stops the job when the clients of the API interrupt canceled
+// requests with sdcli.WithInterruptOnCancel; WebUI interrupts whatever runs on the server, so
+// only use it when the queue is the only client of the backends.
+func WithPreemption() Option {
+	return func(q *Queue) {
+		q.preempt = true
+	}
+}
+
+// WithSink writes the images of the jobs to s as <job ID>/<index>.<format>, listed in the
+// Files of the results.
+func WithSink(s sink.Sink) Option {
+	return func(q *Queue) {
+		q.sink = s
+	}
+}
+
+// DefaultRetention is how long finished jobs are kept in memory by default.
+const DefaultRetention = 24 * time.Hour
+
+// WithRetention keeps the finished jobs, their images included, available with Get for d
+// (DefaultRetention by default, forever if not positive). Older ones are dropped along with
+// their idempotency keys, the Store of WithStore still has their records. Stats and the daily
+// quotas only count the jobs kept, which the default covers for the current day.
+func WithRetention(d time.Duration) Option {
+	return func(q *Queue) {
+		q.retention = d
+	}
+}
+
+// Queue runs the submitted jobs by priority once Run is called.
+type Queue struct {
+	api       sdcli.API
+	workers   int
+	preempt   bool
+	sink      sink.Sink
+	store     Store
+	quotas    *Quotas
+	retention time.Duration
+
+	mu      sync.Mutex
+	pending []*Job
+	running map[string]*Job
+	jobs    map[string]*Job
+	keys    map[string]*Job
+	// finished are the finished jobs kept, in finishing order.
+	finished []*Job
+	closed   bool
+	// wake is signaled when jobs are submitted or the queue is closed.
+	wake chan struct{}
+	// idle is closed and replaced whenever a running job finishes.
+	idle chan struct{}
+}
+
+// New creates a queue running jobs against api.
+func New(api sdcli.API, opts ...Option) *Queue {
+	q := &Queue{
+		api:       api,
+		workers:   1,
+		retention: DefaultRetention,
+		running:   map[string]*Job{},
+		jobs:      map[string]*Job{},
+		keys:      map[string]*Job{},
+		wake:      make(chan struct{}, 1),
+		idle:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// Submit adds a job to the queue, after the pending jobs of the same or a higher priority.
+//
+// A request with the Key of a pending, running or done job returns that job instead of
+// generating again, so retried submissions are safe; the request must have the same parameters
+// or ErrKeyConflict is returned. A failed or canceled job is replaced by a new one.
+func (q *Queue) Submit(req Request) (*Job, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.evict(time.Now())
+	if j, ok := q.keys[req.Key]; ok && len(req.Key) != 0 {
+		if state := j.State(); state != StateFailed && state != StateCanceled {
+			if !sameParams(&j.Request, &req) {
+				return nil, ErrKeyConflict
+			}
+			return j, nil
+		}
+	}
+	if q.closed {
+		return nil, ErrClosed
+	}
+	if err := q.checkQuota(&req); err != nil {
+		return nil, err
+	}
+
+	j := &Job{
+		ID:        newID(),
+		Request:   req,
+		state:     StatePending,
+		submitted: time.Now(),
+		done:      make(chan struct{}),
+	}
+	if err := q.save(j); err != nil {
+		return nil, err
+	}
+	q.jobs[j.ID] = j
+	if len(req.Key) != 0 {
+		q.keys[req.Key] = j
+	}
+	q.enqueue(j, false)
+	q.signal()
+	if q.preempt {
+		q.preemptFor(j)
+	}
+
+	return j, nil
+}
+
+// sameParams reports whether a and b generate the same images, whatever their priority.
+func sameParams(a, b *Request) bool {
+	encode := func(r *Request) []byte {
+		data, _ := json.Marshal([]any{r.Txt2Img, r.Img2Img})
+		return data
+	}
+	return bytes.Equal(encode(a), encode(b))
+}
+
+// enqueue inserts j into the pending jobs after the jobs of the same or a higher priority, or
+// before those of the same priority when front is set.
+func (q *Queue) enqueue(j *Job, front bool) {
+	i := 0
+	for ; i < len(q.pending); i++ {
+		p := q.pending[i].Request.Priority
+		if p < j.Request.Priority || front && p == j.Request.Priority {
+			break
+		}
+	}
+	q.pending = append(q.pending, nil)
+	copy(q.pending[i+1:], q.pending[i:])
+	q.pending[i] = j
+}
+
+// preemptFor interrupts the running job with the lowest priority below the one of j when all
+// workers are busy.
+func (q *Queue) preemptFor(j *Job) {
+	if len(q.running) < q.workers {
+		return
+	}
+
+	var victim *Job
+	for _, r := range q.running {
+		r.mu.Lock()
+		preempted := r.preempted
+		r.mu.Unlock()
+		if r.Request.Priority >= j.Request.Priority || preempted {
+			continue
+		}
+		if victim == nil || r.Request.Priority < victim.Request.Priority {
+			victim = r
+		}
+	}
+	if victim == nil {
+		return
+	}
+
+	victim.mu.Lock()
+	victim.preempted = true
+	cancel := victim.cancel
+	victim.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// Get returns a job by ID.
+func (q *Queue) Get(id string) (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	return j, ok
+}
+
+// Len returns the number of pending and running jobs.
+func (q *Queue) Len() (pending, running int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending), len(q.running)
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs the jobs until ctx is done or the queue is drained, jobs running when ctx is done
+// are canceled.
+func (q *Queue) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		j, closed := q.next()
+		if j != nil {
+			q.run(ctx, j)
+			continue
+		}
+		if closed {
+			// Let the other workers see the queue is closed too.
+			q.signal()
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		}
+	}
+}
+
+// next pops the next pending job and marks it running.
+func (q *Queue) next() (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return nil, q.closed
+	}
+	j := q.pending[0]
+	q.pending = q.pending[1:]
+	q.running[j.ID] = j
+	if len(q.pending) != 0 {
+		q.signal()
+	}
+	return j, false
+}
+
+func (q *Queue) run(ctx context.Context, j *Job) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	j.mu.Lock()
+	j.state, j.started, j.cancel = StateRunning, time.Now(), cancel
+	if j.preempted {
+		// Preempted between leaving the pending jobs and starting.
+		cancel()
+	}
+	j.mu.Unlock()
+	_ = q.save(j)
+
+	res, err := q.generate(ctx, j)
+
+	j.mu.Lock()
+	requeue := j.preempted && (err != nil || ctx.Err() != nil)
+	j.preempted = false
+	if requeue {
+		j.state, j.started, j.cancel = StatePending, time.Time{}, nil
+		j.preemptions++
+	}
+	j.mu.Unlock()
+
+	q.mu.Lock()
+	delete(q.running, j.ID)
+	close(q.idle)
+	q.idle = make(chan struct{})
+	requeue = requeue && !q.closed
+	if requeue {
+		q.enqueue(j, true)
+		q.signal()
+	}
+	q.mu.Unlock()
+
+	var finished bool
+	switch {
+	case requeue:
+	case ctx.Err() != nil:
+		// Interrupted jobs answer the partial images, they still count as canceled.
+		finished = j.finish(StateCanceled, nil, ErrCanceled)
+	case err != nil:
+		finished = j.finish(StateFailed, nil, err)
+	default:
+		finished = j.finish(StateDone, res, nil)
+	}
+	_ = q.save(j)
+	if finished {
+		q.retire(j)
+	}
+}
+
+// retire adds a finished job to the jobs evicted after the retention.
+func (q *Queue) retire(j *Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.retention > 0 {
+		q.finished = append(q.finished, j)
+	}
+	q.evict(time.Now())
+}
+
+// evict drops the jobs finished longer than the retention ago, q.mu must be held.
+func (q *Queue) evict(now time.Time) {
+	if q.retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-q.retention)
+	for len(q.finished) != 0 {
+		j := q.finished[0]
+		if _, _, finished := j.Times(); finished.After(cutoff) {
+			return
+		}
+		delete(q.jobs, j.ID)
+		if key := j.Request.Key; len(key) != 0 && q.keys[key] == j {
+			delete(q.keys, key)
+		}
+		q.finished[0] = nil
+		q.finished = q.finished[1:]
+	}
+}
+
+func (q *Queue) generate(ctx context.Context, j *Job) (*Result, error) {
+	res := new(Result)
+	if req := j.Request; req.Txt2Img != nil {
+		r, err := q.api.Txt2Img(ctx, *req.Txt2Img)
+		if err != nil {
+			return nil, err
+		}
+		res.Images, res.Info = r.RawImages, r.Info
+	} else {
+		r, err := q.api.Img2Img(ctx, *req.Img2Img)
+		if err != nil {
+			return nil, err
+		}
+		res.Images, res.Info = r.RawImages, r.Info
+	}
+
+	if q.sink == nil {
+		return res, nil
+	}
+	for i, data := range res.Images {
+		format := sdcli.DetectFormat(data)
+		if format == sdcli.FormatUnknown {
+			format = "bin"
+		}
+		name := fmt.Sprintf("%s/%d.%s", j.ID, i, format)
+		meta := sink.Metadata{sink.MetaContentType: sink.ContentType(format)}
+		if err := q.sink.Put(ctx, name, bytes.NewReader(data), meta); err != nil {
+			return nil, fmt.Errorf("failed to store image %d: %w", i, err)
+		}
+		res.Files = append(res.Files, name)
+	}
+	return res, nil
+}
+
+// Cancel cancels a pending or running job, see interrupt for the running one.
+func (q *Queue) Cancel(ctx context.Context, id string) error {
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return fmt.Errorf("unknown job %s", id)
+	}
+	for i, p := range q.pending {
+		if p == j {
+			q.pending = append(q.pending[:i:i], q.pending[i+1:]...)
+			break
+		}
+	}
+	_, running := q.running[id]
+	q.mu.Unlock()
+
+	if !running {
+		if j.finish(StateCanceled, nil, ErrCanceled) {
+			_ = q.save(j)
+			q.retire(j)
+		}
+		return nil
+	}
+	q.interrupt([]*Job{j})
+	return nil
+}
+
+// CancelAll cancels the pending jobs and interrupts the running ones, the queue keeps
+// accepting jobs.
+func (q *Queue) CancelAll(ctx context.Context) error {
+	q.mu.Lock()
+	pending := q.pending
+	q.pending = nil
+	running := q.runningJobs()
+	q.mu.Unlock()
+
+	for _, j := range pending {
+		if j.finish(StateCanceled, nil, ErrCanceled) {
+			_ = q.save(j)
+			q.retire(j)
+		}
+	}
+	q.interrupt(running)
+	return nil
+}
+
+// Drain closes the queue, rejecting new jobs with ErrClosed, cancels the pending jobs and
+// interrupts the running ones, then waits for them to return until ctx is done. Run returns
+// once the queue is drained.
+func (q *Queue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+
+	err := q.CancelAll(ctx)
+	for {
+		q.mu.Lock()
+		n, idle := len(q.running), q.idle
+		q.mu.Unlock()
+		if n == 0 {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-idle:
+		}
+	}
+}
+
+func (q *Queue) runningJobs() []*Job {
+	jobs := make([]*Job, 0, len(q.running))
+	for _, j := range q.running {
+		jobs = append(jobs, j)
+	}
+	return jobs
+}
+
+// interrupt cancels the contexts of running jobs. The server is not interrupted directly, as
+// API.Interrupt reaches every backend of a pool and the jobs of the other workers would return
+// their partial images as done; the clients stop their own backend with
+// sdcli.WithInterruptOnCancel instead.
+func (q *Queue) interrupt(jobs []*Job) {
+	for _, j := range jobs {
+		j.mu.Lock()
+		cancel := j.cancel
+		j.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+	}
+}
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
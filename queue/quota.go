@@ -0,0 +1,156 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQuotaExceeded is matched by the QuotaError of Submit.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota limits the jobs of a caller, zero fields are unlimited.
+type Quota struct {
+	// MaxConcurrent is the most pending and running jobs of the caller.
+	MaxConcurrent int
+	// DailyImages is the most images the caller requests per day, counting the batches of the
+	// jobs submitted since midnight local time, except the failed and canceled ones.
+	DailyImages int
+	// MaxShare is the largest fraction of the pending jobs the caller may hold, between 0 and 1.
+	// It only applies while other callers have pending jobs, so a single caller can fill an idle
+	// queue.
+	MaxShare float64
+}
+
+// Quotas are the quotas of the callers, by the Caller of the requests.
+type Quotas struct {
+	// Default is the quota of the callers not in Callers, including the requests without a Caller.
+	Default Quota
+	Callers map[string]Quota
+}
+
+// For returns the quota of caller.
+func (qs *Quotas) For(caller string) Quota {
+	if quota, ok := qs.Callers[caller]; ok {
+		return quota
+	}
+	return qs.Default
+}
+
+// WithQuotas enforces quotas on Submit, which fails with a QuotaError for the requests over
+// the quota of their Caller.
+func WithQuotas(quotas Quotas) Option {
+	return func(q *Queue) {
+		q.quotas = &quotas
+	}
+}
+
+// QuotaError is the error of a request over the quota of its caller.
+type QuotaError struct {
+	Caller string
+	// Limit is the exceeded field of the Quota.
+	Limit string
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("caller %q exceeded its quota of %s", e.Caller, e.Limit)
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QuotaState is the usage and remaining quota of a caller, the remaining counts are -1 when
+// unlimited.
+type QuotaState struct {
+	Caller string `json:"caller"`
+	Quota  Quota  `json:"quota"`
+	// Active are the pending and running jobs of the caller.
+	Active int `json:"active"`
+	// Pending are the pending jobs of the caller, out of TotalPending.
+	Pending      int `json:"pending"`
+	TotalPending int `json:"total_pending"`
+	// Images are the images requested today.
+	Images int `json:"images"`
+
+	RemainingConcurrent int `json:"remaining_concurrent"`
+	RemainingImages     int `json:"remaining_images"`
+	// Reset is when the daily image count starts over.
+	Reset time.Time `json:"reset"`
+}
+
+// Quota returns the quota state of caller, with the zero Quota when WithQuotas is not set.
+func (q *Queue) Quota(caller string) *QuotaState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.quotaState(caller, time.Now())
+}
+
+func (q *Queue) quotaState(caller string, now time.Time) *QuotaState {
+	st := &QuotaState{Caller: caller}
+	if q.quotas != nil {
+		st.Quota = q.quotas.For(caller)
+	}
+	y, m, d := now.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	st.Reset = midnight.AddDate(0, 0, 1)
+
+	st.TotalPending = len(q.pending)
+	for _, j := range q.pending {
+		if j.Request.Caller == caller {
+			st.Pending++
+		}
+	}
+	for _, j := range q.jobs {
+		if j.Request.Caller != caller {
+			continue
+		}
+		state := j.State()
+		if state == StatePending || state == StateRunning {
+			st.Active++
+		}
+		if state != StateFailed && state != StateCanceled && !j.submitted.Before(midnight) {
+			st.Images += j.Request.images()
+		}
+	}
+
+	st.RemainingConcurrent, st.RemainingImages = -1, -1
+	if st.Quota.MaxConcurrent > 0 {
+		st.RemainingConcurrent = max(st.Quota.MaxConcurrent-st.Active, 0)
+	}
+	if st.Quota.DailyImages > 0 {
+		st.RemainingImages = max(st.Quota.DailyImages-st.Images, 0)
+	}
+	return st
+}
+
+// checkQuota returns the QuotaError of req if it is over the quota of its caller.
+func (q *Queue) checkQuota(req *Request) error {
+	if q.quotas == nil {
+		return nil
+	}
+	st := q.quotaState(req.Caller, time.Now())
+
+	switch {
+	case st.RemainingConcurrent == 0:
+		return &QuotaError{Caller: req.Caller, Limit: fmt.Sprintf("%d concurrent jobs", st.Quota.MaxConcurrent)}
+	case st.RemainingImages >= 0 && req.images() > st.RemainingImages:
+		return &QuotaError{Caller: req.Caller, Limit: fmt.Sprintf("%d daily images, %d left", st.Quota.DailyImages, st.RemainingImages)}
+	case st.Quota.MaxShare > 0 && st.TotalPending > st.Pending &&
+		float64(st.Pending+1) > st.Quota.MaxShare*float64(st.TotalPending+1):
+		return &QuotaError{Caller: req.Caller, Limit: fmt.Sprintf("%.0f%% of the queue", st.Quota.MaxShare*100)}
+	}
+	return nil
+}
+
+// images is the number of images req generates.
+func (r *Request) images() int {
+	batch, iter := 1, 1
+	switch {
+	case r.Txt2Img != nil:
+		batch, iter = r.Txt2Img.BatchSize, r.Txt2Img.NIter
+	case r.Img2Img != nil:
+		batch, iter = r.Img2Img.BatchSize, r.Img2Img.NIter
+	}
+	return max(batch, 1) * max(iter, 1)
+}
@@ -0,0 +1,252 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Stats are aggregated over the jobs finished in a period, for capacity planning.
+type Stats struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+
+	// Pending and Running are the current jobs, whatever the period.
+	Pending int `json:"pending"`
+	Running int `json:"running"`
+
+	Finished int `json:"finished"`
+	Done     int `json:"done"`
+	Failed   int `json:"failed"`
+	Canceled int `json:"canceled"`
+	// SuccessRate is Done over Done and Failed, canceled jobs are not counted.
+	SuccessRate float64 `json:"success_rate"`
+	// Interrupts are the running jobs stopped by a cancel or to run a higher priority job.
+	Interrupts int `json:"interrupts"`
+	Images     int `json:"images"`
+
+	// Wait is the time the done jobs spent pending.
+	Wait Latency `json:"wait"`
+	// Resolutions are the generation latencies of the done jobs by image size, largest first.
+	Resolutions []*ResolutionStats `json:"resolutions"`
+	// Models is the usage of the checkpoints, most used first.
+	Models []*ModelStats `json:"models"`
+}
+
+// Latency is the distribution of durations.
+type Latency struct {
+	Count int           `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	Max   time.Duration `json:"max"`
+}
+
+// ResolutionStats is the generation latency of the jobs of an image size.
+type ResolutionStats struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	Latency
+}
+
+// ModelStats is the usage of a checkpoint, reported by the generation info or requested in the
+// override settings.
+type ModelStats struct {
+	Model  string `json:"model"`
+	Jobs   int    `json:"jobs"`
+	Failed int    `json:"failed"`
+	Images int    `json:"images"`
+	// Time is the total generation time.
+	Time time.Duration `json:"time"`
+}
+
+// Stats aggregates the jobs finished since since, all of them if zero.
+func (q *Queue) Stats(since time.Time) *Stats {
+	q.mu.Lock()
+	jobs := make([]*Job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		jobs = append(jobs, j)
+	}
+	st := &Stats{
+		Since:   since,
+		Until:   time.Now(),
+		Pending: len(q.pending),
+		Running: len(q.running),
+	}
+	q.mu.Unlock()
+
+	var (
+		wait   []time.Duration
+		sizes  = map[[2]int][]time.Duration{}
+		models = map[string]*ModelStats{}
+	)
+	for _, j := range jobs {
+		j.mu.Lock()
+		state, res, preemptions := j.state, j.result, j.preemptions
+		submitted, started, finished := j.submitted, j.started, j.finished
+		j.mu.Unlock()
+		if finished.IsZero() || finished.Before(since) {
+			continue
+		}
+
+		st.Finished++
+		st.Interrupts += preemptions
+		model := j.Request.model(res)
+		m, ok := models[model]
+		if !ok {
+			m = &ModelStats{Model: model}
+			models[model] = m
+		}
+		switch state {
+		case StateDone:
+			st.Done++
+			took := finished.Sub(started)
+			// Resumed jobs only have the files of their images.
+			images := max(len(res.Images), len(res.Files))
+			st.Images += images
+			wait = append(wait, started.Sub(submitted))
+			size := j.Request.size()
+			sizes[size] = append(sizes[size], took)
+			m.Jobs++
+			m.Images += images
+			m.Time += took
+		case StateFailed:
+			st.Failed++
+			m.Jobs++
+			m.Failed++
+		case StateCanceled:
+			st.Canceled++
+			if !started.IsZero() {
+				st.Interrupts++
+			}
+			if m.Jobs == 0 {
+				delete(models, model)
+			}
+		}
+	}
+
+	if st.Done+st.Failed != 0 {
+		st.SuccessRate = float64(st.Done) / float64(st.Done+st.Failed)
+	}
+	st.Wait = latency(wait)
+	for size, durations := range sizes {
+		st.Resolutions = append(st.Resolutions, &ResolutionStats{Width: size[0], Height: size[1], Latency: latency(durations)})
+	}
+	sort.Slice(st.Resolutions, func(i, j int) bool {
+		a, b := st.Resolutions[i], st.Resolutions[j]
+		if a.Width*a.Height != b.Width*b.Height {
+			return a.Width*a.Height > b.Width*b.Height
+		}
+		return a.Width > b.Width
+	})
+	for _, m := range models {
+		st.Models = append(st.Models, m)
+	}
+	sort.Slice(st.Models, func(i, j int) bool {
+		if st.Models[i].Jobs != st.Models[j].Jobs {
+			return st.Models[i].Jobs > st.Models[j].Jobs
+		}
+		return st.Models[i].Model < st.Models[j].Model
+	})
+
+	return st
+}
+
+// latency returns the distribution of durations, using the nearest rank percentiles.
+func latency(durations []time.Duration) Latency {
+	l := Latency{Count: len(durations)}
+	if l.Count == 0 {
+		return l
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	rank := func(p float64) time.Duration {
+		return durations[int(math.Ceil(p*float64(l.Count)))-1]
+	}
+	l.Mean = total / time.Duration(l.Count)
+	l.P50, l.P95, l.Max = rank(0.5), rank(0.95), durations[l.Count-1]
+	return l
+}
+
+// size is the requested image size, with the WebUI default of 512 for unset dimensions and the
+// hires fix applied.
+func (r *Request) size() [2]int {
+	var width, height int
+	if r.Txt2Img != nil {
+		width, height = r.Txt2Img.Width, r.Txt2Img.Height
+	} else {
+		width, height = r.Img2Img.Width, r.Img2Img.Height
+	}
+	if width == 0 {
+		width = 512
+	}
+	if height == 0 {
+		height = 512
+	}
+	if opt := r.Txt2Img; opt != nil && opt.EnableHR {
+		switch {
+		case opt.HrResizeX != 0 && opt.HrResizeY != 0:
+			width, height = opt.HrResizeX, opt.HrResizeY
+		case opt.HRScale > 0:
+			width, height = int(float32(width)*opt.HRScale), int(float32(height)*opt.HRScale)
+		}
+	}
+	return [2]int{width, height}
+}
+
+// model returns the checkpoint of the generation info of res, or the one requested in the
+// override settings.
+func (r *Request) model(res *Result) string {
+	if res != nil && len(res.Info) != 0 {
+		if info, err := sdcli.ParseInfo(res.Info); err == nil && len(info.SDModelName) != 0 {
+			return info.SDModelName
+		}
+	}
+	overrides := sdcli.Overrides(nil)
+	if r.Txt2Img != nil {
+		overrides = r.Txt2Img.OverrideSettings
+	} else if r.Img2Img != nil {
+		overrides = r.Img2Img.OverrideSettings
+	}
+	if name, ok := overrides["sd_model_checkpoint"].(string); ok {
+		return name
+	}
+	return ""
+}
+
+// StatsHandler serves the Stats as JSON, over the period of the since query parameter such as
+// ?since=1h, or all the jobs without it.
+func (q *Queue) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var since time.Time
+		if v := r.URL.Query().Get("since"); len(v) != 0 {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				http.Error(w, fmt.Sprintf("invalid since %q", v), http.StatusBadRequest)
+				return
+			}
+			since = time.Now().Add(-d)
+		}
+
+		data, err := json.Marshal(q.Stats(since))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
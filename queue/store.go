@@ -0,0 +1,203 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Record is the persisted state of a job.
+type Record struct {
+	ID        string    `json:"id"`
+	Request   Request   `json:"request"`
+	State     State     `json:"state"`
+	Error     string    `json:"error,omitempty"`
+	Info      string    `json:"info,omitempty"`
+	Files     []string  `json:"files,omitempty"`
+	Submitted time.Time `json:"submitted"`
+	Started   time.Time `json:"started,omitempty"`
+	Finished  time.Time `json:"finished,omitempty"`
+}
+
+// Store persists the jobs of a queue so they survive restarts, see WithStore and Resume.
+type Store interface {
+	Put(rec *Record) error
+	List() ([]*Record, error)
+}
+
+// WithStore saves the jobs to store when they are submitted, started and finished. A failed
+// save on start or finish keeps the previous state, so at worst the job is run again on Resume.
+func WithStore(store Store) Option {
+	return func(q *Queue) {
+		q.store = store
+	}
+}
+
+// record returns the persisted state of j.
+func (j *Job) record() *Record {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	rec := &Record{
+		ID:        j.ID,
+		Request:   j.Request,
+		State:     j.state,
+		Submitted: j.submitted,
+		Started:   j.started,
+		Finished:  j.finished,
+	}
+	if j.err != nil {
+		rec.Error = j.err.Error()
+	}
+	if j.result != nil {
+		rec.Info, rec.Files = j.result.Info, j.result.Files
+	}
+	return rec
+}
+
+func (q *Queue) save(j *Job) error {
+	if q.store == nil {
+		return nil
+	}
+	if err := q.store.Put(j.record()); err != nil {
+		return fmt.Errorf("failed to save job %s: %w", j.ID, err)
+	}
+	return nil
+}
+
+// Resume loads the jobs of the store, call it before Run. Finished jobs within the retention of
+// WithRetention are available with Get, their results holding the files written to the sink. Unfinished jobs, including the ones
+// running when the process stopped, are submitted again in their original order.
+func (q *Queue) Resume() ([]*Job, error) {
+	if q.store == nil {
+		return nil, nil
+	}
+	recs, err := q.store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jobs: %w", err)
+	}
+	sortRecords(recs)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var resumed []*Job
+	for _, rec := range recs {
+		if _, ok := q.jobs[rec.ID]; ok {
+			continue
+		}
+		j := &Job{
+			ID:        rec.ID,
+			Request:   rec.Request,
+			state:     rec.State,
+			submitted: rec.Submitted,
+			done:      make(chan struct{}),
+		}
+		q.jobs[j.ID] = j
+		if len(rec.Request.Key) != 0 {
+			q.keys[rec.Request.Key] = j
+		}
+
+		switch rec.State {
+		case StateDone, StateFailed, StateCanceled:
+			j.started, j.finished = rec.Started, rec.Finished
+			if len(rec.Error) != 0 {
+				j.err = &StoredError{Msg: rec.Error}
+			}
+			if rec.State == StateDone {
+				j.result = &Result{Info: rec.Info, Files: rec.Files}
+			}
+			close(j.done)
+			if q.retention > 0 {
+				q.finished = append(q.finished, j)
+			}
+		default:
+			j.state = StatePending
+			q.enqueue(j, false)
+			resumed = append(resumed, j)
+		}
+	}
+	sort.SliceStable(q.finished, func(a, b int) bool {
+		_, _, fa := q.finished[a].Times()
+		_, _, fb := q.finished[b].Times()
+		return fa.Before(fb)
+	})
+	q.evict(time.Now())
+	if len(resumed) != 0 {
+		q.signal()
+	}
+
+	return resumed, nil
+}
+
+// sortRecords orders records by submission.
+func sortRecords(recs []*Record) {
+	sort.SliceStable(recs, func(i, j int) bool {
+		return recs[i].Submitted.Before(recs[j].Submitted)
+	})
+}
+
+// StoredError is the error of a job finished before a restart, only its message is kept.
+type StoredError struct {
+	Msg string
+}
+
+func (e *StoredError) Error() string {
+	return e.Msg
+}
+
+var boltBucket = []byte("jobs")
+
+// BoltStore is a Store in a bbolt database file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens or creates the database file at path, the caller should call Close.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job store: %w", err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init job store: %w", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Put(rec *Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(rec.ID), data)
+	})
+}
+
+func (s *BoltStore) List() ([]*Record, error) {
+	var recs []*Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).ForEach(func(k, v []byte) error {
+			rec := new(Record)
+			if err := json.Unmarshal(v, rec); err != nil {
+				return fmt.Errorf("invalid job %s: %w", k, err)
+			}
+			recs = append(recs, rec)
+			return nil
+		})
+	})
+	return recs, err
+}
+
+// Close closes the database.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,193 @@
+package sdcli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrResultLost is returned when the connection of a generation dropped and the task finished
+// on the server, but no ResultRecoverer could retrieve its images.
+var ErrResultLost = errors.New("generation finished on the server but its result was lost")
+
+// Defaults of Recovery.
+const (
+	DefaultRecoveryPollInterval = time.Second
+	DefaultRecoveryTimeout      = 10 * time.Minute
+)
+
+// ResultRecoverer retrieves the images and generation info of a finished task, such as the
+// agentscheduler extension client.
+type ResultRecoverer interface {
+	RecoverResult(ctx context.Context, taskID string) (images []string, info string, err error)
+}
+
+// Recovery configures WithRecovery.
+type Recovery struct {
+	// PollInterval is how often the task progress is polled, DefaultRecoveryPollInterval if 0.
+	PollInterval time.Duration
+	// Timeout bounds the wait for the task to finish, DefaultRecoveryTimeout if 0.
+	Timeout time.Duration
+	// Results retrieves the result of the finished task, without it the generation fails with
+	// ErrResultLost.
+	Results ResultRecoverer
+}
+
+// WithRecovery re-attaches to txt2img and img2img generations whose connection drops while the
+// server keeps working on them, e.g. behind proxies closing idle connections. The requests are
+// sent with a force_task_id, and on a dropped connection the task is followed through
+// /internal/progress until it finishes and its result is retrieved by r.Results. Tasks unknown
+// to the server never started, their error is returned as is so the caller can retry.
+//
+// Dropped generation requests are not resubmitted by WithRetry, which would run them twice.
+func WithRecovery(r Recovery) Option {
+	return func(c *Client) {
+		if r.PollInterval <= 0 {
+			r.PollInterval = DefaultRecoveryPollInterval
+		}
+		if r.Timeout <= 0 {
+			r.Timeout = DefaultRecoveryTimeout
+		}
+		c.recovery = &r
+	}
+}
+
+// TaskProgress is the progress of a task of the WebUI interface.
+type TaskProgress struct {
+	Active    bool    `json:"active"`
+	Queued    bool    `json:"queued"`
+	Completed bool    `json:"completed"`
+	Progress  float32 `json:"progress"`
+	ETA       float32 `json:"eta"`
+	TextInfo  string  `json:"textinfo"`
+}
+
+// GetTaskProgress returns the progress of the task taskID through the /internal/progress
+// endpoint of the interface, which tracks the force_task_id of API requests. Unknown tasks are
+// neither active, queued nor completed.
+func (c *Client) GetTaskProgress(ctx context.Context, taskID string) (*TaskProgress, error) {
+	body := map[string]any{"id_task": taskID, "id_live_preview": -1, "live_preview": false}
+	res := new(TaskProgress)
+	if err := c.do(ctx, "/internal/progress", http.MethodPost, body, http.StatusOK, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// NewTaskID returns a random task ID in the format of the WebUI interface.
+func NewTaskID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "task(" + hex.EncodeToString(b) + ")"
+}
+
+// taskBody adds the force_task_id field to the JSON of a generation payload.
+type taskBody struct {
+	payload any
+	taskID  string
+}
+
+func (b *taskBody) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(b.payload)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := json.Marshal(b.taskID)
+	out := append([]byte(`{"force_task_id":`), id...)
+	if len(data) > 2 {
+		out = append(out, ',')
+	}
+	return append(out, data[1:]...), nil
+}
+
+// dialError reports whether err failed to connect, the request was not sent.
+func dialError(err error) bool {
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
+
+// dropped reports whether err is a connection lost after the request was sent.
+func dropped(ctx context.Context, err error) bool {
+	var e *Error
+	if ctx.Err() != nil || !errors.As(err, &e) || e.Err == nil || dialError(e.Err) {
+		return false
+	}
+	if e.Response != nil && e.Response.StatusCode != http.StatusOK {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(e.Err, &netErr) || errors.Is(e.Err, io.EOF) || errors.Is(e.Err, io.ErrUnexpectedEOF) ||
+		errors.Is(e.Err, syscall.ECONNRESET)
+}
+
+// generateRecoverable sends a generation request with a task ID and recovers its result into
+// result if the connection drops.
+func (c *Client) generateRecoverable(ctx context.Context, path string, body, result any) error {
+	taskID := NewTaskID()
+	err := c.doReq(ctx, path, http.MethodPost, &taskBody{payload: body, taskID: taskID}, http.StatusOK, result)
+	if err == nil || !dropped(ctx, err) {
+		return err
+	}
+
+	images, info, rerr := c.recoverTask(ctx, taskID)
+	if rerr != nil {
+		if errors.Is(rerr, errTaskUnknown) {
+			return err
+		}
+		return rerr
+	}
+	data, merr := json.Marshal(map[string]any{"images": images, "info": info})
+	if merr != nil {
+		return wrapError(merr, nil, "failed to encode recovered result")
+	}
+	if uerr := json.Unmarshal(data, result); uerr != nil {
+		return wrapError(uerr, nil, "failed to decode recovered result")
+	}
+	return nil
+}
+
+var errTaskUnknown = errors.New("task unknown to the server")
+
+// recoverTask waits for the task to finish and retrieves its result.
+func (c *Client) recoverTask(ctx context.Context, taskID string) ([]string, string, error) {
+	r := c.recovery
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		// The server may still be unreachable, keep polling until the timeout.
+		p, err := c.GetTaskProgress(ctx, taskID)
+		if err == nil {
+			if p.Completed {
+				break
+			}
+			if !p.Active && !p.Queued {
+				return nil, "", errTaskUnknown
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, "", wrapError(ctx.Err(), nil, "failed to recover %s", taskID)
+		case <-ticker.C:
+		}
+	}
+
+	if r.Results == nil {
+		return nil, "", wrapError(ErrResultLost, nil, "%s finished", taskID)
+	}
+	images, info, err := r.Results.RecoverResult(ctx, taskID)
+	if err != nil {
+		return nil, "", wrapError(errors.Join(ErrResultLost, err), nil, "failed to retrieve the result of %s", taskID)
+	}
+	return images, info, nil
+}
@@ -0,0 +1,130 @@
+package sdcli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// DefaultRequestIDHeader is the header carrying the ID of every request.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// WithRequestIDHeader sets the header carrying request IDs, an empty name disables them.
+func WithRequestIDHeader(name string) Option {
+	return func(c *Client) {
+		c.requestIDHeader = name
+	}
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID makes the requests sent with ctx use id instead of a generated ID,
+// e.g. to propagate the ID of an incoming request.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID set by ContextWithRequestID.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// RequestOption configures a single request, overriding the client behaviors.
+type RequestOption func(r *requestConfig)
+
+type requestConfig struct {
+	timeout  time.Duration
+	deadline time.Time
+	header   http.Header
+	dryRun   *PreparedRequest
+}
+
+// WithRequestTimeout bounds the duration of a single request, e.g. to let a long generation run
+// while other calls stay short. The Timeout of the http.Client still applies, leave it unset
+// to rely on per-request timeouts.
+func WithRequestTimeout(d time.Duration) RequestOption {
+	return func(r *requestConfig) {
+		r.timeout = d
+	}
+}
+
+// WithRequestDeadline sets the deadline of a single request.
+func WithRequestDeadline(t time.Time) RequestOption {
+	return func(r *requestConfig) {
+		r.deadline = t
+	}
+}
+
+// WithHeader sets a header of a single request, e.g. for proxies prioritizing or routing jobs
+// by header. It wins over the headers set by the client, see ContextWithHeader for the calls
+// without request options.
+func WithHeader(key, value string) RequestOption {
+	return func(r *requestConfig) {
+		if r.header == nil {
+			r.header = http.Header{}
+		}
+		r.header.Set(key, value)
+	}
+}
+
+type headerKey struct{}
+
+// ContextWithHeader makes the requests sent with ctx carry a header.
+func ContextWithHeader(ctx context.Context, key, value string) context.Context {
+	header := headerFromContext(ctx).Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(key, value)
+	return context.WithValue(ctx, headerKey{}, header)
+}
+
+func headerFromContext(ctx context.Context) http.Header {
+	header, _ := ctx.Value(headerKey{}).(http.Header)
+	return header
+}
+
+// requestContext derives the context of a request from its options, cancel must be called.
+func requestContext(ctx context.Context, opts []RequestOption) (context.Context, context.CancelFunc) {
+	cfg := requestConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	for key, values := range cfg.header {
+		ctx = ContextWithHeader(ctx, key, values[0])
+	}
+
+	if cfg.dryRun != nil {
+		ctx = ContextWithDryRun(ctx, cfg.dryRun)
+	}
+
+	deadline := cfg.deadline
+	if cfg.timeout > 0 {
+		if d := time.Now().Add(cfg.timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	if deadline.IsZero() {
+		return ctx, func() {}
+	}
+
+	return context.WithDeadline(ctx, deadline)
+}
+
+// RequestContext applies request options to ctx, for other implementations of API to honor them
+// like Client does. cancel must be called.
+func RequestContext(ctx context.Context, opts ...RequestOption) (context.Context, context.CancelFunc) {
+	return requestContext(ctx, opts)
+}
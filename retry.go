@@ -0,0 +1,68 @@
+package sdcli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// DefaultRetryBackoff is the wait before the first retry of WithRetry, doubled at every retry.
+const DefaultRetryBackoff = 500 * time.Millisecond
+
+// WithRetry retries requests up to retries times when the server cannot be reached or a
+// proxy in front of it answers 502, 503 or 504, e.g. while WebUI restarts. Only GET requests
+// are retried after reaching the server, the others such as generations are not idempotent and
+// are only retried when the connection could not be made, see WithRecovery for the generations
+// whose connection drops. The wait starts at backoff (DefaultRetryBackoff if not positive) and
+// doubles at every retry.
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		if backoff <= 0 {
+			backoff = DefaultRetryBackoff
+		}
+		c.retries = retries
+		c.retryBackoff = backoff
+	}
+}
+
+// roundTripRetry is roundTrip with the retries of WithRetry.
+func (c *Client) roundTripRetry(ctx context.Context, requestID, path, method, contentType string, payload []byte) (*http.Response, []byte, error) {
+	wait := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, data, err := c.roundTrip(ctx, requestID, path, method, contentType, payload)
+		if attempt >= c.retries || !retryable(method, resp, err) || ctx.Err() != nil {
+			return resp, data, err
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return resp, data, err
+		case <-t.C:
+		}
+		wait *= 2
+	}
+}
+
+// retryable reports whether a request may be sent again, the requests other than GET only when
+// they did not reach the server as it may still be running them.
+func retryable(method string, resp *http.Response, err error) bool {
+	if method != http.MethodGet {
+		return err != nil && dialError(err)
+	}
+	if err != nil {
+		// Only failures to reach the server, not oversized or truncated responses.
+		var e *Error
+		if errors.As(err, &e) && e.Response != nil {
+			return false
+		}
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package sdcli
+
+// Script is implemented by typed arguments of selectable scripts (the "Script" dropdown in WebUI)
+// and of alwayson scripts added by extensions.
+type Script interface {
+	// ScriptName is the script title as accepted in script_name.
+	ScriptName() string
+	// ScriptArgs returns the positional script_args, img2img tells which tab the script runs on
+	// since some scripts take different arguments there.
+	ScriptArgs(img2img bool) ([]interface{}, error)
+}
+
+// SetScript sets ScriptName and ScriptArgs from typed script arguments.
+func (o *Txt2ImageOption) SetScript(s Script) error {
+	args, err := s.ScriptArgs(false)
+	if err != nil {
+		return err
+	}
+	o.ScriptName = s.ScriptName()
+	o.ScriptArgs = args
+
+	return nil
+}
+
+// SetScript sets ScriptName and ScriptArgs from typed script arguments.
+func (o *Img2ImgOption) SetScript(s Script) error {
+	args, err := s.ScriptArgs(true)
+	if err != nil {
+		return err
+	}
+	o.ScriptName = s.ScriptName()
+	o.ScriptArgs = args
+
+	return nil
+}
+
+// AlwaysonScripts maps alwayson script titles (extensions such as ControlNet) to their arguments.
+type AlwaysonScripts map[string]AlwaysonScriptArgs
+
+type AlwaysonScriptArgs struct {
+	Args []interface{} `json:"args"`
+}
+
+// SetAlwaysonScript adds typed alwayson script arguments, replacing any previous arguments of the script.
+func (o *Txt2ImageOption) SetAlwaysonScript(s Script) error {
+	args, err := s.ScriptArgs(false)
+	if err != nil {
+		return err
+	}
+	if o.AlwaysonScripts == nil {
+		o.AlwaysonScripts = AlwaysonScripts{}
+	}
+	o.AlwaysonScripts[s.ScriptName()] = AlwaysonScriptArgs{Args: args}
+
+	return nil
+}
+
+// SetAlwaysonScript adds typed alwayson script arguments, replacing any previous arguments of the script.
+func (o *Img2ImgOption) SetAlwaysonScript(s Script) error {
+	args, err := s.ScriptArgs(true)
+	if err != nil {
+		return err
+	}
+	if o.AlwaysonScripts == nil {
+		o.AlwaysonScripts = AlwaysonScripts{}
+	}
+	o.AlwaysonScripts[s.ScriptName()] = AlwaysonScriptArgs{Args: args}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package scripts
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// DenoisingCurve is how Loopback moves the denoising strength to its final value.
+type DenoisingCurve string
+
+const (
+	// CurveAggressive changes the strength most in the first loops.
+	CurveAggressive DenoisingCurve = "Aggressive"
+	CurveLinear     DenoisingCurve = "Linear"
+	// CurveLazy changes the strength most in the last loops.
+	CurveLazy DenoisingCurve = "Lazy"
+)
+
+// Interrogation is the interrogator whose prompt Loopback appends at each loop.
+type Interrogation string
+
+const (
+	InterrogateNone      Interrogation = "None"
+	InterrogateCLIP      Interrogation = "CLIP"
+	InterrogateDeepBooru Interrogation = "DeepBooru"
+)
+
+// Loopback is the typed arguments of the Loopback img2img script, which feeds the output of
+// each loop back as the init image of the next one.
+type Loopback struct {
+	Loops int
+	// FinalDenoisingStrength is the strength of the last loop, the first one uses the img2img
+	// denoising strength.
+	FinalDenoisingStrength float32
+	DenoisingCurve         DenoisingCurve
+	AppendInterrogation    Interrogation
+}
+
+// NewLoopback returns the arguments with the script's UI defaults.
+func NewLoopback() *Loopback {
+	return &Loopback{
+		Loops:                  4,
+		FinalDenoisingStrength: 0.5,
+		DenoisingCurve:         CurveLinear,
+		AppendInterrogation:    InterrogateNone,
+	}
+}
+
+var _ sdcli.Script = (*Loopback)(nil)
+
+func (l *Loopback) ScriptName() string {
+	return "loopback"
+}
+
+func (l *Loopback) ScriptArgs(img2img bool) ([]interface{}, error) {
+	if !img2img {
+		return nil, fmt.Errorf("loopback only runs on img2img")
+	}
+	if l.Loops < 1 {
+		return nil, fmt.Errorf("loopback loops must be positive")
+	}
+	curve := l.DenoisingCurve
+	if len(curve) == 0 {
+		curve = CurveLinear
+	}
+	interrogation := l.AppendInterrogation
+	if len(interrogation) == 0 {
+		interrogation = InterrogateNone
+	}
+
+	return []interface{}{l.Loops, l.FinalDenoisingStrength, string(curve), string(interrogation)}, nil
+}
+
+// LoopbackOption configures RunLoopback.
+type LoopbackOption struct {
+	// Img2Img carries the base options such as prompt, steps and the first denoising strength,
+	// the init image and script fields are set by the helper.
+	Img2Img sdcli.Img2ImgOption
+	// Script defaults to NewLoopback().
+	Script *Loopback
+}
+
+// RunLoopback runs the Loopback script on img and returns the images of every loop besides the
+// response: loops[i] holds the outputs of loop i, the batches of each iteration in order, so the
+// last one is the final result. The grids the server may return are left out.
+func RunLoopback(ctx context.Context, cli sdcli.API, img image.Image, opt LoopbackOption) (*sdcli.Img2ImgResponse, [][]image.Image, error) {
+	script := opt.Script
+	if script == nil {
+		script = NewLoopback()
+	}
+
+	req := opt.Img2Img
+	req.InitImages = []string{sdcli.Img2Base64(img)}
+	if err := req.SetScript(script); err != nil {
+		return nil, nil, err
+	}
+	res, err := cli.Img2Img(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Each iteration returns the images of its loops in order, after the grids if any.
+	batch, iter := max(req.BatchSize, 1), max(req.NIter, 1)
+	images := res.Images
+	if len(images) < script.Loops*batch*iter {
+		return res, nil, fmt.Errorf("loopback returned %d images, expected %d", len(images), script.Loops*batch*iter)
+	}
+	images = images[len(images)-script.Loops*batch*iter:]
+
+	loops := make([][]image.Image, script.Loops)
+	for n := 0; n < iter; n++ {
+		for i := range loops {
+			for b := 0; b < batch; b++ {
+				img, _, err := sdcli.Base642Img(images[(n*script.Loops+i)*batch+b])
+				if err != nil {
+					return res, nil, fmt.Errorf("failed to decode loopback image: %w", err)
+				}
+				loops[i] = append(loops[i], img)
+			}
+		}
+	}
+
+	return res, loops, nil
+}
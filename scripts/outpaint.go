@@ -0,0 +1,203 @@
+package scripts
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Direction is a set of sides to outpaint.
+type Direction int
+
+const (
+	DirectionLeft Direction = 1 << iota
+	DirectionRight
+	DirectionUp
+	DirectionDown
+
+	// DirectionAll expands every side, the scripts' UI default.
+	DirectionAll = DirectionLeft | DirectionRight | DirectionUp | DirectionDown
+)
+
+// names returns the sides of d as the outpainting scripts name them.
+func (d Direction) names() ([]string, error) {
+	if d <= 0 || d&^DirectionAll != 0 {
+		return nil, fmt.Errorf("invalid outpainting direction %d", d)
+	}
+	names := []string{}
+	for i, name := range []string{"left", "right", "up", "down"} {
+		if d&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// checkPixels validates the pixels to expand, the scripts take multiples of 8 up to 256.
+func checkPixels(pixels int) error {
+	if pixels < 8 || pixels > 256 || pixels%8 != 0 {
+		return fmt.Errorf("outpainting pixels must be a multiple of 8 between 8 and 256, got %d", pixels)
+	}
+	return nil
+}
+
+// PoorMansOutpainting is the typed arguments of the "Poor man's outpainting" img2img script,
+// which inpaints the expanded borders tile by tile, the tiles being the img2img size.
+type PoorMansOutpainting struct {
+	Pixels   int
+	MaskBlur int
+	// Fill is the content of the expanded borders before denoising.
+	Fill      sdcli.InpaintingFill
+	Direction Direction
+}
+
+// NewPoorMansOutpainting returns the arguments with the script's UI defaults.
+func NewPoorMansOutpainting() *PoorMansOutpainting {
+	return &PoorMansOutpainting{
+		Pixels:    128,
+		MaskBlur:  4,
+		Fill:      sdcli.InpaintFill,
+		Direction: DirectionAll,
+	}
+}
+
+var _ sdcli.Script = (*PoorMansOutpainting)(nil)
+
+func (o *PoorMansOutpainting) ScriptName() string {
+	return "poor man's outpainting"
+}
+
+func (o *PoorMansOutpainting) ScriptArgs(img2img bool) ([]interface{}, error) {
+	if !img2img {
+		return nil, fmt.Errorf("poor man's outpainting only runs on img2img")
+	}
+	if err := checkPixels(o.Pixels); err != nil {
+		return nil, err
+	}
+	if o.Fill < sdcli.InpaintFill || o.Fill > sdcli.InpaintLatentNothing {
+		return nil, fmt.Errorf("invalid inpainting fill %d", o.Fill)
+	}
+	direction, err := o.Direction.names()
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{o.Pixels, o.MaskBlur, int(o.Fill), direction}, nil
+}
+
+func (o *PoorMansOutpainting) outpaint(direction Direction, pixels int) OutpaintScript {
+	s := *o
+	s.Direction, s.Pixels = direction, pixels
+	return &s
+}
+
+// OutpaintingMk2 is the typed arguments of the "Outpainting mk2" img2img script, which
+// expands one side at a time from noise matching the colors of the image. The script
+// recommends 80 to 100 steps of Euler a with a denoising strength of 0.8.
+type OutpaintingMk2 struct {
+	Pixels    int
+	MaskBlur  int
+	Direction Direction
+	// NoiseQ is the fall-off exponent of the noise, lower gives more detail.
+	NoiseQ         float32
+	ColorVariation float32
+}
+
+// NewOutpaintingMk2 returns the arguments with the script's UI defaults.
+func NewOutpaintingMk2() *OutpaintingMk2 {
+	return &OutpaintingMk2{
+		Pixels:         128,
+		MaskBlur:       8,
+		Direction:      DirectionAll,
+		NoiseQ:         1,
+		ColorVariation: 0.05,
+	}
+}
+
+var _ sdcli.Script = (*OutpaintingMk2)(nil)
+
+func (o *OutpaintingMk2) ScriptName() string {
+	return "outpainting mk2"
+}
+
+func (o *OutpaintingMk2) ScriptArgs(img2img bool) ([]interface{}, error) {
+	if !img2img {
+		return nil, fmt.Errorf("outpainting mk2 only runs on img2img")
+	}
+	if err := checkPixels(o.Pixels); err != nil {
+		return nil, err
+	}
+	direction, err := o.Direction.names()
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{
+		nil, // Info HTML.
+		o.Pixels,
+		o.MaskBlur,
+		direction,
+		o.NoiseQ,
+		o.ColorVariation,
+	}, nil
+}
+
+func (o *OutpaintingMk2) outpaint(direction Direction, pixels int) OutpaintScript {
+	s := *o
+	s.Direction, s.Pixels = direction, pixels
+	return &s
+}
+
+// OutpaintScript is an outpainting script, PoorMansOutpainting or OutpaintingMk2.
+type OutpaintScript interface {
+	sdcli.Script
+	// outpaint returns a copy of the script expanding pixels on the sides of direction.
+	outpaint(direction Direction, pixels int) OutpaintScript
+}
+
+// DefaultOutpaintDenoisingStrength is used by Outpaint when Img2Img.DenoisingStrength is unset.
+const DefaultOutpaintDenoisingStrength = 0.8
+
+// OutpaintOption configures Outpaint.
+type OutpaintOption struct {
+	// Img2Img carries the base options such as prompt, steps and sampler, the init image, mask
+	// and script fields are set by the helper.
+	Img2Img sdcli.Img2ImgOption
+	// Script defaults to NewOutpaintingMk2(), its direction and pixels are replaced.
+	Script OutpaintScript
+}
+
+// Outpaint expands img by pixels on the sides of direction with an outpainting script over
+// img2img. The scripts enlarge the canvas and mask the new borders on the server, so the
+// request carries no mask; the size of the output is the expanded size rounded up to a
+// multiple of 64. Poor man's outpainting inpaints tiles of the img2img size, 512 if unset,
+// and Outpainting mk2 sizes its passes itself.
+func Outpaint(ctx context.Context, cli sdcli.API, img image.Image, direction Direction, pixels int, opt OutpaintOption) (*sdcli.Img2ImgResponse, error) {
+	script := opt.Script
+	if script == nil {
+		script = NewOutpaintingMk2()
+	}
+	script = script.outpaint(direction, pixels)
+
+	req := opt.Img2Img
+	req.InitImages = []string{sdcli.Img2Base64(img)}
+	req.Mask = ""
+	req.InpaintingMaskInvert = sdcli.InpaintMasked
+	req.InpaintFullRes = false
+	if req.Width == 0 || req.Height == 0 {
+		req.Width, req.Height = 512, 512
+		if _, ok := script.(*OutpaintingMk2); ok {
+			req.Width, req.Height = img.Bounds().Dx(), img.Bounds().Dy()
+		}
+	}
+	if req.DenoisingStrength == 0 {
+		req.DenoisingStrength = DefaultOutpaintDenoisingStrength
+	}
+	if err := req.SetScript(script); err != nil {
+		return nil, err
+	}
+
+	return cli.Img2Img(ctx, req)
+}
@@ -0,0 +1,145 @@
+package scripts
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// PromptPosition is where PromptsFromFile inserts the lines in the prompt of the request.
+type PromptPosition string
+
+const (
+	PromptAtStart PromptPosition = "start"
+	PromptAtEnd   PromptPosition = "end"
+)
+
+// PromptsFromFile is the typed arguments of the "Prompts from file or textbox" script, which
+// runs a job per line. A line is a prompt, or options such as
+// `--prompt "a cat" --negative_prompt "dog" --steps 30` overriding the request.
+type PromptsFromFile struct {
+	Lines []string
+	// IterateSeed increments the seed at every line.
+	IterateSeed bool
+	// SameSeed uses the same random seed for all the lines.
+	SameSeed bool
+	Position PromptPosition
+}
+
+var _ sdcli.Script = (*PromptsFromFile)(nil)
+
+func (p *PromptsFromFile) ScriptName() string {
+	return "prompts from file or textbox"
+}
+
+func (p *PromptsFromFile) ScriptArgs(img2img bool) ([]interface{}, error) {
+	if len(p.Lines) == 0 {
+		return nil, fmt.Errorf("prompts from file needs at least one line")
+	}
+	for i, line := range p.Lines {
+		if len(strings.TrimSpace(line)) == 0 || strings.ContainsAny(line, "\r\n") {
+			return nil, fmt.Errorf("prompts from file line %d must be a single non-empty line", i+1)
+		}
+	}
+	position := p.Position
+	if len(position) == 0 {
+		position = PromptAtStart
+	}
+
+	return []interface{}{p.IterateSeed, p.SameSeed, string(position), strings.Join(p.Lines, "\n")}, nil
+}
+
+// ReadPromptLines reads the prompt lines of r, skipping the blank ones as the script does.
+func ReadPromptLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); len(line) != 0 {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prompts: %w", err)
+	}
+	return lines, nil
+}
+
+// PromptResult is the output of a line of PromptsFromFile.
+type PromptResult struct {
+	Line   string
+	Images []image.Image
+	// Infotexts are the parameters of the images, empty if the server did not return them.
+	Infotexts []string
+}
+
+// PromptsOption configures RunPrompts.
+type PromptsOption struct {
+	// Txt2Img carries the base options shared by the lines, the script fields are set by the
+	// helper.
+	Txt2Img sdcli.Txt2ImageOption
+	// Script holds the seed and position arguments, its Lines are replaced.
+	Script PromptsFromFile
+}
+
+// RunPrompts runs the Prompts from file script on txt2img with lines, and splits the images of
+// the response by line. Every line gets the batch size and count of the request, so lines must
+// not override them with --batch_size or --n_iter.
+func RunPrompts(ctx context.Context, cli sdcli.API, lines []string, opt PromptsOption) ([]*PromptResult, error) {
+	for _, line := range lines {
+		if strings.Contains(line, "--batch_size") || strings.Contains(line, "--n_iter") {
+			return nil, fmt.Errorf("prompt line %q overrides the batch size or count", line)
+		}
+	}
+	script := opt.Script
+	script.Lines = lines
+
+	req := opt.Txt2Img
+	if err := req.SetScript(&script); err != nil {
+		return nil, err
+	}
+	res, err := cli.Txt2Img(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	perLine := max(req.BatchSize, 1) * max(req.NIter, 1)
+	if len(res.Images) != perLine*len(lines) {
+		return nil, fmt.Errorf("prompts from file returned %d images, expected %d", len(res.Images), perLine*len(lines))
+	}
+	var infotexts []string
+	if info, err := sdcli.ParseInfo(res.Info); err == nil && len(info.Infotexts) == len(res.Images) {
+		infotexts = info.Infotexts
+	}
+
+	results := make([]*PromptResult, len(lines))
+	for i, line := range lines {
+		r := &PromptResult{Line: line}
+		for j := i * perLine; j < (i+1)*perLine; j++ {
+			img, _, err := sdcli.Base642Img(res.Images[j])
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode image of line %d: %w", i+1, err)
+			}
+			r.Images = append(r.Images, img)
+		}
+		if infotexts != nil {
+			r.Infotexts = infotexts[i*perLine : (i+1)*perLine]
+		}
+		results[i] = r
+	}
+	return results, nil
+}
+
+// RunPromptsFrom runs the prompt lines read from r, see RunPrompts.
+func RunPromptsFrom(ctx context.Context, cli sdcli.API, r io.Reader, opt PromptsOption) ([]*PromptResult, error) {
+	lines, err := ReadPromptLines(r)
+	if err != nil {
+		return nil, err
+	}
+	return RunPrompts(ctx, cli, lines, opt)
+}
@@ -0,0 +1,52 @@
+package scripts
+
+import "fmt"
+
+// SDUpscale is the typed arguments of the "SD upscale" img2img script bundled with WebUI, which
+// upscales the image then redraws it in overlapping tiles of the img2img size.
+type SDUpscale struct {
+	// Overlap is the pixels shared by neighbouring tiles.
+	Overlap int
+	// UpscalerIndex is the index of the upscaler in the /upscalers list, see UpscalerIndex.
+	UpscalerIndex int
+	ScaleFactor   float32
+}
+
+// NewSDUpscale returns the arguments with the script's UI defaults, scaling the image by 2.
+func NewSDUpscale() *SDUpscale {
+	return &SDUpscale{
+		Overlap:     64,
+		ScaleFactor: 2,
+	}
+}
+
+var _ UpscaleScript = (*SDUpscale)(nil)
+
+func (u *SDUpscale) ScriptName() string {
+	return "sd upscale"
+}
+
+func (u *SDUpscale) ScriptArgs(img2img bool) ([]interface{}, error) {
+	if !img2img {
+		return nil, fmt.Errorf("sd upscale only runs on img2img")
+	}
+	if u.Overlap < 0 {
+		return nil, fmt.Errorf("sd upscale tile overlap must not be negative")
+	}
+	if u.ScaleFactor < 1 {
+		return nil, fmt.Errorf("sd upscale scale factor must be at least 1")
+	}
+
+	return []interface{}{
+		nil, // Info HTML.
+		u.Overlap,
+		u.UpscalerIndex,
+		u.ScaleFactor,
+	}, nil
+}
+
+func (u *SDUpscale) withUpscaler(index int) UpscaleScript {
+	s := *u
+	s.UpscalerIndex = index
+	return &s
+}
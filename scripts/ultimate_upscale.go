@@ -0,0 +1,193 @@
+package scripts
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strings"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// RedrawMode is the tile redraw order of Ultimate SD upscale.
+type RedrawMode int
+
+const (
+	RedrawLinear RedrawMode = iota
+	RedrawChess
+	RedrawNone
+)
+
+// SeamsFixType is the seams fix pass of Ultimate SD upscale.
+type SeamsFixType int
+
+const (
+	SeamsFixNone SeamsFixType = iota
+	SeamsFixBandPass
+	SeamsFixHalfTile
+	SeamsFixHalfTileIntersections
+)
+
+// TargetSizeType is how Ultimate SD upscale determines the output size.
+type TargetSizeType int
+
+const (
+	// TargetSizeFromImg2Img uses the width and height of the img2img options.
+	TargetSizeFromImg2Img TargetSizeType = iota
+	// TargetSizeCustom uses CustomWidth and CustomHeight.
+	TargetSizeCustom
+	// TargetSizeScale multiplies the init image size by CustomScale.
+	TargetSizeScale
+)
+
+// UltimateSDUpscale is the typed arguments of the Ultimate SD upscale extension script.
+type UltimateSDUpscale struct {
+	// TileWidth and TileHeight are the tile size, a zero TileHeight uses TileWidth.
+	TileWidth, TileHeight int
+	MaskBlur              int
+	Padding               int
+	RedrawMode            RedrawMode
+	// UpscalerIndex is the index of the upscaler in the /upscalers list, see UpscalerIndex.
+	UpscalerIndex     int
+	SaveUpscaledImage bool
+
+	SeamsFixType      SeamsFixType
+	SeamsFixWidth     int
+	SeamsFixDenoise   float32
+	SeamsFixPadding   int
+	SeamsFixMaskBlur  int
+	SaveSeamsFixImage bool
+
+	TargetSizeType            TargetSizeType
+	CustomWidth, CustomHeight int
+	CustomScale               float32
+}
+
+// NewUltimateSDUpscale returns the arguments with the extension's UI defaults, scaling the image by 2.
+func NewUltimateSDUpscale() *UltimateSDUpscale {
+	return &UltimateSDUpscale{
+		TileWidth:        512,
+		MaskBlur:         8,
+		Padding:          32,
+		RedrawMode:       RedrawLinear,
+		SeamsFixWidth:    64,
+		SeamsFixDenoise:  0.35,
+		SeamsFixPadding:  16,
+		SeamsFixMaskBlur: 4,
+		TargetSizeType:   TargetSizeScale,
+		CustomWidth:      2048,
+		CustomHeight:     2048,
+		CustomScale:      2,
+	}
+}
+
+var _ UpscaleScript = (*UltimateSDUpscale)(nil)
+
+func (u *UltimateSDUpscale) ScriptName() string {
+	return "ultimate sd upscale"
+}
+
+func (u *UltimateSDUpscale) ScriptArgs(img2img bool) ([]interface{}, error) {
+	if !img2img {
+		return nil, fmt.Errorf("ultimate sd upscale only runs on img2img")
+	}
+	if u.TileWidth <= 0 {
+		return nil, fmt.Errorf("ultimate sd upscale tile width must be positive")
+	}
+
+	return []interface{}{
+		nil, // Info HTML.
+		u.TileWidth,
+		u.TileHeight,
+		u.MaskBlur,
+		u.Padding,
+		u.SeamsFixWidth,
+		u.SeamsFixDenoise,
+		u.SeamsFixPadding,
+		u.UpscalerIndex,
+		u.SaveUpscaledImage,
+		int(u.RedrawMode),
+		u.SaveSeamsFixImage,
+		u.SeamsFixMaskBlur,
+		int(u.SeamsFixType),
+		int(u.TargetSizeType),
+		u.CustomWidth,
+		u.CustomHeight,
+		u.CustomScale,
+	}, nil
+}
+
+func (u *UltimateSDUpscale) withUpscaler(index int) UpscaleScript {
+	s := *u
+	s.UpscalerIndex = index
+	return &s
+}
+
+// UpscalerIndex returns the index of the named upscaler in a /upscalers list, as upscale scripts expect.
+func UpscalerIndex(upscalers []*sdcli.UpscalersResponse, name string) (int, error) {
+	for i, u := range upscalers {
+		if strings.EqualFold(u.Name, name) {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("upscaler %q not found", name)
+}
+
+// DefaultUpscaleDenoisingStrength is used by the upscale helpers when Img2Img.DenoisingStrength is unset,
+// the server default of 0.75 repaints far too much for upscaling.
+const DefaultUpscaleDenoisingStrength = 0.2
+
+// UpscaleScript is an upscale script, UltimateSDUpscale or SDUpscale.
+type UpscaleScript interface {
+	sdcli.Script
+	// withUpscaler returns a copy of the script using the upscaler at index.
+	withUpscaler(index int) UpscaleScript
+}
+
+// UpscaleOption configures UltimateUpscale.
+type UpscaleOption struct {
+	// Img2Img carries the base options such as prompt, steps and denoising strength,
+	// the init image and script fields are set by the helper.
+	Img2Img sdcli.Img2ImgOption
+	// Script defaults to NewUltimateSDUpscale(), NewSDUpscale() selects the SD upscale script.
+	Script UpscaleScript
+	// Upscaler picks the upscaler by name, resolving the UpscalerIndex of Script from the server list.
+	Upscaler string
+}
+
+// UltimateUpscale upscales img with the Ultimate SD upscale script over img2img, or the SD
+// upscale script when opt.Script is a *SDUpscale. Ultimate SD upscale gets the image size as
+// img2img size when unset, while SD upscale takes it as the tile size, 512 if unset.
+func UltimateUpscale(ctx context.Context, cli sdcli.API, img image.Image, opt UpscaleOption) (*sdcli.Img2ImgResponse, error) {
+	script := opt.Script
+	if script == nil {
+		script = NewUltimateSDUpscale()
+	}
+
+	if len(opt.Upscaler) != 0 {
+		upscalers, err := cli.GetUpscalers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		index, err := UpscalerIndex(upscalers, opt.Upscaler)
+		if err != nil {
+			return nil, err
+		}
+		script = script.withUpscaler(index)
+	}
+
+	req := opt.Img2Img
+	req.InitImages = []string{sdcli.Img2Base64(img)}
+	if _, ok := script.(*UltimateSDUpscale); ok && req.Width == 0 && req.Height == 0 {
+		req.Width, req.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	}
+	if req.DenoisingStrength == 0 {
+		req.DenoisingStrength = DefaultUpscaleDenoisingStrength
+	}
+	if err := req.SetScript(script); err != nil {
+		return nil, err
+	}
+
+	return cli.Img2Img(ctx, req)
+}
@@ -0,0 +1,229 @@
+// Package scripts provides typed arguments for the scripts bundled with WebUI.
+package scripts
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// AxisType is the label of an X/Y/Z plot axis option as shown in WebUI.
+type AxisType string
+
+const (
+	AxisNothing                AxisType = "Nothing"
+	AxisSeed                   AxisType = "Seed"
+	AxisVarSeed                AxisType = "Var. seed"
+	AxisVarStrength            AxisType = "Var. strength"
+	AxisSteps                  AxisType = "Steps"
+	AxisHiresSteps             AxisType = "Hires steps"
+	AxisCFGScale               AxisType = "CFG Scale"
+	AxisImageCFGScale          AxisType = "Image CFG Scale"
+	AxisPromptSR               AxisType = "Prompt S/R"
+	AxisPromptOrder            AxisType = "Prompt order"
+	AxisSampler                AxisType = "Sampler"
+	AxisHiresSampler           AxisType = "Hires sampler"
+	AxisCheckpointName         AxisType = "Checkpoint name"
+	AxisNegativeGuidanceSigma  AxisType = "Negative Guidance minimum sigma"
+	AxisSigmaChurn             AxisType = "Sigma Churn"
+	AxisSigmaMin               AxisType = "Sigma min"
+	AxisSigmaMax               AxisType = "Sigma max"
+	AxisSigmaNoise             AxisType = "Sigma noise"
+	AxisScheduleType           AxisType = "Schedule type"
+	AxisScheduleMinSigma       AxisType = "Schedule min sigma"
+	AxisScheduleMaxSigma       AxisType = "Schedule max sigma"
+	AxisScheduleRho            AxisType = "Schedule rho"
+	AxisBetaScheduleAlpha      AxisType = "Beta schedule alpha"
+	AxisBetaScheduleBeta       AxisType = "Beta schedule beta"
+	AxisEta                    AxisType = "Eta"
+	AxisClipSkip               AxisType = "Clip skip"
+	AxisDenoising              AxisType = "Denoising"
+	AxisInitialNoiseMultiplier AxisType = "Initial noise multiplier"
+	AxisExtraNoise             AxisType = "Extra noise"
+	AxisHiresUpscaler          AxisType = "Hires upscaler"
+	AxisCondImageMaskWeight    AxisType = "Cond. Image Mask Weight"
+	AxisVAE                    AxisType = "VAE"
+	AxisStyles                 AxisType = "Styles"
+	AxisUniPCOrder             AxisType = "UniPC Order"
+	AxisFaceRestore            AxisType = "Face restore"
+	AxisTokenMergingRatio      AxisType = "Token merging ratio"
+	AxisTokenMergingRatioHR    AxisType = "Token merging ratio high-res"
+	AxisDiscardPenultSigma     AxisType = "Always discard next-to-last sigma"
+	AxisSGMNoiseMultiplier     AxisType = "SGM noise multiplier"
+	AxisRefinerCheckpoint      AxisType = "Refiner checkpoint"
+	AxisRefinerSwitchAt        AxisType = "Refiner switch at"
+	AxisRNGSource              AxisType = "RNG source"
+	AxisFP8Mode                AxisType = "FP8 mode"
+	AxisSize                   AxisType = "Size"
+)
+
+const (
+	axisBoth = iota
+	axisTxt2Img
+	axisImg2Img
+)
+
+// axisOptions mirrors axis_options of scripts/xyz_grid.py in WebUI 1.10, the script takes
+// the axis type as an index into the options available on the current tab.
+var axisOptions = []struct {
+	typ AxisType
+	tab int
+}{
+	{AxisNothing, axisBoth},
+	{AxisSeed, axisBoth},
+	{AxisVarSeed, axisBoth},
+	{AxisVarStrength, axisBoth},
+	{AxisSteps, axisBoth},
+	{AxisHiresSteps, axisTxt2Img},
+	{AxisCFGScale, axisBoth},
+	{AxisImageCFGScale, axisImg2Img},
+	{AxisPromptSR, axisBoth},
+	{AxisPromptOrder, axisBoth},
+	{AxisSampler, axisTxt2Img},
+	{AxisHiresSampler, axisTxt2Img},
+	{AxisSampler, axisImg2Img},
+	{AxisCheckpointName, axisBoth},
+	{AxisNegativeGuidanceSigma, axisBoth},
+	{AxisSigmaChurn, axisBoth},
+	{AxisSigmaMin, axisBoth},
+	{AxisSigmaMax, axisBoth},
+	{AxisSigmaNoise, axisBoth},
+	{AxisScheduleType, axisBoth},
+	{AxisScheduleMinSigma, axisBoth},
+	{AxisScheduleMaxSigma, axisBoth},
+	{AxisScheduleRho, axisBoth},
+	{AxisBetaScheduleAlpha, axisBoth},
+	{AxisBetaScheduleBeta, axisBoth},
+	{AxisEta, axisBoth},
+	{AxisClipSkip, axisBoth},
+	{AxisDenoising, axisBoth},
+	{AxisInitialNoiseMultiplier, axisBoth},
+	{AxisExtraNoise, axisBoth},
+	{AxisHiresUpscaler, axisTxt2Img},
+	{AxisCondImageMaskWeight, axisImg2Img},
+	{AxisVAE, axisBoth},
+	{AxisStyles, axisBoth},
+	{AxisUniPCOrder, axisBoth},
+	{AxisFaceRestore, axisBoth},
+	{AxisTokenMergingRatio, axisBoth},
+	{AxisTokenMergingRatioHR, axisBoth},
+	{AxisDiscardPenultSigma, axisBoth},
+	{AxisSGMNoiseMultiplier, axisBoth},
+	{AxisRefinerCheckpoint, axisBoth},
+	{AxisRefinerSwitchAt, axisBoth},
+	{AxisRNGSource, axisBoth},
+	{AxisFP8Mode, axisBoth},
+	{AxisSize, axisBoth},
+}
+
+// AxisIndex returns the index WebUI expects for an axis type on the txt2img or img2img tab.
+func AxisIndex(t AxisType, img2img bool) (int, error) {
+	idx := 0
+	for _, opt := range axisOptions {
+		if (opt.tab == axisTxt2Img && img2img) || (opt.tab == axisImg2Img && !img2img) {
+			continue
+		}
+		if opt.typ == t {
+			return idx, nil
+		}
+		idx++
+	}
+
+	return 0, fmt.Errorf("unknown X/Y/Z plot axis type %q", t)
+}
+
+// Axis is one axis of an X/Y/Z plot, the zero value is the unused "Nothing" axis.
+type Axis struct {
+	Type AxisType
+	// Index is the raw axis option index, used instead of Type when positive, e.g. for axes added by extensions.
+	Index int
+	// Values are the axis values, ranges such as "1-5" or "10-30 (+5)" are expanded by WebUI.
+	Values []string
+}
+
+// NewAxis creates an axis from any values, formatted with fmt.Sprint.
+func NewAxis(t AxisType, values ...interface{}) Axis {
+	a := Axis{Type: t}
+	for _, v := range values {
+		a.Values = append(a.Values, fmt.Sprint(v))
+	}
+	return a
+}
+
+func (a Axis) args(img2img bool) ([]interface{}, error) {
+	idx := a.Index
+	if idx <= 0 {
+		t := a.Type
+		if len(t) == 0 {
+			t = AxisNothing
+		}
+		var err error
+		if idx, err = AxisIndex(t, img2img); err != nil {
+			return nil, err
+		}
+	}
+
+	// Values are sent both as CSV text and as the dropdown list, WebUI reads the dropdown for
+	// axes with choices (samplers, checkpoints...) and the text for everything else.
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if err := w.Write(a.Values); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	dropdown := a.Values
+	if dropdown == nil {
+		dropdown = []string{}
+	}
+
+	return []interface{}{idx, strings.TrimSuffix(buf.String(), "\n"), dropdown}, nil
+}
+
+// XYZPlot is the typed arguments of the built-in "X/Y/Z plot" script.
+type XYZPlot struct {
+	X, Y, Z Axis
+
+	DrawLegend        bool
+	IncludeLoneImages bool
+	IncludeSubGrids   bool
+	// KeepRandomSeeds keeps -1 seeds random for each cell instead of fixing them ("Keep -1 for seeds").
+	KeepRandomSeeds bool
+	// VarySeedsX, VarySeedsY and VarySeedsZ use a different seed for each value of the axis.
+	VarySeedsX, VarySeedsY, VarySeedsZ bool
+	// MarginSize is the grid margin in pixels.
+	MarginSize int
+	// CSVMode uses the CSV text values even for axes with choices.
+	CSVMode bool
+}
+
+var _ sdcli.Script = (*XYZPlot)(nil)
+
+func (p *XYZPlot) ScriptName() string {
+	return "x/y/z plot"
+}
+
+func (p *XYZPlot) ScriptArgs(img2img bool) ([]interface{}, error) {
+	var args []interface{}
+	for _, axis := range []Axis{p.X, p.Y, p.Z} {
+		a, err := axis.args(img2img)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a...)
+	}
+
+	return append(args,
+		p.DrawLegend,
+		p.IncludeLoneImages,
+		p.IncludeSubGrids,
+		p.KeepRandomSeeds,
+		p.VarySeedsX,
+		p.VarySeedsY,
+		p.VarySeedsZ,
+		p.MarginSize,
+		p.CSVMode,
+	), nil
+}
@@ -0,0 +1,172 @@
+package sdclitest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Status is the outcome of probing an endpoint.
+type Status string
+
+const (
+	// StatusSupported is a successful call.
+	StatusSupported Status = "supported"
+	// StatusUnsupported is a 404 answer, the server predates or lacks the endpoint.
+	StatusUnsupported Status = "unsupported"
+	// StatusFailed is any other error.
+	StatusFailed Status = "failed"
+	// StatusSkipped is an endpoint not probed, such as a generation when the Harness skips them.
+	StatusSkipped Status = "skipped"
+)
+
+// EndpointResult is the result of probing an endpoint.
+type EndpointResult struct {
+	Name     string
+	Status   Status
+	Err      error
+	Duration time.Duration
+}
+
+// Report is the results of a Harness run, in probing order.
+type Report []*EndpointResult
+
+// Failed returns the results with StatusFailed.
+func (r Report) Failed() Report {
+	var failed Report
+	for _, res := range r {
+		if res.Status == StatusFailed {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Supports reports whether the endpoint of name was probed successfully.
+func (r Report) Supports(name string) bool {
+	for _, res := range r {
+		if res.Name == name {
+			return res.Status == StatusSupported
+		}
+	}
+	return false
+}
+
+// String formats the report as a table.
+func (r Report) String() string {
+	sb := &strings.Builder{}
+	w := tabwriter.NewWriter(sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tSTATUS\tDURATION\tERROR")
+	for _, res := range r {
+		msg := ""
+		if res.Err != nil {
+			msg = res.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", res.Name, res.Status, res.Duration.Round(time.Millisecond), msg)
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// Harness exercises every endpoint of an API against a live server, reporting which ones the
+// server supports. It doubles as a compatibility checker for WebUI versions and forks.
+type Harness struct {
+	API sdcli.API
+	// SkipGeneration skips txt2img, img2img and extras, which take GPU time. The generations
+	// use a tiny size and a single step otherwise.
+	SkipGeneration bool
+}
+
+// NewHarness returns a Harness for api.
+func NewHarness(api sdcli.API) *Harness {
+	return &Harness{API: api}
+}
+
+// Run probes the endpoints one by one, errors are reported per endpoint.
+func (h *Harness) Run(ctx context.Context) Report {
+	var report Report
+	probe := func(name string, fn func() error) {
+		start := time.Now()
+		err := fn()
+		res := &EndpointResult{Name: name, Status: StatusSupported, Err: err, Duration: time.Since(start)}
+		switch {
+		case err == nil:
+		case isNotFound(err):
+			res.Status = StatusUnsupported
+		default:
+			res.Status = StatusFailed
+		}
+		report = append(report, res)
+	}
+	skip := func(name string) {
+		report = append(report, &EndpointResult{Name: name, Status: StatusSkipped})
+	}
+	discard := func(_ any, err error) error {
+		return err
+	}
+
+	probe("GET /progress", func() error { return discard(h.API.GetProgress(ctx, true)) })
+	probe("GET /options", func() error { return discard(h.API.GetOptions(ctx)) })
+	probe("POST /options", func() error { return h.API.SetOptions(ctx, map[string]any{}) })
+	probe("GET /sd-models", func() error { return discard(h.API.GetModels(ctx)) })
+	probe("POST /refresh-checkpoints", func() error { return h.API.RefreshCheckpoints(ctx) })
+	probe("GET /samplers", func() error { return discard(h.API.GetSamplers(ctx)) })
+	probe("GET /schedulers", func() error { return discard(h.API.GetSchedulers(ctx)) })
+	probe("GET /upscalers", func() error { return discard(h.API.GetUpscalers(ctx)) })
+	probe("GET /sd-vae", func() error { return discard(h.API.GetVAEs(ctx)) })
+	probe("POST /refresh-vae", func() error { return h.API.RefreshVAEs(ctx) })
+	probe("GET /sd-modules", func() error { return discard(h.API.GetSdModules(ctx)) })
+	probe("GET /scripts", func() error { return discard(h.API.GetScripts(ctx)) })
+	probe("GET /loras", func() error { return discard(h.API.GetLoras(ctx)) })
+	probe("GET /hypernetworks", func() error { return discard(h.API.GetHypernetworks(ctx)) })
+	probe("GET /embeddings", func() error { return discard(h.API.GetEmbeddings(ctx)) })
+	probe("GET /memory", func() error { return discard(h.API.GetMemory(ctx)) })
+	probe("POST /interrupt", func() error { return h.API.Interrupt(ctx) })
+	probe("POST /skip", func() error { return h.API.Skip(ctx) })
+
+	if h.SkipGeneration {
+		skip("POST /txt2img")
+		skip("POST /img2img")
+		skip("POST /extra-single-image")
+		return report
+	}
+
+	image := ""
+	probe("POST /txt2img", func() error {
+		res, err := h.API.Txt2Img(ctx, sdcli.Txt2ImageOption{Prompt: "test", Width: 64, Height: 64, Steps: 1, Seed: 1})
+		if err != nil {
+			return err
+		}
+		if len(res.Images) == 0 {
+			return errors.New("no image returned")
+		}
+		image = res.Images[0]
+		return nil
+	})
+	if len(image) == 0 {
+		image = cannedImages(64, 64, 1)[0]
+	}
+	probe("POST /img2img", func() error {
+		return discard(h.API.Img2Img(ctx, sdcli.Img2ImgOption{
+			InitImages: []string{image}, Prompt: "test", Width: 64, Height: 64, Steps: 1, Seed: 1,
+		}))
+	})
+	probe("POST /extra-single-image", func() error {
+		return discard(h.API.ExtraSingleImg(ctx, sdcli.ExtraSingleImgOption{
+			Image: image, UpscalingResize: 1, Upscaler1: "None",
+		}))
+	})
+
+	return report
+}
+
+func isNotFound(err error) bool {
+	var e *sdcli.Error
+	return errors.As(err, &e) && e.Response != nil && e.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,118 @@
+package sdclitest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+type job struct {
+	started     time.Time
+	steps       int
+	count       int
+	interrupted chan struct{}
+}
+
+// run simulates a generation taking the configured delay, it returns early when the job is interrupted
+// or the client goes away.
+func (s *Server) run(r *http.Request, steps, count int) {
+	if s.delay <= 0 {
+		return
+	}
+	if steps <= 0 {
+		steps = 20
+	}
+
+	j := &job{
+		started:     time.Now(),
+		steps:       steps,
+		count:       count,
+		interrupted: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.job = j
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		if s.job == j {
+			s.job = nil
+		}
+		s.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(s.delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-j.interrupted:
+	case <-r.Context().Done():
+	}
+}
+
+func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
+	res := &sdcli.ProgressResponse{}
+
+	s.mu.Lock()
+	j := s.job
+	s.mu.Unlock()
+
+	if j != nil {
+		elapsed := time.Since(j.started)
+		progress := float32(elapsed) / float32(s.delay)
+		if progress > 1 {
+			progress = 1
+		}
+		res.Progress = progress
+		res.ETARelative = float32((s.delay - elapsed).Seconds())
+		res.State.Job = "txt2img"
+		res.State.JobCount = j.count
+		res.State.JobTimestamp = j.started.Format("20060102150405")
+		res.State.SamplingSteps = j.steps
+		res.State.SamplingStep = int(progress * float32(j.steps))
+		select {
+		case <-j.interrupted:
+			res.State.Interrupted = true
+		default:
+		}
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleInterrupt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, &Error{Status: http.StatusMethodNotAllowed, Detail: "Method Not Allowed"})
+		return
+	}
+
+	s.mu.Lock()
+	if s.job != nil {
+		select {
+		case <-s.job.interrupted:
+		default:
+			close(s.job.interrupted)
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, nil)
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
@@ -0,0 +1,269 @@
+package sdclitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Mode is whether a Recorder records real interactions or replays recorded ones.
+type Mode int
+
+const (
+	// ModeReplay answers requests from the fixture file without any network access.
+	ModeReplay Mode = iota
+	// ModeRecord forwards requests to the real server and records them, Save writes the fixture file.
+	ModeRecord
+)
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Method      string      `json:"method"`
+	URI         string      `json:"uri"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body"`
+}
+
+// Recorder is a http.RoundTripper recording API interactions to a fixture file and replaying
+// them deterministically, so tests of code built on sdcli can run against a recording of a real
+// WebUI. Requests are matched by method, URI and JSON body, repeated requests such as /progress
+// polls replay their responses in the recorded order.
+type Recorder struct {
+	file     string
+	mode     Mode
+	next     http.RoundTripper
+	truncate bool
+	redact   []string
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	// replayed is the number of replayed interactions by key.
+	replayed map[string]int
+}
+
+// RecorderOption configures the Recorder.
+type RecorderOption func(r *Recorder)
+
+// WithTruncateImages replaces the base64 images of the recorded bodies with a 1x1 placeholder,
+// keeping fixture files small. Requests are matched after the same replacement, so the
+// replayed images are placeholders too.
+func WithTruncateImages() RecorderOption {
+	return func(r *Recorder) {
+		r.truncate = true
+	}
+}
+
+// WithRedactHeaders removes headers from the recordings on top of the credential headers
+// removed by default.
+func WithRedactHeaders(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.redact = append(r.redact, names...)
+	}
+}
+
+// redactedHeaders are never written to fixture files.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// NewRecorder returns a Recorder for the fixture file. In ModeRecord requests are sent with
+// next, http.DefaultTransport if nil. In ModeReplay the fixture file is loaded and must exist.
+func NewRecorder(file string, mode Mode, next http.RoundTripper, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		file:     file,
+		mode:     mode,
+		next:     next,
+		redact:   redactedHeaders,
+		replayed: map[string]int{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.next == nil {
+		r.next = http.DefaultTransport
+	}
+
+	if mode == ModeReplay {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+		if err := json.Unmarshal(data, &r.interactions); err != nil {
+			return nil, fmt.Errorf("failed to decode fixture %s: %w", file, err)
+		}
+	}
+
+	return r, nil
+}
+
+// Interactions returns the recorded or loaded interactions.
+func (r *Recorder) Interactions() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*Interaction(nil), r.interactions...)
+}
+
+// RoundTrip records or replays req.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := resp.Header.Clone()
+	for _, name := range r.redact {
+		header.Del(name)
+	}
+	// The recorded body is the decoded one.
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, &Interaction{
+		Method:      req.Method,
+		URI:         req.URL.RequestURI(),
+		RequestBody: r.sanitize(body),
+		Status:      resp.StatusCode,
+		Header:      header,
+		Body:        r.sanitize(respBody),
+	})
+
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := r.key(req.Method, req.URL.RequestURI(), r.sanitize(body))
+	n := r.replayed[key]
+	for _, in := range r.interactions {
+		if r.key(in.Method, in.URI, in.RequestBody) != key {
+			continue
+		}
+		if n > 0 {
+			n--
+			continue
+		}
+		r.replayed[key]++
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(in.Body)),
+			ContentLength: int64(len(in.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded interaction left for %s %s", req.Method, req.URL.RequestURI())
+}
+
+// Save writes the recorded interactions to the fixture file.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+
+	if err := os.WriteFile(r.file, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
+
+// key identifies a request for matching, JSON bodies are compared by value and other bodies,
+// such as multipart forms with random boundaries, are ignored.
+func (r *Recorder) key(method, uri, body string) string {
+	var v any
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return method + " " + uri
+	}
+	canonical, _ := json.Marshal(v)
+	return method + " " + uri + "\n" + string(canonical)
+}
+
+// sanitize truncates the images of a JSON body when enabled, other bodies are kept as is.
+func (r *Recorder) sanitize(body []byte) string {
+	if !r.truncate {
+		return string(body)
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	data, err := json.Marshal(truncateImages(v))
+	if err != nil {
+		return string(body)
+	}
+	return string(data)
+}
+
+// imagePrefixes are the base64 prefixes of PNG, JPEG and WebP images.
+var imagePrefixes = []string{"iVBORw0KGgo", "/9j/", "UklGR"}
+
+var placeholderImage = cannedImages(1, 1, 1)[0]
+
+func truncateImages(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = truncateImages(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = truncateImages(e)
+		}
+	case string:
+		prefix, raw := "", v
+		if strings.HasPrefix(v, "data:image/") {
+			if i := strings.Index(v, ","); i > 0 {
+				prefix, raw = v[:i+1], v[i+1:]
+			}
+		}
+		for _, p := range imagePrefixes {
+			if strings.HasPrefix(raw, p) {
+				return prefix + placeholderImage
+			}
+		}
+	}
+	return v
+}
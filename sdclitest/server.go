@@ -0,0 +1,389 @@
+// Package sdclitest provides a fake StableDiffusion WebUI server for testing code built on sdcli
+// without a GPU box.
+package sdclitest
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Server is an httptest based fake WebUI serving the /sdapi/v1 endpoints with canned or scripted responses.
+type Server struct {
+	*httptest.Server
+
+	delay       time.Duration
+	txt2imgFunc func(opt sdcli.Txt2ImageOption) (*sdcli.Txt2ImageResponse, error)
+	img2imgFunc func(opt sdcli.Img2ImgOption) (*sdcli.Img2ImgResponse, error)
+	models      []*sdcli.ModelsResponse
+	samplers    []*sdcli.SamplersResponse
+	upscalers   []*sdcli.UpscalersResponse
+	memory      func() *sdcli.MemoryResponse
+
+	mu       sync.Mutex
+	options  map[string]any
+	requests []Request
+	job      *job
+}
+
+// Request is a request received by the Server.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Error is returned from scripted handlers to control the status code and detail of the error response.
+type Error struct {
+	Status int
+	Detail string
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// Option configures the Server.
+type Option func(s *Server)
+
+// WithDelay makes every generation take d, during which /progress reports the job as running.
+func WithDelay(d time.Duration) Option {
+	return func(s *Server) {
+		s.delay = d
+	}
+}
+
+// WithTxt2Img replaces the canned txt2img response with fn.
+func WithTxt2Img(fn func(opt sdcli.Txt2ImageOption) (*sdcli.Txt2ImageResponse, error)) Option {
+	return func(s *Server) {
+		s.txt2imgFunc = fn
+	}
+}
+
+// WithImg2Img replaces the canned img2img response with fn.
+func WithImg2Img(fn func(opt sdcli.Img2ImgOption) (*sdcli.Img2ImgResponse, error)) Option {
+	return func(s *Server) {
+		s.img2imgFunc = fn
+	}
+}
+
+// WithOptions sets the initial options returned by GET /options.
+func WithOptions(opts map[string]any) Option {
+	return func(s *Server) {
+		for k, v := range opts {
+			s.options[k] = v
+		}
+	}
+}
+
+// WithModels sets the checkpoints returned by /sd-models.
+func WithModels(models ...*sdcli.ModelsResponse) Option {
+	return func(s *Server) {
+		s.models = models
+	}
+}
+
+// WithSamplers sets the samplers returned by /samplers.
+func WithSamplers(samplers ...*sdcli.SamplersResponse) Option {
+	return func(s *Server) {
+		s.samplers = samplers
+	}
+}
+
+// WithUpscalers sets the upscalers returned by /upscalers.
+func WithUpscalers(upscalers ...*sdcli.UpscalersResponse) Option {
+	return func(s *Server) {
+		s.upscalers = upscalers
+	}
+}
+
+// WithMemory sets the function answering /memory, called for every request so the reported
+// usage can change over time.
+func WithMemory(fn func() *sdcli.MemoryResponse) Option {
+	return func(s *Server) {
+		s.memory = fn
+	}
+}
+
+// NewServer starts a fake WebUI server, the caller should call Close when finished.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		models: []*sdcli.ModelsResponse{{
+			Title:     "fake-model.safetensors [0123456789]",
+			ModelName: "fake-model",
+			Hash:      "0123456789",
+			Filename:  "/models/Stable-diffusion/fake-model.safetensors",
+		}},
+		samplers: []*sdcli.SamplersResponse{
+			{Name: "Euler a", Aliases: []string{"k_euler_a", "k_euler_ancestral"}},
+			{Name: "Euler", Aliases: []string{"k_euler"}},
+			{Name: "DPM++ 2M Karras", Aliases: []string{"k_dpmpp_2m_ka"}},
+		},
+		upscalers: []*sdcli.UpscalersResponse{
+			{Name: "None"},
+			{Name: "Lanczos"},
+			{Name: "Nearest"},
+		},
+		options: map[string]any{
+			"sd_model_checkpoint": "fake-model.safetensors [0123456789]",
+		},
+		memory: func() *sdcli.MemoryResponse { return &sdcli.MemoryResponse{} },
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sdapi/v1/txt2img", s.handleTxt2Img)
+	mux.HandleFunc("/sdapi/v1/img2img", s.handleImg2Img)
+	mux.HandleFunc("/sdapi/v1/progress", s.handleProgress)
+	mux.HandleFunc("/sdapi/v1/interrupt", s.handleInterrupt)
+	mux.HandleFunc("/sdapi/v1/skip", s.handleAction)
+	mux.HandleFunc("/sdapi/v1/options", s.handleOptions)
+	mux.HandleFunc("/sdapi/v1/sd-models", s.handleList(func() any { return s.models }))
+	mux.HandleFunc("/sdapi/v1/samplers", s.handleList(func() any { return s.samplers }))
+	mux.HandleFunc("/sdapi/v1/schedulers", s.handleList(func() any {
+		return []*sdcli.SchedulersResponse{{Name: "automatic", Label: "Automatic"}, {Name: "karras", Label: "Karras"}}
+	}))
+	mux.HandleFunc("/sdapi/v1/upscalers", s.handleList(func() any { return s.upscalers }))
+	mux.HandleFunc("/sdapi/v1/memory", s.handleList(func() any { return s.memory() }))
+	mux.HandleFunc("/sdapi/v1/sd-modules", s.handleList(func() any { return []*sdcli.SdModulesResponse{} }))
+	mux.HandleFunc("/sdapi/v1/sd-vae", s.handleList(func() any { return []*sdcli.VAEsResponse{} }))
+	mux.HandleFunc("/sdapi/v1/scripts", s.handleList(func() any { return &sdcli.ScriptsResponse{Txt2Img: []string{}, Img2Img: []string{}} }))
+	mux.HandleFunc("/sdapi/v1/refresh-checkpoints", s.handleAction)
+	mux.HandleFunc("/sdapi/v1/refresh-vae", s.handleAction)
+	mux.HandleFunc("/sdapi/v1/loras", s.handleList(func() any { return []*sdcli.LorasResponse{} }))
+	mux.HandleFunc("/sdapi/v1/hypernetworks", s.handleList(func() any { return []*sdcli.HypernetworksResponse{} }))
+	mux.HandleFunc("/sdapi/v1/embeddings", s.handleList(func() any {
+		return &sdcli.EmbeddingsResponse{Loaded: map[string]*sdcli.Embedding{}, Skipped: map[string]*sdcli.Embedding{}}
+	}))
+
+	s.Server = httptest.NewServer(s.record(mux))
+
+	return s
+}
+
+// Client returns a sdcli.Client talking to the Server.
+func (s *Server) Client(opts ...sdcli.Option) *sdcli.Client {
+	cli, _ := sdcli.NewClient(s.URL, "", "", s.Server.Client(), opts...)
+	return cli
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// Options returns a copy of the current options.
+func (s *Server) Options() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make(map[string]any, len(s.options))
+	for k, v := range s.options {
+		res[k] = v
+	}
+
+	return res
+}
+
+func (s *Server) handleTxt2Img(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, &Error{Status: http.StatusMethodNotAllowed, Detail: "Method Not Allowed"})
+		return
+	}
+
+	var opt sdcli.Txt2ImageOption
+	if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+		writeError(w, &Error{Status: http.StatusUnprocessableEntity, Detail: err.Error()})
+		return
+	}
+
+	s.run(r, opt.Steps, imageCount(opt.BatchSize, opt.NIter))
+
+	if s.txt2imgFunc != nil {
+		res, err := s.txt2imgFunc(opt)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &sdcli.Txt2ImageResponse{
+		Images:     cannedImages(opt.Width, opt.Height, imageCount(opt.BatchSize, opt.NIter)),
+		Parameters: &opt,
+		Info:       cannedInfo(opt.Prompt, opt.NegativePrompt, opt.Seed, opt.Width, opt.Height),
+	})
+}
+
+func (s *Server) handleImg2Img(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, &Error{Status: http.StatusMethodNotAllowed, Detail: "Method Not Allowed"})
+		return
+	}
+
+	var opt sdcli.Img2ImgOption
+	if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+		writeError(w, &Error{Status: http.StatusUnprocessableEntity, Detail: err.Error()})
+		return
+	}
+	if len(opt.InitImages) == 0 {
+		writeError(w, &Error{Status: http.StatusNotFound, Detail: "Init image not found"})
+		return
+	}
+
+	s.run(r, opt.Steps, imageCount(opt.BatchSize, opt.NIter))
+
+	if s.img2imgFunc != nil {
+		res, err := s.img2imgFunc(opt)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &sdcli.Img2ImgResponse{
+		Images: cannedImages(opt.Width, opt.Height, imageCount(opt.BatchSize, opt.NIter)),
+		Info:   cannedInfo(opt.Prompt, opt.NegativePrompt, opt.Seed, opt.Width, opt.Height),
+	})
+}
+
+func (s *Server) handleOptions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Options())
+	case http.MethodPost:
+		opts := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			writeError(w, &Error{Status: http.StatusUnprocessableEntity, Detail: err.Error()})
+			return
+		}
+		s.mu.Lock()
+		for k, v := range opts {
+			s.options[k] = v
+		}
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, nil)
+	default:
+		writeError(w, &Error{Status: http.StatusMethodNotAllowed, Detail: "Method Not Allowed"})
+	}
+}
+
+// handleAction accepts the POST actions the fake has no state for, such as refreshes.
+func (s *Server) handleAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, &Error{Status: http.StatusMethodNotAllowed, Detail: "Method Not Allowed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, nil)
+}
+
+func (s *Server) handleList(list func() any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, &Error{Status: http.StatusMethodNotAllowed, Detail: "Method Not Allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, list())
+	}
+}
+
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := readBody(r)
+		if err != nil {
+			writeError(w, &Error{Status: http.StatusBadRequest, Detail: err.Error()})
+			return
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Header: r.Header.Clone(),
+			Body:   body,
+		})
+		s.mu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	writeJSON(w, e.Status, map[string]string{
+		"error":  http.StatusText(e.Status),
+		"detail": e.Detail,
+	})
+}
+
+func imageCount(batchSize, nIter int) int {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if nIter <= 0 {
+		nIter = 1
+	}
+
+	return batchSize * nIter
+}
+
+func cannedImages(width, height, count int) []string {
+	if width <= 0 {
+		width = 512
+	}
+	if height <= 0 {
+		height = 512
+	}
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	raw := sdcli.Img2RawBase64(img)
+
+	images := make([]string, count)
+	for i := range images {
+		images[i] = raw
+	}
+
+	return images
+}
+
+func cannedInfo(prompt, negative string, seed, width, height int) string {
+	if seed < 0 {
+		seed = 1234567890
+	}
+	info, _ := json.Marshal(map[string]any{
+		"prompt":          prompt,
+		"negative_prompt": negative,
+		"seed":            seed,
+		"all_seeds":       []int{seed},
+		"width":           width,
+		"height":          height,
+	})
+
+	return string(info)
+}
@@ -0,0 +1,122 @@
+// Package seed generates and derives generation seeds the way WebUI does, so results can be reproduced.
+package seed
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// MaxSeed is the largest seed, seeds are 63-bit non negative integers.
+const MaxSeed = 1<<63 - 1
+
+var (
+	rngMu sync.Mutex
+	rng   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// New returns a random non zero 63-bit seed. A zero seed would be omitted from the request
+// and replaced by a random one by the server.
+func New() int {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+
+	for {
+		if s := int(rng.Int63()); s != 0 {
+			return s
+		}
+	}
+}
+
+// Fixed returns s, or a new random seed when s is sdcli.SeedRandom (or any negative value),
+// so the seed is known before the request is sent.
+func Fixed(s int) int {
+	if s < 0 {
+		return New()
+	}
+	return s
+}
+
+// Batch derives the seeds and subseeds of the n images of a generation (BatchSize * NIter)
+// like WebUI: seeds increment per image unless variations are used (subseedStrength > 0),
+// in which case every image shares the seed and the subseeds increment instead.
+func Batch(seed, subseed int, subseedStrength float32, n int) (seeds, subseeds []int) {
+	seeds = make([]int, n)
+	subseeds = make([]int, n)
+	for i := 0; i < n; i++ {
+		seeds[i] = seed
+		if subseedStrength == 0 {
+			seeds[i] += i
+		}
+		subseeds[i] = subseed + i
+	}
+	return seeds, subseeds
+}
+
+// Variation is a subseed and its strength, blending the noise of Subseed into the noise of the seed.
+type Variation struct {
+	Subseed  int
+	Strength float32
+}
+
+// Variations returns variations of a seed for every strength using the same subseed, for example
+// to sweep from the original image (0) to the subseed image (1).
+func Variations(subseed int, strengths ...float32) []Variation {
+	res := make([]Variation, len(strengths))
+	for i, s := range strengths {
+		res[i] = Variation{Subseed: subseed, Strength: s}
+	}
+	return res
+}
+
+// Seeds is the seed settings shared by txt2img and img2img options.
+type Seeds struct {
+	Seed            int
+	Subseed         int
+	SubseedStrength float32
+}
+
+// Fix resolves random seeds and subseeds so they can be reused to reproduce the results.
+func (s Seeds) Fix() Seeds {
+	s.Seed = Fixed(s.Seed)
+	if s.SubseedStrength > 0 {
+		s.Subseed = Fixed(s.Subseed)
+	}
+	return s
+}
+
+// Apply sets the seed of a variation.
+func (s Seeds) Apply(v Variation) Seeds {
+	s.Subseed, s.SubseedStrength = v.Subseed, v.Strength
+	return s
+}
+
+// Txt2Img returns the seeds of txt2img options.
+func Txt2Img(opt *sdcli.Txt2ImageOption) Seeds {
+	return Seeds{Seed: orRandom(opt.Seed), Subseed: orRandom(opt.Subseed), SubseedStrength: opt.SubseedStrength}
+}
+
+// SetTxt2Img sets the seeds of txt2img options.
+func (s Seeds) SetTxt2Img(opt *sdcli.Txt2ImageOption) {
+	opt.Seed, opt.Subseed, opt.SubseedStrength = s.Seed, s.Subseed, s.SubseedStrength
+}
+
+// Img2Img returns the seeds of img2img options.
+func Img2Img(opt *sdcli.Img2ImgOption) Seeds {
+	return Seeds{Seed: orRandom(opt.Seed), Subseed: orRandom(opt.Subseed), SubseedStrength: opt.SubseedStrength}
+}
+
+// SetImg2Img sets the seeds of img2img options.
+func (s Seeds) SetImg2Img(opt *sdcli.Img2ImgOption) {
+	opt.Seed, opt.Subseed, opt.SubseedStrength = s.Seed, s.Subseed, s.SubseedStrength
+}
+
+// orRandom maps the omitted zero value of options to the random seed the server uses.
+func orRandom(s int) int {
+	if s == 0 {
+		return sdcli.SeedRandom
+	}
+	return s
+}
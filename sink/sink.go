@@ -0,0 +1,137 @@
+// Package sink defines where generated images are stored, so they can be streamed out one by one
+// instead of accumulating large batches in memory. Object stores such as S3 or GCS only need to
+// implement Sink.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+)
+
+// Metadata keys set by the generation helpers.
+const (
+	MetaContentType = "content-type"
+	MetaParameters  = "parameters"
+	MetaSeed        = "seed"
+)
+
+// Metadata describes a stored image, e.g. as object store headers.
+type Metadata map[string]string
+
+// Sink stores named images, name is a slash separated relative path.
+type Sink interface {
+	Put(ctx context.Context, name string, r io.Reader, meta Metadata) error
+}
+
+// Func adapts a function to the Sink interface.
+type Func func(ctx context.Context, name string, r io.Reader, meta Metadata) error
+
+func (f Func) Put(ctx context.Context, name string, r io.Reader, meta Metadata) error {
+	return f(ctx, name, r, meta)
+}
+
+// ContentType returns the MIME type of a sdcli format such as sdcli.FormatPNG.
+func ContentType(format string) string {
+	switch format {
+	case sdcli.FormatPNG, sdcli.FormatJPEG, sdcli.FormatWEBP, sdcli.FormatGIF:
+		return "image/" + format
+	case sdcli.FormatMP4, sdcli.FormatWEBM:
+		return "video/" + format
+	}
+	return "application/octet-stream"
+}
+
+// Dir stores images as files under a directory, metadata is ignored.
+type Dir struct {
+	Root string
+}
+
+// NewDir returns a sink writing into root, created on demand.
+func NewDir(root string) *Dir {
+	return &Dir{Root: root}
+}
+
+// Path returns the file path of a name, failing if it escapes the root.
+func (d *Dir) Path(name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid sink name %q", name)
+	}
+	return filepath.Join(d.Root, clean), nil
+}
+
+// Put writes to a temporary file renamed once complete, so readers never see partial images.
+func (d *Dir) Put(ctx context.Context, name string, r io.Reader, meta Metadata) error {
+	path, err := d.Path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create output: %w", err)
+	}
+	tmp := f.Name()
+
+	_, err = io.Copy(f, &ctxReader{ctx: ctx, r: r})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return nil
+}
+
+// Writer writes every image to a single io.Writer one after the other, e.g. to pipe them to
+// another program. Names and metadata are ignored.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter returns a sink writing into w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (s *Writer) Put(ctx context.Context, name string, r io.Reader, meta Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := io.Copy(s.w, &ctxReader{ctx: ctx, r: r}); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// ctxReader stops reading once the context is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,109 @@
+package sdcli
+
+import (
+	"fmt"
+	"math"
+)
+
+// SDXLSizeMultiple is the multiple SDXL models work best with, SizeMultiple is enough for SD 1.5.
+const SDXLSizeMultiple = 64
+
+// SizeAdjustment is a size field changed by SnapSize, Field is its JSON name.
+type SizeAdjustment struct {
+	Field    string
+	From, To int
+}
+
+func (a SizeAdjustment) String() string {
+	return fmt.Sprintf("%s: %d -> %d", a.Field, a.From, a.To)
+}
+
+// SnapSize rounds width and height down or up to multiples of multiple, SizeMultiple if not
+// positive, picking the pair closest to the aspect ratio and then to the size. Sizes are at least
+// multiple, zero sizes are left as is for the server defaults and negative ones for Validate to
+// reject.
+func SnapSize(width, height, multiple int) (int, int) {
+	if multiple <= 0 {
+		multiple = SizeMultiple
+	}
+	if width <= 0 || height <= 0 {
+		return snap(width, multiple), snap(height, multiple)
+	}
+
+	ratio := float64(width) / float64(height)
+	bestW, bestH := 0, 0
+	var bestRatio, bestSize float64
+	for _, w := range bounds(width, multiple) {
+		for _, h := range bounds(height, multiple) {
+			r := math.Abs(float64(w)/float64(h)-ratio) / ratio
+			d := math.Abs(float64(w-width)) + math.Abs(float64(h-height))
+			// Ratios within rounding errors are equal.
+			if bestW == 0 || r < bestRatio-1e-9 || r < bestRatio+1e-9 && d < bestSize {
+				bestW, bestH, bestRatio, bestSize = w, h, r, d
+			}
+		}
+	}
+	return bestW, bestH
+}
+
+// bounds returns the multiples of multiple around the positive n, at least multiple.
+func bounds(n, multiple int) []int {
+	down := max(n/multiple*multiple, multiple)
+	if up := (n + multiple - 1) / multiple * multiple; up != down {
+		return []int{down, up}
+	}
+	return []int{down}
+}
+
+func snap(n, multiple int) int {
+	if n <= 0 {
+		return n
+	}
+	n = (n + multiple/2) / multiple * multiple
+	if n < multiple {
+		return multiple
+	}
+	return n
+}
+
+// snapFields snaps a width and height pair, appending the changes to adjs.
+func snapFields(adjs []SizeAdjustment, multiple int, wField string, w *int, hField string, h *int) []SizeAdjustment {
+	sw, sh := SnapSize(*w, *h, multiple)
+	if sw != *w {
+		adjs = append(adjs, SizeAdjustment{Field: wField, From: *w, To: sw})
+		*w = sw
+	}
+	if sh != *h {
+		adjs = append(adjs, SizeAdjustment{Field: hField, From: *h, To: sh})
+		*h = sh
+	}
+	return adjs
+}
+
+// SnapSize rounds the size and the hires fix size to multiples of multiple, see SnapSize, and
+// returns the fields it changed.
+func (o *Txt2ImageOption) SnapSize(multiple int) []SizeAdjustment {
+	adjs := snapFields(nil, multiple, "width", &o.Width, "height", &o.Height)
+	if o.EnableHR {
+		adjs = snapFields(adjs, multiple, "hr_resize_x", &o.HrResizeX, "hr_resize_y", &o.HrResizeY)
+	}
+	return adjs
+}
+
+// ValidateSnapped is Validate after SnapSize, fixing the sizes instead of rejecting them.
+func (o *Txt2ImageOption) ValidateSnapped(multiple int) ([]SizeAdjustment, error) {
+	adjs := o.SnapSize(multiple)
+	return adjs, o.Validate()
+}
+
+// SnapSize rounds the size to multiples of multiple, see SnapSize, and returns the fields it
+// changed.
+func (o *Img2ImgOption) SnapSize(multiple int) []SizeAdjustment {
+	return snapFields(nil, multiple, "width", &o.Width, "height", &o.Height)
+}
+
+// ValidateSnapped is Validate after SnapSize, fixing the sizes instead of rejecting them.
+func (o *Img2ImgOption) ValidateSnapped(multiple int) ([]SizeAdjustment, error) {
+	adjs := o.SnapSize(multiple)
+	return adjs, o.Validate()
+}
@@ -0,0 +1,395 @@
+package sdcli
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ImageStreamFunc consumes image i of a streamed generation, r yields its bytes decoded from
+// base64 as they are read from the response. r is only valid during the call, the unread rest
+// of the image is skipped when it returns. An error stops the stream and is returned.
+type ImageStreamFunc func(i int, r io.Reader) error
+
+// Txt2ImgStream is Txt2Img delivering the images to fn one by one while the response is read,
+// so large batches are piped to disk or object storage without holding them in memory. The
+// returned response has the info and parameters of the generation but no images.
+//
+// The response is not buffered, so WithMaxResponseBytes, WithRetry, WithRecovery, WithOOMRecovery
+// and WithImageProcessors do not apply.
+func (c *Client) Txt2ImgStream(ctx context.Context, opt Txt2ImageOption, fn ImageStreamFunc, opts ...RequestOption) (*Txt2ImageResponse, error) {
+	ctx, cancel := requestContext(ctx, opts)
+	defer cancel()
+
+	if err := c.preflightTxt2Img(ctx, &opt); err != nil {
+		return nil, err
+	}
+
+	res := new(Txt2ImageResponse)
+	if err := c.stream(ctx, "/sdapi/v1/txt2img", &opt, fn, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Img2ImgStream is Img2Img delivering the images to fn, see Txt2ImgStream.
+func (c *Client) Img2ImgStream(ctx context.Context, opt Img2ImgOption, fn ImageStreamFunc, opts ...RequestOption) (*Img2ImgResponse, error) {
+	ctx, cancel := requestContext(ctx, opts)
+	defer cancel()
+
+	if err := c.preflightImg2Img(ctx, &opt); err != nil {
+		return nil, err
+	}
+	if err := c.downscaleInputs(&opt); err != nil {
+		return nil, err
+	}
+
+	res := new(Img2ImgResponse)
+	if err := c.stream(ctx, "/sdapi/v1/img2img", &opt, fn, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// stream posts body and hands the images of the response to fn, decoding the other fields
+// into result.
+func (c *Client) stream(ctx context.Context, path string, body any, fn ImageStreamFunc, result any) error {
+	var id string
+	if len(c.requestIDHeader) != 0 {
+		if id = RequestIDFromContext(ctx); len(id) == 0 {
+			id = newRequestID()
+		}
+	}
+
+	err := c.sendStream(ctx, id, path, body, fn, result)
+	var e *Error
+	if errors.As(err, &e) {
+		e.RequestID = id
+	}
+	if err != nil {
+		c.interruptCanceled(ctx)
+	}
+
+	return err
+}
+
+func (c *Client) sendStream(ctx context.Context, requestID, path string, body any, fn ImageStreamFunc, result any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return wrapError(err, nil, "failed to encode body")
+	}
+
+	if dry, err := c.dryRun(ctx, requestID, path, http.MethodPost, "application/json", payload); dry {
+		return err
+	}
+	if err := c.checkPayload(ctx, path, payload); err != nil {
+		return err
+	}
+
+	resp, err := c.streamRoundTrip(ctx, requestID, path, payload)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.credentialProvider != nil {
+		resp.Body.Close()
+		username, password, err := c.credentialProvider(ctx)
+		if err != nil {
+			return wrapError(err, resp, "failed to refresh credentials")
+		}
+		c.setCredentials(username, password)
+
+		if resp, err = c.streamRoundTrip(ctx, requestID, path, payload); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return wrapError(nil, resp, "got bad status %d, body: %s", resp.StatusCode, string(data))
+	}
+
+	fields, err := scanImages(bufio.NewReader(resp.Body), fn)
+	if err != nil {
+		return wrapError(err, resp, "failed to stream response")
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return wrapError(err, resp, "failed to parse response")
+	}
+	return decodeResult(data, resp, result)
+}
+
+// streamRoundTrip sends a request, leaving the response body to the caller.
+func (c *Client) streamRoundTrip(ctx context.Context, requestID, path string, payload []byte) (*http.Response, error) {
+	req, err := c.newRequest(ctx, requestID, path, http.MethodPost, "application/json", payload)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := c.cli.Do(req)
+	c.debug.dump(req, payload, resp, nil, time.Since(start), err)
+	if err != nil {
+		return nil, wrapError(err, nil, "failed to do request")
+	}
+	return resp, nil
+}
+
+// scanImages reads a JSON object, streaming the strings of its "images" array to fn and
+// returning the other fields.
+func scanImages(r *bufio.Reader, fn ImageStreamFunc) (map[string]json.RawMessage, error) {
+	s := &jsonScanner{r: r}
+	if err := s.expect('{'); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	for first := true; ; first = false {
+		b, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		if b == '}' && first {
+			return fields, nil
+		}
+		if b != '"' {
+			return nil, fmt.Errorf("unexpected %q, expected a key", b)
+		}
+		raw, err := s.readString()
+		if err != nil {
+			return nil, err
+		}
+		var key string
+		if err := json.Unmarshal(raw, &key); err != nil {
+			return nil, err
+		}
+		if err := s.expect(':'); err != nil {
+			return nil, err
+		}
+
+		if b, err = s.next(); err != nil {
+			return nil, err
+		}
+		if key == "images" && b == '[' {
+			if err := s.streamImages(fn); err != nil {
+				return nil, err
+			}
+		} else if fields[key], err = s.readValue(b); err != nil {
+			return nil, err
+		}
+
+		if b, err = s.next(); err != nil {
+			return nil, err
+		}
+		switch b {
+		case ',':
+		case '}':
+			return fields, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q after the value of %s", b, key)
+		}
+	}
+}
+
+// jsonScanner reads JSON tokens without buffering the string values.
+type jsonScanner struct {
+	r *bufio.Reader
+}
+
+// next returns the next byte that is not white space.
+func (s *jsonScanner) next() (byte, error) {
+	for {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return b, nil
+		}
+	}
+}
+
+func (s *jsonScanner) expect(want byte) error {
+	b, err := s.next()
+	if err != nil {
+		return err
+	}
+	if b != want {
+		return fmt.Errorf("unexpected %q, expected %q", b, want)
+	}
+	return nil
+}
+
+// readString returns the raw string whose opening quote was read, quotes included.
+func (s *jsonScanner) readString() ([]byte, error) {
+	raw := []byte{'"'}
+	for escaped := false; ; {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		raw = append(raw, b)
+		switch {
+		case escaped:
+			escaped = false
+		case b == '\\':
+			escaped = true
+		case b == '"':
+			return raw, nil
+		}
+	}
+}
+
+// readValue returns the raw value starting with first.
+func (s *jsonScanner) readValue(first byte) (json.RawMessage, error) {
+	switch first {
+	case '"':
+		return s.readString()
+	case '{', '[':
+		raw := []byte{first}
+		for depth := 1; depth > 0; {
+			b, err := s.r.ReadByte()
+			if err != nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+			switch b {
+			case '"':
+				str, err := s.readString()
+				if err != nil {
+					return nil, err
+				}
+				raw = append(raw, str...)
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+			raw = append(raw, b)
+		}
+		return raw, nil
+	default:
+		raw := []byte{first}
+		for {
+			b, err := s.r.ReadByte()
+			if err != nil {
+				return raw, nil
+			}
+			switch b {
+			case ',', '}', ']', ' ', '\t', '\r', '\n':
+				return raw, s.r.UnreadByte()
+			}
+			raw = append(raw, b)
+		}
+	}
+}
+
+// streamImages hands the strings of the array whose opening bracket was read to fn.
+func (s *jsonScanner) streamImages(fn ImageStreamFunc) error {
+	for i := 0; ; i++ {
+		b, err := s.next()
+		if err != nil {
+			return err
+		}
+		if b == ']' && i == 0 {
+			return nil
+		}
+		if b != '"' {
+			return fmt.Errorf("unexpected %q, expected image %d", b, i)
+		}
+
+		r := &stringReader{r: s.r}
+		if err := r.skipDataURI(); err != nil {
+			return err
+		}
+		if err := fn(i, base64.NewDecoder(base64.StdEncoding, r)); err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return err
+		}
+
+		if b, err = s.next(); err != nil {
+			return err
+		}
+		switch b {
+		case ',':
+		case ']':
+			return nil
+		default:
+			return fmt.Errorf("unexpected %q after image %d", b, i)
+		}
+	}
+}
+
+// stringReader reads a JSON string of base64 data up to its closing quote.
+type stringReader struct {
+	r    *bufio.Reader
+	done bool
+}
+
+// skipDataURI discards the data URI prefix of the string, if any.
+func (r *stringReader) skipDataURI() error {
+	if head, _ := r.r.Peek(5); string(head) != "data:" {
+		return nil
+	}
+	for {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		switch b {
+		case ',':
+			return nil
+		case '"':
+			r.done = true
+			return nil
+		}
+	}
+}
+
+func (r *stringReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && (n == 0 || r.r.Buffered() != 0) {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			return n, io.ErrUnexpectedEOF
+		}
+		switch b {
+		case '"':
+			r.done = true
+			if n == 0 {
+				return 0, io.EOF
+			}
+			return n, nil
+		case '\\':
+			if b, err = r.r.ReadByte(); err != nil {
+				return n, io.ErrUnexpectedEOF
+			}
+			switch b {
+			case '/':
+			case 'n', 'r', 't':
+				continue
+			default:
+				return n, fmt.Errorf("unexpected escape \\%c in image data", b)
+			}
+		}
+		p[n] = b
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,192 @@
+package sdcli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ranges checked by the Validate methods, zero values are omitted from requests and
+// replaced by the server defaults so they are not checked.
+const (
+	MinCfgScale = 1
+	MaxCfgScale = 30
+	// SizeMultiple is the multiple image sizes must be, the latent space is 8 times smaller.
+	SizeMultiple = 8
+	// MinUpscalingResize and MaxUpscalingResize bound the upscaling factor of the extras.
+	MinUpscalingResize = 1
+	MaxUpscalingResize = 8
+)
+
+// ValidationError is an invalid option field, Field is its JSON name.
+type ValidationError struct {
+	Field string
+	Msg   string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Msg
+}
+
+// ValidationErrors are all the invalid fields of an option.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid options: " + strings.Join(msgs, "; ")
+}
+
+// Fields returns the names of the invalid fields.
+func (e ValidationErrors) Fields() []string {
+	fields := make([]string, len(e))
+	for i, err := range e {
+		fields[i] = err.Field
+	}
+	return fields
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.errs = append(v.errs, &ValidationError{Field: field, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (v *validator) steps(field string, steps int) {
+	if steps < 0 {
+		v.add(field, "must be positive, got %d", steps)
+	}
+}
+
+func (v *validator) cfgScale(field string, cfg float32) {
+	if cfg != 0 && (cfg < MinCfgScale || cfg > MaxCfgScale) {
+		v.add(field, "must be in [%d, %d], got %v", MinCfgScale, MaxCfgScale, cfg)
+	}
+}
+
+func (v *validator) ratio(field string, r float32) {
+	if r < 0 || r > 1 {
+		v.add(field, "must be in [0, 1], got %v", r)
+	}
+}
+
+func (v *validator) size(field string, size int) {
+	if size < 0 || size%SizeMultiple != 0 {
+		v.add(field, "must be a positive multiple of %d, got %d", SizeMultiple, size)
+	}
+}
+
+func (v *validator) nonNegative(field string, n int) {
+	if n < 0 {
+		v.add(field, "must not be negative, got %d", n)
+	}
+}
+
+// Validate checks the ranges of the options before sending them, the returned error is
+// a ValidationErrors listing every invalid field.
+func (o *Txt2ImageOption) Validate() error {
+	v := &validator{}
+	v.steps("steps", o.Steps)
+	v.cfgScale("cfg_scale", o.CfgScale)
+	v.size("width", o.Width)
+	v.size("height", o.Height)
+	v.ratio("denoising_strength", o.DenoisingStrength)
+	v.ratio("subseed_strength", o.SubseedStrength)
+	v.nonNegative("batch_size", o.BatchSize)
+	v.nonNegative("n_iter", o.NIter)
+	if o.EnableHR {
+		v.steps("hr_second_pass_steps", o.HrSecondPassSteps)
+		if o.HRScale != 0 && o.HRScale < 1 {
+			v.add("hr_scale", "must be at least 1, got %v", o.HRScale)
+		}
+		v.size("hr_resize_x", o.HrResizeX)
+		v.size("hr_resize_y", o.HrResizeY)
+	}
+	if len(o.ScriptArgs) != 0 && len(o.ScriptName) == 0 {
+		v.add("script_args", "requires script_name")
+	}
+
+	return v.err()
+}
+
+// Validate checks the ranges of the options before sending them, the returned error is
+// a ValidationErrors listing every invalid field.
+func (o *Img2ImgOption) Validate() error {
+	v := &validator{}
+	if len(o.InitImages) == 0 {
+		v.add("init_images", "at least one init image is required")
+	}
+	if len(o.Mask) != 0 && len(o.InitImages) == 0 {
+		v.add("mask", "requires an init image")
+	}
+	if o.ResizeMode < ResizeJust || o.ResizeMode > ResizeLatent {
+		v.add("resize_mode", "must be in [0, 3], got %d", o.ResizeMode)
+	}
+	v.steps("steps", o.Steps)
+	v.cfgScale("cfg_scale", o.CfgScale)
+	v.size("width", o.Width)
+	v.size("height", o.Height)
+	v.ratio("denoising_strength", o.DenoisingStrength)
+	v.ratio("subseed_strength", o.SubseedStrength)
+	v.nonNegative("batch_size", o.BatchSize)
+	v.nonNegative("n_iter", o.NIter)
+	v.nonNegative("mask_blur", o.MaskBlur)
+	v.nonNegative("inpaint_full_res_padding", o.InpaintFullResPadding)
+	if o.InpaintingFill < InpaintFill || o.InpaintingFill > InpaintLatentNothing {
+		v.add("inpainting_fill", "must be in [0, 3], got %d", o.InpaintingFill)
+	}
+	if len(o.ScriptArgs) != 0 && len(o.ScriptName) == 0 {
+		v.add("script_args", "requires script_name")
+	}
+
+	return v.err()
+}
+
+// Validate checks the ranges of the options before sending them, the returned error is
+// a ValidationErrors listing every invalid field.
+func (o *ExtraSingleImgOption) Validate() error {
+	v := &validator{}
+	if len(o.Image) == 0 {
+		v.add("image", "is required")
+	}
+	switch o.ResizeMode {
+	case ExtrasResizeBy:
+		if o.UpscalingResize != 0 && (o.UpscalingResize < MinUpscalingResize || o.UpscalingResize > MaxUpscalingResize) {
+			v.add("upscaling_resize", "must be in [%d, %d], got %v", MinUpscalingResize, MaxUpscalingResize, o.UpscalingResize)
+		}
+	case ExtrasResizeTo:
+		if o.UpscalingResizeW <= 0 {
+			v.add("upscaling_resize_w", "must be positive with resize_mode 1")
+		}
+		if o.UpscalingResizeH <= 0 {
+			v.add("upscaling_resize_h", "must be positive with resize_mode 1")
+		}
+	default:
+		v.add("resize_mode", "must be 0 or 1, got %d", o.ResizeMode)
+	}
+	for _, vis := range []struct {
+		field string
+		value float64
+	}{
+		{"gfpgan_visibility", o.GfpganVisibility},
+		{"codeformer_visibility", o.CodeformerVisibility},
+		{"codeformer_weight", o.CodeformerWeight},
+		{"extras_upscaler_2_visibility", o.ExtrasUpscaler2Visibility},
+	} {
+		if vis.value < 0 || vis.value > 1 {
+			v.add(vis.field, "must be in [0, 1], got %v", vis.value)
+		}
+	}
+
+	return v.err()
+}
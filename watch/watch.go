@@ -0,0 +1,269 @@
+// Package watch processes the images dropped into a directory with img2img or extras upscaling.
+//
+// Every image may come with sidecar files sharing its base name: a .txt file holding the prompt
+// and a .json or .yaml file holding API parameters overriding the watcher defaults.
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	sdcli "github.com/shallowclouds/go-sd-webui-cli"
+	"github.com/shallowclouds/go-sd-webui-cli/sink"
+)
+
+const (
+	ModeImg2Img = "img2img"
+	ModeUpscale = "upscale"
+)
+
+// DefaultInterval is the polling interval of the watched directory.
+const DefaultInterval = 2 * time.Second
+
+var imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".webp": true}
+
+// Result is the outcome of processing an image.
+type Result struct {
+	Source string
+	Files  []string
+	Error  error
+}
+
+// Watcher polls a directory for new images. Images are processed once their size and
+// modification time are stable across two polls, so files still being copied are skipped.
+// An image is processed again when it is modified.
+type Watcher struct {
+	Client sdcli.API
+	Dir    string
+	// OutDir receives the outputs named after the source images, it must not be Dir as the
+	// outputs would replace the sources and be processed again.
+	OutDir string
+	// Mode is ModeImg2Img (the default) or ModeUpscale.
+	Mode string
+	// Defaults are the API parameters of every image, overridden by parameter sidecars.
+	Defaults map[string]any
+	// Interval defaults to DefaultInterval.
+	Interval time.Duration
+	// OnResult is called after every processed image.
+	OnResult func(*Result)
+
+	pending map[string]fileState
+	done    map[string]fileState
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Run polls the directory until ctx is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Scan(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan polls the directory once and processes the images that became stable.
+func (w *Watcher) Scan(ctx context.Context) error {
+	if sameDir(w.Dir, w.OutDir) {
+		return fmt.Errorf("output directory %s is the watched directory", w.OutDir)
+	}
+	if w.pending == nil {
+		w.pending = map[string]fileState{}
+		w.done = map[string]fileState{}
+	}
+
+	entries, err := os.ReadDir(w.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read watched directory: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, e := range entries {
+		if e.IsDir() || !imageExts[strings.ToLower(filepath.Ext(e.Name()))] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(w.Dir, e.Name())
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+
+		if done, ok := w.done[path]; ok && done == state {
+			continue
+		}
+		if prev, ok := w.pending[path]; !ok || prev != state {
+			w.pending[path] = state
+			continue
+		}
+		delete(w.pending, path)
+		w.done[path] = state
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		res := w.process(ctx, path)
+		if w.OnResult != nil {
+			w.OnResult(res)
+		}
+	}
+
+	return nil
+}
+
+func (w *Watcher) process(ctx context.Context, path string) *Result {
+	res := &Result{Source: path}
+
+	params, err := w.params(path)
+	if err != nil {
+		res.Error = err
+		return res
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		res.Error = fmt.Errorf("failed to read image: %w", err)
+		return res
+	}
+
+	var images [][]byte
+	var info string
+	if w.Mode == ModeUpscale {
+		images, err = w.upscale(ctx, params, data)
+	} else {
+		images, info, err = w.img2img(ctx, params, data)
+	}
+	if err != nil {
+		res.Error = err
+		return res
+	}
+
+	gen, _ := sdcli.ParseInfo(info)
+	out := sink.NewDir(w.OutDir)
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for i, img := range images {
+		if gen != nil && len(gen.Infotext(i)) != 0 {
+			if withParams, err := sdcli.SetParameters(img, gen.Infotext(i)); err == nil {
+				img = withParams
+			}
+		}
+
+		name := base
+		if len(images) > 1 {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		name += "." + sdcli.DetectFormat(img)
+		if err := out.Put(ctx, name, bytes.NewReader(img), nil); err != nil {
+			res.Error = err
+			return res
+		}
+		file, _ := out.Path(name)
+		res.Files = append(res.Files, file)
+	}
+
+	return res
+}
+
+// params merges the defaults with the sidecars of an image.
+func (w *Watcher) params(path string) (map[string]any, error) {
+	params := map[string]any{}
+	for k, v := range w.Defaults {
+		params[k] = v
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, ext := range []string{".json", ".yaml", ".yml"} {
+		data, err := os.ReadFile(base + ext)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sidecar: %w", err)
+		}
+		var sidecar map[string]any
+		if err := yaml.Unmarshal(data, &sidecar); err != nil {
+			return nil, fmt.Errorf("invalid sidecar %s: %w", base+ext, err)
+		}
+		for k, v := range sidecar {
+			params[k] = v
+		}
+		break
+	}
+
+	prompt, err := os.ReadFile(base + ".txt")
+	if err == nil {
+		params["prompt"] = strings.TrimSpace(string(prompt))
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read prompt sidecar: %w", err)
+	}
+
+	return params, nil
+}
+
+func (w *Watcher) img2img(ctx context.Context, params map[string]any, img []byte) ([][]byte, string, error) {
+	var opt sdcli.Img2ImgOption
+	if err := decodeParams(params, &opt); err != nil {
+		return nil, "", err
+	}
+	opt.InitImages = []string{sdcli.ImgBytes2Base64(img)}
+
+	res, err := w.Client.Img2Img(ctx, opt)
+	if err != nil {
+		return nil, "", err
+	}
+	return res.RawImages, res.Info, nil
+}
+
+func (w *Watcher) upscale(ctx context.Context, params map[string]any, img []byte) ([][]byte, error) {
+	var opt sdcli.ExtraSingleImgOption
+	if err := decodeParams(params, &opt); err != nil {
+		return nil, err
+	}
+	opt.Image = sdcli.ImgBytes2Base64(img)
+
+	res, err := w.Client.ExtraSingleImg(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{res.RawImage}, nil
+}
+
+func decodeParams(params map[string]any, opt any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode params: %w", err)
+	}
+	if err := json.Unmarshal(data, opt); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
+
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
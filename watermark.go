@@ -0,0 +1,109 @@
+package sdcli
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Position is where Watermark places the overlay.
+type Position int
+
+const (
+	BottomRight Position = iota
+	BottomLeft
+	TopRight
+	TopLeft
+	Center
+)
+
+// Watermark is the post-processing stage compositing a text or image overlay onto the images,
+// e.g. for attribution. The image is used when set, the text otherwise.
+type Watermark struct {
+	Text string
+	// Color is the text color, white if nil.
+	Color color.Color
+	// TextScale enlarges the 7x13 pixel font by an integer factor, 1 if not positive.
+	TextScale int
+
+	Image image.Image
+
+	Position Position
+	// Opacity is from 0 to 1, 0 means opaque.
+	Opacity float64
+	// Margin is the distance in pixels to the image edges.
+	Margin int
+}
+
+// Process implements ImageProcessor.
+func (w *Watermark) Process(ctx context.Context, out *Output) error {
+	overlay := w.Image
+	if overlay == nil {
+		if len(w.Text) == 0 {
+			return errors.New("watermark has neither text nor image")
+		}
+		overlay = w.textImage()
+	}
+
+	img, err := out.Image()
+	if err != nil {
+		return err
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	ob := overlay.Bounds()
+	db := dst.Bounds()
+	var at image.Point
+	switch w.Position {
+	case BottomRight:
+		at = image.Pt(db.Dx()-ob.Dx()-w.Margin, db.Dy()-ob.Dy()-w.Margin)
+	case BottomLeft:
+		at = image.Pt(w.Margin, db.Dy()-ob.Dy()-w.Margin)
+	case TopRight:
+		at = image.Pt(db.Dx()-ob.Dx()-w.Margin, w.Margin)
+	case TopLeft:
+		at = image.Pt(w.Margin, w.Margin)
+	case Center:
+		at = image.Pt((db.Dx()-ob.Dx())/2, (db.Dy()-ob.Dy())/2)
+	}
+
+	var mask image.Image
+	if w.Opacity > 0 && w.Opacity < 1 {
+		mask = image.NewUniform(color.Alpha{A: uint8(w.Opacity * 0xff)})
+	}
+	draw.DrawMask(dst, image.Rectangle{Min: at, Max: at.Add(ob.Size())}, overlay, ob.Min, mask, image.Point{}, draw.Over)
+	out.SetImage(dst)
+
+	return nil
+}
+
+// textImage renders the text on a transparent background.
+func (w *Watermark) textImage() image.Image {
+	face := basicfont.Face7x13
+	fg := w.Color
+	if fg == nil {
+		fg = color.White
+	}
+
+	width := font.MeasureString(face, w.Text).Ceil()
+	metrics := face.Metrics()
+	text := image.NewRGBA(image.Rect(0, 0, width, metrics.Height.Ceil()))
+	d := &font.Drawer{Dst: text, Src: image.NewUniform(fg), Face: face, Dot: fixed.P(0, metrics.Ascent.Ceil())}
+	d.DrawString(w.Text)
+
+	if w.TextScale <= 1 {
+		return text
+	}
+	b := text.Bounds()
+	scaled := image.NewRGBA(image.Rect(0, 0, b.Dx()*w.TextScale, b.Dy()*w.TextScale))
+	xdraw.NearestNeighbor.Scale(scaled, scaled.Bounds(), text, b, xdraw.Src, nil)
+	return scaled
+}